@@ -321,13 +321,7 @@ func (r *RPC) Call(pipeID string, fullMethod string, in any, resultPtr any, time
 			return zlog.NewError(err, "unmarshal RP.Result payload failed")
 		}
 	}
-	if rp.Error != "" {
-		return errors.New(rp.Error)
-	}
-	if rp.TransportError != "" {
-		return fmt.Errorf("RPC call transport error: %s", rp.TransportError)
-	}
-	return nil
+	return rp.ErrorFromPayload()
 }
 
 func SetupSimpleClient(port int, address, clientID string) {
@@ -1,6 +1,7 @@
 package zbuild
 
 import (
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +23,71 @@ type Info struct {
 	Branch     string
 	Host       string
 	Version    string
+	Dirty      bool     // built with uncommitted changes
+	GoVersion  string   // toolchain version, like go1.26.0
+	MainModule string   // module path of the program
+	Modules    []Module `json:",omitempty"`
+}
+
+// Module is a dependency compiled into the program.
+type Module struct {
+	Path    string
+	Version string
+	Sum     string `json:",omitempty"`
+	Replace string `json:",omitempty"` // path and version of replacement, if any
+}
+
+func init() {
+	SetFromBuildInfo()
+}
+
+// SetFromBuildInfo fills Build from what the Go toolchain embeds in the binary:
+// VCS revision, time and modified flag, Go version, main module version and dependencies.
+// It is called on init, so SetFromLine, called after with explicit build args, overrides it.
+func SetFromBuildInfo() {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	Build.GoVersion = bi.GoVersion
+	Build.MainModule = bi.Main.Path
+	if Build.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		Build.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if Build.CommitHash == "" {
+				Build.CommitHash = s.Value
+			}
+		case "vcs.time":
+			if Build.At.IsZero() {
+				t, err := time.Parse(time.RFC3339, s.Value)
+				if err == nil {
+					Build.At = t
+				}
+			}
+		case "vcs.modified":
+			Build.Dirty = (s.Value == "true")
+		}
+	}
+	Build.Modules = nil
+	for _, d := range bi.Deps {
+		m := Module{Path: d.Path, Version: d.Version, Sum: d.Sum}
+		if d.Replace != nil {
+			m.Replace = zstr.Concat("@", d.Replace.Path, d.Replace.Version)
+		}
+		Build.Modules = append(Build.Modules, m)
+	}
+}
+
+// ShortHash is the first 8 characters of the commit hash, with a * after if built with uncommitted changes.
+func (info Info) ShortHash() string {
+	str := zstr.Head(info.CommitHash, 8)
+	if info.Dirty && str != "" {
+		str += "*"
+	}
+	return str
 }
 
 func SetFromLine(line, sep, eq string) {
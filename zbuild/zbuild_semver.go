@@ -0,0 +1,142 @@
+package zbuild
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// Version is a semantic version: MAJOR.MINOR.PATCH with optional -prerelease and +metadata, like v1.2.3-beta.2+abc.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string // dot-separated identifiers after -, like beta.2
+	Metadata   string // after +, ignored when comparing
+}
+
+// ParseVersion parses str as a semantic version. A leading v is optional, and so are minor and patch, so v2 is 2.0.0.
+func ParseVersion(str string) (Version, error) {
+	var v Version
+	s := strings.TrimPrefix(strings.TrimSpace(str), "v")
+	s, v.Metadata, _ = strings.Cut(s, "+")
+	var hasPre bool
+	s, v.PreRelease, hasPre = strings.Cut(s, "-")
+	if hasPre && v.PreRelease == "" {
+		return v, zlog.NewError("empty pre-release:", str)
+	}
+	parts := strings.Split(s, ".")
+	if s == "" || len(parts) > 3 {
+		return v, zlog.NewError("bad version:", str)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, zlog.NewError("bad version number:", str, p)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// MustParseVersion is ParseVersion, logging and returning a zero Version on error.
+func MustParseVersion(str string) Version {
+	v, err := ParseVersion(str)
+	zlog.OnError(err)
+	return v
+}
+
+// String returns v as vMAJOR.MINOR.PATCH[-prerelease][+metadata].
+func (v Version) String() string {
+	str := "v" + strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor) + "." + strconv.Itoa(v.Patch)
+	if v.PreRelease != "" {
+		str += "-" + v.PreRelease
+	}
+	if v.Metadata != "" {
+		str += "+" + v.Metadata
+	}
+	return str
+}
+
+// IsPreRelease is true if v has a pre-release tag, like -rc.1.
+func (v Version) IsPreRelease() bool {
+	return v.PreRelease != ""
+}
+
+// Compare returns -1, 0 or 1 if v is lower, equal or higher precedence than o, following semver 2.0:
+// A pre-release is lower than its release, pre-release identifiers are compared numerically if both are numbers,
+// numbers are lower than text, and more identifiers is higher if all before are equal. Metadata is ignored.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	if v.PreRelease == o.PreRelease {
+		return 0
+	}
+	if v.PreRelease == "" {
+		return 1
+	}
+	if o.PreRelease == "" {
+		return -1
+	}
+	a := strings.Split(v.PreRelease, ".")
+	b := strings.Split(o.PreRelease, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := comparePreReleaseIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(a) - len(b))
+}
+
+func comparePreReleaseIdentifier(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return sign(na - nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	if n < 0 {
+		return -1
+	}
+	if n > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Less is true if v has lower precedence than o.
+func (v Version) Less(o Version) bool {
+	return v.Compare(o) < 0
+}
+
+// CompareVersions parses a and b and compares them with Version.Compare.
+func CompareVersions(a, b string) (int, error) {
+	va, err := ParseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := ParseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// SemVersion returns info's Version parsed, or a zero Version if it isn't a valid one.
+func (info Info) SemVersion() Version {
+	v, _ := ParseVersion(info.Version)
+	return v
+}
@@ -0,0 +1,31 @@
+//go:build server
+
+package zbuild
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/torlangballe/zutil/zrest"
+)
+
+// AddHandler serves Build as JSON at path. Add ?deps=false to leave out the dependency modules.
+func AddHandler(router *mux.Router, path string) *mux.Route {
+	return zrest.AddHandler(router, path, handleBuildInfo).Methods("GET")
+}
+
+func handleBuildInfo(w http.ResponseWriter, req *http.Request) {
+	info := Build
+	if req.URL.Query().Get("deps") == "false" {
+		info.Modules = nil
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		zrest.ReturnAndPrintError(w, req, http.StatusInternalServerError, "marshal build info", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	zrest.AddCORSHeaders(w, req)
+	w.Write(data)
+}
@@ -0,0 +1,67 @@
+package zbuild
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func TestVersion(t *testing.T) {
+	v, err := ParseVersion("v1.2.3-beta.2+abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, v, Version{Major: 1, Minor: 2, Patch: 3, PreRelease: "beta.2", Metadata: "abc"}, "parsed")
+	ztesting.Equal(t, v.String(), "v1.2.3-beta.2+abc", "string")
+	ztesting.Equal(t, MustParseVersion("2").String(), "v2.0.0", "short")
+	for _, bad := range []string{"", "v", "1.2.3.4", "1.x", "1.2-", "-1.0"} {
+		_, err := ParseVersion(bad)
+		ztesting.Equal(t, err != nil, true, "bad:", bad)
+	}
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.2.0", "v10.0.0"}
+	for i := 1; i < len(ordered); i++ {
+		c, err := CompareVersions(ordered[i-1], ordered[i])
+		ztesting.Equal(t, err, nil)
+		ztesting.Equal(t, c, -1, ordered[i-1], "<", ordered[i])
+	}
+	c, _ := CompareVersions("1.0.0+a", "v1.0.0+b")
+	ztesting.Equal(t, c, 0, "metadata ignored")
+}
+
+func TestCheckForUpdate(t *testing.T) {
+	manifest := `{"version":"v1.3.0-rc.1","minimumVersion":"v1.1.0","downloadURL":"https://x/app"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, manifest)
+	}))
+	defer server.Close()
+
+	old := Build.Version
+	defer func() { Build.Version = old }()
+	Build.Version = "v1.0.5"
+
+	check, err := CheckForUpdate(server.URL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, check.Available, false, "pre-release not offered")
+	ztesting.Equal(t, check.Required, true, "below minimum")
+
+	check, _ = CheckForUpdate(server.URL, true)
+	ztesting.Equal(t, check.Available, true, "pre-release allowed")
+	surl, _ := check.Manifest.PlatformDownloadURL()
+	ztesting.Equal(t, surl, "https://x/app", "download")
+
+	manifest = `{"version":"v1.0.5"}`
+	check, _ = CheckForUpdate(server.URL, true)
+	ztesting.Equal(t, check.Available, false, "same version")
+	ztesting.Equal(t, check.Required, false, "no minimum")
+}
+
+func TestBuildInfo(t *testing.T) {
+	ztesting.Equal(t, Build.GoVersion != "", true, "go version from build info")
+	SetFromLine("VERSION:v2.1,HASH:0123456789abcdef", ",", ":")
+	ztesting.Equal(t, Build.ShortHash(), "01234567"+map[bool]string{true: "*"}[Build.Dirty], "short hash")
+}
@@ -0,0 +1,71 @@
+package zbuild
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/torlangballe/zutil/zhttp"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// UpdateManifest is a JSON document at a known URL describing the latest release of a program.
+type UpdateManifest struct {
+	Version        string            `json:"version"`
+	MinimumVersion string            `json:"minimumVersion,omitempty"` // versions below this must update
+	ReleasedAt     time.Time         `json:"releasedAt,omitempty"`
+	Notes          string            `json:"notes,omitempty"`
+	DownloadURL    string            `json:"downloadURL,omitempty"`
+	Downloads      map[string]string `json:"downloads,omitempty"` // download URLs by GOOS-GOARCH, like linux-amd64
+	SHA256         map[string]string `json:"sha256,omitempty"`    // checksums by GOOS-GOARCH
+}
+
+// UpdateCheck is the result of CheckForUpdate.
+type UpdateCheck struct {
+	Manifest  UpdateManifest
+	Current   Version
+	Latest    Version
+	Available bool // Latest is newer than Current
+	Required  bool // Current is below MinimumVersion
+}
+
+// CheckForUpdate gets the manifest at manifestURL and compares it to Build.Version.
+// Pre-release versions in the manifest are only offered if allowPreRelease is true or the current version is one.
+func CheckForUpdate(manifestURL string, allowPreRelease bool) (UpdateCheck, error) {
+	var check UpdateCheck
+	current, err := ParseVersion(Build.Version)
+	if err != nil {
+		return check, zlog.Error("current version", err)
+	}
+	check.Current = current
+	params := zhttp.MakeParameters()
+	params.TimeoutSecs = 20
+	_, err = zhttp.Get(manifestURL, params, &check.Manifest)
+	if err != nil {
+		return check, zlog.Error("get manifest", manifestURL, err)
+	}
+	check.Latest, err = ParseVersion(check.Manifest.Version)
+	if err != nil {
+		return check, zlog.Error("manifest version", err)
+	}
+	if !check.Latest.IsPreRelease() || allowPreRelease || current.IsPreRelease() {
+		check.Available = current.Less(check.Latest)
+	}
+	if check.Manifest.MinimumVersion != "" {
+		minimum, err := ParseVersion(check.Manifest.MinimumVersion)
+		if err != nil {
+			return check, zlog.Error("manifest minimum version", err)
+		}
+		check.Required = current.Less(minimum)
+	}
+	return check, nil
+}
+
+// PlatformDownloadURL returns the download for the running OS and architecture, or the general DownloadURL.
+func (m UpdateManifest) PlatformDownloadURL() (surl, sha256 string) {
+	platform := runtime.GOOS + "-" + runtime.GOARCH
+	surl = m.Downloads[platform]
+	if surl == "" {
+		surl = m.DownloadURL
+	}
+	return surl, m.SHA256[platform]
+}
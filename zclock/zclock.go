@@ -0,0 +1,88 @@
+// Package zclock is where ztimer's timers and repeaters, and ztime's functions relative to now, get the time and schedule from.
+// It has no dependencies, so ztesting can fake the clock without importing the packages that use it.
+package zclock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the real clock, except in tests that set a fake one with Set, like FakeClock,
+// to make timing deterministic.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a *time.Timer from Clock.AfterFunc.
+type Timer interface {
+	Stop() bool
+}
+
+// Ticker is a *time.Ticker from Clock.NewTicker.
+// Done is called by the receiver when it has handled a tick from Chan.
+// It does nothing for the real clock, a FakeClock waits for it before moving on.
+type Ticker interface {
+	Chan() <-chan time.Time
+	Done()
+	Stop()
+}
+
+type realClock struct{}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+var (
+	clock     Clock = realClock{}
+	clockLock sync.RWMutex
+)
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (t realTicker) Chan() <-chan time.Time {
+	return t.C
+}
+
+func (t realTicker) Done() {}
+
+// Set sets the clock used, returning the previous one. Set nil to use the real clock.
+func Set(c Clock) (previous Clock) {
+	clockLock.Lock()
+	defer clockLock.Unlock()
+	previous = clock
+	if c == nil {
+		c = realClock{}
+	}
+	clock = c
+	return previous
+}
+
+// Current returns the clock set with Set, or the real one.
+func Current() Clock {
+	clockLock.RLock()
+	defer clockLock.RUnlock()
+	return clock
+}
+
+// Now is time.Now() from the current clock.
+func Now() time.Time {
+	return Current().Now()
+}
+
+// Since is time.Since() using the current clock.
+func Since(t time.Time) time.Duration {
+	return Now().Sub(t)
+}
@@ -0,0 +1,166 @@
+package zclock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeClock is a Clock that only moves when Advance is called, so code using ztimer timers, repeaters,
+// rate limiters and ztime's functions relative to now can be tested without sleeping.
+// Timer functions are called synchronously from Advance, in the order they are due.
+// Ticks are handed to a ticker's receiver, and Advance waits until it calls Ticker.Done, or the ticker is stopped,
+// so what a ztimer.Repeater does on a tick has happened when Advance returns.
+type FakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	count   int
+}
+
+type fakeWaiter struct {
+	clock  *FakeClock
+	at     time.Time
+	period time.Duration // set for tickers
+	do     func()
+	ch     chan time.Time // unbuffered, so Advance knows when a tick is received
+	done   chan struct{}  // sent to by Ticker.Done
+	stop   chan struct{}  // closed when a ticker is stopped
+	once   sync.Once
+	order  int
+}
+
+// NewFakeClock returns a FakeClock starting at start, or at a fixed time if it is zero.
+func NewFakeClock(start time.Time) *FakeClock {
+	if start.IsZero() {
+		start = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	}
+	return &FakeClock{now: start}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return fakeTimer{c.add(d, 0, f, nil)}
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	w := c.add(d, d, nil, make(chan time.Time))
+	w.done = make(chan struct{})
+	w.stop = make(chan struct{})
+	return fakeTicker{w}
+}
+
+func (c *FakeClock) add(d, period time.Duration, f func(), ch chan time.Time) *fakeWaiter {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.count++
+	w := &fakeWaiter{clock: c, at: c.now.Add(d), period: period, do: f, ch: ch, order: c.count}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+// Pending is how many timers and tickers are waiting.
+func (c *FakeClock) Pending() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.waiters)
+}
+
+// Advance moves the clock forward by d, firing timers and tickers due on the way at the time they are due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	end := c.now.Add(d)
+	c.lock.Unlock()
+	for {
+		c.lock.Lock()
+		sort.SliceStable(c.waiters, func(i, j int) bool {
+			wi, wj := c.waiters[i], c.waiters[j]
+			if wi.at.Equal(wj.at) {
+				return wi.order < wj.order
+			}
+			return wi.at.Before(wj.at)
+		})
+		if len(c.waiters) == 0 || c.waiters[0].at.After(end) {
+			c.now = end
+			c.lock.Unlock()
+			return
+		}
+		w := c.waiters[0]
+		c.now = w.at
+		if w.period != 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			c.waiters = c.waiters[1:]
+		}
+		now := c.now
+		c.lock.Unlock()
+		if w.do != nil {
+			w.do()
+			continue
+		}
+		select {
+		case w.ch <- now:
+		case <-w.stop:
+			continue
+		}
+		select {
+		case <-w.done:
+		case <-w.stop:
+		}
+	}
+}
+
+// Set advances the clock to t. It can't go backwards.
+func (c *FakeClock) Set(t time.Time) {
+	c.Advance(t.Sub(c.Now()))
+}
+
+func (c *FakeClock) remove(w *fakeWaiter) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, cw := range c.waiters {
+		if cw == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	*fakeWaiter
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTimer) Stop() bool {
+	return t.clock.remove(t.fakeWaiter)
+}
+
+func (t fakeTicker) Stop() {
+	t.clock.remove(t.fakeWaiter)
+	t.once.Do(func() {
+		close(t.stop)
+	})
+}
+
+func (t fakeTicker) Done() {
+	select {
+	case t.done <- struct{}{}:
+	case <-t.stop:
+	}
+}
+
+func (t fakeTicker) Chan() <-chan time.Time {
+	return t.ch
+}
@@ -11,6 +11,7 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/torlangballe/zutil/zbuild"
 	"github.com/torlangballe/zutil/zdevice"
 	"github.com/torlangballe/zutil/zdict"
 	"github.com/torlangballe/zutil/zint"
@@ -287,6 +288,38 @@ func (d *UtilCommands) Command_debug(c *CommandInfo, a struct {
 	// }
 }
 
+func (d *UtilCommands) Command_build(c *CommandInfo, a struct {
+	Description string `zui:"desc:Show version, commit, build time and Go version of this program."`
+}) {
+	b := zbuild.Build
+	dict := zdict.Dict{
+		"Version": b.Version,
+		"Commit":  b.ShortHash(),
+		"Branch":  b.Branch,
+		"Built":   ztime.GetNice(b.At, true),
+		"User":    b.User,
+		"Host":    b.Host,
+		"Go":      b.GoVersion,
+		"Module":  b.MainModule,
+	}
+	dict.WriteTabulated(c.Session.TermSession.Writer())
+}
+
+func (d *UtilCommands) Command_deps(c *CommandInfo, a struct {
+	Match       string `zui:"allowempty,desc:only show modules with paths containing this."`
+	Description string `zui:"desc:Show modules compiled into this program, with versions."`
+}) {
+	tabs := zstr.NewTabWriter(c.Session.TermSession.Writer())
+	fmt.Fprintln(tabs, zstr.EscGreen+"module\tversion\treplaced by"+zstr.EscNoColor)
+	for _, m := range zbuild.Build.Modules {
+		if a.Match != "" && !strings.Contains(m.Path, a.Match) {
+			continue
+		}
+		fmt.Fprint(tabs, zstr.EscCyan, m.Path, zstr.EscNoColor, "\t", m.Version, "\t", m.Replace, "\n")
+	}
+	tabs.Flush()
+}
+
 func (d *UtilCommands) Command_net(c *CommandInfo, a struct {
 	Description string `zui:"desc:Show i/o network bandwidth per second, and drops/sec and errors/sec."`
 }) {
@@ -0,0 +1,69 @@
+//go:build server
+
+package zcommands
+
+import (
+	"fmt"
+
+	"github.com/torlangballe/zutil/zprocess"
+	"github.com/torlangballe/zutil/zstr"
+	"github.com/torlangballe/zutil/ztime"
+	"github.com/torlangballe/zutil/zwords"
+)
+
+// SupervisorCommands exposes a zprocess.Supervisor's children as commands to show status, start, stop and restart them.
+type SupervisorCommands struct {
+	Supervisor *zprocess.Supervisor
+}
+
+func (sc *SupervisorCommands) Command_status(c *CommandInfo, a struct {
+	Description string `zui:"desc:Show state, pid, restarts and memory use of supervised processes."`
+}) {
+	tabs := zstr.NewTabWriter(c.Session.TermSession.Writer())
+	fmt.Fprint(tabs, zstr.EscGreen, "name\tstate\tpid\tup\trestarts\tmemory\texit\terror", zstr.EscNoColor, "\n")
+	for _, s := range sc.Supervisor.Statuses() {
+		col := zstr.EscCyan
+		if s.State == zprocess.ChildFailed {
+			col = zstr.EscMagenta
+		}
+		var up, mem string
+		if s.State == zprocess.ChildRunning {
+			up = ztime.DurationToVerbal(ztime.SecondsDur(s.RunningSecs), true)
+			mem = zwords.GetStorageSizeString(s.MemoryBytes, "", 2)
+		} else if s.State == zprocess.ChildBackoff {
+			up = fmt.Sprintf("restart in %.1fs", s.NextStartSec)
+		}
+		fmt.Fprint(tabs, col, s.Name, "\t", s.State, "\t", s.PID, "\t", up, "\t", s.Restarts, "\t", mem, "\t", s.ExitCode, "\t", s.LastError, zstr.EscNoColor, "\n")
+	}
+	tabs.Flush()
+}
+
+func (sc *SupervisorCommands) doForName(c *CommandInfo, name, verb string, do func(name string) error) {
+	err := do(name)
+	if err != nil {
+		c.Session.TermSession.Writeln(err)
+		return
+	}
+	c.Session.TermSession.Writeln(verb, name)
+}
+
+func (sc *SupervisorCommands) Command_start(c *CommandInfo, a struct {
+	Name        string `zui:"desc:name of process to start."`
+	Description string `zui:"desc:Start a supervised process."`
+}) {
+	sc.doForName(c, a.Name, "Started", sc.Supervisor.Start)
+}
+
+func (sc *SupervisorCommands) Command_stop(c *CommandInfo, a struct {
+	Name        string `zui:"desc:name of process to stop."`
+	Description string `zui:"desc:Stop a supervised process with SIGTERM, then SIGKILL after its stop timeout."`
+}) {
+	sc.doForName(c, a.Name, "Stopped", sc.Supervisor.Stop)
+}
+
+func (sc *SupervisorCommands) Command_restart(c *CommandInfo, a struct {
+	Name        string `zui:"desc:name of process to restart."`
+	Description string `zui:"desc:Stop and start a supervised process."`
+}) {
+	sc.doForName(c, a.Name, "Restarted", sc.Supervisor.Restart)
+}
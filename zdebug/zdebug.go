@@ -137,6 +137,9 @@ func RecoverFromPanic(exit bool, invokeFunc string) {
 		err = fmt.Errorf("%v", r)
 	}
 
+	if HandleCrashReportFunc != nil {
+		HandleCrashReportFunc(MakeCrashReport(r, invokeFunc))
+	}
 	err = MakeContextErrorForSignalRestartFunc(upStackLevels, invokeFunc, "Panic Restart", err)
 	fmt.Println("RecoverFromPanic:", upStackLevels, err, r)
 	debug.PrintStack()
@@ -0,0 +1,262 @@
+//go:build !js
+
+package zdebug
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CrashReportStore keeps crash reports as json files in a directory, one per fingerprint,
+// removing the oldest when there are more than MaxReports.
+// If CollectorURL is set, reports are POSTed to it as json, and marked as Uploaded when it returns 2xx.
+type CrashReportStore struct {
+	Dir          string
+	MaxReports   int
+	CollectorURL string
+	Headers      map[string]string // added to upload requests, for authentication
+	lock         sync.Mutex
+	uploadLock   sync.Mutex
+}
+
+const CrashReportsURLPrefix = "debug/crashes/"
+
+// MainCrashReportStore is set by SetupCrashReports, and served by SetCrashReportHandler.
+var MainCrashReportStore *CrashReportStore
+
+// SetupCrashReports makes MainCrashReportStore in dir, and sets HandleCrashReportFunc to save reports from RecoverFromPanic to it.
+// Reports are uploaded to collectorURL in the background, so a panicking program isn't held up.
+// Those not uploaded yet, perhaps because the program exited on panic, are uploaded at setup.
+func SetupCrashReports(dir string, maxReports int, collectorURL string) (*CrashReportStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	s := &CrashReportStore{Dir: dir, MaxReports: maxReports, CollectorURL: collectorURL}
+	MainCrashReportStore = s
+	HandleCrashReportFunc = func(report *CrashReport) {
+		err := s.Save(report)
+		if err != nil {
+			fmt.Println("save crash report:", err) // can't use zlog due to dependency cycle
+			return
+		}
+		if s.CollectorURL != "" {
+			go s.UploadPending()
+		}
+	}
+	if collectorURL != "" {
+		go s.UploadPending()
+	}
+	return s, nil
+}
+
+func (s *CrashReportStore) path(fingerprint string) string {
+	return filepath.Join(s.Dir, fingerprint+".json")
+}
+
+// Save stores report. If one with the same fingerprint exists, it is replaced with report, keeping its FirstAt and adding to Count.
+// It doesn't upload, as it is called while panicking; use UploadPending for that.
+func (s *CrashReportStore) Save(report *CrashReport) error {
+	s.lock.Lock()
+	old, err := s.load(report.Fingerprint)
+	if err == nil {
+		report.FirstAt = old.FirstAt
+		report.Count = old.Count + 1
+		report.Uploaded = false
+	}
+	err = s.write(report)
+	if err == nil {
+		s.removeOldest()
+	}
+	s.lock.Unlock()
+	return err
+}
+
+func (s *CrashReportStore) write(report *CrashReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := s.path(report.Fingerprint) + ".tmp"
+	err = os.WriteFile(temp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, s.path(report.Fingerprint))
+}
+
+func (s *CrashReportStore) load(fingerprint string) (*CrashReport, error) {
+	data, err := os.ReadFile(s.path(fingerprint))
+	if err != nil {
+		return nil, err
+	}
+	var report CrashReport
+	err = json.Unmarshal(data, &report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Get returns the report with fingerprint.
+func (s *CrashReportStore) Get(fingerprint string) (*CrashReport, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.load(fingerprint)
+}
+
+// Delete removes the report with fingerprint.
+func (s *CrashReportStore) Delete(fingerprint string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return os.Remove(s.path(fingerprint))
+}
+
+// List returns all reports, newest first.
+func (s *CrashReportStore) List() ([]*CrashReport, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.list()
+}
+
+func (s *CrashReportStore) list() ([]*CrashReport, error) {
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var reports []*CrashReport
+	for _, f := range files {
+		r, err := s.load(strings.TrimSuffix(filepath.Base(f), ".json"))
+		if err != nil {
+			continue
+		}
+		reports = append(reports, r)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].At.After(reports[j].At)
+	})
+	return reports, nil
+}
+
+func (s *CrashReportStore) removeOldest() {
+	if s.MaxReports <= 0 {
+		return
+	}
+	reports, _ := s.list()
+	for i := s.MaxReports; i < len(reports); i++ {
+		os.Remove(s.path(reports[i].Fingerprint))
+	}
+}
+
+// Upload POSTs report to CollectorURL, and marks it as Uploaded if that succeeds.
+func (s *CrashReportStore) Upload(report *CrashReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.CollectorURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("crash report upload: %s", resp.Status)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	report.Uploaded = true
+	current, err := s.load(report.Fingerprint)
+	if err == nil && current.Count != report.Count { // saved again while uploading, upload that later
+		return nil
+	}
+	return s.write(report)
+}
+
+// UploadPending uploads all reports not uploaded yet. Calls at the same time are done one after the other.
+func (s *CrashReportStore) UploadPending() {
+	s.uploadLock.Lock()
+	defer s.uploadLock.Unlock()
+	reports, _ := s.List()
+	for _, r := range reports {
+		if r.Uploaded {
+			continue
+		}
+		err := s.Upload(r)
+		if err != nil {
+			fmt.Println("upload crash report:", r.Fingerprint, err)
+			return
+		}
+	}
+}
+
+// SetCrashReportHandler serves MainCrashReportStore next to the profiling handlers:
+// debug/crashes/ lists reports without goroutines and log lines, debug/crashes/<fingerprint> gets or DELETEs one.
+func SetCrashReportHandler(router *mux.Router) {
+	prefix := AppURLPrefix() + CrashReportsURLPrefix
+	router.HandleFunc(prefix, handleCrashReportList).Methods(http.MethodGet)
+	router.HandleFunc(prefix+"{fingerprint}", handleCrashReport).Methods(http.MethodGet, http.MethodDelete)
+}
+
+func returnCrashJSON(w http.ResponseWriter, v any, err error) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		if os.IsNotExist(err) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+func handleCrashReportList(w http.ResponseWriter, req *http.Request) {
+	if MainCrashReportStore == nil {
+		http.Error(w, "no crash report store", http.StatusNotFound)
+		return
+	}
+	reports, err := MainCrashReportStore.List()
+	for _, r := range reports {
+		r.Goroutines = ""
+		r.LogLines = nil
+	}
+	returnCrashJSON(w, reports, err)
+}
+
+func handleCrashReport(w http.ResponseWriter, req *http.Request) {
+	if MainCrashReportStore == nil {
+		http.Error(w, "no crash report store", http.StatusNotFound)
+		return
+	}
+	fingerprint := mux.Vars(req)["fingerprint"]
+	if strings.ContainsAny(fingerprint, `/\.`) {
+		http.Error(w, "bad fingerprint", http.StatusBadRequest)
+		return
+	}
+	if req.Method == http.MethodDelete {
+		err := MainCrashReportStore.Delete(fingerprint)
+		returnCrashJSON(w, map[string]string{"deleted": fingerprint}, err)
+		return
+	}
+	report, err := MainCrashReportStore.Get(fingerprint)
+	returnCrashJSON(w, report, err)
+}
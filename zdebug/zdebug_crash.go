@@ -0,0 +1,136 @@
+package zdebug
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CrashReport is what is known about a panic: its stack, all goroutines, memory and the last log lines before it.
+// Reports with the same Fingerprint are the same crash, and are stored as one with a Count.
+type CrashReport struct {
+	Fingerprint string // hash of the functions in the panicking stack, without addresses and arguments
+	Message     string
+	Invoked     string `json:",omitempty"`
+	Stack       string // the panicking goroutine
+	Goroutines  string `json:",omitempty"`
+	Memory      CrashMemory
+	LogLines    []string `json:",omitempty"`
+	At          time.Time
+	FirstAt     time.Time
+	Count       int
+	Host        string
+	Executable  string
+	GoVersion   string
+	OS          string
+	Uploaded    bool
+}
+
+// CrashMemory is a summary of runtime.MemStats at the time of a crash.
+type CrashMemory struct {
+	Alloc      uint64
+	TotalAlloc uint64
+	Sys        uint64
+	HeapInuse  uint64
+	NumGC      uint32
+	Goroutines int
+}
+
+var (
+	RecentLogLinesMax = 100 // how many lines AddRecentLogLine keeps for crash reports
+
+	// HandleCrashReportFunc is called by RecoverFromPanic with a report of the panic, before storing the restart error.
+	// SetupCrashReports sets it to store reports on disk.
+	HandleCrashReportFunc func(report *CrashReport)
+
+	recentLogLines []string
+	recentLogLock  sync.Mutex
+)
+
+// AddRecentLogLine adds line to a ring of the last RecentLogLinesMax lines, which are included in crash reports.
+// zlog adds all lines it outputs.
+func AddRecentLogLine(line string) {
+	recentLogLock.Lock()
+	defer recentLogLock.Unlock()
+	recentLogLines = append(recentLogLines, strings.TrimRight(line, "\n"))
+	if over := len(recentLogLines) - RecentLogLinesMax; over > 0 {
+		recentLogLines = append([]string{}, recentLogLines[over:]...)
+	}
+}
+
+// RecentLogLines returns a copy of the last log lines added with AddRecentLogLine.
+func RecentLogLines() []string {
+	recentLogLock.Lock()
+	defer recentLogLock.Unlock()
+	return append([]string{}, recentLogLines...)
+}
+
+// MakeCrashReport makes a report for a panic with value r. It should be called in the deferred function that recovered,
+// so the current goroutine's stack includes where it panicked.
+func MakeCrashReport(r any, invokeFunc string) *CrashReport {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stack := string(debug.Stack())
+	report := &CrashReport{
+		Message:    fmt.Sprint(r),
+		Invoked:    invokeFunc,
+		Stack:      stack,
+		Goroutines: allGoroutines(),
+		LogLines:   RecentLogLines(),
+		At:         time.Now(),
+		Count:      1,
+		GoVersion:  runtime.Version(),
+		OS:         runtime.GOOS + "-" + runtime.GOARCH,
+		Memory: CrashMemory{
+			Alloc:      m.Alloc,
+			TotalAlloc: m.TotalAlloc,
+			Sys:        m.Sys,
+			HeapInuse:  m.HeapInuse,
+			NumGC:      m.NumGC,
+			Goroutines: runtime.NumGoroutine(),
+		},
+	}
+	report.FirstAt = report.At
+	report.Host, _ = os.Hostname()
+	report.Executable, _ = os.Executable()
+	report.Fingerprint = StackFingerprint(stack)
+	return report
+}
+
+func allGoroutines() string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// StackFingerprint returns a short hash of the function names in a stack from debug.Stack(), from where it panicked if it did.
+// Line numbers, addresses and arguments are left out, so the same crash in a slightly changed build gets the same fingerprint.
+func StackFingerprint(stack string) string {
+	var funcs []string
+	for _, line := range strings.Split(stack, "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "goroutine ") {
+			continue
+		}
+		if strings.HasPrefix(line, "panic(") {
+			funcs = funcs[:0] // only what's below the panic is the crash
+			continue
+		}
+		funcs = append(funcs, funcWithoutArgs(line))
+	}
+	if len(funcs) > 12 {
+		funcs = funcs[:12]
+	}
+	sum := sha1.Sum([]byte(strings.Join(funcs, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
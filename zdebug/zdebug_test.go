@@ -0,0 +1,153 @@
+//go:build !js
+
+package zdebug_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/torlangballe/zutil/zdebug"
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func crash(i int) {
+	defer zdebug.RecoverFromPanic(false, "")
+	var s []int
+	_ = s[i]
+}
+
+func crashElsewhere() {
+	defer zdebug.RecoverFromPanic(false, "")
+	var m map[string]int
+	m["x"] = 1
+}
+
+func TestCrashReports(t *testing.T) {
+	var uploads atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(io.Discard, req.Body)
+		uploads.Add(1)
+	}))
+	defer collector.Close()
+
+	store, err := zdebug.SetupCrashReports(t.TempDir(), 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.CollectorURL = collector.URL
+	defer func() { zdebug.HandleCrashReportFunc = nil }()
+	zlog.Warn("before the crash")
+	crash(3)
+	crash(5)
+	var reports []*zdebug.CrashReport
+	for range 200 { // uploaded in the background
+		reports, err = store.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reports) == 1 && reports[0].Uploaded {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ztesting.Equal(t, len(reports), 1, "same stack is one report")
+	r := reports[0]
+	ztesting.Equal(t, r.Count, 2, "count")
+	ztesting.Equal(t, r.Message, "runtime error: index out of range [5] with length 0", "latest message")
+	ztesting.Equal(t, r.Uploaded, true, "uploaded")
+	ztesting.Equal(t, uploads.Load() >= 1, true, "uploads")
+	ztesting.Equal(t, len(r.LogLines) > 0 && r.Memory.Goroutines > 0 && r.Goroutines != "", true, "log lines, memory and goroutines")
+
+	crashElsewhere()
+	reports, _ = store.List()
+	ztesting.Equal(t, len(reports), 2, "different stack")
+	ztesting.Equal(t, reports[0].Fingerprint != r.Fingerprint, true, "newest first")
+
+	zdebug.AppURLPrefix = func() string { return "/" }
+	router := mux.NewRouter()
+	zdebug.SetCrashReportHandler(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/" + zdebug.CrashReportsURLPrefix + r.Fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got zdebug.CrashReport
+	json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close()
+	ztesting.Equal(t, got.Count, 2, "served report")
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/"+zdebug.CrashReportsURLPrefix+r.Fingerprint, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	reports, _ = store.List()
+	ztesting.Equal(t, len(reports), 1, "deleted")
+}
+
+func blockOn(ch chan struct{}, started *sync.WaitGroup) {
+	started.Done()
+	<-ch
+}
+
+func TestWatchdog(t *testing.T) {
+	w := zdebug.NewWatchdog()
+	w.BlockedThreshold = 50 * time.Millisecond
+	w.GrowthThreshold = 10
+	var found []zdebug.WatchdogFinding
+	w.HandleFindingsFunc = func(f []zdebug.WatchdogFinding) {
+		found = append(found, f...)
+	}
+	w.Check() // baseline
+
+	ch := make(chan struct{})
+	defer close(ch)
+	var started sync.WaitGroup
+	started.Add(20)
+	for i := 0; i < 20; i++ {
+		go blockOn(ch, &started)
+	}
+	started.Wait()
+	findings := w.Check()
+	ztesting.Equal(t, len(findings), 1, "growth only")
+	ztesting.Equal(t, findings[0].Kind, zdebug.WatchdogGrowth, "growth")
+	ztesting.Equal(t, findings[0].Place, "github.com/torlangballe/zutil/zdebug_test.TestWatchdog", "creator")
+	ztesting.Equal(t, findings[0].Growth, 20, "growth count")
+
+	time.Sleep(60 * time.Millisecond)
+	findings = w.Check()
+	var blocked *zdebug.WatchdogFinding
+	for i, f := range findings {
+		if f.Kind == zdebug.WatchdogBlocked && strings.Contains(f.Example, "zdebug_test.blockOn") {
+			blocked = &findings[i]
+		}
+	}
+	if blocked == nil {
+		t.Fatal("no blocked finding", findings)
+	}
+	ztesting.Equal(t, blocked.Count, 20, "blocked count")
+	ztesting.Equal(t, blocked.State, "chan receive", "state")
+	ztesting.Equal(t, len(w.Check()), 0, "not reported twice")
+	ztesting.Equal(t, len(found) >= 2, true, "handler called")
+}
+
+func TestGoroutineLeaks(t *testing.T) {
+	ztesting.CheckGoroutineLeaks(t)
+	done := make(chan bool)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		done <- true
+	}()
+	go func() { <-done }()
+}
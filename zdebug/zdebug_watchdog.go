@@ -0,0 +1,285 @@
+package zdebug
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Goroutine is one goroutine from a runtime.Stack dump of all goroutines.
+type Goroutine struct {
+	ID        int64
+	State     string // like "running", "chan receive" or "sync.Mutex.Lock"
+	Top       string // the function it is in, without arguments
+	CreatedBy string // the function that started it, without arguments
+	Stack     string // the whole entry, including the header line
+}
+
+// WatchdogFindingKind is what a Watchdog found.
+type WatchdogFindingKind string
+
+const (
+	WatchdogBlocked WatchdogFindingKind = "blocked" // goroutines waiting in the same place longer than BlockedThreshold, a possible deadlock
+	WatchdogGrowth  WatchdogFindingKind = "growth"  // goroutines started by the same function grew by GrowthThreshold or more, a possible leak
+)
+
+// WatchdogFinding is a group of goroutines that a Watchdog thinks might be a problem.
+type WatchdogFinding struct {
+	Kind    WatchdogFindingKind
+	Place   string // the function blocked in, or the creating function for growth
+	State   string `json:",omitempty"`
+	Count   int    // goroutines blocked there, or now created by Place
+	Growth  int    `json:",omitempty"` // how much Count grew since last reported
+	For     time.Duration
+	Example string // the stack of one of them
+}
+
+// Watchdog periodically snapshots all goroutines, to find ones blocked in the same place for a long time,
+// and functions whose goroutines keep growing in number.
+// Findings are logged with WarnFunc, and given to HandleFindingsFunc if set.
+type Watchdog struct {
+	Interval           time.Duration
+	BlockedThreshold   time.Duration
+	GrowthThreshold    int
+	IgnoreFuncs        []string // goroutines with any of these in their stack are ignored, for ones that wait forever by design
+	HandleFindingsFunc func(findings []WatchdogFinding)
+	HandleCountsFunc   func(countsByCreator map[string]int) // called with goroutine counts per creating function each check, for metrics
+
+	lock      sync.Mutex
+	waiting   map[int64]waitingGoroutine
+	baselines map[string]int
+	reported  map[string]bool
+	checked   bool
+	stop      chan struct{}
+}
+
+type waitingGoroutine struct {
+	signature string
+	since     time.Time
+}
+
+var (
+	// WarnFunc is used by Watchdog to report findings. zlog sets it to zlog.Warn.
+	WarnFunc = func(parts ...any) {
+		fmt.Println(parts...)
+	}
+
+	goroutineHeaderRegex = regexp.MustCompile(`^goroutine (\d+) \[([^\],]+)`)
+	// states where a goroutine is doing something, not blocked
+	activeStates = []string{"running", "runnable", "syscall", "preempted"}
+)
+
+// NewWatchdog returns a Watchdog checking every 30 seconds, flagging goroutines blocked 5 minutes or growing by 100.
+func NewWatchdog() *Watchdog {
+	return &Watchdog{
+		Interval:         30 * time.Second,
+		BlockedThreshold: 5 * time.Minute,
+		GrowthThreshold:  100,
+		waiting:          map[int64]waitingGoroutine{},
+		baselines:        map[string]int{},
+		reported:         map[string]bool{},
+	}
+}
+
+// Start checks every Interval in a goroutine until Stop is called.
+func (w *Watchdog) Start() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				w.Check()
+			}
+		}
+	}(w.stop)
+}
+
+// Stop stops periodic checking.
+func (w *Watchdog) Stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+// Check snapshots goroutines now, reports and returns findings.
+func (w *Watchdog) Check() []WatchdogFinding {
+	return w.checkGoroutines(AllGoroutines(), time.Now())
+}
+
+func (w *Watchdog) checkGoroutines(gs []Goroutine, now time.Time) []WatchdogFinding {
+	w.lock.Lock()
+	var findings []WatchdogFinding
+	blocked := map[string]*WatchdogFinding{}
+	counts := map[string]int{}
+	seen := map[int64]bool{}
+	for _, g := range gs {
+		if w.Ignores(g) {
+			continue
+		}
+		counts[g.CreatedBy]++
+		if isActiveState(g.State) {
+			continue
+		}
+		seen[g.ID] = true
+		sig := g.State + "|" + StackFingerprint(g.Stack)
+		wg, got := w.waiting[g.ID]
+		if !got || wg.signature != sig {
+			w.waiting[g.ID] = waitingGoroutine{signature: sig, since: now}
+			continue
+		}
+		waited := now.Sub(wg.since)
+		if waited < w.BlockedThreshold {
+			continue
+		}
+		f := blocked[sig]
+		if f == nil {
+			f = &WatchdogFinding{Kind: WatchdogBlocked, Place: g.Top, State: g.State, Example: g.Stack}
+			blocked[sig] = f
+		}
+		f.Count++
+		f.For = max(f.For, waited)
+	}
+	for id := range w.waiting {
+		if !seen[id] {
+			delete(w.waiting, id)
+		}
+	}
+	for sig, f := range blocked {
+		if !w.reported[sig] {
+			w.reported[sig] = true
+			findings = append(findings, *f)
+		}
+	}
+	for sig := range w.reported {
+		if blocked[sig] == nil {
+			delete(w.reported, sig) // unblocked, report again if it happens again
+		}
+	}
+	for creator := range w.baselines {
+		if counts[creator] == 0 {
+			delete(w.baselines, creator)
+		}
+	}
+	for creator, count := range counts {
+		base := w.baselines[creator] // 0 for creators that appeared since the first check
+		if !w.checked {
+			w.baselines[creator] = count
+			continue
+		}
+		if count < base {
+			w.baselines[creator] = count
+			continue
+		}
+		if w.GrowthThreshold > 0 && count-base >= w.GrowthThreshold {
+			f := WatchdogFinding{Kind: WatchdogGrowth, Place: creator, Count: count, Growth: count - base}
+			for _, g := range gs {
+				if g.CreatedBy == creator {
+					f.Example = g.Stack
+				}
+			}
+			findings = append(findings, f)
+			w.baselines[creator] = count
+		}
+	}
+	w.checked = true
+	handleFindings := w.HandleFindingsFunc
+	handleCounts := w.HandleCountsFunc
+	w.lock.Unlock()
+
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Count > findings[j].Count
+	})
+	for _, f := range findings {
+		switch f.Kind {
+		case WatchdogBlocked:
+			WarnFunc("🟥Goroutine watchdog:", f.Count, "goroutines blocked in", f.Place, "["+f.State+"] for", f.For.Round(time.Second), "\n"+f.Example)
+		case WatchdogGrowth:
+			WarnFunc("🟥Goroutine watchdog:", f.Count, "goroutines created by", f.Place, "grew by", f.Growth, "\n"+f.Example)
+		}
+	}
+	if handleCounts != nil {
+		handleCounts(counts)
+	}
+	if handleFindings != nil && len(findings) > 0 {
+		handleFindings(findings)
+	}
+	return findings
+}
+
+// Ignores is true if g has any of IgnoreFuncs in its stack.
+func (w *Watchdog) Ignores(g Goroutine) bool {
+	for _, f := range w.IgnoreFuncs {
+		if strings.Contains(g.Stack, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func isActiveState(state string) bool {
+	for _, s := range activeStates {
+		if state == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AllGoroutines returns all current goroutines, parsed from runtime.Stack.
+func AllGoroutines() []Goroutine {
+	return ParseGoroutines(allGoroutines())
+}
+
+// ParseGoroutines parses a dump of goroutines from runtime.Stack or a panic.
+func ParseGoroutines(dump string) []Goroutine {
+	var gs []Goroutine
+	for _, entry := range strings.Split(strings.TrimSpace(dump), "\n\n") {
+		lines := strings.Split(entry, "\n")
+		m := goroutineHeaderRegex.FindStringSubmatch(lines[0])
+		if m == nil {
+			continue
+		}
+		var g Goroutine
+		g.ID, _ = strconv.ParseInt(m[1], 10, 64)
+		g.State = m[2]
+		g.Stack = entry
+		for _, line := range lines[1:] {
+			if strings.HasPrefix(line, "\t") {
+				continue
+			}
+			if str, found := strings.CutPrefix(line, "created by "); found {
+				str, _, _ = strings.Cut(str, " in goroutine ")
+				g.CreatedBy = str
+				continue
+			}
+			if g.Top == "" {
+				g.Top = funcWithoutArgs(line)
+			}
+		}
+		gs = append(gs, g)
+	}
+	return gs
+}
+
+func funcWithoutArgs(line string) string {
+	if i := strings.LastIndex(line, "("); i != -1 {
+		return line[:i]
+	}
+	return line
+}
@@ -21,7 +21,7 @@ func BootTime() (t time.Time, err error) {
 	if err != nil {
 		return t, err
 	}
-	t = time.Now().Add(ztime.SecondsDur(secs))
+	t = time.Now().Add(-ztime.SecondsDur(secs))
 	return t, nil
 }
 
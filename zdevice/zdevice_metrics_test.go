@@ -0,0 +1,60 @@
+//go:build !js && !windows
+
+package zdevice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	clock := ztesting.UseFakeClock(t, time.Time{})
+	c := NewMetricsCollector()
+	c.MaxSamples = 3
+	queue := 5.0
+	c.AddGauge("queue", "jobs", func() float64 {
+		return queue
+	})
+	var alerts []MetricAlert
+	c.AddThreshold(MetricThreshold{Name: "queue", Limit: 10, For: 30 * time.Second, AlertFunc: func(a MetricAlert) {
+		alerts = append(alerts, a)
+	}})
+
+	c.Sample()
+	cpu, got := c.Latest(MetricCPU, "")
+	ztesting.Equal(t, got, true, "cpu sampled")
+	ztesting.NearEqualF(t, cpu, 0.25, "cpu in tests")
+	_, got = c.Latest(MetricDiskUsed, "/")
+	ztesting.Equal(t, got, true, "disk sampled")
+	_, got = c.Latest(MetricProcessRSS, "")
+	ztesting.Equal(t, got, true, "process rss sampled")
+
+	queue = 20
+	clock.Advance(15 * time.Second)
+	c.Sample()
+	ztesting.Equal(t, len(alerts), 0, "not over limit for long enough")
+	clock.Advance(30 * time.Second)
+	c.Sample()
+	if ztesting.Equal(t, len(alerts), 1, "firing") {
+		ztesting.Equal(t, alerts[0].Firing, true)
+		ztesting.Equal(t, alerts[0].Label, "jobs")
+		ztesting.Equal(t, alerts[0].At.Sub(alerts[0].Since), 30*time.Second)
+	}
+	clock.Advance(15 * time.Second)
+	c.Sample()
+	ztesting.Equal(t, len(alerts), 1, "fires once")
+
+	queue = 1
+	clock.Advance(15 * time.Second)
+	c.Sample()
+	if ztesting.Equal(t, len(alerts), 2, "resolved") {
+		ztesting.Equal(t, alerts[1].Firing, false)
+	}
+	samples := c.Series("queue", "jobs")
+	ztesting.Equal(t, len(samples), 3, "max samples")
+	ztesting.Equal(t, samples[2].Value, 1.0)
+	_, got = c.Latest(MetricProcessCPU, "")
+	ztesting.Equal(t, got, true, "process cpu after two samples")
+}
@@ -0,0 +1,346 @@
+//go:build !js && !windows
+
+package zdevice
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/torlangballe/zutil/zclock"
+	"github.com/torlangballe/zutil/zfloat"
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zprocess"
+	"github.com/torlangballe/zutil/ztimer"
+)
+
+// Names of the metrics a MetricsCollector samples. Fractions are 0-1.
+const (
+	MetricCPU               = "cpu"                // fraction of all cores used
+	MetricMemoryUsed        = "memory_used"        // fraction of memory used
+	MetricMemoryAvailable   = "memory_available"   // bytes
+	MetricDiskUsed          = "disk_used"          // fraction of disk used, labeled with the path
+	MetricDiskFree          = "disk_free"          // bytes, labeled with the path
+	MetricNetIn             = "net_in_per_sec"     // bytes received per second, labeled with the interface
+	MetricNetOut            = "net_out_per_sec"    // bytes sent per second, labeled with the interface
+	MetricUptime            = "uptime"             // seconds since the machine booted
+	MetricProcessCPU        = "process_cpu"        // fraction of all cores used by this process
+	MetricProcessRSS        = "process_rss"        // bytes
+	MetricProcessOpenFiles  = "process_open_files" // file descriptors
+	MetricProcessThreads    = "process_threads"    // os threads
+	MetricProcessGoroutines = "process_goroutines"
+)
+
+// MetricSample is a value of a metric at a time.
+type MetricSample struct {
+	At    time.Time
+	Value float64
+}
+
+// MetricSeries is the samples of a metric with a label, oldest first.
+type MetricSeries struct {
+	Name    string
+	Label   string `json:",omitempty"`
+	Samples []MetricSample
+}
+
+// MetricThreshold makes a MetricsCollector call AlertFunc when a metric goes over Limit, or under it if Below is set,
+// and stays there For a while. It is called again with Firing false when it is back.
+type MetricThreshold struct {
+	Name      string
+	Label     string // if empty, all labels of Name are checked separately
+	Limit     float64
+	Below     bool
+	For       time.Duration
+	AlertFunc func(alert MetricAlert) // if nil, the collector's AlertFunc is used
+}
+
+// MetricAlert is a metric crossing a MetricThreshold.
+type MetricAlert struct {
+	Name   string
+	Label  string
+	Value  float64
+	Limit  float64
+	Firing bool // false when the metric is back within its limit
+	Since  time.Time
+	At     time.Time
+}
+
+// MetricsCollector samples host and process metrics from zdevice and zprocess every Interval into in-memory series
+// of up to MaxSamples each, checking them against thresholds.
+// HandleSampleFunc is called for each value sampled, ztelemetry.ReportHostMetrics uses it to export gauges.
+type MetricsCollector struct {
+	Interval         time.Duration
+	MaxSamples       int
+	DiskPaths        []string
+	AlertFunc        func(alert MetricAlert) // called for thresholds without an AlertFunc. If nil, alerts are logged
+	HandleSampleFunc func(name, label string, value float64)
+
+	lock        sync.Mutex
+	series      map[string]*MetricSeries
+	gauges      []metricGauge
+	thresholds  []MetricThreshold
+	crossed     map[string]time.Time // key of threshold index and label, when it went over
+	firing      map[string]bool
+	repeater    *ztimer.Repeater
+	lastProcCPU float64
+	lastProcAt  time.Time
+}
+
+type metricGauge struct {
+	name  string
+	label string
+	get   func() float64
+}
+
+// MainMetricsCollector is started by StartHostMetrics.
+var MainMetricsCollector *MetricsCollector
+
+// NewMetricsCollector returns a collector sampling every 15 seconds, keeping an hour of samples, for the root disk.
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{
+		Interval:   15 * time.Second,
+		MaxSamples: 240,
+		DiskPaths:  []string{"/"},
+		series:     map[string]*MetricSeries{},
+		crossed:    map[string]time.Time{},
+		firing:     map[string]bool{},
+	}
+}
+
+// StartHostMetrics starts MainMetricsCollector if not started, with alerts for disks and memory over 90% used.
+func StartHostMetrics() *MetricsCollector {
+	if MainMetricsCollector != nil {
+		return MainMetricsCollector
+	}
+	c := NewMetricsCollector()
+	c.AddThreshold(MetricThreshold{Name: MetricDiskUsed, Limit: 0.9})
+	c.AddThreshold(MetricThreshold{Name: MetricMemoryUsed, Limit: 0.9, For: time.Minute})
+	MainMetricsCollector = c
+	c.Start()
+	return c
+}
+
+// AddGauge makes c sample get() as metric name with label each time it samples, for app-specific metrics.
+func (c *MetricsCollector) AddGauge(name, label string, get func() float64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.gauges = append(c.gauges, metricGauge{name: name, label: label, get: get})
+}
+
+// AddThreshold adds a threshold to check each sample against.
+func (c *MetricsCollector) AddThreshold(t MetricThreshold) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.thresholds = append(c.thresholds, t)
+}
+
+// Start samples now and every Interval until Stop is called.
+func (c *MetricsCollector) Start() {
+	InitNetworkBandwidth()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.repeater != nil {
+		return
+	}
+	c.repeater = ztimer.RepeatForeverNow(c.Interval.Seconds(), c.Sample)
+}
+
+// Stop stops sampling.
+func (c *MetricsCollector) Stop() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.repeater != nil {
+		c.repeater.Stop()
+		c.repeater = nil
+	}
+}
+
+// Sample reads all metrics and gauges now, adds them to their series and checks thresholds.
+func (c *MetricsCollector) Sample() {
+	now := zclock.Now()
+	c.add(now, MetricCPU, "", zfloat.Average(CPUUsage(1)))
+	available, used, total := MemoryAvailableUsedAndTotal()
+	if total > 0 {
+		c.add(now, MetricMemoryUsed, "", float64(used)/float64(total))
+	}
+	c.add(now, MetricMemoryAvailable, "", float64(available))
+	for _, path := range c.DiskPaths {
+		free, used, total := FreeUsedAndTotalDiskSpace(path)
+		if total == 0 {
+			continue
+		}
+		c.add(now, MetricDiskUsed, path, float64(used)/float64(total))
+		c.add(now, MetricDiskFree, path, float64(free))
+	}
+	bandwidth, err := NetworkBandwidthPerSec()
+	if err == nil {
+		for name, io := range bandwidth {
+			c.add(now, MetricNetIn, name, float64(io.In.Bytes))
+			c.add(now, MetricNetOut, name, float64(io.Out.Bytes))
+		}
+	}
+	boot, err := BootTime()
+	if err == nil {
+		c.add(now, MetricUptime, "", time.Since(boot).Seconds())
+	}
+	c.sampleProcess(now)
+	c.lock.Lock()
+	gauges := append([]metricGauge{}, c.gauges...)
+	c.lock.Unlock()
+	for _, g := range gauges {
+		c.add(now, g.name, g.label, g.get())
+	}
+	c.checkThresholds(now)
+}
+
+func (c *MetricsCollector) sampleProcess(now time.Time) {
+	c.add(now, MetricProcessGoroutines, "", float64(runtime.NumGoroutine()))
+	stats, err := zprocess.GetProcessStats(int64(os.Getpid()))
+	if err != nil {
+		return
+	}
+	c.lock.Lock()
+	lastCPU, lastAt := c.lastProcCPU, c.lastProcAt
+	c.lastProcCPU, c.lastProcAt = stats.CPUSeconds, now
+	c.lock.Unlock()
+	if !lastAt.IsZero() && now.After(lastAt) {
+		fraction := (stats.CPUSeconds - lastCPU) / now.Sub(lastAt).Seconds() / float64(runtime.NumCPU())
+		c.add(now, MetricProcessCPU, "", fraction)
+	}
+	c.add(now, MetricProcessRSS, "", float64(stats.RSS))
+	c.add(now, MetricProcessThreads, "", float64(stats.Threads))
+	if stats.OpenFiles != -1 {
+		c.add(now, MetricProcessOpenFiles, "", float64(stats.OpenFiles))
+	}
+}
+
+func seriesKey(name, label string) string {
+	return name + "|" + label
+}
+
+func (c *MetricsCollector) add(at time.Time, name, label string, value float64) {
+	c.lock.Lock()
+	key := seriesKey(name, label)
+	s := c.series[key]
+	if s == nil {
+		s = &MetricSeries{Name: name, Label: label}
+		c.series[key] = s
+	}
+	s.Samples = append(s.Samples, MetricSample{At: at, Value: value})
+	if over := len(s.Samples) - c.MaxSamples; c.MaxSamples > 0 && over > 0 {
+		s.Samples = append([]MetricSample{}, s.Samples[over:]...)
+	}
+	handle := c.HandleSampleFunc
+	c.lock.Unlock()
+	if handle != nil {
+		handle(name, label, value)
+	}
+}
+
+func (c *MetricsCollector) checkThresholds(now time.Time) {
+	var alerts []MetricAlert
+	var funcs []func(MetricAlert)
+	c.lock.Lock()
+	for i, t := range c.thresholds {
+		for _, s := range c.series {
+			if s.Name != t.Name || (t.Label != "" && s.Label != t.Label) || len(s.Samples) == 0 {
+				continue
+			}
+			value := s.Samples[len(s.Samples)-1].Value
+			key := fmt.Sprint(seriesKey(s.Name, s.Label), "|", i)
+			over := value > t.Limit
+			if t.Below {
+				over = value < t.Limit
+			}
+			since, crossed := c.crossed[key]
+			alert := MetricAlert{Name: s.Name, Label: s.Label, Value: value, Limit: t.Limit, Since: since, At: now}
+			if !over {
+				delete(c.crossed, key)
+				if c.firing[key] {
+					delete(c.firing, key)
+					alerts = append(alerts, alert)
+					funcs = append(funcs, t.AlertFunc)
+				}
+				continue
+			}
+			if !crossed {
+				since = now
+				c.crossed[key] = now
+				alert.Since = now
+			}
+			if !c.firing[key] && now.Sub(since) >= t.For {
+				c.firing[key] = true
+				alert.Firing = true
+				alerts = append(alerts, alert)
+				funcs = append(funcs, t.AlertFunc)
+			}
+		}
+	}
+	defaultFunc := c.AlertFunc
+	c.lock.Unlock()
+	for i, a := range alerts {
+		f := funcs[i]
+		if f == nil {
+			f = defaultFunc
+		}
+		if f == nil {
+			f = logMetricAlert
+		}
+		f(a)
+	}
+}
+
+func logMetricAlert(a MetricAlert) {
+	name := a.Name
+	if a.Label != "" {
+		name += " " + a.Label
+	}
+	if a.Firing {
+		zlog.Warn("🟥Host metric", name, "is", a.Value, "beyond limit", a.Limit, "since", a.Since)
+		return
+	}
+	zlog.Info("🟩Host metric", name, "is back within limit", a.Limit, ":", a.Value)
+}
+
+// Series returns a copy of the samples of metric name with label.
+func (c *MetricsCollector) Series(name, label string) []MetricSample {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s := c.series[seriesKey(name, label)]
+	if s == nil {
+		return nil
+	}
+	return append([]MetricSample{}, s.Samples...)
+}
+
+// Latest returns the last value sampled of metric name with label, and false if there is none.
+func (c *MetricsCollector) Latest(name, label string) (float64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s := c.series[seriesKey(name, label)]
+	if s == nil || len(s.Samples) == 0 {
+		return 0, false
+	}
+	return s.Samples[len(s.Samples)-1].Value, true
+}
+
+// AllSeries returns a copy of all series sorted by name and label.
+func (c *MetricsCollector) AllSeries() []MetricSeries {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	all := make([]MetricSeries, 0, len(c.series))
+	for _, s := range c.series {
+		all = append(all, MetricSeries{Name: s.Name, Label: s.Label, Samples: append([]MetricSample{}, s.Samples...)})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Name == all[j].Name {
+			return all[i].Label < all[j].Label
+		}
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
@@ -14,6 +14,7 @@ import (
 
 type ContextError struct {
 	Title           string        `json:",omitempty"`
+	Code            Code          `json:",omitempty"` // see CodeOf
 	SubContextError *ContextError `json:",omitempty"`
 	WrappedError    error         `json:"-"`
 	KeyValues       zdict.Dict    `json:",omitempty"`
@@ -0,0 +1,199 @@
+package zerrors
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+
+	"github.com/torlangballe/zutil/zdict"
+)
+
+// Code is a stable, machine-readable error category, that survives being sent over http or xrpc,
+// so clients can branch on it instead of parsing error strings.
+type Code string
+
+const (
+	CodeNone               Code = ""
+	CodeUnknown            Code = "unknown"
+	CodeInvalidArgument    Code = "invalid-argument"
+	CodeNotFound           Code = "not-found"
+	CodeAlreadyExists      Code = "already-exists"
+	CodeConflict           Code = "conflict" // concurrent modification, try again with fresh data
+	CodeFailedPrecondition Code = "failed-precondition"
+	CodeUnauthenticated    Code = "unauthenticated"
+	CodePermissionDenied   Code = "permission-denied"
+	CodeResourceExhausted  Code = "resource-exhausted" // rate limited or quota used
+	CodeCanceled           Code = "canceled"
+	CodeDeadlineExceeded   Code = "deadline-exceeded"
+	CodeUnavailable        Code = "unavailable"
+	CodeUnimplemented      Code = "unimplemented"
+	CodeInternal           Code = "internal"
+)
+
+// Coder is implemented by errors that know their Code. CodeOf uses it for errors that aren't a ContextError.
+type Coder interface {
+	ErrorCode() Code
+}
+
+var codeStatuses = map[Code]int{
+	CodeInvalidArgument:    http.StatusBadRequest,
+	CodeNotFound:           http.StatusNotFound,
+	CodeAlreadyExists:      http.StatusConflict,
+	CodeConflict:           http.StatusConflict,
+	CodeFailedPrecondition: http.StatusPreconditionFailed,
+	CodeUnauthenticated:    http.StatusUnauthorized,
+	CodePermissionDenied:   http.StatusForbidden,
+	CodeResourceExhausted:  http.StatusTooManyRequests,
+	CodeCanceled:           499, // nginx's client closed request
+	CodeDeadlineExceeded:   http.StatusGatewayTimeout,
+	CodeUnavailable:        http.StatusServiceUnavailable,
+	CodeUnimplemented:      http.StatusNotImplemented,
+	CodeInternal:           http.StatusInternalServerError,
+	CodeUnknown:            http.StatusInternalServerError,
+}
+
+// MakeCodedError is MakeContextError with code set.
+func MakeCodedError(code Code, dict zdict.Dict, parts ...any) ContextError {
+	ce := MakeContextError(dict, parts...)
+	ce.Code = code
+	return ce
+}
+
+// ErrorCode returns e's code, or that of its sub-error if it has none.
+func (e ContextError) ErrorCode() Code {
+	if e.Code != CodeNone {
+		return e.Code
+	}
+	if e.SubContextError != nil {
+		return e.SubContextError.ErrorCode()
+	}
+	if e.WrappedError != nil {
+		return findCode(e.WrappedError)
+	}
+	return CodeNone
+}
+
+// CodeOf returns the first code found in err's chain, from a ContextError or Coder,
+// or one for well-known standard library errors. It is CodeNone for nil, and CodeUnknown if nothing is found.
+func CodeOf(err error) Code {
+	if err == nil {
+		return CodeNone
+	}
+	if c := findCode(err); c != CodeNone {
+		return c
+	}
+	return CodeUnknown
+}
+
+func findCode(err error) Code {
+	if coder, is := err.(Coder); is {
+		if c := coder.ErrorCode(); c != CodeNone {
+			return c
+		}
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeDeadlineExceeded
+	case errors.Is(err, fs.ErrNotExist):
+		return CodeNotFound
+	case errors.Is(err, fs.ErrExist):
+		return CodeAlreadyExists
+	case errors.Is(err, fs.ErrPermission):
+		return CodePermissionDenied
+	}
+	if ne, is := err.(net.Error); is && ne.Timeout() {
+		return CodeDeadlineExceeded
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if w := u.Unwrap(); w != nil {
+			return findCode(w)
+		}
+	case interface{ Unwrap() []error }:
+		for _, w := range u.Unwrap() {
+			if c := findCode(w); c != CodeNone {
+				return c
+			}
+		}
+	}
+	return CodeNone
+}
+
+// HasCode is true if err's code is code.
+func HasCode(err error, code Code) bool {
+	return CodeOf(err) == code
+}
+
+// IsRetryable is true for codes where trying the same thing again later might succeed.
+func (c Code) IsRetryable() bool {
+	switch c {
+	case CodeUnavailable, CodeDeadlineExceeded, CodeResourceExhausted, CodeConflict:
+		return true
+	}
+	return false
+}
+
+// IsClientError is true for codes caused by the request, not the server.
+func (c Code) IsClientError() bool {
+	status := c.HTTPStatus()
+	return status >= 400 && status < 500
+}
+
+// IsRetryable is true if err's code is retryable.
+func IsRetryable(err error) bool {
+	return CodeOf(err).IsRetryable()
+}
+
+// HTTPStatus is the http status code c is returned as, 200 for CodeNone.
+func (c Code) HTTPStatus() int {
+	if c == CodeNone {
+		return http.StatusOK
+	}
+	status, got := codeStatuses[c]
+	if !got {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// CodeFromHTTPStatus is the code for an http status, CodeNone for non-errors and CodeUnknown for unknown errors.
+func CodeFromHTTPStatus(status int) Code {
+	switch status {
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return CodeDeadlineExceeded
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusUnprocessableEntity, http.StatusRequestEntityTooLarge:
+		return CodeInvalidArgument
+	case http.StatusGone:
+		return CodeNotFound
+	}
+	if status < 400 {
+		return CodeNone
+	}
+	for c, s := range codeStatuses {
+		if s == status && c != CodeUnknown && c != CodeAlreadyExists {
+			return c
+		}
+	}
+	if status < 500 {
+		return CodeInvalidArgument
+	}
+	return CodeUnknown
+}
+
+// WithCode returns err as a ContextError with code set, keeping its title, sub-errors and values.
+func WithCode(err error, code Code) ContextError {
+	ce, got := err.(ContextError)
+	if !got {
+		ce = ContextError{WrappedError: err}
+	}
+	ce.Code = code
+	return ce
+}
@@ -0,0 +1,34 @@
+package zerrors_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/torlangballe/zutil/zerrors"
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func TestCodes(t *testing.T) {
+	ce := zerrors.MakeCodedError(zerrors.CodeNotFound, nil, "no user", errors.New("db miss"))
+	ztesting.Equal(t, zerrors.CodeOf(ce), zerrors.CodeNotFound, "coded")
+	ztesting.Equal(t, zerrors.CodeOf(fmt.Errorf("wrapped: %w", ce)), zerrors.CodeNotFound, "wrapped")
+	outer := zerrors.MakeContextError(nil, "get user", ce)
+	ztesting.Equal(t, zerrors.CodeOf(outer), zerrors.CodeNotFound, "sub-error code")
+	ztesting.Equal(t, zerrors.CodeOf(nil), zerrors.CodeNone, "nil")
+	ztesting.Equal(t, zerrors.CodeOf(errors.New("x")), zerrors.CodeUnknown, "plain")
+	_, err := os.Open("/no/such/file")
+	ztesting.Equal(t, zerrors.CodeOf(err), zerrors.CodeNotFound, "fs")
+	ztesting.Equal(t, zerrors.IsRetryable(errors.Join(errors.New("a"), context.DeadlineExceeded)), true, "joined deadline")
+	ztesting.Equal(t, zerrors.WithCode(errors.New("busy"), zerrors.CodeUnavailable).Error(), "busy", "zerrors.WithCode message")
+
+	for _, c := range []zerrors.Code{zerrors.CodeInvalidArgument, zerrors.CodeNotFound, zerrors.CodeConflict, zerrors.CodeUnauthenticated, zerrors.CodePermissionDenied, zerrors.CodeResourceExhausted, zerrors.CodeUnavailable, zerrors.CodeInternal} {
+		ztesting.Equal(t, zerrors.CodeFromHTTPStatus(c.HTTPStatus()), c, "round trip", c)
+	}
+	ztesting.Equal(t, zerrors.CodeFromHTTPStatus(http.StatusTeapot), zerrors.CodeInvalidArgument, "other 4xx")
+	ztesting.Equal(t, zerrors.CodeFromHTTPStatus(http.StatusOK), zerrors.CodeNone, "ok")
+	ztesting.Equal(t, zerrors.CodeUnauthenticated.IsClientError(), true, "client error")
+}
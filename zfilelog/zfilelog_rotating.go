@@ -0,0 +1,111 @@
+//go:build !js
+
+package zfilelog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/torlangballe/zutil/zfile"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// RotatingFile is an io.Writer that appends to a file at Path. When the file grows beyond MaxBytes,
+// it is renamed to Path.1, with older ones shifted to Path.2 etc, keeping up to Keep old files.
+type RotatingFile struct {
+	Path     string
+	MaxBytes int64
+	Keep     int
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewRotatingFile(fpath string, maxBytes int64, keep int) (*RotatingFile, error) {
+	r := &RotatingFile{Path: fpath, MaxBytes: maxBytes, Keep: keep}
+	err := zfile.MakeDirAllIfNotExists(filepath.Dir(fpath))
+	if err != nil {
+		return nil, zlog.Error("make dir", fpath, err)
+	}
+	err = r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return zlog.Error("open", r.Path, err)
+	}
+	r.file = file
+	r.size = 0
+	info, err := file.Stat()
+	if err == nil {
+		r.size = info.Size()
+	}
+	return nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.MaxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxBytes {
+		err := r.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate renames the current file to Path.1, shifting older files up, and starts a new, empty file.
+func (r *RotatingFile) Rotate() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.rotate()
+}
+
+func (r *RotatingFile) rotate() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	if r.Keep <= 0 {
+		os.Remove(r.Path)
+	} else {
+		os.Remove(oldLogPath(r.Path, r.Keep))
+		for i := r.Keep - 1; i >= 1; i-- {
+			os.Rename(oldLogPath(r.Path, i), oldLogPath(r.Path, i+1))
+		}
+		err := os.Rename(r.Path, oldLogPath(r.Path, 1))
+		if err != nil && !os.IsNotExist(err) {
+			zlog.Error("rename", r.Path, err)
+		}
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func oldLogPath(fpath string, i int) string {
+	return fmt.Sprintf("%s.%d", fpath, i)
+}
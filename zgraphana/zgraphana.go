@@ -1,6 +1,7 @@
 package zgraphana
 
 import (
+	"net/url"
 	"time"
 
 	"github.com/torlangballe/zutil/zfile"
@@ -10,6 +11,7 @@ import (
 )
 
 type Annotation struct {
+	ID           int       `json:"id,omitempty"`
 	DashboardUID string    `json:"dashboardUID"`
 	PanelID      int       `json:"panelId"`
 	Time         time.Time `json:"-"` // is converted to TimeEpocMS
@@ -32,30 +34,64 @@ var (
 	DashboardUID = zkeyvalrpc.NewOption[string]("GraphanaDashboardUID", "")
 )
 
-func SetAnnotation(graphanaURLPrefix string, a Annotation) error {
-	if a.DashboardUID == "" {
-		a.DashboardUID = DashboardUID.Get()
-	}
-	if graphanaURLPrefix == "" {
-		graphanaURLPrefix = URLPrefix.Get()
+// Client calls the Grafana HTTP API at URLPrefix, authorizing with the service account token or API key APIKey.
+type Client struct {
+	URLPrefix string
+	APIKey    string
+}
+
+// NewClient returns a client for the Grafana at urlPrefix, using the URLPrefix and APIKey options if empty.
+func NewClient(urlPrefix, apiKey string) *Client {
+	if urlPrefix == "" {
+		urlPrefix = URLPrefix.Get()
 	}
-	var got GraphanaResult
-	if !a.Time.IsZero() {
-		a.TimeEpocMS = a.Time.UnixMilli()
+	if apiKey == "" {
+		apiKey = APIKey.Get()
 	}
-	if !a.TimeEnd.IsZero() {
-		a.TimeEndEpocMS = a.TimeEnd.UnixMilli()
+	return &Client{URLPrefix: urlPrefix, APIKey: apiKey}
+}
+
+func (c *Client) makeURL(path string, args url.Values) string {
+	surl := zfile.JoinPathParts(c.URLPrefix, path)
+	if len(args) != 0 {
+		surl += "?" + args.Encode()
 	}
-	// zlog.Info("ANNO:", a.Text, a.Time, a.TimeEnd)
-	surl := zfile.JoinPathParts(graphanaURLPrefix, "api/annotations")
+	return surl
+}
+
+func (c *Client) parameters() zhttp.Parameters {
 	params := zhttp.MakeParameters()
-	// params.PrintBody = true
-	key := APIKey.Get()
-	if a.TimeEnd.IsZero() && a.Time.IsZero() || a.DashboardUID == "" || graphanaURLPrefix == "" || key == "" {
+	params.Headers["Authorization"] = "Bearer " + c.APIKey
+	return params
+}
+
+func (c *Client) get(path string, args url.Values, receive any) error {
+	_, err := zhttp.Get(c.makeURL(path, args), c.parameters(), receive)
+	return err
+}
+
+func (c *Client) send(method, path string, send, receive any) error {
+	params := c.parameters()
+	params.Method = method
+	_, err := zhttp.SendBody(c.makeURL(path, nil), params, send, receive)
+	return err
+}
+
+func (c *Client) delete(path string) error {
+	_, err := zhttp.Delete(c.makeURL(path, nil), c.parameters())
+	return err
+}
+
+// SetAnnotation adds a to a dashboard, using the URLPrefix, APIKey and DashboardUID options if empty.
+func SetAnnotation(graphanaURLPrefix string, a Annotation) error {
+	if a.DashboardUID == "" {
+		a.DashboardUID = DashboardUID.Get()
+	}
+	c := NewClient(graphanaURLPrefix, "")
+	if a.TimeEnd.IsZero() && a.Time.IsZero() || a.DashboardUID == "" || c.URLPrefix == "" || c.APIKey == "" {
 		return zlog.Error("Missing parameters for SetAnnotation", a)
 	}
-	params.Headers["Authorization"] = "Bearer " + key
-	_, err := zhttp.Post(surl, params, a, &got)
+	_, err := c.AddAnnotation(a)
 	if zlog.OnError(err, "annot") {
 		return err
 	}
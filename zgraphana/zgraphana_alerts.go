@@ -0,0 +1,73 @@
+package zgraphana
+
+import (
+	"time"
+)
+
+// Alert states, see AlertRule.State and Alert.State.
+const (
+	AlertInactive = "inactive"
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+)
+
+// Alert is an instance of an alert rule, one for each label set its query returns.
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+	ActiveAt    time.Time         `json:"activeAt"`
+	Value       string            `json:"value"`
+}
+
+// AlertRule is a Grafana-managed alert rule and its current state.
+type AlertRule struct {
+	Name           string            `json:"name"`
+	Group          string            `json:"-"`
+	Folder         string            `json:"-"`
+	State          string            `json:"state"`
+	Health         string            `json:"health"`
+	LastError      string            `json:"lastError"`
+	Labels         map[string]string `json:"labels"`
+	Annotations    map[string]string `json:"annotations"`
+	LastEvaluation time.Time         `json:"lastEvaluation"`
+	Alerts         []Alert           `json:"alerts"`
+}
+
+// AlertRules returns all Grafana-managed alert rules with their states, using the Prometheus-compatible rules API.
+func (c *Client) AlertRules() ([]AlertRule, error) {
+	var got struct {
+		Data struct {
+			Groups []struct {
+				Name  string      `json:"name"`
+				File  string      `json:"file"`
+				Rules []AlertRule `json:"rules"`
+			} `json:"groups"`
+		} `json:"data"`
+	}
+	err := c.get("api/prometheus/grafana/api/v1/rules", nil, &got)
+	if err != nil {
+		return nil, err
+	}
+	var rules []AlertRule
+	for _, g := range got.Data.Groups {
+		for _, r := range g.Rules {
+			r.Group = g.Name
+			r.Folder = g.File
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+// FiringAlerts returns the alert rules that are firing.
+func (c *Client) FiringAlerts() ([]AlertRule, error) {
+	rules, err := c.AlertRules()
+	var firing []AlertRule
+	for _, r := range rules {
+		if r.State == AlertFiring {
+			firing = append(firing, r)
+		}
+	}
+	return firing, err
+}
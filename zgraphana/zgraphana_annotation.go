@@ -0,0 +1,88 @@
+package zgraphana
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// AnnotationQuery is what to get with Client.Annotations. Zero fields aren't used.
+type AnnotationQuery struct {
+	From         time.Time
+	To           time.Time
+	DashboardUID string
+	PanelID      int
+	Tags         []string
+	MatchAny     bool // match annotations with any of Tags, rather than all
+	Limit        int  // Grafana returns at most 100 if 0
+}
+
+func (a *Annotation) setEpocs() {
+	if !a.Time.IsZero() {
+		a.TimeEpocMS = a.Time.UnixMilli()
+	}
+	if !a.TimeEnd.IsZero() {
+		a.TimeEndEpocMS = a.TimeEnd.UnixMilli()
+	}
+}
+
+func (a *Annotation) setTimes() {
+	if a.TimeEpocMS != 0 {
+		a.Time = time.UnixMilli(a.TimeEpocMS)
+	}
+	if a.TimeEndEpocMS != 0 && a.TimeEndEpocMS != a.TimeEpocMS {
+		a.TimeEnd = time.UnixMilli(a.TimeEndEpocMS)
+	}
+}
+
+// AddAnnotation adds a, returning its id. It is a region if TimeEnd is set.
+func (c *Client) AddAnnotation(a Annotation) (id int, err error) {
+	a.setEpocs()
+	var got GraphanaResult
+	err = c.send(http.MethodPost, "api/annotations", a, &got)
+	return got.ID, err
+}
+
+// Annotations returns the annotations matching q, newest first.
+func (c *Client) Annotations(q AnnotationQuery) ([]Annotation, error) {
+	args := url.Values{}
+	if !q.From.IsZero() {
+		args.Set("from", strconv.FormatInt(q.From.UnixMilli(), 10))
+	}
+	if !q.To.IsZero() {
+		args.Set("to", strconv.FormatInt(q.To.UnixMilli(), 10))
+	}
+	if q.DashboardUID != "" {
+		args.Set("dashboardUID", q.DashboardUID)
+	}
+	if q.PanelID != 0 {
+		args.Set("panelId", strconv.Itoa(q.PanelID))
+	}
+	for _, t := range q.Tags {
+		args.Add("tags", t)
+	}
+	if q.MatchAny {
+		args.Set("matchAny", "true")
+	}
+	if q.Limit != 0 {
+		args.Set("limit", strconv.Itoa(q.Limit))
+	}
+	var annotations []Annotation
+	err := c.get("api/annotations", args, &annotations)
+	for i := range annotations {
+		annotations[i].setTimes()
+	}
+	return annotations, err
+}
+
+// ResolveAnnotation ends the annotation with id at end, making it a region, for instance when an incident is over.
+func (c *Client) ResolveAnnotation(id int, end time.Time) error {
+	patch := map[string]any{"timeEnd": end.UnixMilli()}
+	return c.send(http.MethodPatch, fmt.Sprint("api/annotations/", id), patch, nil)
+}
+
+func (c *Client) DeleteAnnotation(id int) error {
+	return c.delete(fmt.Sprint("api/annotations/", id))
+}
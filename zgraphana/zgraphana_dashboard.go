@@ -0,0 +1,189 @@
+package zgraphana
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Dashboard is the JSON model of a Grafana dashboard, with the fields needed to generate one.
+// ID is 0 for new dashboards; with a UID, saving with overwrite replaces the existing one.
+type Dashboard struct {
+	ID            int       `json:"id,omitempty"`
+	UID           string    `json:"uid,omitempty"`
+	Title         string    `json:"title"`
+	Tags          []string  `json:"tags,omitempty"`
+	Timezone      string    `json:"timezone,omitempty"`
+	Refresh       string    `json:"refresh,omitempty"` // like "30s"
+	SchemaVersion int       `json:"schemaVersion,omitempty"`
+	Version       int       `json:"version,omitempty"`
+	Time          TimeRange `json:"time"`
+	Panels        []Panel   `json:"panels"`
+}
+
+// TimeRange is a dashboard's default time range, in Grafana's syntax like "now-6h" and "now".
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// GridPos is where a panel is on the dashboard's grid, which is 24 units wide.
+type GridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type DataSourceRef struct {
+	Type string `json:"type,omitempty"`
+	UID  string `json:"uid"`
+}
+
+// Target is a query of a panel, Expr is a PromQL expression for Prometheus data sources.
+type Target struct {
+	RefID        string         `json:"refId"`
+	Expr         string         `json:"expr,omitempty"`
+	LegendFormat string         `json:"legendFormat,omitempty"`
+	Datasource   *DataSourceRef `json:"datasource,omitempty"`
+}
+
+type FieldDefaults struct {
+	Unit     string   `json:"unit,omitempty"` // like "s", "bytes" or "reqps"
+	Decimals *int     `json:"decimals,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+}
+
+type FieldConfig struct {
+	Defaults  FieldDefaults `json:"defaults"`
+	Overrides []any         `json:"overrides"`
+}
+
+// Panel types, see Panel.Type.
+const (
+	PanelTimeSeries = "timeseries"
+	PanelStat       = "stat"
+	PanelGauge      = "gauge"
+	PanelHeatmap    = "heatmap"
+	PanelTable      = "table"
+	PanelText       = "text"
+	PanelRow        = "row"
+)
+
+type Panel struct {
+	ID          int            `json:"id"`
+	Type        string         `json:"type"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	GridPos     GridPos        `json:"gridPos"`
+	Datasource  *DataSourceRef `json:"datasource,omitempty"`
+	Targets     []Target       `json:"targets,omitempty"`
+	FieldConfig *FieldConfig   `json:"fieldConfig,omitempty"`
+	Options     map[string]any `json:"options,omitempty"`
+}
+
+// DashboardMeta is information about a saved dashboard.
+type DashboardMeta struct {
+	URL       string `json:"url"`
+	FolderUID string `json:"folderUid"`
+	Version   int    `json:"version"`
+	Slug      string `json:"slug"`
+}
+
+// DashboardHit is a dashboard found with SearchDashboards.
+type DashboardHit struct {
+	ID        int      `json:"id"`
+	UID       string   `json:"uid"`
+	Title     string   `json:"title"`
+	URL       string   `json:"url"`
+	Tags      []string `json:"tags"`
+	FolderUID string   `json:"folderUid"`
+}
+
+// SaveDashboardResult is the result of SaveDashboard.
+type SaveDashboardResult struct {
+	ID      int    `json:"id"`
+	UID     string `json:"uid"`
+	URL     string `json:"url"`
+	Status  string `json:"status"`
+	Version int    `json:"version"`
+}
+
+// NewDashboard returns an empty dashboard showing the last 6 hours, refreshing every minute.
+func NewDashboard(uid, title string, tags ...string) *Dashboard {
+	return &Dashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          tags,
+		Refresh:       "1m",
+		SchemaVersion: 39,
+		Time:          TimeRange{From: "now-6h", To: "now"},
+		Panels:        []Panel{},
+	}
+}
+
+// AddPanel adds p, giving it the next id, and if its GridPos is empty, a w x h position after the last panel,
+// wrapping to a new row when the 24 unit width is full.
+func (d *Dashboard) AddPanel(p Panel, w, h int) *Panel {
+	var x, y, maxID int
+	if len(d.Panels) != 0 {
+		last := d.Panels[len(d.Panels)-1].GridPos
+		x = last.X + last.W
+		y = last.Y
+		if x+w > 24 {
+			x = 0
+			y += last.H
+		}
+	}
+	for _, dp := range d.Panels {
+		maxID = max(maxID, dp.ID)
+	}
+	p.ID = maxID + 1
+	if p.GridPos == (GridPos{}) {
+		p.GridPos = GridPos{X: x, Y: y, W: w, H: h}
+	}
+	d.Panels = append(d.Panels, p)
+	return &d.Panels[len(d.Panels)-1]
+}
+
+// SaveDashboard creates or updates d in the folder with folderUID, or General if empty.
+// If overwrite is false, it fails if a dashboard with the same uid or title exists and d's Version is out of date.
+func (c *Client) SaveDashboard(d Dashboard, folderUID string, overwrite bool, message string) (SaveDashboardResult, error) {
+	send := map[string]any{
+		"dashboard": d,
+		"folderUid": folderUID,
+		"overwrite": overwrite,
+		"message":   message,
+	}
+	var result SaveDashboardResult
+	err := c.send(http.MethodPost, "api/dashboards/db", send, &result)
+	return result, err
+}
+
+// Dashboard gets the dashboard with uid.
+func (c *Client) Dashboard(uid string) (Dashboard, DashboardMeta, error) {
+	var got struct {
+		Dashboard Dashboard     `json:"dashboard"`
+		Meta      DashboardMeta `json:"meta"`
+	}
+	err := c.get("api/dashboards/uid/"+url.PathEscape(uid), nil, &got)
+	return got.Dashboard, got.Meta, err
+}
+
+func (c *Client) DeleteDashboard(uid string) error {
+	return c.delete("api/dashboards/uid/" + url.PathEscape(uid))
+}
+
+// SearchDashboards finds dashboards with query in the title, and all of tags.
+func (c *Client) SearchDashboards(query string, tags ...string) ([]DashboardHit, error) {
+	args := url.Values{"type": {"dash-db"}}
+	if query != "" {
+		args.Set("query", query)
+	}
+	for _, t := range tags {
+		args.Add("tag", t)
+	}
+	var hits []DashboardHit
+	err := c.get("api/search", args, &hits)
+	return hits, err
+}
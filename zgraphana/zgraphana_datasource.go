@@ -0,0 +1,87 @@
+package zgraphana
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// DataSource types, see DataSource.Type.
+const (
+	DataSourcePrometheus = "prometheus"
+	DataSourceLoki       = "loki"
+	DataSourceInflux     = "influxdb"
+	DataSourcePostgres   = "grafana-postgresql-datasource"
+)
+
+type DataSource struct {
+	ID             int               `json:"id,omitempty"`
+	UID            string            `json:"uid,omitempty"`
+	Name           string            `json:"name"`
+	Type           string            `json:"type"`
+	URL            string            `json:"url"`
+	Access         string            `json:"access"` // "proxy" for the Grafana server to connect, "direct" for the browser
+	IsDefault      bool              `json:"isDefault"`
+	BasicAuth      bool              `json:"basicAuth"`
+	BasicAuthUser  string            `json:"basicAuthUser,omitempty"`
+	Database       string            `json:"database,omitempty"`
+	User           string            `json:"user,omitempty"`
+	JSONData       map[string]any    `json:"jsonData,omitempty"`
+	SecureJSONData map[string]string `json:"secureJsonData,omitempty"` // passwords etc, only sent, never received
+	Version        int               `json:"version,omitempty"`
+}
+
+// Ref returns a reference to ds for panels and targets.
+func (ds DataSource) Ref() *DataSourceRef {
+	return &DataSourceRef{Type: ds.Type, UID: ds.UID}
+}
+
+func (c *Client) DataSources() ([]DataSource, error) {
+	var sources []DataSource
+	err := c.get("api/datasources", nil, &sources)
+	return sources, err
+}
+
+func (c *Client) DataSourceByName(name string) (DataSource, error) {
+	var ds DataSource
+	err := c.get("api/datasources/name/"+url.PathEscape(name), nil, &ds)
+	return ds, err
+}
+
+func (c *Client) DataSourceByUID(uid string) (DataSource, error) {
+	var ds DataSource
+	err := c.get("api/datasources/uid/"+url.PathEscape(uid), nil, &ds)
+	return ds, err
+}
+
+// AddDataSource adds ds, returning it with its ID and UID set.
+func (c *Client) AddDataSource(ds DataSource) (DataSource, error) {
+	var got struct {
+		DataSource DataSource `json:"datasource"`
+	}
+	err := c.send(http.MethodPost, "api/datasources", ds, &got)
+	return got.DataSource, err
+}
+
+// UpdateDataSource replaces the data source with ds.UID with ds.
+func (c *Client) UpdateDataSource(ds DataSource) (DataSource, error) {
+	var got struct {
+		DataSource DataSource `json:"datasource"`
+	}
+	err := c.send(http.MethodPut, "api/datasources/uid/"+url.PathEscape(ds.UID), ds, &got)
+	return got.DataSource, err
+}
+
+// EnsureDataSource adds ds, or updates the data source with the same name, so services can provision their own.
+func (c *Client) EnsureDataSource(ds DataSource) (DataSource, error) {
+	existing, err := c.DataSourceByName(ds.Name)
+	if err != nil || existing.UID == "" {
+		return c.AddDataSource(ds)
+	}
+	ds.ID = existing.ID
+	ds.UID = existing.UID
+	return c.UpdateDataSource(ds)
+}
+
+func (c *Client) DeleteDataSource(uid string) error {
+	return c.delete("api/datasources/uid/" + url.PathEscape(uid))
+}
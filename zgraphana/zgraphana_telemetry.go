@@ -0,0 +1,73 @@
+//go:build server
+
+package zgraphana
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/torlangballe/zutil/ztelemetry"
+)
+
+// unitForMetric guesses a Grafana unit from the Prometheus naming convention of ending with the unit.
+func unitForMetric(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_seconds"):
+		return "s"
+	case strings.HasSuffix(name, "_bytes"):
+		return "bytes"
+	case strings.HasSuffix(name, "_ratio"):
+		return "percentunit"
+	}
+	return ""
+}
+
+// PanelForMetric returns a time series panel for a ztelemetry metric on the Prometheus data source ds.
+// Counters show their rate per second, histograms their 95th percentile and summaries their average, per label set.
+func PanelForMetric(m ztelemetry.Metric, ds *DataSourceRef) Panel {
+	by := strings.Join(m.Labels, ", ")
+	var legend []string
+	for _, l := range m.Labels {
+		legend = append(legend, "{{"+l+"}}")
+	}
+	unit := unitForMetric(m.Name)
+	var expr, title string
+	switch m.Kind {
+	case ztelemetry.MetricCounter:
+		title = m.Name + " per second"
+		expr = fmt.Sprintf("sum by (%s) (rate(%s[5m]))", by, m.Name)
+		if unit == "" {
+			unit = "cps"
+		}
+	case ztelemetry.MetricHistogram:
+		title = m.Name + " 95th percentile"
+		expr = fmt.Sprintf("histogram_quantile(0.95, sum by (%s) (rate(%s_bucket[5m])))", strings.Join(append([]string{"le"}, m.Labels...), ", "), m.Name)
+	case ztelemetry.MetricSummary:
+		title = m.Name + " average"
+		expr = fmt.Sprintf("sum by (%[1]s) (rate(%[2]s_sum[5m])) / sum by (%[1]s) (rate(%[2]s_count[5m]))", by, m.Name)
+	default:
+		title = m.Name
+		expr = m.Name
+	}
+	p := Panel{
+		Type:        PanelTimeSeries,
+		Title:       title,
+		Description: m.Help,
+		Datasource:  ds,
+		Targets:     []Target{{RefID: "A", Expr: expr, LegendFormat: strings.Join(legend, " ")}},
+		FieldConfig: &FieldConfig{Defaults: FieldDefaults{Unit: unit}, Overrides: []any{}},
+	}
+	return p
+}
+
+// TelemetryDashboard returns a dashboard with a panel for each ztelemetry metric made so far whose name starts with prefix,
+// two panels across.
+func TelemetryDashboard(uid, title, prefix string, ds *DataSourceRef) *Dashboard {
+	d := NewDashboard(uid, title, "ztelemetry")
+	for _, m := range ztelemetry.Metrics() {
+		if strings.HasPrefix(m.Name, prefix) {
+			d.AddPanel(PanelForMetric(m, ds), 12, 8)
+		}
+	}
+	return d
+}
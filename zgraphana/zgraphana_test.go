@@ -0,0 +1,178 @@
+//go:build server
+
+package zgraphana
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/torlangballe/zutil/ztelemetry"
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+// standIn is a tiny stand-in for the parts of the Grafana API the client uses.
+type standIn struct {
+	lock        sync.Mutex
+	dashboards  map[string]Dashboard
+	sources     []DataSource
+	annotations []Annotation
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if req.Header.Get("Authorization") != "Bearer key" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/grafana/")
+	reply := func(v any) {
+		json.NewEncoder(w).Encode(v)
+	}
+	switch {
+	case path == "api/dashboards/db" && req.Method == http.MethodPost:
+		var send struct {
+			Dashboard Dashboard `json:"dashboard"`
+		}
+		json.NewDecoder(req.Body).Decode(&send)
+		d := send.Dashboard
+		d.Version = s.dashboards[d.UID].Version + 1
+		s.dashboards[d.UID] = d
+		reply(SaveDashboardResult{UID: d.UID, Status: "success", Version: d.Version, URL: "/d/" + d.UID})
+	case strings.HasPrefix(path, "api/dashboards/uid/"):
+		uid := strings.TrimPrefix(path, "api/dashboards/uid/")
+		d, got := s.dashboards[uid]
+		if !got {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reply(map[string]any{"dashboard": d, "meta": DashboardMeta{Version: d.Version}})
+	case path == "api/search":
+		var hits []DashboardHit
+		for _, d := range s.dashboards {
+			if strings.Contains(d.Title, req.URL.Query().Get("query")) {
+				hits = append(hits, DashboardHit{UID: d.UID, Title: d.Title, Tags: d.Tags})
+			}
+		}
+		reply(hits)
+	case path == "api/datasources" && req.Method == http.MethodPost:
+		var ds DataSource
+		json.NewDecoder(req.Body).Decode(&ds)
+		ds.ID = len(s.sources) + 1
+		ds.UID = "ds" + strconv.Itoa(ds.ID)
+		ds.SecureJSONData = nil
+		s.sources = append(s.sources, ds)
+		reply(map[string]any{"datasource": ds, "id": ds.ID})
+	case strings.HasPrefix(path, "api/datasources/uid/") && req.Method == http.MethodPut:
+		var ds DataSource
+		json.NewDecoder(req.Body).Decode(&ds)
+		for i := range s.sources {
+			if s.sources[i].UID == ds.UID {
+				s.sources[i] = ds
+			}
+		}
+		reply(map[string]any{"datasource": ds})
+	case strings.HasPrefix(path, "api/datasources/name/"):
+		for _, ds := range s.sources {
+			if ds.Name == strings.TrimPrefix(path, "api/datasources/name/") {
+				reply(ds)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case path == "api/annotations" && req.Method == http.MethodPost:
+		var a Annotation
+		json.NewDecoder(req.Body).Decode(&a)
+		a.ID = len(s.annotations) + 1
+		s.annotations = append(s.annotations, a)
+		reply(GraphanaResult{ID: a.ID, Message: "Annotation added"})
+	case path == "api/annotations":
+		from, _ := strconv.ParseInt(req.URL.Query().Get("from"), 10, 64)
+		tags := req.URL.Query()["tags"]
+		var found []Annotation
+		for _, a := range s.annotations {
+			if a.TimeEpocMS >= from && (len(tags) == 0 || strings.Join(a.Tags, ",") == strings.Join(tags, ",")) {
+				found = append(found, a)
+			}
+		}
+		reply(found)
+	case strings.HasPrefix(path, "api/annotations/") && req.Method == http.MethodPatch:
+		id, _ := strconv.Atoi(strings.TrimPrefix(path, "api/annotations/"))
+		var patch struct {
+			TimeEnd int64 `json:"timeEnd"`
+		}
+		json.NewDecoder(req.Body).Decode(&patch)
+		s.annotations[id-1].TimeEndEpocMS = patch.TimeEnd
+		reply(GraphanaResult{Message: "Annotation patched"})
+	case path == "api/prometheus/grafana/api/v1/rules":
+		w.Write([]byte(`{"status":"success","data":{"groups":[{"name":"servers","file":"Ops","rules":[
+			{"name":"High latency","state":"firing","health":"ok","alerts":[{"labels":{"host":"a"},"state":"Alerting","value":"1.5"}]},
+			{"name":"Disk full","state":"inactive","health":"ok"}]}]}}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(&standIn{dashboards: map[string]Dashboard{}})
+	defer server.Close()
+	c := NewClient(server.URL+"/grafana", "key")
+
+	ds, err := c.EnsureDataSource(DataSource{Name: "Prometheus", Type: DataSourcePrometheus, URL: "http://localhost:9090", Access: "proxy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, ds.UID, "ds1", "added data source")
+	ds.URL = "http://prometheus:9090"
+	ds, err = c.EnsureDataSource(ds)
+	ztesting.Equal(t, err, nil, "ensure again")
+	sources, _ := c.DataSourceByName("Prometheus")
+	ztesting.Equal(t, sources.URL, "http://prometheus:9090", "updated data source")
+
+	ztelemetry.NewCounterVec("zgraphana_test_requests_total", "Requests", "path")
+	ztelemetry.NewHistogramVec("zgraphana_test_latency_seconds", nil, "Latency", "path")
+	d := TelemetryDashboard("svc", "Service", "zgraphana_test_", ds.Ref())
+	ztesting.Equal(t, len(d.Panels), 2, "panels")
+	ztesting.Equal(t, d.Panels[0].Targets[0].Expr, "sum by (path) (rate(zgraphana_test_requests_total[5m]))", "counter expr")
+	ztesting.Equal(t, d.Panels[1].GridPos, GridPos{X: 12, Y: 0, W: 12, H: 8}, "second panel beside first")
+	ztesting.Equal(t, d.Panels[1].FieldConfig.Defaults.Unit, "s", "unit from name")
+	result, err := c.SaveDashboard(*d, "", true, "generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, result.Version, 1, "saved")
+	got, meta, err := c.Dashboard("svc")
+	ztesting.Equal(t, err, nil, "get dashboard")
+	ztesting.Equal(t, len(got.Panels), 2, "got panels")
+	ztesting.Equal(t, meta.Version, 1, "meta version")
+	hits, _ := c.SearchDashboards("Serv")
+	ztesting.Equal(t, len(hits), 1, "search")
+	_, _, err = c.Dashboard("missing")
+	ztesting.Equal(t, err != nil, true, "missing dashboard")
+
+	start := time.Now().Add(-time.Hour)
+	id, err := c.AddAnnotation(Annotation{DashboardUID: "svc", Time: start, Tags: []string{"deploy"}, Text: "v2"})
+	ztesting.Equal(t, err, nil, "add annotation")
+	c.AddAnnotation(Annotation{DashboardUID: "svc", Time: start.Add(-time.Hour), Tags: []string{"deploy"}, Text: "v1"})
+	err = c.ResolveAnnotation(id, start.Add(time.Minute))
+	ztesting.Equal(t, err, nil, "resolve")
+	found, err := c.Annotations(AnnotationQuery{From: start, Tags: []string{"deploy"}})
+	ztesting.Equal(t, err, nil, "query annotations")
+	ztesting.Equal(t, len(found), 1, "annotations in range")
+	ztesting.Equal(t, found[0].TimeEnd.Sub(found[0].Time), time.Minute, "resolved region")
+
+	firing, err := c.FiringAlerts()
+	ztesting.Equal(t, err, nil, "alerts")
+	ztesting.Equal(t, len(firing), 1, "firing")
+	ztesting.Equal(t, firing[0].Folder, "Ops", "folder")
+	ztesting.Equal(t, firing[0].Alerts[0].Labels["host"], "a", "alert labels")
+
+	_, err = NewClient(server.URL+"/grafana", "wrong").DataSources()
+	ztesting.Equal(t, err != nil, true, "unauthorized")
+}
@@ -63,6 +63,11 @@ func makeCacheFoldername(secondsPerPixel int, grapherName string) string {
 	return fmt.Sprintf("%s-%d-%s", CachePostfix, secondsPerPixel, grapherName)
 }
 
+// makePyramidFoldername is where a Pyramid serves tiles for any SecondsPerPixel, as <folder>/<secondsPerPixel>/<storage name>.
+func makePyramidFoldername(grapherName string) string {
+	return fmt.Sprintf("%s-pyramid-%s", CachePostfix, grapherName)
+}
+
 func calculateWindowStart(t time.Time, windowMinutes int) time.Time {
 	t = t.UTC()
 	if windowMinutes <= 24*60 {
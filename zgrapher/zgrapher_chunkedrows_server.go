@@ -0,0 +1,57 @@
+//go:build server && !js
+
+package zgrapher
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/torlangballe/zutil/zchunkedrows"
+)
+
+// ChunkedRowsSource is a SeriesSource that gets points from rows in a zchunkedrows.ChunkedRows,
+// ordered by a UnixMicro time at OrdererOffset, as set in its LSOpts.
+// ValueFromRow returns the value of a job's series for a row, or false if the row isn't for it.
+type ChunkedRowsSource struct {
+	Rows          *zchunkedrows.ChunkedRows
+	OrdererOffset int
+	ValueFromRow  func(job *Job, seriesID string, row []byte) (float64, bool)
+}
+
+func (s *ChunkedRowsSource) timeFromRow(row []byte) time.Time {
+	return time.UnixMicro(int64(binary.LittleEndian.Uint64(row[s.OrdererOffset:])))
+}
+
+func (s *ChunkedRowsSource) SeriesPoints(job *Job, seriesID string, start, end time.Time) ([]SeriesPoint, error) {
+	row, chunkIndex, rowIndex, _, err := s.Rows.BinarySearch(start.UnixMicro(), false)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	if rowIndex > 0 { // BinarySearch can return the row after start when not exact, so start one before that to be sure
+		rowIndex--
+	}
+	var points []SeriesPoint
+	var iterErr error
+	_, err = s.Rows.Iterate(chunkIndex, rowIndex, true, "", nil, func(row []byte, chunkIndex, index int, err error) bool {
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		t := s.timeFromRow(row)
+		if t.Before(start) {
+			return true
+		}
+		if !t.Before(end) {
+			return false
+		}
+		v, got := s.ValueFromRow(job, seriesID, row)
+		if got {
+			points = append(points, SeriesPoint{Time: t, Value: v})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return points, iterErr
+}
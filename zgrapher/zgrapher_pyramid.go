@@ -0,0 +1,66 @@
+package zgrapher
+
+import (
+	"image"
+	"image/color"
+	"time"
+)
+
+// ClosestSecondsPerPixel returns the resolution in levels to render secondsPerPixel from.
+// This is the coarsest level that is at least as fine as secondsPerPixel, so no detail is lost,
+// or the finest level if they are all coarser. levels must be sorted from finest to coarsest.
+func ClosestSecondsPerPixel(levels []int, secondsPerPixel int) int {
+	if len(levels) == 0 {
+		return secondsPerPixel
+	}
+	best := levels[0]
+	for _, l := range levels {
+		if l <= secondsPerPixel {
+			best = l
+		}
+	}
+	return best
+}
+
+// resampleTile draws the columns of dst covering start to end for job at base's resolution,
+// from the tiles of a level with srcJob's window and srcBase's resolution.
+// getTile returns the level's tile starting at canvasStart, or nil if it has none.
+// Each destination pixel gets the most opaque source pixel it covers, so thin lines and spikes survive downsampling.
+func resampleTile(dst *image.NRGBA, job *Job, base *GrapherBase, srcJob Job, srcBase *GrapherBase, start, end time.Time, getTile func(canvasStart time.Time) *image.NRGBA) {
+	w := dst.Bounds().Dx()
+	h := dst.Bounds().Dy()
+	x0 := max(0, job.XForTime(base, start))
+	x1 := min(w-1, job.XForTime(base, end))
+	step := time.Duration(srcBase.SecondsPerPixel) * time.Second
+	tiles := map[time.Time]*image.NRGBA{}
+	for x := x0; x <= x1; x++ {
+		for y := 0; y < h; y++ {
+			dst.SetNRGBA(x, y, color.NRGBA{})
+		}
+		t0 := job.TimeForX(base, x)
+		t1 := job.TimeForX(base, x+1)
+		for t := t0.Truncate(step); t.Before(t1); t = t.Add(step) {
+			canvasStart := calculateWindowStart(t, srcJob.WindowMinutes)
+			tile, got := tiles[canvasStart]
+			if !got {
+				tile = getTile(canvasStart)
+				tiles[canvasStart] = tile
+			}
+			if tile == nil {
+				continue
+			}
+			srcJob.CanvasStartTime = canvasStart
+			sx := srcJob.XForTime(srcBase, t)
+			if sx < 0 || sx >= tile.Bounds().Dx() {
+				continue
+			}
+			sh := tile.Bounds().Dy()
+			for y := 0; y < h; y++ {
+				c := tile.NRGBAAt(sx, y*sh/h)
+				if c.A != 0 && c.A >= dst.NRGBAAt(x, y).A {
+					dst.SetNRGBA(x, y, c)
+				}
+			}
+		}
+	}
+}
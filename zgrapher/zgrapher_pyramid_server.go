@@ -0,0 +1,180 @@
+//go:build server && !js
+
+package zgrapher
+
+import (
+	"image"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/torlangballe/zui/zimage"
+	"github.com/torlangballe/zutil/zfile"
+	"github.com/torlangballe/zutil/zgeo"
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zrest"
+	"github.com/torlangballe/zutil/zstr"
+)
+
+type PyramidLevel struct {
+	SecondsPerPixel int
+	WindowMinutes   int // the window of this level's tiles, if 0 the WindowMinutes of jobs added is used
+	DeleteDays      int // how long this level's tiles are kept
+}
+
+// Pyramid is a set of Graphers rendering the same jobs at increasing SecondsPerPixel.
+// Only the finest level, Finest(), draws from data, using its Draw or SeriesSource and the jobs' Series.
+// Each coarser level is downsampled from the cached tiles of the level below it.
+// It also serves tiles for any SecondsPerPixel, resampled from the closest level, for a GraphView with Pyramid set.
+type Pyramid struct {
+	Levels []*Grapher // finest first
+
+	specs []PyramidLevel
+}
+
+const maxPyramidServeWidth = 10000
+
+func NewPyramid(router *mux.Router, grapherName, folderPath string, levels []PyramidLevel) *Pyramid {
+	zlog.Assert(len(levels) != 0)
+	p := &Pyramid{}
+	p.specs = slices.Clone(levels)
+	slices.SortFunc(p.specs, func(a, b PyramidLevel) int {
+		return a.SecondsPerPixel - b.SecondsPerPixel
+	})
+	for i, spec := range p.specs {
+		g := NewGrapher(router, spec.DeleteDays, grapherName, folderPath, spec.SecondsPerPixel)
+		if i > 0 {
+			finer := p.Levels[i-1]
+			g.Draw = func(img *image.NRGBA, job *SJob, start, end time.Time, first bool) {
+				g.drawFromFiner(finer, img, job, start, end)
+			}
+		}
+		p.Levels = append(p.Levels, g)
+	}
+	spath := zstr.Concat("/", "zgrapher/", makePyramidFoldername(grapherName))
+	zrest.AddSubHandler(router, spath, p)
+	return p
+}
+
+// Finest returns the level that draws from data; set its Draw or SeriesSource before adding jobs.
+func (p *Pyramid) Finest() *Grapher {
+	return p.Levels[0]
+}
+
+func (p *Pyramid) SecondsPerPixels() []int {
+	var all []int
+	for _, g := range p.Levels {
+		all = append(all, g.SecondsPerPixel)
+	}
+	return all
+}
+
+// AddJob adds job to all levels. Coarser levels don't get its Series, as they are drawn from the level below.
+func (p *Pyramid) AddJob(job SJob) {
+	for i, g := range p.Levels {
+		j := job
+		if p.specs[i].WindowMinutes != 0 {
+			j.WindowMinutes = p.specs[i].WindowMinutes
+		}
+		if i > 0 {
+			j.Series = nil
+		}
+		g.AddJob(j)
+	}
+}
+
+func (p *Pyramid) RemoveJob(jobID string) {
+	for _, g := range p.Levels {
+		g.RemoveJob(jobID)
+	}
+}
+
+func (p *Pyramid) HasJob(jobID string) bool {
+	return p.Finest().HasJob(jobID)
+}
+
+// drawFromFiner draws job's columns from start to end by downsampling finer's tiles.
+// It starts a pixel before start, as finer might not have saved that column yet when it was last drawn.
+func (g *Grapher) drawFromFiner(finer *Grapher, img *image.NRGBA, job *SJob, start, end time.Time) {
+	fj, got := finer.jobs.Get(job.ID)
+	if !got {
+		return
+	}
+	src := *fj
+	start = start.Add(-time.Duration(g.SecondsPerPixel) * time.Second)
+	resampleTile(img, &job.Job, &g.GrapherBase, src.Job, &finer.GrapherBase, start, end, func(canvasStart time.Time) *image.NRGBA {
+		return finer.tileImage(src, canvasStart)
+	})
+}
+
+// tileImage returns job's tile starting at canvasStart from the cache, rendering it first if it is an old part not cached yet.
+func (g *Grapher) tileImage(job SJob, canvasStart time.Time) *image.NRGBA {
+	fpath, _ := g.cache.GetPathForName(job.storageNameForTime(canvasStart))
+	if zfile.NotExists(fpath) {
+		if !canvasStart.Before(job.CanvasStartTime) {
+			return nil // the current tile isn't saved yet
+		}
+		g.renderOldPart(job, canvasStart)
+		if zfile.NotExists(fpath) {
+			return nil
+		}
+	}
+	img, _, err := zimage.GoImageFromFile(fpath)
+	if zlog.OnError(err, fpath) {
+		return nil
+	}
+	return zimage.GoImageToNRGBA(img)
+}
+
+// ServeHTTP serves a tile at <secondsPerPixel>/<storage name>, resampled from the closest level.
+func (p *Pyramid) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	zrest.AddCORSHeaders(w, req)
+	dir, name := path.Split(req.URL.Path)
+	spp, err := strconv.Atoi(path.Base(dir))
+	if err != nil || spp <= 0 {
+		zrest.ReturnAndPrintError(w, req, http.StatusBadRequest, "bad seconds per pixel:", req.URL.Path)
+		return
+	}
+	if !zstr.HasSuffix(name, ".png", &name) {
+		zrest.ReturnAndPrintError(w, req, http.StatusBadRequest, "not png:", req.URL.Path)
+		return
+	}
+	date := zstr.TailUntilWithRest(name, "@", &name)
+	sid := zstr.HeadUntil(name, "_")
+	window, _ := strconv.Atoi(zstr.TailUntil(name, "_"))
+	t, err := time.ParseInLocation("2006-01-02T1504", date, time.UTC)
+	if err != nil || sid == "" || window <= 0 {
+		zrest.ReturnAndPrintError(w, req, http.StatusBadRequest, "bad tile name:", req.URL.Path, err)
+		return
+	}
+	base := GrapherBase{SecondsPerPixel: spp}
+	job := Job{ID: sid, WindowMinutes: window, CanvasStartTime: t}
+	if job.PixelWidth(&base) > maxPyramidServeWidth {
+		zrest.ReturnAndPrintError(w, req, http.StatusBadRequest, "tile too wide:", req.URL.Path)
+		return
+	}
+	i := slices.Index(p.SecondsPerPixels(), ClosestSecondsPerPixel(p.SecondsPerPixels(), spp))
+	g := p.Levels[i]
+	sj, got := g.jobs.Get(sid)
+	if !got {
+		zrest.ReturnAndPrintError(w, req, http.StatusNotFound, "no job:", sid)
+		return
+	}
+	src := *sj
+	job.PixelHeight = src.PixelHeight
+	img := image.NewNRGBA(zgeo.Rect{Size: job.PixelSize(&base)}.GoRect())
+	end := t.Add(time.Duration(window) * time.Minute)
+	resampleTile(img, &job, &base, src.Job, &g.GrapherBase, t, end, func(canvasStart time.Time) *image.NRGBA {
+		return g.tileImage(src, canvasStart)
+	})
+	data, err := zimage.GoImagePNGData(img)
+	if err != nil {
+		zrest.ReturnAndPrintError(w, req, http.StatusInternalServerError, "png:", err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(data)
+}
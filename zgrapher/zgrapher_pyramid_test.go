@@ -0,0 +1,54 @@
+package zgrapher
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func TestClosestSecondsPerPixel(t *testing.T) {
+	levels := []int{1, 10, 60, 600}
+	ztesting.Equal(t, ClosestSecondsPerPixel(levels, 1), 1)
+	ztesting.Equal(t, ClosestSecondsPerPixel(levels, 30), 10)
+	ztesting.Equal(t, ClosestSecondsPerPixel(levels, 60), 60)
+	ztesting.Equal(t, ClosestSecondsPerPixel(levels, 3600), 600)
+	ztesting.Equal(t, ClosestSecondsPerPixel([]int{10, 60}, 5), 10)
+}
+
+func TestResampleTile(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	fineBase := &GrapherBase{SecondsPerPixel: 1}
+	fineJob := Job{ID: "test", WindowMinutes: 1, PixelHeight: 2}
+	red := color.NRGBA{R: 255, A: 255}
+	tiles := map[time.Time]*image.NRGBA{}
+	for m := 0; m < 10; m++ {
+		img := image.NewNRGBA(image.Rect(0, 0, 60, 2))
+		img.SetNRGBA(m, 0, red) // a one-second spike in minute m
+		tiles[start.Add(time.Duration(m)*time.Minute)] = img
+	}
+	getTile := func(canvasStart time.Time) *image.NRGBA {
+		return tiles[canvasStart]
+	}
+	coarseBase := &GrapherBase{SecondsPerPixel: 10}
+	coarseJob := &Job{ID: "test", WindowMinutes: 10, PixelHeight: 2, CanvasStartTime: start}
+	dst := image.NewNRGBA(image.Rect(0, 0, 60, 2))
+	resampleTile(dst, coarseJob, coarseBase, fineJob, fineBase, start, start.Add(10*time.Minute), getTile)
+	for x := 0; x < 60; x++ {
+		spike := x%6 == 0 && x/6 < 10
+		ztesting.Equal(t, dst.NRGBAAt(x, 0) == red, spike, "spike kept when downsampling", x)
+		ztesting.Equal(t, dst.NRGBAAt(x, 1).A, uint8(0), "bottom row empty", x)
+	}
+
+	upBase := &GrapherBase{SecondsPerPixel: 1}
+	upJob := &Job{ID: "test", WindowMinutes: 1, PixelHeight: 4, CanvasStartTime: start}
+	up := image.NewNRGBA(image.Rect(0, 0, 60, 4))
+	coarseTiles := map[time.Time]*image.NRGBA{start: dst}
+	resampleTile(up, upJob, upBase, *coarseJob, coarseBase, start, start.Add(time.Minute), func(canvasStart time.Time) *image.NRGBA {
+		return coarseTiles[canvasStart]
+	})
+	ztesting.Equal(t, up.NRGBAAt(9, 1) == red, true, "upsampled first column")
+	ztesting.Equal(t, up.NRGBAAt(10, 1).A, uint8(0), "upsampled second column")
+}
@@ -0,0 +1,254 @@
+package zgrapher
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/torlangballe/zutil/zfloat"
+	"github.com/torlangballe/zutil/zgeo"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+type SeriesKind string
+
+const (
+	SeriesLine   SeriesKind = "line"   // a line through the aggregated value of each column
+	SeriesArea   SeriesKind = "area"   // like line, but filled down to the bottom of the band
+	SeriesHeat   SeriesKind = "heat"   // fills the band in each column with Color mixed towards MaxColor by value
+	SeriesEvents SeriesKind = "events" // a vertical marker across the band for each column with any points
+)
+
+type SeriesAggregate string
+
+const (
+	AggregateMax   SeriesAggregate = "max" // the default
+	AggregateMin   SeriesAggregate = "min"
+	AggregateMean  SeriesAggregate = "mean"
+	AggregateSum   SeriesAggregate = "sum"
+	AggregateLast  SeriesAggregate = "last"
+	AggregateCount SeriesAggregate = "count"
+)
+
+type SeriesPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// SeriesSource returns the points of a job's series from start up to, but not including end, in time order.
+type SeriesSource interface {
+	SeriesPoints(job *Job, seriesID string, start, end time.Time) ([]SeriesPoint, error)
+}
+
+// SeriesSourceFunc allows a function to be used as a SeriesSource.
+type SeriesSourceFunc func(job *Job, seriesID string, start, end time.Time) ([]SeriesPoint, error)
+
+// ValueAxis maps values to the height of a series' band. If Max <= Min, 0 to 1 is used.
+// Grid values are drawn as horizontal lines in GridColor behind the series.
+type ValueAxis struct {
+	Min       float64
+	Max       float64
+	Grid      []float64
+	GridColor zgeo.Color
+}
+
+// Series is one of the data series a job can have, rendered into its image instead of, or before, a Grapher's Draw function.
+// Each series gets its points from a SeriesSource, aggregates them per pixel column, and draws them
+// as a line, filled area, heat-strip or event markers within a horizontal band of the image.
+type Series struct {
+	ID        string
+	Kind      SeriesKind
+	Color     zgeo.Color
+	MaxColor  zgeo.Color // for SeriesHeat, the color at Axis.Max; Color is used at Axis.Min
+	Axis      ValueAxis
+	Aggregate SeriesAggregate
+	TopY      int          // top of the band the series is drawn in
+	Height    int          // height of band, if 0, it goes to the bottom of image
+	Source    SeriesSource // if nil, Grapher.SeriesSource is used
+}
+
+func (f SeriesSourceFunc) SeriesPoints(job *Job, seriesID string, start, end time.Time) ([]SeriesPoint, error) {
+	return f(job, seriesID, start, end)
+}
+
+func (a ValueAxis) fraction(v float64) float64 {
+	min, max := a.Min, a.Max
+	if max <= min {
+		min, max = 0, 1
+	}
+	return zfloat.Clamped((v-min)/(max-min), 0, 1)
+}
+
+func (s *Series) band(img *image.NRGBA) (top, bottom int) {
+	top = max(0, s.TopY)
+	bottom = img.Bounds().Dy() - 1
+	if s.Height > 0 {
+		bottom = min(bottom, top+s.Height-1)
+	}
+	return top, bottom
+}
+
+func (s *Series) yForValue(v float64, top, bottom int) int {
+	f := s.Axis.fraction(v)
+	return top + int(math.Round((1-f)*float64(bottom-top)))
+}
+
+// DrawSeries renders series into img for the pixel columns of job covering start to end.
+// The columns are cleared first, and each column is drawn using all points within its whole time span,
+// so rendering a window incrementally gives the same image as rendering it in one go.
+// A line or area also gets the column before start, so it joins up with what is already drawn.
+func (b *GrapherBase) DrawSeries(img *image.NRGBA, job *Job, series []Series, start, end time.Time) error {
+	w := img.Bounds().Dx()
+	x0 := max(0, job.XForTime(b, start))
+	x1 := min(w-1, job.XForTime(b, end))
+	if x1 < x0 {
+		return nil
+	}
+	clear := color.NRGBA{}
+	for x := x0; x <= x1; x++ {
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			img.SetNRGBA(x, y, clear)
+		}
+	}
+	var firstErr error
+	for _, s := range series {
+		if s.Source == nil {
+			zlog.Error("series has no source:", job.ID, s.ID)
+			continue
+		}
+		from := x0
+		if from > 0 && (s.Kind == SeriesLine || s.Kind == SeriesArea || s.Kind == "") {
+			from--
+		}
+		points, err := s.Source.SeriesPoints(job, s.ID, job.TimeForX(b, from), job.TimeForX(b, x1+1))
+		if err != nil {
+			zlog.Error("get series points:", job.ID, s.ID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		values, has := aggregateColumns(b, job, &s, points, from, x1)
+		s.draw(img, values, has, from, x0)
+	}
+	return firstErr
+}
+
+func aggregateColumns(b *GrapherBase, job *Job, s *Series, points []SeriesPoint, x0, x1 int) (values []float64, has []bool) {
+	n := x1 - x0 + 1
+	values = make([]float64, n)
+	has = make([]bool, n)
+	counts := make([]int, n)
+	for _, p := range points {
+		i := job.XForTime(b, p.Time) - x0
+		if i < 0 || i >= n {
+			continue
+		}
+		v := p.Value
+		c := counts[i]
+		counts[i]++
+		has[i] = true
+		if c == 0 {
+			if s.Aggregate == AggregateCount {
+				v = 1
+			}
+			values[i] = v
+			continue
+		}
+		switch s.Aggregate {
+		case AggregateMin:
+			values[i] = math.Min(values[i], v)
+		case AggregateMean:
+			values[i] += (v - values[i]) / float64(c+1)
+		case AggregateSum:
+			values[i] += v
+		case AggregateLast:
+			values[i] = v
+		case AggregateCount:
+			values[i]++
+		default:
+			values[i] = math.Max(values[i], v)
+		}
+	}
+	return values, has
+}
+
+// draw draws values, which start at column from. Columns before drawStart are only used to join lines.
+func (s *Series) draw(img *image.NRGBA, values []float64, has []bool, from, drawStart int) {
+	top, bottom := s.band(img)
+	if bottom < top {
+		return
+	}
+	col := goNRGBA(s.Color)
+	if s.Axis.GridColor.Valid {
+		gcol := goNRGBA(s.Axis.GridColor)
+		for _, g := range s.Axis.Grid {
+			y := s.yForValue(g, top, bottom)
+			for i := drawStart - from; i < len(values); i++ {
+				blendPixel(img, from+i, y, gcol)
+			}
+		}
+	}
+	prevY := -1
+	for i, v := range values {
+		x := from + i
+		if !has[i] {
+			prevY = -1
+			continue
+		}
+		y := s.yForValue(v, top, bottom)
+		if x < drawStart {
+			prevY = y
+			continue
+		}
+		switch s.Kind {
+		case SeriesHeat:
+			hcol := goNRGBA(s.Color.Mixed(s.MaxColor, float32(s.Axis.fraction(v))))
+			strokeVert(img, x, top, bottom, hcol)
+		case SeriesEvents:
+			strokeVert(img, x, top, bottom, col)
+		case SeriesArea:
+			strokeVert(img, x, y, bottom, col)
+		default:
+			y1, y2 := y, y
+			if prevY != -1 {
+				y1, y2 = min(y, prevY), max(y, prevY)
+			}
+			strokeVert(img, x, y1, y2, col)
+		}
+		prevY = y
+	}
+}
+
+func goNRGBA(c zgeo.Color) color.NRGBA {
+	if !c.Valid {
+		return color.NRGBA{}
+	}
+	return color.NRGBAModel.Convert(c.GoColor()).(color.NRGBA)
+}
+
+func strokeVert(img *image.NRGBA, x, y1, y2 int, col color.NRGBA) {
+	for y := y1; y <= y2; y++ {
+		blendPixel(img, x, y, col)
+	}
+}
+
+// blendPixel draws col over what is at x, y, so semi-transparent series and grid lines show what is below.
+func blendPixel(img *image.NRGBA, x, y int, col color.NRGBA) {
+	if col.A == 0 {
+		return
+	}
+	if col.A == 255 {
+		img.SetNRGBA(x, y, col)
+		return
+	}
+	dst := img.NRGBAAt(x, y)
+	sa := float64(col.A) / 255
+	da := float64(dst.A) / 255
+	oa := sa + da*(1-sa)
+	mix := func(s, d uint8) uint8 {
+		return uint8(math.Round((float64(s)*sa + float64(d)*da*(1-sa)) / oa))
+	}
+	img.SetNRGBA(x, y, color.NRGBA{R: mix(col.R, dst.R), G: mix(col.G, dst.G), B: mix(col.B, dst.B), A: uint8(math.Round(oa * 255))})
+}
@@ -0,0 +1,76 @@
+package zgrapher
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/torlangballe/zutil/zgeo"
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func seriesTestSource(points []SeriesPoint) SeriesSource {
+	return SeriesSourceFunc(func(job *Job, seriesID string, start, end time.Time) ([]SeriesPoint, error) {
+		var out []SeriesPoint
+		for _, p := range points {
+			if !p.Time.Before(start) && p.Time.Before(end) {
+				out = append(out, p)
+			}
+		}
+		return out, nil
+	})
+}
+
+func isDrawn(img *image.NRGBA, x, y int) bool {
+	return img.NRGBAAt(x, y).A != 0
+}
+
+func TestDrawSeries(t *testing.T) {
+	base := &GrapherBase{SecondsPerPixel: 1}
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	job := &Job{ID: "test", WindowMinutes: 1, PixelHeight: 11, CanvasStartTime: start}
+	var points []SeriesPoint
+	for i := 0; i < 60; i++ {
+		at := start.Add(time.Duration(i) * time.Second)
+		points = append(points, SeriesPoint{Time: at, Value: float64(i % 11)})
+		points = append(points, SeriesPoint{Time: at.Add(time.Second / 2), Value: 0})
+	}
+	series := []Series{
+		{ID: "line", Kind: SeriesLine, Color: zgeo.ColorRed, Axis: ValueAxis{Min: 0, Max: 10}, Source: seriesTestSource(points)},
+		{ID: "events", Kind: SeriesEvents, Color: zgeo.ColorBlue, TopY: 0, Height: 1, Source: seriesTestSource(points[:2])},
+	}
+	end := start.Add(time.Minute)
+
+	whole := image.NewNRGBA(image.Rect(0, 0, 60, 11))
+	err := base.DrawSeries(whole, job, series, start, end)
+	ztesting.Equal(t, err, nil, "draw error")
+	ztesting.Equal(t, isDrawn(whole, 0, 0), true, "event marker")
+	ztesting.Equal(t, isDrawn(whole, 1, 0), false, "no event marker")
+	ztesting.Equal(t, isDrawn(whole, 5, 5), true, "max of column 5 is 5")
+	ztesting.Equal(t, isDrawn(whole, 5, 3), false, "above line in column 5")
+	ztesting.Equal(t, isDrawn(whole, 11, 10), true, "line joined down from column 10 to 11")
+	ztesting.Equal(t, isDrawn(whole, 11, 1), true, "line joined down from column 10 to 11")
+
+	parts := image.NewNRGBA(image.Rect(0, 0, 60, 11))
+	for s := 0; s < 60; s += 7 {
+		from := start.Add(time.Duration(s) * time.Second)
+		base.DrawSeries(parts, job, series, from, from.Add(7*time.Second))
+	}
+	ztesting.Equal(t, string(parts.Pix), string(whole.Pix), "incremental drawing same as whole")
+}
+
+func TestDrawSeriesHeat(t *testing.T) {
+	base := &GrapherBase{SecondsPerPixel: 1}
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	job := &Job{ID: "test", WindowMinutes: 1, PixelHeight: 4, CanvasStartTime: start}
+	points := []SeriesPoint{{Time: start, Value: 0}, {Time: start.Add(time.Second), Value: 1}}
+	series := []Series{
+		{ID: "heat", Kind: SeriesHeat, Color: zgeo.ColorBlack, MaxColor: zgeo.ColorWhite, TopY: 2, Source: seriesTestSource(points)},
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 60, 4))
+	base.DrawSeries(img, job, series, start, start.Add(time.Minute))
+	ztesting.Equal(t, isDrawn(img, 0, 1), false, "above heat band")
+	ztesting.Equal(t, img.NRGBAAt(0, 3).R, uint8(0), "heat min color")
+	ztesting.Equal(t, img.NRGBAAt(1, 2).R, uint8(255), "heat max color")
+	ztesting.Equal(t, isDrawn(img, 2, 3), false, "no heat data")
+}
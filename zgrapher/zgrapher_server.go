@@ -25,7 +25,8 @@ import (
 
 type SJob struct {
 	Job
-	AlwaysDrawnPixelY int // alwaysDrawnPixelY is a pixel if clear on a column, hasn't been drawn yet
+	AlwaysDrawnPixelY int      // alwaysDrawnPixelY is a pixel if clear on a column, hasn't been drawn yet
+	Series            []Series // if set, these are drawn with DrawSeries before Draw is called
 
 	image      *image.NRGBA
 	drawnUntil time.Time
@@ -33,7 +34,8 @@ type SJob struct {
 
 type Grapher struct {
 	GrapherBase
-	Draw func(img *image.NRGBA, job *SJob, start, end time.Time, first bool) // Called on the second for SecondsPerPixel. Set Draw to render to the image between start and end. If first is true, can get data for multiple jobs
+	Draw         func(img *image.NRGBA, job *SJob, start, end time.Time, first bool) // Called on the second for SecondsPerPixel. Set Draw to render to the image between start and end. If first is true, can get data for multiple jobs
+	SeriesSource SeriesSource                                                        // Used for a job's Series that have no Source of their own
 
 	jobs    zmap.LockMap[string, *SJob]
 	cache   *zfilecache.Cache
@@ -168,7 +170,7 @@ func (g *Grapher) AddJob(job SJob) {
 	zlog.Assert(job.ID != "")
 	zlog.Assert(job.WindowMinutes != 0)
 	zlog.Assert(job.PixelHeight != 0)
-	zlog.Assert(g.Draw != nil)
+	zlog.Assert(g.Draw != nil || len(job.Series) != 0)
 	if job.WindowMinutes <= 60 {
 		zlog.Assert(60%job.WindowMinutes == 0, job.WindowMinutes)
 	} else if job.WindowMinutes <= 24*60 {
@@ -249,7 +251,7 @@ func (g *Grapher) renderOldPart(job SJob, t time.Time) {
 	img := image.NewNRGBA(zgeo.Rect{Size: s}.GoRect())
 
 	zprocess.RunFuncUntilTimeoutSecs(2, func() {
-		g.Draw(img, &job, t, t.Add(time.Duration(job.WindowMinutes)*time.Minute), true)
+		g.drawJob(img, &job, t, t.Add(time.Duration(job.WindowMinutes)*time.Minute), true)
 		job.saveToCacheAtTime(g, img, t)
 		g.renderingOldParts.Remove(job.Job) // we remove from rending map, it has a file now.
 	})
@@ -266,7 +268,7 @@ func (g *Grapher) updateAll(now time.Time) {
 		}
 		onePixBack := -time.Duration(g.SecondsPerPixel) * time.Second
 		onePixBack = 0
-		g.Draw(job.image, job, job.drawnUntil.Add(onePixBack), now, first)
+		g.drawJob(job.image, job, job.drawnUntil.Add(onePixBack), now, first)
 		first = false
 		job.saveToCache(g)
 		job.drawnUntil = now
@@ -275,6 +277,23 @@ func (g *Grapher) updateAll(now time.Time) {
 	})
 }
 
+// drawJob draws job's Series if it has any, and then calls Draw if set, so it can draw on top of them.
+func (g *Grapher) drawJob(img *image.NRGBA, job *SJob, start, end time.Time, first bool) {
+	if len(job.Series) != 0 {
+		series := make([]Series, len(job.Series))
+		for i, s := range job.Series {
+			if s.Source == nil {
+				s.Source = g.SeriesSource
+			}
+			series[i] = s
+		}
+		g.DrawSeries(img, &job.Job, series, start, end)
+	}
+	if g.Draw != nil {
+		g.Draw(img, job, start, end, first)
+	}
+}
+
 func (j *SJob) saveToCacheAtTime(g *Grapher, img *image.NRGBA, t time.Time) {
 	t = t.UTC()
 	data, err := zimage.GoImagePNGData(img)
@@ -46,6 +46,7 @@ type GraphView struct {
 	ShowMarkerAt        time.Time
 	EndMarkerAt         time.Time
 	ShowTicksText       bool
+	Pyramid             bool // if true, tiles are requested from a Pyramid, which serves any SecondsPerPixel
 	HandleSimplePressed func(t time.Time)
 	HandleSelectedTime  func(from, to time.Time)
 
@@ -169,6 +170,9 @@ func (v *GraphView) requestParts() {
 			return
 		}
 		folderName := makeCacheFoldername(v.SecondsPerPixel, v.grapherName)
+		if v.Pyramid {
+			folderName = zfile.JoinPathParts(makePyramidFoldername(v.grapherName), strconv.Itoa(v.SecondsPerPixel))
+		}
 		surl := zfile.JoinPathParts(v.ImagePathPrefix, "zgrapher", folderName, name)
 		surl += "?tick=" + zstr.GenerateRandomHexBytes(12)
 		zimage.FromPath(surl, false, func(img *zimage.Image) {
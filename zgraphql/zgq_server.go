@@ -0,0 +1,658 @@
+//go:build !js
+
+package zgraphql
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/torlangballe/zutil/zhttp"
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zmap"
+	"github.com/torlangballe/zutil/znamedfuncs"
+	"github.com/torlangballe/zutil/zstr"
+	"github.com/torlangballe/zutil/zwebsocket"
+)
+
+// Server serves GraphQL queries and mutations that call methods registered in a znamedfuncs.Executor,
+// with argument and reply types made from their go types, and subscriptions to values sent with Publish.
+// It is an http.Handler for queries and mutations, MakeWebSocketServer adds a zwebsocket server for subscriptions.
+// Calls are authenticated with the Executor's Authenticator, using the token in a "Authorization: Bearer" header,
+// or the Token of SocketMessages.
+type Server struct {
+	Executor         *znamedfuncs.Executor
+	OnlyPersisted    bool // if set, only queries added with AddPersistedQuery can be run
+	MaxAutoPersisted int  // how many queries sent by clients with their hash are kept, least recently used are removed
+
+	types         *typeBuilder
+	queries       graphql.Fields
+	mutations     graphql.Fields
+	subscriptions graphql.Fields
+	schema        *graphql.Schema
+	persisted     zmap.LockMap[string, string] // id or sha256 hash to query, added with AddPersistedQuery
+	autoPersisted *queryLRU                    // sha256 hash to query, sent by clients
+	subscribers   map[string][]*subscriber     // by subscription name
+	connections   map[string]map[string]func() // cancel funcs of subscriptions, by websocket connection id and subscription id
+	socket        *zwebsocket.Server
+	lock          sync.Mutex
+}
+
+// Request is a GraphQL request, posted as json or sent as the payload of a SocketSubscribe message.
+// Instead of Query, it can have the ID of a query added with AddPersistedQuery,
+// or a sha256 hash of a query in extensions.persistedQuery, which is stored when sent with the query, up to Server.MaxAutoPersisted.
+type Request struct {
+	Query         string            `json:"query,omitempty"`
+	Variables     map[string]any    `json:"variables,omitempty"`
+	OperationName string            `json:"operationName,omitempty"`
+	ID            string            `json:"id,omitempty"`
+	Extensions    RequestExtensions `json:"extensions,omitempty"`
+}
+
+type RequestExtensions struct {
+	PersistedQuery *PersistedQuery `json:"persistedQuery,omitempty"`
+}
+
+type PersistedQuery struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+// Subscription is a subscription field of type Reply, whose values are sent with Server.Publish.
+type Subscription struct {
+	Name          string
+	Args          any // a struct whose fields are the arguments, or nil
+	Reply         any // a value of the type published
+	AuthNotNeeded bool
+	// Filter returns if value should be sent to a subscriber with args and ci. If nil, all values are sent.
+	Filter func(ci *znamedfuncs.ClientInfo, args map[string]any, value any) bool
+}
+
+type subscriber struct {
+	ch     chan any
+	args   map[string]any
+	ci     znamedfuncs.ClientInfo
+	filter func(ci *znamedfuncs.ClientInfo, args map[string]any, value any) bool
+}
+
+// SocketMessage is the json sent both ways on a websocket made with MakeWebSocketServer.
+// The client starts an operation with a SocketSubscribe message with a Request as Payload.
+// Queries and mutations are replied to with SocketNext with a graphql.Result as payload,
+// subscriptions with SocketAck, and the server then sends SocketNext for each value until SocketComplete.
+// The client sends SocketComplete with the ID it subscribed with to stop.
+type SocketMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Token   string          `json:"token,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+const (
+	SocketSubscribe = "subscribe"
+	SocketAck       = "ack"
+	SocketNext      = "next"
+	SocketError     = "error"
+	SocketComplete  = "complete"
+)
+
+type clientInfoKey struct{}
+
+var (
+	// SubscriptionBufferSize is how many values can wait to be sent to a subscriber, more are dropped.
+	SubscriptionBufferSize = 32
+	PersistedQueryNotFound = errors.New("PersistedQueryNotFound")
+	PersistedQueryMismatch = errors.New("provided sha256Hash does not match query")
+	PersistedQueryOnly     = errors.New("only persisted queries are allowed")
+	unusedType             = reflect.TypeOf(znamedfuncs.Unused{})
+)
+
+func NewServer(executor *znamedfuncs.Executor) *Server {
+	s := &Server{}
+	s.Executor = executor
+	s.types = newTypeBuilder()
+	s.queries = graphql.Fields{}
+	s.mutations = graphql.Fields{}
+	s.subscriptions = graphql.Fields{}
+	s.subscribers = map[string][]*subscriber{}
+	s.connections = map[string]map[string]func(){}
+	s.MaxAutoPersisted = 1000
+	s.autoPersisted = newQueryLRU()
+	return s
+}
+
+// AddQuery adds registered executor method as a query field.
+// If field is empty, it is the method name with . replaced by _, i.e Users_GetUser.
+// If the method's argument is a struct, its fields are the arguments, otherwise it is a single argument called arg.
+// Methods without a reply return a Boolean that is true.
+func (s *Server) AddQuery(method, field string) error {
+	return s.addMethod(s.queries, method, field)
+}
+
+// AddMutation adds registered executor method as a mutation field, see AddQuery.
+func (s *Server) AddMutation(method, field string) error {
+	return s.addMethod(s.mutations, method, field)
+}
+
+func (s *Server) addMethod(fields graphql.Fields, method, field string) error {
+	argType, replyType, got := s.Executor.MethodTypes(method)
+	if !got {
+		return zlog.NewError("no method registered:", method)
+	}
+	if field == "" {
+		field = strings.ReplaceAll(method, ".", "_")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f := &graphql.Field{Type: graphql.Boolean, Description: method}
+	structArg := false
+	if argType != unusedType {
+		f.Args, structArg = s.types.arguments(argType)
+	}
+	if replyType != nil {
+		f.Type = s.types.output(field, replyType.Elem())
+	}
+	f.Resolve = s.methodResolver(method, structArg, replyType)
+	fields[field] = f
+	s.schema = nil
+	return nil
+}
+
+// methodResolver calls method with the arguments as json, like xrpc does, so it is authenticated by the executor.
+func (s *Server) methodResolver(method string, structArg bool, replyType reflect.Type) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		ci, _ := p.Context.Value(clientInfoKey{}).(znamedfuncs.ClientInfo)
+		var arg any = p.Args
+		if !structArg {
+			arg = p.Args[singleArgName]
+		}
+		var cp znamedfuncs.CallPayloadReceive
+		var rp znamedfuncs.ReceivePayload
+		var err error
+		cp.ClientInfo = ci
+		cp.Method = method
+		cp.Args, err = json.Marshal(arg)
+		if err != nil {
+			return nil, err
+		}
+		s.Executor.Execute(&cp, &rp)
+		err = rp.ErrorFromPayload()
+		if err != nil {
+			return nil, err
+		}
+		if replyType == nil {
+			return true, nil
+		}
+		reply := reflect.New(replyType.Elem())
+		err = json.Unmarshal(rp.Result, reply.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return reply.Elem().Interface(), nil
+	}
+}
+
+// AddSubscription adds a subscription field.
+func (s *Server) AddSubscription(sub Subscription) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f := &graphql.Field{
+		Type: s.types.output(sub.Name, reflect.TypeOf(sub.Reply)),
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			return p.Source, nil // the published value
+		},
+		Subscribe: func(p graphql.ResolveParams) (any, error) {
+			return s.subscribe(sub, p)
+		},
+	}
+	if sub.Args != nil {
+		f.Args, _ = s.types.arguments(reflect.TypeOf(sub.Args))
+	}
+	s.subscriptions[sub.Name] = f
+	s.schema = nil
+}
+
+func (s *Server) subscribe(sub Subscription, p graphql.ResolveParams) (any, error) {
+	ci, _ := p.Context.Value(clientInfoKey{}).(znamedfuncs.ClientInfo)
+	auth := s.Executor.Authenticator
+	if !sub.AuthNotNeeded && auth != nil {
+		valid, userID := auth.IsTokenValid(ci.Token, ci.Request)
+		if !valid {
+			zlog.Error("token not valid for subscription:", sub.Name, ci.ClientID)
+			return nil, znamedfuncs.AuthenticationInvalidError
+		}
+		ci.UserID = userID
+	}
+	sr := &subscriber{ch: make(chan any, SubscriptionBufferSize), args: p.Args, ci: ci, filter: sub.Filter}
+	s.lock.Lock()
+	s.subscribers[sub.Name] = append(s.subscribers[sub.Name], sr)
+	s.lock.Unlock()
+	go func() {
+		<-p.Context.Done()
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		subs := s.subscribers[sub.Name]
+		for i, is := range subs {
+			if is == sr {
+				s.subscribers[sub.Name] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+	}()
+	return sr.ch, nil
+}
+
+// Publish sends value to the subscribers of subscription name its Filter allows.
+// Subscribers with SubscriptionBufferSize values waiting to be sent don't get it.
+func (s *Server) Publish(name string, value any) {
+	s.lock.Lock()
+	subs := append([]*subscriber{}, s.subscribers[name]...)
+	s.lock.Unlock()
+	for _, sr := range subs {
+		if sr.filter != nil && !sr.filter(&sr.ci, sr.args, value) {
+			continue
+		}
+		select {
+		case sr.ch <- value:
+		default:
+			zlog.Warn("subscription buffer full, dropping value:", name, sr.ci.ClientID)
+		}
+	}
+}
+
+// AddPersistedQuery stores query so it can be run by sending id, or the sha256 hash of query.
+func (s *Server) AddPersistedQuery(id, query string) {
+	s.persisted.Set(id, query)
+	s.persisted.Set(queryHash(query), query)
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// queryFor returns the query to run for r, looking up persisted queries,
+// and storing automatic persisted queries sent with their hash if not OnlyPersisted.
+func (s *Server) queryFor(r *Request) (string, error) {
+	if r.ID != "" {
+		query, got := s.persisted.Get(r.ID)
+		if !got {
+			return "", PersistedQueryNotFound
+		}
+		return query, nil
+	}
+	pq := r.Extensions.PersistedQuery
+	if pq == nil || pq.Sha256Hash == "" {
+		if s.OnlyPersisted {
+			return "", PersistedQueryOnly
+		}
+		return r.Query, nil
+	}
+	query, got := s.persisted.Get(pq.Sha256Hash)
+	if !got {
+		query, got = s.autoPersisted.get(pq.Sha256Hash)
+	}
+	if got {
+		return query, nil
+	}
+	if r.Query == "" {
+		return "", PersistedQueryNotFound
+	}
+	if s.OnlyPersisted {
+		return "", PersistedQueryOnly
+	}
+	if queryHash(r.Query) != pq.Sha256Hash {
+		return "", PersistedQueryMismatch
+	}
+	s.autoPersisted.set(pq.Sha256Hash, r.Query, s.MaxAutoPersisted)
+	return r.Query, nil
+}
+
+// queryLRU keeps queries by hash, removing the least recently used when full.
+type queryLRU struct {
+	lock    sync.Mutex
+	order   *list.List // of *queryEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type queryEntry struct {
+	hash  string
+	query string
+}
+
+func newQueryLRU() *queryLRU {
+	return &queryLRU{order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *queryLRU) get(hash string) (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e := c.entries[hash]
+	if e == nil {
+		return "", false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*queryEntry).query, true
+}
+
+func (c *queryLRU) set(hash, query string, max int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e := c.entries[hash]; e != nil {
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[hash] = c.order.PushFront(&queryEntry{hash: hash, query: query})
+	for max > 0 && c.order.Len() > max {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.entries, last.Value.(*queryEntry).hash)
+	}
+}
+
+func (c *queryLRU) count() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
+
+// Build makes the schema from the fields added. It is done when first needed, but can be called to check for errors at startup.
+func (s *Server) Build() error {
+	_, err := s.getSchema()
+	return err
+}
+
+func (s *Server) getSchema() (*graphql.Schema, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.schema != nil {
+		return s.schema, nil
+	}
+	if len(s.queries) == 0 {
+		return nil, zlog.NewError("zgraphql server has no queries")
+	}
+	config := graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: s.queries}),
+	}
+	if len(s.mutations) != 0 {
+		config.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: s.mutations})
+	}
+	if len(s.subscriptions) != 0 {
+		config.Subscription = graphql.NewObject(graphql.ObjectConfig{Name: "Subscription", Fields: s.subscriptions})
+	}
+	schema, err := graphql.NewSchema(config)
+	if err != nil {
+		return nil, zlog.Error("new schema", err)
+	}
+	s.schema = &schema
+	return s.schema, nil
+}
+
+func errorResult(err error) *graphql.Result {
+	return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+}
+
+// operationType returns query, mutation or subscription for the operation in query named operationName,
+// or the only one if operationName is empty. It returns "" if not found or query can't be parsed, which Do reports.
+func operationType(query, operationName string) string {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return ""
+	}
+	for _, def := range doc.Definitions {
+		op, is := def.(*ast.OperationDefinition)
+		if is && (operationName == "" || op.Name != nil && op.Name.Value == operationName) {
+			return op.Operation
+		}
+	}
+	return ""
+}
+
+func withClientInfo(ci znamedfuncs.ClientInfo) context.Context {
+	if ci.Context == nil {
+		ci.Context = context.Background()
+	}
+	return context.WithValue(ci.Context, clientInfoKey{}, ci)
+}
+
+// Do runs the query or mutation in r for the caller ci.
+func (s *Server) Do(ci znamedfuncs.ClientInfo, r Request) *graphql.Result {
+	schema, err := s.getSchema()
+	if err != nil {
+		return errorResult(err)
+	}
+	query, err := s.queryFor(&r)
+	if err != nil {
+		return errorResult(err)
+	}
+	return graphql.Do(graphql.Params{
+		Schema:         *schema,
+		RequestString:  query,
+		VariableValues: r.Variables,
+		OperationName:  r.OperationName,
+		Context:        withClientInfo(ci),
+	})
+}
+
+// ServeHTTP runs a Request posted as json, or in the query, variables, operationName, id and extensions parameters of a GET.
+// Mutations can't be done with GET.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var r Request
+	switch req.Method {
+	case http.MethodGet:
+		q := req.URL.Query()
+		r.Query = q.Get("query")
+		r.OperationName = q.Get("operationName")
+		r.ID = q.Get("id")
+		var err error
+		if vars := q.Get("variables"); vars != "" {
+			err = json.Unmarshal([]byte(vars), &r.Variables)
+		}
+		if ext := q.Get("extensions"); ext != "" && err == nil {
+			err = json.Unmarshal([]byte(ext), &r.Extensions)
+		}
+		if err != nil {
+			http.Error(w, "bad parameters: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		err := json.NewDecoder(req.Body).Decode(&r)
+		if err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	ci := znamedfuncs.ClientInfo{Type: "graphql", Request: req, UserAgent: req.UserAgent(), Context: req.Context()}
+	ci.IPAddress, _, _ = zhttp.GetIPAddressAndPortFromRequest(req)
+	zstr.HasPrefix(req.Header.Get("Authorization"), "Bearer ", &ci.Token)
+	var result *graphql.Result
+	if req.Method == http.MethodGet {
+		query, err := s.queryFor(&r)
+		if err == nil && operationType(query, r.OperationName) == ast.OperationTypeMutation {
+			err = errors.New("mutations must be posted")
+		}
+		if err != nil {
+			result = errorResult(err)
+		} else {
+			r.Query, r.ID, r.Extensions.PersistedQuery = query, "", nil
+		}
+	}
+	if result == nil {
+		result = s.Do(ci, r)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(result)
+	zlog.OnError(err, "encode result")
+}
+
+// MakeWebSocketServer starts a zwebsocket server on path for SocketMessages, on router if not nil, otherwise on port.
+func (s *Server) MakeWebSocketServer(path string, port int, router *mux.Router) (*zwebsocket.Server, error) {
+	var ws *zwebsocket.Server
+	var err error
+	if router != nil {
+		ws, err = zwebsocket.NewServerWithRouter(path, router, s.handleSocketMessage)
+	} else {
+		ws, err = zwebsocket.NewServer(path, port, s.handleSocketMessage)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	s.socket = ws
+	s.lock.Unlock()
+	return ws, nil
+}
+
+func socketReply(m SocketMessage, payload any) []byte {
+	if payload != nil {
+		m.Payload, _ = json.Marshal(payload)
+	}
+	data, _ := json.Marshal(m)
+	return data
+}
+
+func (s *Server) handleSocketMessage(connID string, data []byte, err error) []byte {
+	if err != nil { // connection closed
+		s.endSubscriptions(connID, "")
+		return nil
+	}
+	var m SocketMessage
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return socketReply(SocketMessage{Type: SocketError}, errorResult(err).Errors)
+	}
+	switch m.Type {
+	case SocketComplete:
+		s.endSubscriptions(connID, m.ID)
+		return socketReply(SocketMessage{Type: SocketComplete, ID: m.ID}, nil)
+	case SocketSubscribe:
+		var r Request
+		err = json.Unmarshal(m.Payload, &r)
+		if err != nil {
+			return socketReply(SocketMessage{Type: SocketError, ID: m.ID}, errorResult(err).Errors)
+		}
+		ci := znamedfuncs.ClientInfo{Type: "graphql-ws", ClientID: connID, Token: m.Token}
+		query, err := s.queryFor(&r)
+		if err != nil {
+			return socketReply(SocketMessage{Type: SocketError, ID: m.ID}, errorResult(err).Errors)
+		}
+		r.Query, r.ID, r.Extensions.PersistedQuery = query, "", nil
+		if operationType(query, r.OperationName) != ast.OperationTypeSubscription {
+			return socketReply(SocketMessage{Type: SocketNext, ID: m.ID}, s.Do(ci, r))
+		}
+		err = s.startSubscription(connID, m.ID, ci, r)
+		if err != nil {
+			return socketReply(SocketMessage{Type: SocketError, ID: m.ID}, errorResult(err).Errors)
+		}
+		return socketReply(SocketMessage{Type: SocketAck, ID: m.ID}, nil)
+	}
+	return socketReply(SocketMessage{Type: SocketError, ID: m.ID}, errorResult(zlog.NewError("unknown message type:", m.Type)).Errors)
+}
+
+// startSubscription runs subscription r, sending its results to connection connID until it ends or endSubscriptions cancels it.
+func (s *Server) startSubscription(connID, id string, ci znamedfuncs.ClientInfo, r Request) error {
+	schema, err := s.getSchema()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ci.Context = ctx
+	results := graphql.Subscribe(graphql.Params{
+		Schema:         *schema,
+		RequestString:  r.Query,
+		VariableValues: r.Variables,
+		OperationName:  r.OperationName,
+		Context:        withClientInfo(ci),
+	})
+	s.lock.Lock()
+	subs := s.connections[connID]
+	if subs == nil {
+		subs = map[string]func(){}
+		s.connections[connID] = subs
+	}
+	if old := subs[id]; old != nil {
+		old()
+	}
+	subs[id] = cancel
+	s.lock.Unlock()
+	go func() {
+		defer s.endSubscription(connID, id, ctx)
+		for result := range results {
+			if ctx.Err() != nil {
+				continue // drain until closed
+			}
+			mtype := SocketNext
+			if result.Data == nil && len(result.Errors) != 0 {
+				mtype = SocketError
+			}
+			var payload any = result
+			if mtype == SocketError {
+				payload = result.Errors
+			}
+			err := s.send(connID, socketReply(SocketMessage{Type: mtype, ID: id}, payload))
+			if err != nil {
+				zlog.Error("send subscription result", connID, id, err)
+				cancel()
+			}
+		}
+		if ctx.Err() == nil {
+			s.send(connID, socketReply(SocketMessage{Type: SocketComplete, ID: id}, nil))
+		}
+	}()
+	return nil
+}
+
+func (s *Server) send(connID string, data []byte) error {
+	s.lock.Lock()
+	ws := s.socket
+	s.lock.Unlock()
+	if ws == nil {
+		return zlog.NewError("no websocket server")
+	}
+	_, err := ws.ExchangeWithID(connID, data)
+	return err
+}
+
+// endSubscription removes subscription id of connection connID when it is done, unless it has been replaced.
+func (s *Server) endSubscription(connID, id string, ctx context.Context) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	subs := s.connections[connID]
+	if cancel := subs[id]; cancel != nil && ctx.Err() == nil {
+		cancel()
+		delete(subs, id)
+	}
+	if len(subs) == 0 {
+		delete(s.connections, connID)
+	}
+}
+
+// endSubscriptions cancels subscription id of connection connID, or all its subscriptions if id is empty.
+func (s *Server) endSubscriptions(connID, id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	subs := s.connections[connID]
+	for sid, cancel := range subs {
+		if id == "" || sid == id {
+			cancel()
+			delete(subs, sid)
+		}
+	}
+	if len(subs) == 0 {
+		delete(s.connections, connID)
+	}
+}
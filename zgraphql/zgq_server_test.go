@@ -0,0 +1,230 @@
+//go:build !js
+
+package zgraphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/torlangballe/zutil/znamedfuncs"
+	"github.com/torlangballe/zutil/ztesting"
+	"github.com/torlangballe/zutil/zwebsocket"
+)
+
+type Address struct {
+	Street string `json:"street"`
+}
+
+type User struct {
+	ID      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Address
+	Friends []User `json:"friends,omitempty"`
+}
+
+type UserCalls struct{}
+
+type GetUserArgs struct {
+	ID int64 `json:"id"`
+}
+
+func (UserCalls) GetUser(args GetUserArgs, reply *User) error {
+	if args.ID == 0 {
+		return errors.New("no user")
+	}
+	*reply = User{ID: args.ID, Name: "Tor", Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Address: Address{Street: "Main"}}
+	reply.Friends = []User{{ID: 2, Name: "Ann"}}
+	return nil
+}
+
+func (UserCalls) SetName(ci *znamedfuncs.ClientInfo, name string, reply *string) error {
+	*reply = name + " set by " + ci.Type
+	return nil
+}
+
+type testAuth struct{}
+
+func (testAuth) IsTokenValid(token string, req *http.Request) (bool, int64) {
+	return token == "secret", 7
+}
+
+type NewsArgs struct {
+	Topic string `json:"topic"`
+}
+
+type News struct {
+	Topic string `json:"topic"`
+	Text  string `json:"text"`
+}
+
+func newTestServer(t *testing.T) *Server {
+	e := znamedfuncs.NewExecutor()
+	e.Register(UserCalls{})
+	e.Authenticator = testAuth{}
+	e.SetAuthNotNeededForMethod("UserCalls.GetUser")
+	s := NewServer(e)
+	ztesting.OnErrorFatal(t, s.AddQuery("UserCalls.GetUser", "user"))
+	ztesting.OnErrorFatal(t, s.AddMutation("UserCalls.SetName", ""))
+	s.AddSubscription(Subscription{
+		Name:  "news",
+		Args:  NewsArgs{},
+		Reply: News{},
+		Filter: func(ci *znamedfuncs.ClientInfo, args map[string]any, value any) bool {
+			return value.(News).Topic == args["topic"]
+		},
+	})
+	ztesting.OnErrorFatal(t, s.Build())
+	return s
+}
+
+func post(t *testing.T, s *Server, token string, r Request) map[string]any {
+	body, _ := json.Marshal(r)
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	var m map[string]any
+	ztesting.OnErrorFatal(t, json.Unmarshal(rec.Body.Bytes(), &m))
+	return m
+}
+
+func toJSON(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestServerQueries(t *testing.T) {
+	s := newTestServer(t)
+	m := post(t, s, "", Request{Query: `query($id: Int!) { user(id: $id) { id name created street friends { name } } }`, Variables: map[string]any{"id": 5}})
+	ztesting.Equal(t, toJSON(m["data"]), `{"user":{"created":"2024-01-02T03:04:05Z","friends":[{"name":"Ann"}],"id":5,"name":"Tor","street":"Main"}}`, "user")
+
+	m = post(t, s, "", Request{Query: `{ user(id: 0) { id } }`})
+	ztesting.Equal(t, m["errors"] != nil, true, "call error")
+
+	m = post(t, s, "", Request{Query: `mutation { UserCalls_SetName(arg: "Bo") }`})
+	ztesting.Equal(t, toJSON(m["data"]), `{"UserCalls_SetName":null}`, "no token")
+	m = post(t, s, "secret", Request{Query: `mutation { UserCalls_SetName(arg: "Bo") }`})
+	ztesting.Equal(t, toJSON(m["data"]), `{"UserCalls_SetName":"Bo set by graphql"}`, "authenticated")
+
+	req := httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`mutation { UserCalls_SetName(arg: "Bo") }`), nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	ztesting.Equal(t, bytes.Contains(rec.Body.Bytes(), []byte("mutations must be posted")), true, "get mutation", rec.Body.String())
+
+	o, err := NewObjectFromStruct(&User{})
+	ztesting.OnErrorFatal(t, err)
+	ztesting.Equal(t, o.Name(), "_User", "object name")
+	_, err = NewObjectFromStruct(5)
+	ztesting.Equal(t, err != nil, true, "not a struct")
+}
+
+func TestServerPersisted(t *testing.T) {
+	s := newTestServer(t)
+	query := `{ user(id: 3) { name } }`
+	pq := &PersistedQuery{Version: 1, Sha256Hash: queryHash(query)}
+	m := post(t, s, "", Request{Extensions: RequestExtensions{PersistedQuery: pq}})
+	ztesting.Equal(t, toJSON(m["errors"]), `[{"locations":[],"message":"PersistedQueryNotFound"}]`, "not found")
+	m = post(t, s, "", Request{Query: query, Extensions: RequestExtensions{PersistedQuery: pq}})
+	ztesting.Equal(t, toJSON(m["data"]), `{"user":{"name":"Tor"}}`, "stored")
+	m = post(t, s, "", Request{Extensions: RequestExtensions{PersistedQuery: pq}})
+	ztesting.Equal(t, toJSON(m["data"]), `{"user":{"name":"Tor"}}`, "by hash")
+
+	s.MaxAutoPersisted = 2
+	for i := range 5 {
+		q := fmt.Sprintf(`{ user(id: %d) { name } }`, i+10)
+		post(t, s, "", Request{Query: q, Extensions: RequestExtensions{PersistedQuery: &PersistedQuery{Version: 1, Sha256Hash: queryHash(q)}}})
+	}
+	ztesting.Equal(t, s.autoPersisted.count(), 2, "auto persisted bounded")
+	m = post(t, s, "", Request{Extensions: RequestExtensions{PersistedQuery: pq}})
+	ztesting.Equal(t, toJSON(m["errors"]), `[{"locations":[],"message":"PersistedQueryNotFound"}]`, "least recently used removed")
+
+	s.OnlyPersisted = true
+	s.AddPersistedQuery("getUser", `{ user(id: 4) { id } }`)
+	m = post(t, s, "", Request{ID: "getUser"})
+	ztesting.Equal(t, toJSON(m["data"]), `{"user":{"id":4}}`, "by id")
+	m = post(t, s, "", Request{Query: `{ user(id: 4) { name } }`})
+	ztesting.Equal(t, m["data"] == nil, true, "only persisted")
+}
+
+func TestServerSubscription(t *testing.T) {
+	s := newTestServer(t)
+	router := mux.NewRouter()
+	ws, err := s.MakeWebSocketServer("/graphql", 0, router)
+	ztesting.OnErrorFatal(t, err)
+	hs := httptest.NewServer(router)
+	defer hs.Close()
+	defer ws.Close()
+
+	got := make(chan SocketMessage, 10)
+	client, err := zwebsocket.NewClient("client1", "ws"+strings.TrimPrefix(hs.URL, "http")+"/graphql", func(data []byte, err error) []byte {
+		var m SocketMessage
+		if err == nil && json.Unmarshal(data, &m) == nil {
+			got <- m
+		}
+		return nil
+	})
+	ztesting.OnErrorFatal(t, err)
+	defer client.Close()
+
+	exchange := func(m SocketMessage) SocketMessage {
+		data, err := client.Exchange([]byte(toJSON(m)))
+		ztesting.OnErrorFatal(t, err)
+		var reply SocketMessage
+		ztesting.OnErrorFatal(t, json.Unmarshal(data, &reply))
+		return reply
+	}
+	payload := func(r Request) json.RawMessage {
+		return json.RawMessage(toJSON(r))
+	}
+	reply := exchange(SocketMessage{Type: SocketSubscribe, ID: "q", Payload: payload(Request{Query: `{ user(id: 1) { name } }`})})
+	ztesting.Equal(t, reply.Type, SocketNext, "query reply")
+	ztesting.Equal(t, string(reply.Payload), `{"data":{"user":{"name":"Tor"}}}`, "query result")
+
+	reply = exchange(SocketMessage{Type: SocketSubscribe, ID: "s1", Token: "secret", Payload: payload(Request{Query: `subscription { news(topic: "go") { text } }`})})
+	ztesting.Equal(t, reply.Type, SocketAck, "subscribe reply")
+	var subscribed bool
+	for range 100 { // the subscription is registered when graphql has started it
+		s.lock.Lock()
+		subscribed = len(s.subscribers["news"]) == 1
+		s.lock.Unlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ztesting.Equal(t, subscribed, true, "subscribed")
+	s.Publish("news", News{Topic: "rust", Text: "skipped"})
+	s.Publish("news", News{Topic: "go", Text: "1.26 out"})
+	select {
+	case m := <-got:
+		ztesting.Equal(t, m.Type, SocketNext, "event type")
+		ztesting.Equal(t, m.ID, "s1", "event id")
+		ztesting.Equal(t, string(m.Payload), `{"data":{"news":{"text":"1.26 out"}}}`, "event")
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+	}
+
+	reply = exchange(SocketMessage{Type: SocketComplete, ID: "s1"})
+	ztesting.Equal(t, reply.Type, SocketComplete, "complete")
+
+	reply = exchange(SocketMessage{Type: SocketSubscribe, ID: "s2", Payload: payload(Request{Query: `subscription { news(topic: "go") { text } }`})})
+	ztesting.Equal(t, reply.Type, SocketAck, "unauthenticated ack")
+	select {
+	case m := <-got:
+		ztesting.Equal(t, m.Type, SocketError, "unauthenticated")
+	case <-time.After(2 * time.Second):
+		t.Fatal("no error")
+	}
+}
@@ -1,7 +1,6 @@
 package zgraphql
 
 import (
-	"fmt"
 	"reflect"
 
 	"github.com/graphql-go/graphql"
@@ -9,26 +8,36 @@ import (
 	"github.com/torlangballe/zutil/zreflect"
 )
 
-func getGraphQlTypeFromKind(parentFieldName string, s any) graphql.Output {
-	//fmt.Println("getGraphQlTypeFromKind:", item.TypeName, item.Kind, item.FieldName)
-	rval := reflect.ValueOf(s)
-	if rval.Kind() == reflect.Slice {
-		nt := reflect.ValueOf(s).Type().Elem()
-		n := reflect.New(nt)
-		o := getGraphQlTypeFromKind(parentFieldName, n.Interface())
-		return graphql.NewList(o)
+// singleArgName is the argument of fields made from methods whose argument isn't a struct.
+const singleArgName = "arg"
+
+// typeBuilder makes graphql types from go types, keeping them so each go type is one named graphql type,
+// as a schema can't have different types with the same name.
+type typeBuilder struct {
+	outputs map[reflect.Type]graphql.Output
+	inputs  map[reflect.Type]graphql.Input
+}
+
+func newTypeBuilder() *typeBuilder {
+	return &typeBuilder{
+		outputs: map[reflect.Type]graphql.Output{},
+		inputs:  map[reflect.Type]graphql.Input{},
 	}
-	kind := zreflect.KindFromReflectKindAndType(rval.Kind(), rval.Type())
+}
+
+// scalarForType returns the scalar used for basic types, or nil if not a scalar. Maps and other types become strings.
+func scalarForType(t reflect.Type) *graphql.Scalar {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 { // json has []byte as base64
+		return graphql.String
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Pointer:
+		return nil
+	}
+	kind := zreflect.KindFromReflectKindAndType(t.Kind(), t)
 	switch kind {
 	case zreflect.KindStruct:
-		o, err := newObjectFromStructure(parentFieldName, s)
-		if err != nil {
-			err = zlog.NewError(err, "getGraphQlTypeFromKind: struct", err, rval.Type())
-			fmt.Println("zreflect.KindStruct err:", err)
-			return nil
-		}
-		return o
-
+		return nil
 	case zreflect.KindInt:
 		return graphql.Int
 	case zreflect.KindFloat:
@@ -39,18 +48,152 @@ func getGraphQlTypeFromKind(parentFieldName string, s any) graphql.Output {
 		return graphql.Boolean
 	case zreflect.KindTime:
 		return graphql.DateTime
-	default:
-		//		fmt.Println("KIND:", item)
-		return graphql.String
 	}
+	return graphql.String
+}
+
+func (b *typeBuilder) output(parentFieldName string, t reflect.Type) graphql.Output {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if s := scalarForType(t); s != nil {
+		return s
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		return graphql.NewList(b.output(parentFieldName, t.Elem()))
+	}
+	o, got := b.outputs[t]
+	if got {
+		return o
+	}
+	object := graphql.NewObject(graphql.ObjectConfig{
+		Name:   typeName(parentFieldName, t),
+		Fields: graphql.FieldsThunk(func() graphql.Fields { return b.objectFields(t) }),
+	})
+	b.outputs[t] = object // before fields are made, for recursive types
+	return object
+}
+
+func typeName(parentFieldName string, t reflect.Type) string {
+	if t.Name() == "" {
+		return parentFieldName
+	}
+	return t.Name()
+}
+
+// forEachField calls got with the graphql name, description and non-null-ness of the fields of struct type t,
+// including those of anonymous fields.
+func forEachField(t reflect.Type, got func(f reflect.StructField, name, desc string, nonNull bool)) {
+	zreflect.ForEachField(reflect.New(t).Elem().Interface(), zreflect.FlattenIfAnonymous, func(each zreflect.FieldInfo) bool {
+		name, desc, omitEmpty, ignore := getInfoFromTag(each.StructField.Name, string(each.StructField.Tag))
+		if ignore || !each.StructField.IsExported() {
+			return true
+		}
+		f, _ := t.FieldByName(each.StructField.Name) // gets index of fields promoted from anonymous ones
+		got(f, name, desc, !omitEmpty && f.Type.Kind() != reflect.Pointer)
+		return true
+	})
+}
+
+func (b *typeBuilder) objectFields(t reflect.Type) graphql.Fields {
+	fields := graphql.Fields{}
+	forEachField(t, func(f reflect.StructField, name, desc string, nonNull bool) {
+		ft := b.output(f.Name, f.Type)
+		if nonNull {
+			ft = graphql.NewNonNull(ft)
+		}
+		index := f.Index
+		fields[name] = &graphql.Field{
+			Type:        ft,
+			Description: desc,
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				v := reflect.Indirect(reflect.ValueOf(p.Source))
+				if v.Kind() != reflect.Struct {
+					return graphql.DefaultResolveFn(p)
+				}
+				fv, err := v.FieldByIndexErr(index)
+				if err != nil { // nil anonymous pointer
+					return nil, nil
+				}
+				return fv.Interface(), nil
+			},
+		}
+	})
+	return fields
+}
+
+// input returns an input type for t, with structs as input objects named with Input after their name.
+func (b *typeBuilder) input(parentFieldName string, t reflect.Type) graphql.Input {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if s := scalarForType(t); s != nil {
+		return s
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		return graphql.NewList(b.input(parentFieldName, t.Elem()))
+	}
+	in, got := b.inputs[t]
+	if got {
+		return in
+	}
+	object := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: typeName(parentFieldName, t) + "Input",
+		Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+			fields := graphql.InputObjectConfigFieldMap{}
+			forEachField(t, func(f reflect.StructField, name, desc string, nonNull bool) {
+				fields[name] = &graphql.InputObjectFieldConfig{Type: b.nonNullInput(b.input(f.Name, f.Type), nonNull), Description: desc}
+			})
+			return fields
+		}),
+	})
+	b.inputs[t] = object
+	return object
+}
+
+func (b *typeBuilder) nonNullInput(in graphql.Input, nonNull bool) graphql.Input {
+	if nonNull {
+		return graphql.NewNonNull(in)
+	}
+	return in
 }
 
+// arguments returns arguments for the fields of t if it is a struct, otherwise a single argument called arg.
+func (b *typeBuilder) arguments(t reflect.Type) (args graphql.FieldConfigArgument, isStruct bool) {
+	args = graphql.FieldConfigArgument{}
+	et := t
+	for et.Kind() == reflect.Pointer {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Struct || et == zreflect.TimeType {
+		args[singleArgName] = &graphql.ArgumentConfig{Type: b.nonNullInput(b.input(singleArgName, t), t.Kind() != reflect.Pointer)}
+		return args, false
+	}
+	forEachField(et, func(f reflect.StructField, name, desc string, nonNull bool) {
+		args[name] = &graphql.ArgumentConfig{Type: b.nonNullInput(b.input(f.Name, f.Type), nonNull), Description: desc}
+	})
+	return args, true
+}
+
+// NewObjectFromStruct returns a graphql object type with the fields of struct s, named with its type prefixed by _.
+// Fields are named by their json tag, and are non-null unless pointers or omitempty.
+// A graphqldesc tag sets a field's description, graphql:"-" skips it.
+// It returns an error if s isn't a struct or pointer to one, as an object type needs fields.
 func NewObjectFromStruct(s any) (object graphql.Type, err error) {
-	// root, err := zreflect.IterateStruct(v, zreflect.Options{UnnestAnonymous: true})
-	// if err != nil {
-	// 	err = zlog.Error(err, "NewObjectFromStruct: urefect.IterateStruct", err)
-	// }
-	return newObjectFromStructure("", s)
+	t := reflect.TypeOf(s)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, zlog.NewError("not a struct:", t)
+	}
+	b := newTypeBuilder()
+	o := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "_" + t.Name(),
+		Fields: graphql.FieldsThunk(func() graphql.Fields { return b.objectFields(t) }),
+	})
+	b.outputs[t] = o
+	return o, nil
 }
 
 func getInfoFromTag(fieldName, stags string) (name, description string, omitEmpty, ignore bool) {
@@ -64,7 +207,9 @@ func getInfoFromTag(fieldName, stags string) (name, description string, omitEmpt
 						ignore = true
 						return
 					}
-					name = n
+					if n != "" {
+						name = n
+					}
 				} else if n == "omitempty" {
 					omitEmpty = true
 				}
@@ -80,46 +225,6 @@ func getInfoFromTag(fieldName, stags string) (name, description string, omitEmpt
 	return
 }
 
-func getNonNullFromType(s any, t graphql.Output, omitEmpty bool) graphql.Output {
-	if omitEmpty || reflect.ValueOf(s).Kind() == reflect.Pointer {
-		return t
-	}
-	//	fmt.Println("non-null:", c.TypeName, c.FieldName, c.IsPointer, omitEmpty)
-	return graphql.NewNonNull(t)
-}
-
-func newObjectFromStructure(parentFieldName string, s any) (object graphql.Type, err error) {
-	//	fmt.Println("newObjectFromReflectItem:", item.TypeName, item.Kind, item.FieldName)
-	var fields = graphql.Fields{}
-
-	zreflect.ForEachField(s, zreflect.FlattenIfAnonymous, func(each zreflect.FieldInfo) bool {
-		name, desc, omitEmpty, ignore := getInfoFromTag(each.StructField.Name, string(each.StructField.Tag))
-		//		lowerName := ustr.MakeFirstLetterLowerCase(name)
-		if ignore {
-			return true
-		}
-		t := getGraphQlTypeFromKind(each.StructField.Name, s)
-		t = getNonNullFromType(s, t, omitEmpty)
-		field := &graphql.Field{
-			Type:        t,
-			Description: desc,
-		}
-		//		fmt.Println("addfield:", name, c.FieldName, c.Tag)
-		fields[name] = field
-		return true
-	})
-	name := parentFieldName
-	if name == "" {
-		name = reflect.TypeOf(s).Name()
-		name = parentFieldName + "_" + name
-	}
-	object = graphql.NewObject(graphql.ObjectConfig{
-		Name:   name,
-		Fields: fields,
-	})
-	return
-}
-
 func MakeEnum(name, description string, items ...EnumItem) graphql.Output {
 	vals := graphql.EnumValueConfigMap{}
 	for _, item := range items {
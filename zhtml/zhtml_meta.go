@@ -0,0 +1,241 @@
+package zhtml
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Link is an <a> or <link> element, with URL resolved against the document's base.
+type Link struct {
+	URL   string
+	Text  string
+	Rel   string
+	Type  string
+	Title string
+}
+
+// Metadata is what a document says about itself in its head: title, description, OpenGraph and Twitter card tags,
+// canonical URL and feeds. Fields are filled from OpenGraph first, then Twitter, then standard tags.
+type Metadata struct {
+	Title         string
+	Description   string
+	SiteName      string
+	Type          string // og:type, like "article" or "website"
+	CanonicalURL  string
+	ImageURL      string
+	Author        string
+	Language      string
+	Keywords      []string
+	PublishedTime time.Time
+	ModifiedTime  time.Time
+	IconURL       string
+	Feeds         []Link            // RSS and Atom feeds
+	OpenGraph     map[string]string // all og: and article: properties, the first of each
+	Twitter       map[string]string // all twitter: names
+}
+
+// ParseDocument parses html from r, and the base URL of links in it, which is <base href> if it has one.
+func ParseDocument(r io.Reader, baseURL string) (doc *html.Node, base *url.URL, err error) {
+	doc, err = html.Parse(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	base, _ = url.Parse(baseURL)
+	if b := findFirst(doc, atom.Base); b != nil {
+		if href := attrValue(b.Attr, "href"); href != "" {
+			u, err := url.Parse(href)
+			if err == nil {
+				if base != nil {
+					u = base.ResolveReference(u)
+				}
+				base = u
+			}
+		}
+	}
+	return doc, base, nil
+}
+
+// ExtractMetadata reads html from r and returns its metadata. baseURL is the url it came from, for relative links.
+func ExtractMetadata(r io.Reader, baseURL string) (*Metadata, error) {
+	doc, base, err := ParseDocument(r, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return MetadataFromNode(doc, base), nil
+}
+
+// ExtractLinks returns all <a href> links in the html read from r.
+func ExtractLinks(r io.Reader, baseURL string) ([]Link, error) {
+	doc, base, err := ParseDocument(r, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	var links []Link
+	walk(doc, func(n *html.Node) bool {
+		if n.DataAtom == atom.A {
+			href := attrValue(n.Attr, "href")
+			if href != "" && !strings.HasPrefix(href, "#") && !strings.HasPrefix(strings.ToLower(href), "javascript:") {
+				links = append(links, Link{
+					URL:   resolve(base, href),
+					Text:  strings.Join(strings.Fields(nodeText(n)), " "),
+					Rel:   attrValue(n.Attr, "rel"),
+					Title: attrValue(n.Attr, "title"),
+				})
+			}
+		}
+		return true
+	})
+	return links, nil
+}
+
+func MetadataFromNode(doc *html.Node, base *url.URL) *Metadata {
+	m := &Metadata{OpenGraph: map[string]string{}, Twitter: map[string]string{}}
+	names := map[string]string{}
+	var title string
+	walk(doc, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.Html:
+			m.Language = attrValue(n.Attr, "lang")
+		case atom.Title:
+			if title == "" {
+				title = strings.TrimSpace(nodeText(n))
+			}
+		case atom.Meta:
+			content := strings.TrimSpace(attrValue(n.Attr, "content"))
+			if prop := strings.ToLower(attrValue(n.Attr, "property")); prop != "" {
+				if (strings.HasPrefix(prop, "og:") || strings.HasPrefix(prop, "article:")) && m.OpenGraph[prop] == "" {
+					m.OpenGraph[prop] = content
+				}
+			}
+			if name := strings.ToLower(attrValue(n.Attr, "name")); name != "" {
+				if strings.HasPrefix(name, "twitter:") && m.Twitter[name] == "" {
+					m.Twitter[name] = content
+				} else if names[name] == "" {
+					names[name] = content
+				}
+			}
+		case atom.Link:
+			rels := strings.Fields(strings.ToLower(attrValue(n.Attr, "rel")))
+			href := attrValue(n.Attr, "href")
+			for _, rel := range rels {
+				switch rel {
+				case "canonical":
+					m.CanonicalURL = resolve(base, href)
+				case "alternate":
+					t := attrValue(n.Attr, "type")
+					if t == "application/rss+xml" || t == "application/atom+xml" {
+						m.Feeds = append(m.Feeds, Link{URL: resolve(base, href), Rel: rel, Type: t, Title: attrValue(n.Attr, "title")})
+					}
+				case "icon", "apple-touch-icon":
+					if m.IconURL == "" {
+						m.IconURL = resolve(base, href)
+					}
+				}
+			}
+		case atom.Body:
+			return false // metadata is in head, but parsers are lenient so check all of it before body
+		}
+		return true
+	})
+	og := m.OpenGraph
+	tw := m.Twitter
+	m.Title = firstNonEmpty(og["og:title"], tw["twitter:title"], title)
+	m.Description = firstNonEmpty(og["og:description"], tw["twitter:description"], names["description"])
+	m.SiteName = firstNonEmpty(og["og:site_name"], names["application-name"])
+	m.Type = og["og:type"]
+	m.ImageURL = resolve(base, firstNonEmpty(og["og:image:secure_url"], og["og:image"], og["og:image:url"], tw["twitter:image"], tw["twitter:image:src"]))
+	m.Author = firstNonEmpty(names["author"], og["article:author"], tw["twitter:creator"])
+	if m.CanonicalURL == "" && og["og:url"] != "" {
+		m.CanonicalURL = resolve(base, og["og:url"])
+	}
+	for _, k := range strings.Split(names["keywords"], ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			m.Keywords = append(m.Keywords, k)
+		}
+	}
+	m.PublishedTime = parseMetaTime(firstNonEmpty(og["article:published_time"], names["date"]))
+	m.ModifiedTime = parseMetaTime(firstNonEmpty(og["article:modified_time"], og["og:updated_time"]))
+	return m
+}
+
+func parseMetaTime(str string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		t, err := time.Parse(layout, str)
+		if err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func firstNonEmpty(strs ...string) string {
+	for _, s := range strs {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func resolve(base *url.URL, surl string) string {
+	if surl == "" || base == nil {
+		return surl
+	}
+	u, err := url.Parse(strings.TrimSpace(surl))
+	if err != nil {
+		return surl
+	}
+	return base.ResolveReference(u).String()
+}
+
+// walk calls f for n and its descendants depth first, not going into a node's children if f returns false.
+func walk(n *html.Node, f func(n *html.Node) bool) {
+	if n.Type == html.ElementNode || n.Type == html.DocumentNode {
+		if n.Type == html.ElementNode && !f(n) {
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, f)
+	}
+}
+
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walk(n, func(n *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if n.DataAtom == a {
+			found = n
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// nodeText returns the text in n and its descendants, without that of scripts and styles.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var add func(n *html.Node)
+	add = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			return
+		}
+		if n.DataAtom == atom.Script || n.DataAtom == atom.Style {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			add(c)
+		}
+	}
+	add(n)
+	return sb.String()
+}
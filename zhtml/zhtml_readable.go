@@ -0,0 +1,332 @@
+package zhtml
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Article is the readable content of a page, without navigation, ads, comments and other clutter.
+type Article struct {
+	Title        string
+	Byline       string
+	SiteName     string
+	LeadImageURL string
+	Excerpt      string
+	HTML         string // the main content, sanitized with UGCPolicy
+	Text         string // the main content as plain text, with paragraphs separated by blank lines
+	Meta         *Metadata
+}
+
+var (
+	unlikelyRegex  = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|header|legends|menu|modal|nav|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|ad-break|agegate|pagination|pager|popup|promo|newsletter|subscribe`)
+	maybeRegex     = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow|story`)
+	positiveRegex  = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	negativeRegex  = regexp.MustCompile(`(?i)hidden|banner|combx|comment|com-|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget|ad-`)
+	bylineRegex    = regexp.MustCompile(`(?i)byline|author|dateline|writtenby`)
+	titleSeparator = regexp.MustCompile(`\s+[|\-–—\\/>»:]\s+`)
+)
+
+// tags never part of readable content
+var removedAtoms = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true, atom.Form: true, atom.Button: true,
+	atom.Input: true, atom.Select: true, atom.Textarea: true, atom.Nav: true, atom.Aside: true, atom.Footer: true,
+	atom.Object: true, atom.Embed: true, atom.Svg: true, atom.Template: true, atom.Link: true, atom.Meta: true,
+}
+
+// ExtractArticle reads an html page from r and finds its main content, title, byline and lead image.
+// baseURL is the url it came from, used to make links and images in the content absolute.
+// It scores blocks by how much paragraph text they hold, and how few links, like browsers' reader modes.
+func ExtractArticle(r io.Reader, baseURL string) (*Article, error) {
+	doc, base, err := ParseDocument(r, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	a := &Article{}
+	a.Meta = MetadataFromNode(doc, base)
+	a.SiteName = a.Meta.SiteName
+	a.Title = articleTitle(doc, a.Meta)
+	a.Byline = a.Meta.Author
+	if a.Byline == "" {
+		a.Byline = findByline(doc)
+	}
+	removeClutter(doc)
+
+	top := topCandidate(doc)
+	if top == nil {
+		top = findFirst(doc, atom.Body)
+		if top == nil {
+			top = doc
+		}
+	}
+	removeHeadingTitle(top, a.Title)
+
+	var buf bytes.Buffer
+	for c := top.FirstChild; c != nil; c = c.NextSibling {
+		html.Render(&buf, c)
+	}
+	policy := UGCPolicy()
+	policy.BaseURL = base
+	a.HTML = strings.TrimSpace(policy.SanitizeString(buf.String()))
+	a.Text = blockText(top)
+	a.Excerpt = a.Meta.Description
+	if a.Excerpt == "" {
+		if p := findFirst(top, atom.P); p != nil {
+			a.Excerpt = strings.Join(strings.Fields(nodeText(p)), " ")
+		}
+	}
+	a.LeadImageURL = a.Meta.ImageURL
+	if a.LeadImageURL == "" {
+		if img := findFirst(top, atom.Img); img != nil {
+			a.LeadImageURL = resolve(base, attrValue(img.Attr, "src"))
+		}
+	}
+	return a, nil
+}
+
+// ExtractArticleFromString is ExtractArticle for html in a string.
+func ExtractArticleFromString(shtml, baseURL string) (*Article, error) {
+	return ExtractArticle(strings.NewReader(shtml), baseURL)
+}
+
+// articleTitle uses og:title or <title>, removing a " | Site Name" suffix, or the first h1 if that leaves too little.
+func articleTitle(doc *html.Node, meta *Metadata) string {
+	title := meta.Title
+	if title != "" && meta.OpenGraph["og:title"] == "" {
+		parts := titleSeparator.Split(title, -1)
+		if len(parts) > 1 {
+			longest := parts[0]
+			for _, p := range parts[1:] {
+				if len(p) > len(longest) {
+					longest = p
+				}
+			}
+			if len(strings.Fields(longest)) >= 3 {
+				title = longest
+			}
+		}
+	}
+	if title == "" {
+		if h1 := findFirst(doc, atom.H1); h1 != nil {
+			title = strings.Join(strings.Fields(nodeText(h1)), " ")
+		}
+	}
+	return strings.TrimSpace(title)
+}
+
+func findByline(doc *html.Node) string {
+	var byline string
+	walk(doc, func(n *html.Node) bool {
+		if byline != "" {
+			return false
+		}
+		if strings.Contains(attrValue(n.Attr, "rel"), "author") || attrValue(n.Attr, "itemprop") == "author" || bylineRegex.MatchString(classAndID(n)) {
+			text := strings.Join(strings.Fields(nodeText(n)), " ")
+			if text != "" && len(text) < 100 {
+				byline = text
+				return false
+			}
+		}
+		return true
+	})
+	return byline
+}
+
+func classAndID(n *html.Node) string {
+	return attrValue(n.Attr, "class") + " " + attrValue(n.Attr, "id")
+}
+
+// removeClutter removes elements that are never content, hidden ones, and those with unlikely classes or ids.
+func removeClutter(doc *html.Node) {
+	var remove []*html.Node
+	walk(doc, func(n *html.Node) bool {
+		if n.DataAtom == atom.Html || n.DataAtom == atom.Body || n.DataAtom == atom.Head {
+			return true
+		}
+		style := strings.ReplaceAll(strings.ToLower(attrValue(n.Attr, "style")), " ", "")
+		hidden := strings.Contains(style, "display:none") || hasAttr(n, "hidden") || attrValue(n.Attr, "aria-hidden") == "true"
+		ca := classAndID(n)
+		unlikely := unlikelyRegex.MatchString(ca) && !maybeRegex.MatchString(ca) && n.DataAtom != atom.A && n.DataAtom != atom.Article
+		if removedAtoms[n.DataAtom] || hidden || unlikely || (n.DataAtom == atom.Header && n.Parent != nil && n.Parent.DataAtom == atom.Body) {
+			remove = append(remove, n)
+			return false
+		}
+		return true
+	})
+	for _, n := range remove {
+		n.Parent.RemoveChild(n)
+	}
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// topCandidate scores each paragraph by its length and commas, adding to its parent and half to its grandparent.
+// The parent with the highest score, lowered by how much of its text is links, is the content.
+func topCandidate(doc *html.Node) *html.Node {
+	scores := map[*html.Node]float64{}
+	var order []*html.Node
+	addScore := func(n *html.Node, s float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, got := scores[n]; !got {
+			scores[n] = initialScore(n)
+			order = append(order, n)
+		}
+		scores[n] += s
+	}
+	walk(doc, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Td, atom.Blockquote:
+		case atom.Div:
+			if hasBlockChildren(n) {
+				return true
+			}
+		default:
+			return true
+		}
+		text := strings.TrimSpace(nodeText(n))
+		if len(text) < 25 {
+			return true
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text)/100), 3)
+		addScore(n.Parent, score)
+		if n.Parent != nil {
+			addScore(n.Parent.Parent, score/2)
+		}
+		return true
+	})
+	var top *html.Node
+	var best float64
+	for _, n := range order { // in document order, so the first of equals wins
+		s := scores[n] * (1 - linkDensity(n))
+		if top == nil || s > best {
+			top = n
+			best = s
+		}
+	}
+	return top
+}
+
+func initialScore(n *html.Node) float64 {
+	var s float64
+	switch n.DataAtom {
+	case atom.Article:
+		s += 10
+	case atom.Div, atom.Section, atom.Main:
+		s += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		s += 3
+	case atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		s -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		s -= 5
+	}
+	for _, str := range []string{attrValue(n.Attr, "class"), attrValue(n.Attr, "id")} {
+		if str == "" {
+			continue
+		}
+		if negativeRegex.MatchString(str) {
+			s -= 25
+		}
+		if positiveRegex.MatchString(str) {
+			s += 25
+		}
+	}
+	return s
+}
+
+var blockAtoms = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Table: true, atom.Ul: true, atom.Ol: true, atom.Pre: true, atom.Blockquote: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Section: true,
+	atom.Article: true, atom.Figure: true, atom.Dl: true, atom.Img: true,
+}
+
+func hasBlockChildren(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if blockAtoms[c.DataAtom] {
+			return true
+		}
+	}
+	return false
+}
+
+// linkDensity is the fraction of n's text that is inside links.
+func linkDensity(n *html.Node) float64 {
+	total := len(strings.TrimSpace(nodeText(n)))
+	if total == 0 {
+		return 0
+	}
+	var links int
+	walk(n, func(c *html.Node) bool {
+		if c.DataAtom == atom.A {
+			links += len(strings.TrimSpace(nodeText(c)))
+			return false
+		}
+		return true
+	})
+	return float64(links) / float64(total)
+}
+
+// removeHeadingTitle removes the first heading in content if it repeats the title.
+func removeHeadingTitle(content *html.Node, title string) {
+	var found *html.Node
+	walk(content, func(n *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if n.DataAtom == atom.H1 || n.DataAtom == atom.H2 {
+			if strings.EqualFold(strings.Join(strings.Fields(nodeText(n)), " "), title) {
+				found = n
+			}
+			return false
+		}
+		return true
+	})
+	if found != nil && found.Parent != nil {
+		found.Parent.RemoveChild(found)
+	}
+}
+
+// blockText returns the text of n with whitespace collapsed, and block elements on their own lines.
+func blockText(n *html.Node) string {
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		if s := strings.Join(strings.Fields(line.String()), " "); s != "" {
+			lines = append(lines, s)
+		}
+		line.Reset()
+	}
+	var add func(n *html.Node)
+	add = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			line.WriteString(n.Data)
+			return
+		}
+		block := blockAtoms[n.DataAtom] || n.DataAtom == atom.Li || n.DataAtom == atom.Tr || n.DataAtom == atom.Br
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			add(c)
+		}
+		if block {
+			flush()
+		}
+	}
+	add(n)
+	flush()
+	return strings.Join(lines, "\n\n")
+}
@@ -0,0 +1,198 @@
+package zhtml
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/torlangballe/zutil/zstr"
+	"golang.org/x/net/html"
+)
+
+// Policy is an allow-list of elements and attributes that Sanitize keeps. Everything else is removed,
+// keeping the text inside removed elements, except for those in DropContents, whose contents are removed too.
+type Policy struct {
+	Elements         map[string][]string // allowed elements, with the attributes allowed on each
+	GlobalAttributes []string            // attributes allowed on all allowed elements
+	URLSchemes       []string            // schemes allowed in href and src; relative URLs are always allowed
+	DropContents     []string            // elements removed with everything in them
+	AddNoFollow      bool                // add rel="nofollow noopener" to links
+	BaseURL          *url.URL            // if set, relative URLs are resolved against it
+}
+
+var urlAttributes = []string{"href", "src", "cite", "poster"}
+
+// voidElements have no end tag.
+var voidElements = []string{"area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr"}
+
+// UGCPolicy returns a policy for user-generated content like comments, markdown output and emails:
+// text formatting, lists, tables, links and images, but no scripts, styles, forms or embedded content.
+func UGCPolicy() *Policy {
+	p := &Policy{
+		Elements: map[string][]string{
+			"a":          {"href", "title"},
+			"img":        {"src", "alt", "title", "width", "height"},
+			"blockquote": {"cite"},
+			"q":          {"cite"},
+			"td":         {"colspan", "rowspan", "align"},
+			"th":         {"colspan", "rowspan", "align", "scope"},
+			"ol":         {"start", "type"},
+			"li":         {"value"},
+			"code":       {"class"}, // for language-xxx from markdown
+			"input":      {"type", "checked", "disabled"},
+		},
+		GlobalAttributes: []string{"title", "lang", "dir"},
+		URLSchemes:       []string{"http", "https", "mailto"},
+		DropContents:     []string{"script", "style", "iframe", "object", "embed", "noscript", "template", "textarea", "select", "head", "svg", "math"},
+		AddNoFollow:      true,
+	}
+	for _, e := range []string{"p", "br", "hr", "div", "span", "b", "i", "u", "s", "em", "strong", "small", "sub", "sup", "mark", "del", "ins", "abbr",
+		"h1", "h2", "h3", "h4", "h5", "h6", "ul", "pre", "kbd", "samp", "var", "dl", "dt", "dd",
+		"table", "thead", "tbody", "tfoot", "tr", "caption", "figure", "figcaption", "details", "summary"} {
+		p.Elements[e] = nil
+	}
+	return p
+}
+
+// TextPolicy returns a policy that removes all elements, keeping only text.
+func TextPolicy() *Policy {
+	p := UGCPolicy()
+	p.Elements = map[string][]string{}
+	return p
+}
+
+// Sanitize writes the html read from r to w, with only what p allows.
+// Unclosed allowed elements are closed at the end, and unmatched end tags dropped.
+func (p *Policy) Sanitize(r io.Reader, w io.Writer) error {
+	z := html.NewTokenizer(r)
+	var open []string
+	dropDepth := 0
+	var dropTag string
+	var buf bytes.Buffer
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			err := z.Err()
+			if err != io.EOF {
+				return err
+			}
+			break
+		}
+		token := z.Token()
+		if dropDepth > 0 {
+			switch tt {
+			case html.StartTagToken:
+				if token.Data == dropTag {
+					dropDepth++
+				}
+			case html.EndTagToken:
+				if token.Data == dropTag {
+					dropDepth--
+				}
+			}
+			continue
+		}
+		switch tt {
+		case html.TextToken:
+			buf.WriteString(html.EscapeString(token.Data))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if zstr.StringsContain(p.DropContents, token.Data) {
+				if tt == html.StartTagToken && !zstr.StringsContain(voidElements, token.Data) {
+					dropTag = token.Data
+					dropDepth = 1
+				}
+				continue
+			}
+			attrs, allowed := p.Elements[token.Data]
+			if !allowed {
+				continue
+			}
+			buf.WriteString(p.startTag(token, attrs))
+			if tt == html.StartTagToken && !zstr.StringsContain(voidElements, token.Data) {
+				open = append(open, token.Data)
+			}
+		case html.EndTagToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == token.Data {
+					for j := len(open) - 1; j >= i; j-- {
+						buf.WriteString("</" + open[j] + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		}
+		if buf.Len() > 4096 {
+			_, err := w.Write(buf.Bytes())
+			if err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		buf.WriteString("</" + open[i] + ">")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// SanitizeString returns shtml with only what p allows.
+func (p *Policy) SanitizeString(shtml string) string {
+	var out strings.Builder
+	p.Sanitize(strings.NewReader(shtml), &out)
+	return out.String()
+}
+
+func (p *Policy) startTag(token html.Token, allowed []string) string {
+	var attrs []html.Attribute
+	isLink := token.Data == "a"
+	if token.Data == "input" && attrValue(token.Attr, "type") != "checkbox" { // only task list checkboxes
+		return ""
+	}
+	for _, a := range token.Attr {
+		key := strings.ToLower(a.Key)
+		if !zstr.StringsContain(allowed, key) && !zstr.StringsContain(p.GlobalAttributes, key) {
+			continue
+		}
+		if zstr.StringsContain(urlAttributes, key) {
+			surl, ok := p.cleanURL(a.Val)
+			if !ok {
+				continue
+			}
+			a.Val = surl
+		}
+		attrs = append(attrs, html.Attribute{Key: key, Val: a.Val})
+	}
+	if isLink && p.AddNoFollow {
+		attrs = append(attrs, html.Attribute{Key: "rel", Val: "nofollow noopener"})
+	}
+	token.Attr = attrs
+	token.Type = html.StartTagToken
+	return token.String()
+}
+
+func attrValue(attrs []html.Attribute, key string) string {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// cleanURL returns surl resolved against BaseURL, and false if it has a scheme not allowed.
+func (p *Policy) cleanURL(surl string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(surl))
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" && !zstr.StringsContain(p.URLSchemes, strings.ToLower(u.Scheme)) {
+		return "", false
+	}
+	if p.BaseURL != nil {
+		u = p.BaseURL.ResolveReference(u)
+	}
+	return u.String(), true
+}
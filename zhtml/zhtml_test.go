@@ -0,0 +1,83 @@
+package zhtml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func TestSanitize(t *testing.T) {
+	p := UGCPolicy()
+	ztesting.Equal(t, p.SanitizeString(`<p onclick="x()">Hi<script>alert(1)</script> <b>there</p>`), `<p>Hi <b>there</b></p>`, "script, handler and unclosed tag")
+	ztesting.Equal(t, p.SanitizeString(`<a href="javascript:alert(1)">x</a>`), `<a rel="nofollow noopener">x</a>`, "javascript url")
+	ztesting.Equal(t, p.SanitizeString(`<a href="https://a.com/b" target="_blank">x</a>`), `<a href="https://a.com/b" rel="nofollow noopener">x</a>`, "link")
+	ztesting.Equal(t, p.SanitizeString(`<div><style>p{}</style><font>a &amp; b</font></div></span>`), `<div>a &amp; b</div>`, "unknown elements")
+	ztesting.Equal(t, TextPolicy().SanitizeString(`<h1>Title</h1><p>x &lt; y</p>`), `Titlex &lt; y`, "text only")
+}
+
+const testPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<title>How Fjords Were Formed | The Geo Times</title>
+<meta property="og:site_name" content="The Geo Times">
+<meta property="og:image" content="/img/fjord.jpg">
+<meta property="article:published_time" content="2024-05-01T10:00:00Z">
+<meta name="description" content="Glaciers, ice ages and the sea.">
+<link rel="canonical" href="/articles/fjords">
+<link rel="alternate" type="application/rss+xml" title="Feed" href="/rss.xml">
+</head>
+<body>
+<header><a href="/">Home</a> <a href="/news">News</a></header>
+<nav><ul><li><a href="/a">A</a></li><li><a href="/b">B</a></li></ul></nav>
+<div class="sidebar"><p>Subscribe to our newsletter, and get news, tips, offers and more every single week.</p></div>
+<div id="main-content">
+  <h1>How Fjords Were Formed</h1>
+  <p class="byline">By Kari Nordmann</p>
+  <p>Fjords were carved by glaciers, which ground down through valleys during the ice ages, leaving deep, narrow inlets.</p>
+  <p>When the ice melted, the sea filled the valleys, and today, some fjords are over a thousand meters deep, with steep walls.</p>
+  <p>Read <a href="/more">more about glaciers</a>, or see the <script>track()</script>map.</p>
+</div>
+<div class="comments"><p>Great article, really, thanks, I loved it, and so did my whole family, truly.</p></div>
+</body>
+</html>`
+
+func TestMetadata(t *testing.T) {
+	m, err := ExtractMetadata(strings.NewReader(testPage), "https://geo.example/articles/fjords?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, m.Title, "How Fjords Were Formed | The Geo Times", "title")
+	ztesting.Equal(t, m.SiteName, "The Geo Times", "site name")
+	ztesting.Equal(t, m.CanonicalURL, "https://geo.example/articles/fjords", "canonical")
+	ztesting.Equal(t, m.ImageURL, "https://geo.example/img/fjord.jpg", "image")
+	ztesting.Equal(t, m.Language, "en", "language")
+	ztesting.Equal(t, m.PublishedTime.Year(), 2024, "published")
+	ztesting.Equal(t, len(m.Feeds), 1, "feeds")
+	ztesting.Equal(t, m.Feeds[0].URL, "https://geo.example/rss.xml", "feed url")
+
+	links, err := ExtractLinks(strings.NewReader(testPage), "https://geo.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, len(links), 5, "links")
+	ztesting.Equal(t, links[4].URL, "https://geo.example/more", "link url")
+	ztesting.Equal(t, links[4].Text, "more about glaciers", "link text")
+}
+
+func TestExtractArticle(t *testing.T) {
+	a, err := ExtractArticleFromString(testPage, "https://geo.example/articles/fjords")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, a.Title, "How Fjords Were Formed", "title")
+	ztesting.Equal(t, a.Byline, "By Kari Nordmann", "byline")
+	ztesting.Equal(t, a.LeadImageURL, "https://geo.example/img/fjord.jpg", "lead image")
+	ztesting.Equal(t, a.Excerpt, "Glaciers, ice ages and the sea.", "excerpt")
+	ztesting.Equal(t, strings.Contains(a.HTML, "carved by glaciers"), true, "content")
+	ztesting.Equal(t, strings.Contains(a.HTML, `href="https://geo.example/more"`), true, "absolute links")
+	for _, clutter := range []string{"newsletter", "Great article", "Home", "track()", "<h1>"} {
+		ztesting.Equal(t, strings.Contains(a.HTML, clutter), false, "no clutter:", clutter)
+	}
+	ztesting.Equal(t, strings.HasPrefix(a.Text, "By Kari Nordmann\n\nFjords were carved"), true, "text:", a.Text)
+}
@@ -2,6 +2,7 @@ package zhttp
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
@@ -43,6 +44,7 @@ type ErrJSON struct {
 type HTTPError struct {
 	Err        error
 	StatusCode int
+	Code       zerrors.Code // from the body of a zrest error, otherwise from StatusCode
 }
 
 type Parameters struct {
@@ -90,6 +92,32 @@ func (e *HTTPError) Unwrap() error {
 	return e.Err
 }
 
+// ErrorCode is Code if set, or the code of the wrapped error, or one for the status code.
+func (e *HTTPError) ErrorCode() zerrors.Code {
+	if e.Code != zerrors.CodeNone {
+		return e.Code
+	}
+	c := zerrors.CodeOf(e.Err)
+	if c == zerrors.CodeUnknown {
+		c = zerrors.CodeFromHTTPStatus(e.StatusCode)
+	}
+	return c
+}
+
+// errorFromRESTBody returns an *HTTPError from a body with an "error" string, as zrest.ReturnError writes,
+// with its "code" if any. It returns nil if body isn't that.
+func errorFromRESTBody(body []byte, statusCode int) error {
+	var re struct {
+		Error string       `json:"error"`
+		Code  zerrors.Code `json:"code"`
+	}
+	if json.Unmarshal(body, &re) != nil || re.Error == "" {
+		return nil
+	}
+	code := cmp.Or(re.Code, zerrors.CodeFromHTTPStatus(statusCode))
+	return &HTTPError{Err: errors.New(strings.TrimSpace(re.Error)), StatusCode: statusCode, Code: code}
+}
+
 func MakeHTTPError(err error, code int, message string) error {
 	if message != "" {
 		zstr.Replace(&message, "<p>", "\n")
@@ -432,12 +460,8 @@ func processResponse(surl string, resp *http.Response, params Parameters, receiv
 		zlog.Fatal("not pointer", surl)
 	}
 	if err != nil {
-		var m zdict.Dict
-		if json.Unmarshal([]byte(GetCopyOfResponseBodyAsString(resp)), &m) == nil {
-			str, _ := m["error"].(string)
-			if str != "" {
-				err = errors.New(str)
-			}
+		if rerr := errorFromRESTBody([]byte(GetCopyOfResponseBodyAsString(resp)), resp.StatusCode); rerr != nil {
+			err = rerr
 		}
 		return err
 	}
@@ -660,6 +684,10 @@ func CheckErrorFromBody(resp *http.Response) (err error) {
 	if err != nil {
 		return
 	}
+	err = errorFromRESTBody(body, resp.StatusCode)
+	if err != nil {
+		return
+	}
 	var e ErrorStruct
 	jerr := json.Unmarshal(body, &e)
 	if jerr != nil {
@@ -691,7 +719,7 @@ func CheckErrorFromBody(resp *http.Response) (err error) {
 		return
 	}
 
-	err = errors.New(fmt.Sprintf("Code: %d", resp.StatusCode))
+	err = &HTTPError{Err: fmt.Errorf("Code: %d", resp.StatusCode), StatusCode: resp.StatusCode}
 	return
 }
 
@@ -1,9 +1,72 @@
 package zlocale
 
+import (
+	"sync"
+
+	"github.com/torlangballe/zutil/zwords"
+)
+
+var (
+	languageLock            sync.Mutex
+	currentLanguage         string // "" is GetDeviceLanguageCode()
+	languageChangedHandlers []func(langCode string)
+)
+
+func init() {
+	zwords.TS = TS
+	zwords.TSL = TSL
+	zwords.TSPlural = DefaultCatalog.TranslatePlural
+}
+
+// TS translates str to the current language using DefaultCatalog, returning str if it has no translation.
+// Put a comment for translators after it on the same line, it is extracted with it to the catalogs:
+//
+//	title := zlocale.TS("Log in") // button to log in to a service
 func TS(str string) string {
-	return str
+	return TSL(str, "")
 }
 
+// TSL translates str to langCode, or the current language if langCode is "".
 func TSL(str, langCode string) string {
-	return str
+	if langCode == "" {
+		langCode = CurrentLanguage()
+	}
+	tr, _ := DefaultCatalog.Translate(str, langCode)
+	return tr
+}
+
+// CurrentLanguage returns the language TS translates to; the one set with SetLanguage, or GetDeviceLanguageCode().
+func CurrentLanguage() string {
+	languageLock.Lock()
+	lang := currentLanguage
+	languageLock.Unlock()
+	if lang == "" {
+		return GetDeviceLanguageCode()
+	}
+	return lang
+}
+
+// SetLanguage switches the language TS translates to, also setting zwords.DefaultLanguage.
+// langCode "" goes back to using GetDeviceLanguageCode(). Handlers added with AddLanguageChangedHandler are called
+// if the language changes, so UI can re-translate its texts.
+func SetLanguage(langCode string) {
+	old := CurrentLanguage()
+	languageLock.Lock()
+	currentLanguage = langCode
+	handlers := append([]func(string){}, languageChangedHandlers...)
+	languageLock.Unlock()
+	lang := CurrentLanguage()
+	zwords.DefaultLanguage = lang
+	if lang == old {
+		return
+	}
+	for _, h := range handlers {
+		h(lang)
+	}
+}
+
+func AddLanguageChangedHandler(handler func(langCode string)) {
+	languageLock.Lock()
+	languageChangedHandlers = append(languageChangedHandlers, handler)
+	languageLock.Unlock()
 }
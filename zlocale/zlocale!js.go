@@ -0,0 +1,16 @@
+//go:build !js
+
+package zlocale
+
+import "os"
+
+// deviceLocale returns the locale set in the environment, like nb_NO.UTF-8.
+func deviceLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := os.Getenv(name)
+		if locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+// Command zlocale-extract scans Go sources for strings translated with zlocale.TS/TSL and pluralized with zwords,
+// and updates the translation catalogs with them, keeping existing translations:
+//
+//	zlocale-extract -catalogs ./translations -langs no,de [-json] [-keep] [source-dir ...]
+//
+// All .po and .json catalogs in the catalogs folder are updated, and ones are created for -langs that don't have one.
+// The source folder defaults to the current one.
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/torlangballe/zutil/zfile"
+	"github.com/torlangballe/zutil/zlocale"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+func main() {
+	catalogs := flag.String("catalogs", "", "folder with <language code>.po/.json catalog files")
+	langs := flag.String("langs", "", "comma-separated language codes to create catalogs for if missing")
+	asJSON := flag.Bool("json", false, "create new catalogs as json instead of .po")
+	keep := flag.Bool("keep", false, "keep messages no longer in the sources")
+	flag.Parse()
+	if *catalogs == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	msgs, err := zlocale.ExtractMessages(dirs...)
+	if err != nil {
+		zlog.Fatal("extract", err)
+	}
+	err = zfile.MakeDirAllIfNotExists(*catalogs)
+	if err != nil {
+		zlog.Fatal("make catalogs folder", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(*catalogs, "*.po"))
+	jsonFiles, _ := filepath.Glob(filepath.Join(*catalogs, "*.json"))
+	files = append(files, jsonFiles...)
+	ext := ".po"
+	if *asJSON {
+		ext = ".json"
+	}
+	for _, lang := range strings.Split(*langs, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang == "" {
+			continue
+		}
+		po := filepath.Join(*catalogs, lang+".po")
+		js := filepath.Join(*catalogs, lang+".json")
+		if zfile.NotExists(po) && zfile.NotExists(js) {
+			files = append(files, filepath.Join(*catalogs, lang+ext))
+		}
+	}
+	for _, f := range files {
+		err := zlocale.UpdateCatalogFile(f, msgs, *keep)
+		if err != nil {
+			zlog.Fatal("update", f, err)
+		}
+		zlog.Info("Updated", f, len(msgs), "messages")
+	}
+}
@@ -21,8 +21,14 @@ var (
 	IsDisplayServerTime          *zkeyvalue.Option[bool]
 )
 
+// GetDeviceLanguageCode returns the language code of the device's locale, like "no" or "en".
+// It is the language TS translates to unless SetLanguage is used.
 func GetDeviceLanguageCode() string {
-	return "en"
+	code := NormalizedLanguageCode(deviceLocale())
+	if code == "" || code == "c" || code == "posix" {
+		return "en"
+	}
+	return code
 }
 
 func GetLangCodeAndCountryFromLocaleId(bcp string, forceNo bool) (string, string) { // lang, country-code
@@ -0,0 +1,257 @@
+package zlocale
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zwords"
+)
+
+// Message is a translatable string and its translation to a language.
+// Translation has one string, or one per plural form of the language if Plural is set.
+// Comments, Extracted and References are kept to write .po files back, they aren't used for translating.
+type Message struct {
+	ID          string
+	Plural      string // msgid_plural, the untranslated plural of ID
+	Context     string // msgctxt, to tell apart equal IDs with different meanings
+	Translation []string
+	Comments    []string // translator comments
+	Extracted   []string // comments from the source code, the text after TS("...") // on the same line
+	References  []string // file:line of where ID is used
+	Fuzzy       bool     // translation needs checking, and isn't used
+}
+
+// Catalog holds messages per language, loaded from gettext .po files or json files.
+// A json catalog is an object with the untranslated string as key, and the translation as value,
+// or an array of translations, one for each plural form:
+//
+//	{ "Log in": "Logg inn", "hour": ["time", "timer"] }
+type Catalog struct {
+	lock  sync.RWMutex
+	langs map[string]map[string]*Message // language code → message key → message
+}
+
+const (
+	poExtension   = ".po"
+	jsonExtension = ".json"
+)
+
+// DefaultCatalog is used by TS, TSL and zwords.PluralizeWord.
+var DefaultCatalog = NewCatalog()
+
+func NewCatalog() *Catalog {
+	return &Catalog{langs: map[string]map[string]*Message{}}
+}
+
+func messageKey(context, id string) string {
+	if context == "" {
+		return id
+	}
+	return context + "\x04" + id
+}
+
+// Add adds or replaces m for lang.
+func (c *Catalog) Add(lang string, m Message) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	msgs := c.langs[lang]
+	if msgs == nil {
+		msgs = map[string]*Message{}
+		c.langs[lang] = msgs
+	}
+	msgs[messageKey(m.Context, m.ID)] = &m
+}
+
+// Languages returns the language codes c has messages for, sorted.
+func (c *Catalog) Languages() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	var langs []string
+	for l := range c.langs {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Messages returns a copy of lang's messages, sorted by ID.
+func (c *Catalog) Messages(lang string) []Message {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	var msgs []Message
+	for _, m := range c.langs[lang] {
+		msgs = append(msgs, *m)
+	}
+	sortMessages(msgs)
+	return msgs
+}
+
+func sortMessages(msgs []Message) {
+	sort.Slice(msgs, func(i, j int) bool {
+		if msgs[i].ID == msgs[j].ID {
+			return msgs[i].Context < msgs[j].Context
+		}
+		return msgs[i].ID < msgs[j].ID
+	})
+}
+
+// LoadFS loads all <language code>.po and <language code>.json files in dir of fsys, like no.po or pt-BR.json.
+func (c *Catalog) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return zlog.Error("read dir", dir, err)
+	}
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		if e.IsDir() || (ext != poExtension && ext != jsonExtension) {
+			continue
+		}
+		err = c.LoadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile loads a .po or .json catalog file, getting the language code from its name.
+func (c *Catalog) LoadFile(fsys fs.FS, fpath string) error {
+	data, err := fs.ReadFile(fsys, fpath)
+	if err != nil {
+		return zlog.Error("read", fpath, err)
+	}
+	name := path.Base(fpath)
+	ext := path.Ext(name)
+	lang := strings.TrimSuffix(name, ext)
+	var msgs []Message
+	if ext == jsonExtension {
+		msgs, err = ParseJSONCatalog(data)
+	} else {
+		msgs, err = ParsePO(bytes.NewReader(data))
+	}
+	if err != nil {
+		return zlog.Error("parse", fpath, err)
+	}
+	for _, m := range msgs {
+		if m.ID != "" {
+			c.Add(lang, m)
+		}
+	}
+	return nil
+}
+
+// ParseJSONCatalog parses a json catalog into messages.
+func ParseJSONCatalog(data []byte) ([]Message, error) {
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []Message
+	for id, r := range raw {
+		m := Message{ID: id}
+		var str string
+		if json.Unmarshal(r, &str) == nil {
+			m.Translation = []string{str}
+		} else {
+			err = json.Unmarshal(r, &m.Translation)
+			if err != nil {
+				return nil, zlog.Error("translation isn't string or array", id, err)
+			}
+			m.Plural = id
+		}
+		msgs = append(msgs, m)
+	}
+	sortMessages(msgs)
+	return msgs, nil
+}
+
+// MarshalJSONCatalog makes a json catalog of msgs, indented for editing by hand. A header entry is skipped.
+func MarshalJSONCatalog(msgs []Message) ([]byte, error) {
+	raw := map[string]any{}
+	for _, m := range msgs {
+		if m.ID == "" {
+			continue
+		}
+		if m.Plural != "" {
+			trans := m.Translation
+			if trans == nil {
+				trans = []string{}
+			}
+			raw[m.ID] = trans
+			continue
+		}
+		var str string
+		if len(m.Translation) != 0 {
+			str = m.Translation[0]
+		}
+		raw[m.ID] = str
+	}
+	return json.MarshalIndent(raw, "", "\t")
+}
+
+// langFallbacks returns lang, and its language code without country if different, like "pt-BR", "pt".
+// nb and nn fall back to no, as GetLangCodeAndCountryFromLocaleId does.
+func langFallbacks(lang string) []string {
+	langs := []string{lang}
+	code := NormalizedLanguageCode(lang)
+	if code != lang {
+		langs = append(langs, code)
+	}
+	return langs
+}
+
+// NormalizedLanguageCode returns the lower-case language code of a locale id like "nb_NO.UTF-8" or "en-US", mapping nb and nn to no.
+func NormalizedLanguageCode(locale string) string {
+	code, _, _ := strings.Cut(locale, ".")
+	code, _, _ = strings.Cut(code, "@")
+	code, _, _ = strings.Cut(code, "_")
+	code, _, _ = strings.Cut(code, "-")
+	code = strings.ToLower(code)
+	switch code {
+	case "nb", "nn":
+		return "no"
+	}
+	return code
+}
+
+func (c *Catalog) lookup(lang, context, id string) *Message {
+	key := messageKey(context, id)
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, l := range langFallbacks(lang) {
+		m := c.langs[l][key]
+		if m != nil && !m.Fuzzy && len(m.Translation) != 0 && m.Translation[0] != "" {
+			return m
+		}
+	}
+	return nil
+}
+
+// Translate returns str translated to lang, or false if it has no translation.
+func (c *Catalog) Translate(str, lang string) (string, bool) {
+	m := c.lookup(lang, "", str)
+	if m == nil {
+		return str, false
+	}
+	return m.Translation[0], true
+}
+
+// TranslatePlural returns the plural form of word's translation to lang for count, using zwords.PluralFormIndex.
+func (c *Catalog) TranslatePlural(word, plural string, count float64, lang string) (string, bool) {
+	m := c.lookup(lang, "", word)
+	if m == nil || m.Plural == "" {
+		return "", false
+	}
+	i := zwords.PluralFormIndex(lang, count)
+	if i >= len(m.Translation) || m.Translation[i] == "" {
+		return "", false
+	}
+	return m.Translation[i], true
+}
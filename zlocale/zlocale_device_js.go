@@ -0,0 +1,12 @@
+package zlocale
+
+import "syscall/js"
+
+// deviceLocale returns the browser's preferred language, like nb-NO.
+func deviceLocale() string {
+	lang := js.Global().Get("navigator").Get("language")
+	if lang.IsUndefined() {
+		return ""
+	}
+	return lang.String()
+}
@@ -0,0 +1,246 @@
+//go:build !js
+
+package zlocale
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/torlangballe/zutil/zfile"
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zstr"
+	"github.com/torlangballe/zutil/zwords"
+)
+
+// extractedFuncs are the functions whose string literal arguments are extracted,
+// with the argument index of the message, and of its plural or -1.
+var extractedFuncs = map[string][2]int{
+	"TS":                     {0, -1},
+	"TSL":                    {0, -1},
+	"Pluralize":              {0, -1},
+	"PluralizeWord":          {0, 3},
+	"PluralWordWithCount":    {0, 3},
+	"PluralizeWordWithTable": {0, 3},
+}
+
+// ExtractMessages scans the Go files in dirs and their sub-folders for calls to TS, TSL and zwords' pluralizing functions
+// with string literals, returning a message for each string, without translations.
+// A comment on the same line as the call is added as Extracted, and file:line as References.
+// Test files, testdata, vendor and hidden folders are skipped. Pluralized words become messages with plural forms.
+func ExtractMessages(dirs ...string) ([]Message, error) {
+	found := map[string]*Message{}
+	var order []string
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(fpath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			name := d.Name()
+			if d.IsDir() {
+				if fpath != dir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+				return nil
+			}
+			msgs, err := extractFromFile(fpath)
+			if err != nil {
+				return err
+			}
+			for _, m := range msgs {
+				key := messageKey(m.Context, m.ID)
+				existing := found[key]
+				if existing == nil {
+					found[key] = &m
+					order = append(order, key)
+					continue
+				}
+				if existing.Plural == "" {
+					existing.Plural = m.Plural
+				}
+				existing.References = appendMissing(existing.References, m.References...)
+				existing.Extracted = appendMissing(existing.Extracted, m.Extracted...)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, zlog.Error("walk", dir, err)
+		}
+	}
+	var all []Message
+	for _, key := range order {
+		all = append(all, *found[key])
+	}
+	sortMessages(all)
+	return all, nil
+}
+
+func appendMissing(to []string, strs ...string) []string {
+	for _, s := range strs {
+		if !zstr.StringsContain(to, s) {
+			to = append(to, s)
+		}
+	}
+	return to
+}
+
+func extractFromFile(fpath string) ([]Message, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, fpath, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	lineComments := map[int]string{}
+	for _, cg := range file.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//") {
+				lineComments[fset.Position(c.Pos()).Line] = strings.TrimSpace(c.Text[2:])
+			}
+		}
+	}
+	var msgs []Message
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, _ := n.(*ast.CallExpr)
+		if call == nil {
+			return true
+		}
+		var name string
+		switch f := call.Fun.(type) {
+		case *ast.Ident:
+			name = f.Name
+		case *ast.SelectorExpr:
+			name = f.Sel.Name
+		}
+		args, got := extractedFuncs[name]
+		if !got || len(call.Args) <= args[0] {
+			return true
+		}
+		id, got := stringLiteral(call.Args[args[0]])
+		if !got || id == "" {
+			return true
+		}
+		m := Message{ID: id}
+		if args[1] != -1 {
+			if len(call.Args) > args[1] {
+				m.Plural, _ = stringLiteral(call.Args[args[1]])
+			}
+			if m.Plural == "" {
+				m.Plural = zwords.PluralizeEnglishWord(id)
+			}
+		} else if name == "Pluralize" {
+			m.Plural = zwords.PluralizeEnglishWord(id)
+		}
+		line := fset.Position(call.End()).Line
+		comment := lineComments[line]
+		if comment != "" {
+			m.Extracted = []string{comment}
+		}
+		m.References = []string{fmt.Sprint(filepath.ToSlash(fpath), ":", fset.Position(call.Pos()).Line)}
+		msgs = append(msgs, m)
+		return true
+	})
+	return msgs, nil
+}
+
+func stringLiteral(e ast.Expr) (string, bool) {
+	lit, _ := e.(*ast.BasicLit)
+	if lit == nil || lit.Kind != token.STRING {
+		return "", false
+	}
+	str, err := strconv.Unquote(lit.Value)
+	return str, err == nil
+}
+
+// MergeMessages returns extracted with translations and translator comments from existing.
+// Existing messages not in extracted are dropped, unless keepUnused is true.
+// The header entry of existing, or a new one for lang, is kept first.
+// Plural messages get as many translations as lang has plural forms.
+func MergeMessages(lang string, existing, extracted []Message, keepUnused bool) []Message {
+	old := map[string]Message{}
+	header := POHeader(lang)
+	for _, m := range existing {
+		if m.ID == "" {
+			header = m
+			continue
+		}
+		old[messageKey(m.Context, m.ID)] = m
+	}
+	merged := []Message{header}
+	for _, m := range extracted {
+		key := messageKey(m.Context, m.ID)
+		o, got := old[key]
+		if got {
+			m.Translation = o.Translation
+			m.Comments = o.Comments
+			m.Fuzzy = o.Fuzzy
+			delete(old, key)
+		}
+		if m.Plural != "" {
+			for len(m.Translation) < zwords.PluralFormsCount(lang) {
+				m.Translation = append(m.Translation, "")
+			}
+		} else if len(m.Translation) > 1 {
+			m.Translation = m.Translation[:1]
+		}
+		merged = append(merged, m)
+	}
+	if keepUnused {
+		for _, m := range old {
+			merged = append(merged, m)
+		}
+	}
+	sortMessages(merged)
+	return merged
+}
+
+// UpdateCatalogFile merges extracted into the .po or .json catalog at fpath, creating it if it doesn't exist.
+// The language is gotten from the file name, like no.po.
+func UpdateCatalogFile(fpath string, extracted []Message, keepUnused bool) error {
+	ext := filepath.Ext(fpath)
+	lang := strings.TrimSuffix(filepath.Base(fpath), ext)
+	var existing []Message
+	if !zfile.NotExists(fpath) {
+		data, err := os.ReadFile(fpath)
+		if err != nil {
+			return zlog.Error("read", fpath, err)
+		}
+		if ext == jsonExtension {
+			existing, err = ParseJSONCatalog(data)
+		} else {
+			existing, err = ParsePO(bytes.NewReader(data))
+		}
+		if err != nil {
+			return zlog.Error("parse", fpath, err)
+		}
+	}
+	merged := MergeMessages(lang, existing, extracted, keepUnused)
+	var buf bytes.Buffer
+	if ext == jsonExtension {
+		data, err := MarshalJSONCatalog(merged)
+		if err != nil {
+			return zlog.Error("marshal", fpath, err)
+		}
+		buf.Write(data)
+		buf.WriteString("\n")
+	} else {
+		err := WritePO(&buf, merged)
+		if err != nil {
+			return zlog.Error("write po", fpath, err)
+		}
+	}
+	err := os.WriteFile(fpath, buf.Bytes(), 0644)
+	if err != nil {
+		return zlog.Error("write", fpath, err)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package zlocale
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/torlangballe/zutil/zstr"
+	"github.com/torlangballe/zutil/zwords"
+)
+
+// DateOrder is the order of day, month and year in a numeric date.
+type DateOrder string
+
+const (
+	DayMonthYear DateOrder = "DMY"
+	MonthDayYear DateOrder = "MDY"
+	YearMonthDay DateOrder = "YMD"
+)
+
+// RelativeUnit is a unit used in relative times like "3 minutes ago".
+type RelativeUnit int
+
+const (
+	RelativeSecond RelativeUnit = iota
+	RelativeMinute
+	RelativeHour
+	RelativeDay
+	RelativeWeek
+	RelativeMonth
+	RelativeYear
+)
+
+// RelativeWords are a language's words for relative times.
+// Ago and In are patterns with %s for the count and unit. Units has the plural forms of each unit
+// in the order of zwords.PluralFormIndex, FutureUnits is used with In if the language inflects them differently.
+type RelativeWords struct {
+	Now           string
+	Ago           string
+	In            string
+	UnitSeparator string // between count and unit, " " in most languages
+	Units         [][]string
+	FutureUnits   [][]string
+}
+
+// LocaleData is how a locale formats numbers, dates and units.
+type LocaleData struct {
+	Decimal         string
+	Group           string
+	MinGrouping     int  // numbers with fewer than 3+MinGrouping integer digits aren't grouped, 1 if 0
+	CurrencyFirst   bool // the currency symbol is before the amount
+	CurrencySpace   bool // there is a space between currency symbol and amount
+	DateOrder       DateOrder
+	DateSeparator   string
+	DatePadded      bool   // day and month are padded with 0 to two digits
+	TimeSeparator   string // between hours and minutes
+	Use24Hour       bool
+	MondayFirst     bool
+	Imperial        bool // distances, speeds, weights and temperatures are in miles, mph, pounds and fahrenheit
+	RelativeWords   RelativeWords
+	fallbackEnglish bool
+}
+
+// Formatter formats numbers, currencies, relative times and units for a locale.
+// Dates are formatted with its LocaleData by ztime.FormatLocalized.
+// Its fields can be changed, for instance from user preferences like IsUse24HourClock.
+type Formatter struct {
+	Locale   string // BCP-47 code it was made for, like nb-NO
+	LangCode string // normalized language code, like no
+	LocaleData
+}
+
+type currencyInfo struct {
+	Symbol     string
+	Decimals   int
+	LocalLangs []string // if set, Symbol is only used in these languages, others use the currency code
+}
+
+// NewFormatter returns a formatter for the BCP-47 code bcp, like "en-US", "nb-NO" or "pt-BR".
+// Languages without data format as English. A region can change things like date order and units.
+// bcp "" uses CurrentLanguage().
+func NewFormatter(bcp string) *Formatter {
+	if bcp == "" {
+		bcp = CurrentLanguage()
+	}
+	f := &Formatter{Locale: bcp, LangCode: NormalizedLanguageCode(bcp)}
+	data, got := localeDataTable[f.LangCode]
+	if !got {
+		data = localeDataTable["en"]
+		data.fallbackEnglish = true
+	}
+	f.LocaleData = data
+	region := localeRegion(bcp)
+	override := regionOverrides[f.LangCode+"-"+region]
+	if override == nil {
+		override = regionOverrides[region]
+	}
+	if override != nil {
+		override(&f.LocaleData)
+	}
+	return f
+}
+
+// localeRegion returns the upper-case region of a locale id like "en-GB" or "nb_NO.UTF-8", or "".
+func localeRegion(bcp string) string {
+	id, _, _ := strings.Cut(bcp, ".")
+	id = strings.ReplaceAll(id, "_", "-")
+	parts := strings.Split(id, "-")
+	for _, p := range parts[1:] {
+		if len(p) == 2 {
+			return strings.ToUpper(p)
+		}
+	}
+	return ""
+}
+
+// FormatInt formats n with the locale's digit grouping.
+func (f *Formatter) FormatInt(n int64) string {
+	str := strconv.FormatInt(n, 10)
+	neg := strings.HasPrefix(str, "-")
+	if neg {
+		str = str[1:]
+	}
+	str = f.group(str)
+	if neg {
+		return "-" + str
+	}
+	return str
+}
+
+// FormatNumber formats n with decimals digits after the decimal separator, or as few as needed if decimals is -1.
+func (f *Formatter) FormatNumber(n float64, decimals int) string {
+	if math.IsInf(n, 0) || math.IsNaN(n) {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	str := strconv.FormatFloat(math.Abs(n), 'f', decimals, 64)
+	whole, fract, _ := strings.Cut(str, ".")
+	str = f.group(whole)
+	if fract != "" {
+		str += f.Decimal + fract
+	}
+	if n < 0 && strings.Trim(str, "0"+f.Decimal+f.Group) != "" {
+		return "-" + str
+	}
+	return str
+}
+
+// FormatNice formats n like zwords.NiceFloat, with at most significant decimals and no trailing zeros.
+func (f *Formatter) FormatNice(n float64, significant int) string {
+	s := zwords.NiceFloat(n, significant)
+	num, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	return f.FormatNumber(num, -1)
+}
+
+func (f *Formatter) group(digits string) string {
+	minGrouping := max(1, f.MinGrouping)
+	if len(digits) < 3+minGrouping || f.Group == "" {
+		return digits
+	}
+	var parts []string
+	for len(digits) > 3 {
+		parts = append([]string{digits[len(digits)-3:]}, parts...)
+		digits = digits[:len(digits)-3]
+	}
+	parts = append([]string{digits}, parts...)
+	return strings.Join(parts, f.Group)
+}
+
+// FormatCurrency formats amount in the currency with ISO 4217 code, like "USD", with its symbol if known.
+func (f *Formatter) FormatCurrency(amount float64, code string) string {
+	info, got := currencies[code]
+	symbol := code
+	decimals := 2
+	if got {
+		decimals = info.Decimals
+		if len(info.LocalLangs) == 0 || zstr.StringsContain(info.LocalLangs, f.LangCode) {
+			symbol = info.Symbol
+		}
+	}
+	num := f.FormatNumber(math.Abs(amount), decimals)
+	space := ""
+	if f.CurrencySpace || symbol == code {
+		space = nbsp
+	}
+	var str string
+	if f.CurrencyFirst {
+		str = symbol + space + num
+	} else {
+		str = num + space + symbol
+	}
+	if amount < 0 && num != f.FormatNumber(0, decimals) {
+		return "-" + str
+	}
+	return str
+}
+
+// FormatRelative formats how long ago t was, or how long until it is, compared to now, like "3 minutes ago" or "in 2 days".
+// It uses the largest unit with a count of at least one, rounding down, and Now for under a second.
+func (f *Formatter) FormatRelative(t, now time.Time) string {
+	d := t.Sub(now)
+	future := d > 0
+	if d < 0 {
+		d = -d
+	}
+	w := f.RelativeWords
+	if d < time.Second {
+		return w.Now
+	}
+	unit, count := relativeUnitAndCount(d)
+	forms := w.Units[unit]
+	pattern := w.Ago
+	if future {
+		pattern = w.In
+		if len(w.FutureUnits) != 0 {
+			forms = w.FutureUnits[unit]
+		}
+	}
+	i := zwords.PluralFormIndex(f.LangCode, float64(count))
+	if f.fallbackEnglish {
+		i = zwords.PluralFormIndex("en", float64(count))
+	}
+	i = min(i, len(forms)-1)
+	str := f.FormatInt(count) + w.UnitSeparator + forms[i]
+	return strings.Replace(pattern, "%s", str, 1)
+}
+
+func relativeUnitAndCount(d time.Duration) (RelativeUnit, int64) {
+	const day = 24 * time.Hour
+	switch {
+	case d < time.Minute:
+		return RelativeSecond, int64(d / time.Second)
+	case d < time.Hour:
+		return RelativeMinute, int64(d / time.Minute)
+	case d < day:
+		return RelativeHour, int64(d / time.Hour)
+	case d < 7*day:
+		return RelativeDay, int64(d / day)
+	case d < 30*day:
+		return RelativeWeek, int64(d / (7 * day))
+	case d < 365*day:
+		return RelativeMonth, int64(d / (30 * day))
+	}
+	return RelativeYear, int64(d / (365 * day))
+}
+
+// FormatStorageSize formats a number of bytes with K, M, G etc prefixes in multiples of 1000.
+func (f *Formatter) FormatStorageSize(b int64, maxSignificant int) string {
+	return f.localizeDecimal(zwords.GetStorageSizeString(b, f.LangCode, maxSignificant))
+}
+
+// FormatMemory formats a number of bytes with K, M, G etc prefixes in multiples of 1024.
+func (f *Formatter) FormatMemory(b int64, maxSignificant int) string {
+	return f.localizeDecimal(zwords.GetMemoryString(b, f.LangCode, maxSignificant))
+}
+
+func (f *Formatter) localizeDecimal(str string) string {
+	num, unit, _ := strings.Cut(str, " ")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return str
+	}
+	return f.FormatNumber(n, -1) + nbsp + unit
+}
+
+// withUnit formats n with a no-break space before unit, so they aren't wrapped apart.
+func (f *Formatter) withUnit(n float64, decimals int, unit string) string {
+	return f.FormatNumber(n, decimals) + nbsp + unit
+}
+
+// FormatDistance formats meters as m or km, or as ft or mi if Imperial.
+func (f *Formatter) FormatDistance(meters float64, decimals int) string {
+	if f.Imperial {
+		const feetPerMeter, metersPerMile = 3.28084, 1609.344
+		if math.Abs(meters) < metersPerMile/10 {
+			return f.withUnit(meters*feetPerMeter, 0, "ft")
+		}
+		return f.withUnit(meters/metersPerMile, decimals, "mi")
+	}
+	if math.Abs(meters) < 1000 {
+		return f.withUnit(meters, 0, "m")
+	}
+	return f.withUnit(meters/1000, decimals, "km")
+}
+
+// FormatSpeed formats meters per second as km/h, or mph if Imperial.
+func (f *Formatter) FormatSpeed(metersPerSecond float64, decimals int) string {
+	if f.Imperial {
+		return f.withUnit(metersPerSecond*2.236936, decimals, "mph")
+	}
+	return f.withUnit(metersPerSecond*3.6, decimals, "km/h")
+}
+
+// FormatWeight formats kilograms as kg, or lb if Imperial.
+func (f *Formatter) FormatWeight(kg float64, decimals int) string {
+	if f.Imperial {
+		return f.withUnit(kg*2.2046226, decimals, "lb")
+	}
+	return f.withUnit(kg, decimals, "kg")
+}
+
+// FormatTemperature formats degrees celsius as °C, or °F if Imperial.
+func (f *Formatter) FormatTemperature(celsius float64, decimals int) string {
+	if f.Imperial {
+		return f.withUnit(celsius*9/5+32, decimals, "°F")
+	}
+	return f.withUnit(celsius, decimals, "°C")
+}
@@ -0,0 +1,192 @@
+package zlocale
+
+// The locale data is from CLDR, simplified to what Formatter uses.
+
+const (
+	nbsp       = "\u00a0" // no-break space, used as group separator
+	narrowNbsp = "\u202f" // narrow no-break space, French group separator
+)
+
+func units(second, minute, hour, day, week, month, year []string) [][]string {
+	return [][]string{second, minute, hour, day, week, month, year}
+}
+
+var localeDataTable = map[string]LocaleData{
+	"en": {
+		Decimal: ".", Group: ",", CurrencyFirst: true,
+		DateOrder: MonthDayYear, DateSeparator: "/", TimeSeparator: ":", Imperial: true,
+		RelativeWords: RelativeWords{Now: "now", Ago: "%s ago", In: "in %s", UnitSeparator: " ",
+			Units: units([]string{"second", "seconds"}, []string{"minute", "minutes"}, []string{"hour", "hours"},
+				[]string{"day", "days"}, []string{"week", "weeks"}, []string{"month", "months"}, []string{"year", "years"}),
+		},
+	},
+	"no": {
+		Decimal: ",", Group: nbsp, CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: ".", DatePadded: true, TimeSeparator: ":", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "nå", Ago: "for %s siden", In: "om %s", UnitSeparator: " ",
+			Units: units([]string{"sekund", "sekunder"}, []string{"minutt", "minutter"}, []string{"time", "timer"},
+				[]string{"dag", "dager"}, []string{"uke", "uker"}, []string{"måned", "måneder"}, []string{"år", "år"}),
+		},
+	},
+	"sv": {
+		Decimal: ",", Group: nbsp, CurrencySpace: true,
+		DateOrder: YearMonthDay, DateSeparator: "-", DatePadded: true, TimeSeparator: ":", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "nu", Ago: "för %s sedan", In: "om %s", UnitSeparator: " ",
+			Units: units([]string{"sekund", "sekunder"}, []string{"minut", "minuter"}, []string{"timme", "timmar"},
+				[]string{"dag", "dagar"}, []string{"vecka", "veckor"}, []string{"månad", "månader"}, []string{"år", "år"}),
+		},
+	},
+	"da": {
+		Decimal: ",", Group: ".", CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: ".", DatePadded: true, TimeSeparator: ".", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "nu", Ago: "for %s siden", In: "om %s", UnitSeparator: " ",
+			Units: units([]string{"sekund", "sekunder"}, []string{"minut", "minutter"}, []string{"time", "timer"},
+				[]string{"dag", "dage"}, []string{"uge", "uger"}, []string{"måned", "måneder"}, []string{"år", "år"}),
+		},
+	},
+	"de": {
+		Decimal: ",", Group: ".", CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: ".", DatePadded: true, TimeSeparator: ":", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "jetzt", Ago: "vor %s", In: "in %s", UnitSeparator: " ",
+			Units: units([]string{"Sekunde", "Sekunden"}, []string{"Minute", "Minuten"}, []string{"Stunde", "Stunden"},
+				[]string{"Tag", "Tagen"}, []string{"Woche", "Wochen"}, []string{"Monat", "Monaten"}, []string{"Jahr", "Jahren"}),
+		},
+	},
+	"nl": {
+		Decimal: ",", Group: ".", CurrencyFirst: true, CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: "-", DatePadded: true, TimeSeparator: ":", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "nu", Ago: "%s geleden", In: "over %s", UnitSeparator: " ",
+			Units: units([]string{"seconde", "seconden"}, []string{"minuut", "minuten"}, []string{"uur", "uur"},
+				[]string{"dag", "dagen"}, []string{"week", "weken"}, []string{"maand", "maanden"}, []string{"jaar", "jaar"}),
+		},
+	},
+	"fr": {
+		Decimal: ",", Group: narrowNbsp, CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: "/", DatePadded: true, TimeSeparator: ":", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "maintenant", Ago: "il y a %s", In: "dans %s", UnitSeparator: " ",
+			Units: units([]string{"seconde", "secondes"}, []string{"minute", "minutes"}, []string{"heure", "heures"},
+				[]string{"jour", "jours"}, []string{"semaine", "semaines"}, []string{"mois", "mois"}, []string{"an", "ans"}),
+		},
+	},
+	"es": {
+		Decimal: ",", Group: ".", MinGrouping: 2, CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: "/", DatePadded: true, TimeSeparator: ":", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "ahora", Ago: "hace %s", In: "dentro de %s", UnitSeparator: " ",
+			Units: units([]string{"segundo", "segundos"}, []string{"minuto", "minutos"}, []string{"hora", "horas"},
+				[]string{"día", "días"}, []string{"semana", "semanas"}, []string{"mes", "meses"}, []string{"año", "años"}),
+		},
+	},
+	"it": {
+		Decimal: ",", Group: ".", CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: "/", DatePadded: true, TimeSeparator: ":", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "ora", Ago: "%s fa", In: "tra %s", UnitSeparator: " ",
+			Units: units([]string{"secondo", "secondi"}, []string{"minuto", "minuti"}, []string{"ora", "ore"},
+				[]string{"giorno", "giorni"}, []string{"settimana", "settimane"}, []string{"mese", "mesi"}, []string{"anno", "anni"}),
+		},
+	},
+	"pt": {
+		Decimal: ",", Group: ".", CurrencyFirst: true, CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: "/", DatePadded: true, TimeSeparator: ":", Use24Hour: true,
+		RelativeWords: RelativeWords{Now: "agora", Ago: "há %s", In: "em %s", UnitSeparator: " ",
+			Units: units([]string{"segundo", "segundos"}, []string{"minuto", "minutos"}, []string{"hora", "horas"},
+				[]string{"dia", "dias"}, []string{"semana", "semanas"}, []string{"mês", "meses"}, []string{"ano", "anos"}),
+		},
+	},
+	"fi": {
+		Decimal: ",", Group: nbsp, CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: ".", TimeSeparator: ".", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "nyt", Ago: "%s sitten", In: "%s päästä", UnitSeparator: " ",
+			Units: units([]string{"sekunti", "sekuntia"}, []string{"minuutti", "minuuttia"}, []string{"tunti", "tuntia"},
+				[]string{"päivä", "päivää"}, []string{"viikko", "viikkoa"}, []string{"kuukausi", "kuukautta"}, []string{"vuosi", "vuotta"}),
+			FutureUnits: units([]string{"sekunnin"}, []string{"minuutin"}, []string{"tunnin"},
+				[]string{"päivän"}, []string{"viikon"}, []string{"kuukauden"}, []string{"vuoden"}),
+		},
+	},
+	"pl": {
+		Decimal: ",", Group: nbsp, MinGrouping: 2, CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: ".", DatePadded: true, TimeSeparator: ":", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "teraz", Ago: "%s temu", In: "za %s", UnitSeparator: " ",
+			Units: units([]string{"sekundę", "sekundy", "sekund"}, []string{"minutę", "minuty", "minut"}, []string{"godzinę", "godziny", "godzin"},
+				[]string{"dzień", "dni", "dni"}, []string{"tydzień", "tygodnie", "tygodni"}, []string{"miesiąc", "miesiące", "miesięcy"}, []string{"rok", "lata", "lat"}),
+		},
+	},
+	"ru": {
+		Decimal: ",", Group: nbsp, CurrencySpace: true,
+		DateOrder: DayMonthYear, DateSeparator: ".", DatePadded: true, TimeSeparator: ":", Use24Hour: true, MondayFirst: true,
+		RelativeWords: RelativeWords{Now: "сейчас", Ago: "%s назад", In: "через %s", UnitSeparator: " ",
+			Units: units([]string{"секунду", "секунды", "секунд"}, []string{"минуту", "минуты", "минут"}, []string{"час", "часа", "часов"},
+				[]string{"день", "дня", "дней"}, []string{"неделю", "недели", "недель"}, []string{"месяц", "месяца", "месяцев"}, []string{"год", "года", "лет"}),
+		},
+	},
+	"ja": {
+		Decimal: ".", Group: ",", CurrencyFirst: true,
+		DateOrder: YearMonthDay, DateSeparator: "/", DatePadded: true, TimeSeparator: ":", Use24Hour: true,
+		RelativeWords: RelativeWords{Now: "今", Ago: "%s前", In: "%s後",
+			Units: units([]string{"秒"}, []string{"分"}, []string{"時間"}, []string{"日"}, []string{"週間"}, []string{"か月"}, []string{"年"}),
+		},
+	},
+	"zh": {
+		Decimal: ".", Group: ",", CurrencyFirst: true,
+		DateOrder: YearMonthDay, DateSeparator: "/", TimeSeparator: ":", Use24Hour: true,
+		RelativeWords: RelativeWords{Now: "现在", Ago: "%s前", In: "%s后",
+			Units: units([]string{"秒钟"}, []string{"分钟"}, []string{"小时"}, []string{"天"}, []string{"周"}, []string{"个月"}, []string{"年"}),
+		},
+	},
+}
+
+// regionOverrides change a language's data for a region, keyed by "lang-REGION" or just region.
+var regionOverrides = map[string]func(d *LocaleData){
+	"en-GB": func(d *LocaleData) {
+		d.DateOrder, d.DatePadded, d.Use24Hour, d.MondayFirst, d.Imperial = DayMonthYear, true, true, true, false
+	},
+	"en-IE": func(d *LocaleData) {
+		d.DateOrder, d.DatePadded, d.Use24Hour, d.MondayFirst, d.Imperial = DayMonthYear, true, true, true, false
+	},
+	"en-AU": func(d *LocaleData) {
+		d.DateOrder, d.DatePadded, d.MondayFirst, d.Imperial = DayMonthYear, true, true, false
+	},
+	"en-NZ": func(d *LocaleData) {
+		d.DateOrder, d.DatePadded, d.MondayFirst, d.Imperial = DayMonthYear, true, true, false
+	},
+	"en-IN": func(d *LocaleData) {
+		d.DateOrder, d.DatePadded, d.Imperial = DayMonthYear, true, false
+	},
+	"en-CA": func(d *LocaleData) {
+		d.DateOrder, d.DateSeparator, d.DatePadded, d.Imperial = YearMonthDay, "-", true, false
+	},
+	"pt-PT": func(d *LocaleData) {
+		d.Group, d.CurrencyFirst, d.MondayFirst = nbsp, false, true
+	},
+	"de-CH": func(d *LocaleData) {
+		d.Decimal, d.Group, d.CurrencyFirst = ".", "’", true
+	},
+	"fr-CA": func(d *LocaleData) {
+		d.Group, d.DateOrder, d.DateSeparator, d.MondayFirst = nbsp, YearMonthDay, "-", false
+	},
+	"US": func(d *LocaleData) {
+		d.Imperial = true
+	},
+	"LR": func(d *LocaleData) {
+		d.Imperial = true
+	},
+	"MM": func(d *LocaleData) {
+		d.Imperial = true
+	},
+}
+
+var currencies = map[string]currencyInfo{
+	"USD": {Symbol: "$", Decimals: 2},
+	"EUR": {Symbol: "€", Decimals: 2},
+	"GBP": {Symbol: "£", Decimals: 2},
+	"JPY": {Symbol: "¥", Decimals: 0},
+	"CNY": {Symbol: "¥", Decimals: 2, LocalLangs: []string{"zh"}},
+	"NOK": {Symbol: "kr", Decimals: 2, LocalLangs: []string{"no"}},
+	"SEK": {Symbol: "kr", Decimals: 2, LocalLangs: []string{"sv"}},
+	"DKK": {Symbol: "kr.", Decimals: 2, LocalLangs: []string{"da"}},
+	"CHF": {Symbol: "CHF", Decimals: 2},
+	"PLN": {Symbol: "zł", Decimals: 2, LocalLangs: []string{"pl"}},
+	"RUB": {Symbol: "₽", Decimals: 2},
+	"BRL": {Symbol: "R$", Decimals: 2},
+	"INR": {Symbol: "₹", Decimals: 2},
+	"KRW": {Symbol: "₩", Decimals: 0},
+}
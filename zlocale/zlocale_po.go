@@ -0,0 +1,187 @@
+package zlocale
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zwords"
+)
+
+// ParsePO parses a gettext .po file into messages.
+// The header entry with an empty ID is included. Obsolete #~ entries are skipped.
+func ParsePO(r io.Reader) ([]Message, error) {
+	var msgs []Message
+	var m Message
+	var target *string // the string continuation lines are added to
+	started := false
+	flush := func() {
+		if started {
+			msgs = append(msgs, m)
+		}
+		m = Message{}
+		target = nil
+		started = false
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if started {
+				flush()
+			}
+			switch {
+			case strings.HasPrefix(line, "#~"), strings.HasPrefix(line, "#|"):
+			case strings.HasPrefix(line, "#."):
+				m.Extracted = append(m.Extracted, strings.TrimSpace(line[2:]))
+			case strings.HasPrefix(line, "#:"):
+				m.References = append(m.References, strings.Fields(line[2:])...)
+			case strings.HasPrefix(line, "#,"):
+				for _, f := range strings.Split(line[2:], ",") {
+					if strings.TrimSpace(f) == "fuzzy" {
+						m.Fuzzy = true
+					}
+				}
+			default:
+				m.Comments = append(m.Comments, strings.TrimSpace(line[1:]))
+			}
+			continue
+		}
+		if strings.HasPrefix(line, `"`) {
+			if target == nil {
+				return nil, zlog.NewError("string without keyword on line", lineNo)
+			}
+			str, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, zlog.Error("bad string on line", lineNo, err)
+			}
+			*target += str
+			continue
+		}
+		keyword, rest, _ := strings.Cut(line, " ")
+		str, err := strconv.Unquote(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, zlog.Error("bad string on line", lineNo, err)
+		}
+		switch {
+		case keyword == "msgctxt":
+			if started {
+				flush()
+			}
+			m.Context = str
+			target = &m.Context
+		case keyword == "msgid":
+			if started && target != &m.Context {
+				flush()
+			}
+			m.ID = str
+			target = &m.ID
+		case keyword == "msgid_plural":
+			m.Plural = str
+			target = &m.Plural
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+			i := 0
+			if keyword != "msgstr" {
+				i, err = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(keyword, "msgstr["), "]"))
+				if err != nil {
+					return nil, zlog.Error("bad plural index on line", lineNo, err)
+				}
+			}
+			for len(m.Translation) <= i {
+				m.Translation = append(m.Translation, "")
+			}
+			m.Translation[i] = str
+			target = &m.Translation[i]
+		default:
+			return nil, zlog.NewError("unknown keyword on line", lineNo, keyword)
+		}
+		started = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return msgs, nil
+}
+
+// POHeader returns a header entry for a .po file for lang, with its Plural-Forms.
+func POHeader(lang string) Message {
+	var header string
+	header += "Language: " + lang + "\n"
+	header += "MIME-Version: 1.0\n"
+	header += "Content-Type: text/plain; charset=UTF-8\n"
+	header += "Content-Transfer-Encoding: 8bit\n"
+	header += "Plural-Forms: " + zwords.PluralFormsHeader(lang) + "\n"
+	return Message{Translation: []string{header}}
+}
+
+// WritePO writes msgs as a gettext .po file. A message with an empty ID is written first, as the header.
+func WritePO(w io.Writer, msgs []Message) error {
+	bw := bufio.NewWriter(w)
+	sorted := append([]Message{}, msgs...)
+	sortMessages(sorted)
+	for i, m := range sorted {
+		if i != 0 {
+			bw.WriteString("\n")
+		}
+		for _, c := range m.Comments {
+			bw.WriteString(strings.TrimSpace("# "+c) + "\n")
+		}
+		for _, c := range m.Extracted {
+			bw.WriteString("#. " + c + "\n")
+		}
+		if len(m.References) != 0 {
+			bw.WriteString("#: " + strings.Join(m.References, " ") + "\n")
+		}
+		if m.Fuzzy {
+			bw.WriteString("#, fuzzy\n")
+		}
+		if m.Context != "" {
+			writePOString(bw, "msgctxt", m.Context)
+		}
+		writePOString(bw, "msgid", m.ID)
+		if m.Plural == "" {
+			var str string
+			if len(m.Translation) != 0 {
+				str = m.Translation[0]
+			}
+			writePOString(bw, "msgstr", str)
+			continue
+		}
+		writePOString(bw, "msgid_plural", m.Plural)
+		trans := m.Translation
+		if len(trans) == 0 {
+			trans = []string{"", ""}
+		}
+		for j, t := range trans {
+			writePOString(bw, fmt.Sprintf("msgstr[%d]", j), t)
+		}
+	}
+	return bw.Flush()
+}
+
+// writePOString writes a keyword and quoted string, splitting it in lines after each \n if it has several.
+func writePOString(w *bufio.Writer, keyword, str string) {
+	lines := strings.SplitAfter(str, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= 1 {
+		w.WriteString(keyword + " " + strconv.Quote(str) + "\n")
+		return
+	}
+	w.WriteString(keyword + " \"\"\n")
+	for _, l := range lines {
+		w.WriteString(strconv.Quote(l) + "\n")
+	}
+}
@@ -0,0 +1,191 @@
+package zlocale
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/torlangballe/zutil/ztesting"
+	"github.com/torlangballe/zutil/zwords"
+)
+
+const testPO = `# Norwegian translations
+msgid ""
+msgstr ""
+"Language: no\n"
+
+#. generic name for login button etc
+#: zwords/zwords.go:132
+msgid "Log in"
+msgstr "Logg inn"
+
+msgid "hour"
+msgid_plural "hours"
+msgstr[0] "time"
+msgstr[1] "timer"
+
+#, fuzzy
+msgid "Log out"
+msgstr "Logg ut"
+
+msgid "Multi"
+msgstr ""
+"line one\n"
+"line two"
+`
+
+func TestCatalog(t *testing.T) {
+	ztesting.Equal(t, zwords.DefaultLanguage, "en", "zwords language not from device until SetLanguage")
+	fsys := fstest.MapFS{
+		"translations/no.po":   {Data: []byte(testPO)},
+		"translations/ru.json": {Data: []byte(`{ "file": ["файл", "файла", "файлов"], "Log in": "Войти" }`)},
+	}
+	err := DefaultCatalog.LoadFS(fsys, "translations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		DefaultCatalog = NewCatalog()
+		SetLanguage("")
+	}()
+	ztesting.Equal(t, strings.Join(DefaultCatalog.Languages(), ","), "no,ru", "languages")
+	SetLanguage("en")
+	ztesting.Equal(t, TS("Log in"), "Log in", "untranslated")
+	var changed string
+	AddLanguageChangedHandler(func(lang string) {
+		changed = lang
+	})
+	SetLanguage("nb-NO")
+	ztesting.Equal(t, changed, "nb-NO", "changed handler")
+	ztesting.Equal(t, TS("Log in"), "Logg inn", "nb-NO falls back to no")
+	ztesting.Equal(t, TS("Log out"), "Log out", "fuzzy not used")
+	ztesting.Equal(t, TS("Multi"), "line one\nline two", "multi-line")
+	ztesting.Equal(t, TSL("Log in", "ru"), "Войти", "TSL")
+	ztesting.Equal(t, zwords.TS("Log in"), "Logg inn", "zwords hook")
+
+	ztesting.Equal(t, zwords.PluralizeWord("hour", 1, "no", ""), "time", "no singular")
+	ztesting.Equal(t, zwords.PluralizeWord("hour", 3, "no", ""), "timer", "no plural")
+	ztesting.Equal(t, zwords.PluralizeWord("file", 1, "ru", ""), "файл", "ru 1")
+	ztesting.Equal(t, zwords.PluralizeWord("file", 3, "ru", ""), "файла", "ru 3")
+	ztesting.Equal(t, zwords.PluralizeWord("file", 11, "ru", ""), "файлов", "ru 11")
+	ztesting.Equal(t, zwords.PluralizeWord("file", 21, "ru", ""), "файл", "ru 21")
+	ztesting.Equal(t, zwords.PluralizeWord("dog", 2, "en", ""), "dogs", "fallback rules")
+	ztesting.Equal(t, zwords.PluralizeWord("file", 3, "de", ""), "file", "no rules for language")
+}
+
+func TestPORoundTrip(t *testing.T) {
+	msgs, err := ParsePO(strings.NewReader(testPO))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, len(msgs), 5, "count")
+	ztesting.Equal(t, msgs[1].Extracted[0], "generic name for login button etc", "extracted")
+	ztesting.Equal(t, msgs[1].References[0], "zwords/zwords.go:132", "reference")
+	ztesting.Equal(t, msgs[0].Comments[0], "Norwegian translations", "comment")
+	var buf bytes.Buffer
+	err = WritePO(&buf, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParsePO(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, len(again), len(msgs), "count again")
+	sortMessages(msgs)
+	for i := range msgs {
+		ztesting.Equal(t, again[i].ID, msgs[i].ID, "id")
+		ztesting.Equal(t, strings.Join(again[i].Translation, "|"), strings.Join(msgs[i].Translation, "|"), "translation")
+		ztesting.Equal(t, again[i].Fuzzy, msgs[i].Fuzzy, "fuzzy")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+	src := `package x
+
+func f(n int) {
+	a := zlocale.TS("Save") // button to save a document
+	b := TSL("Save", "de")
+	c := zwords.PluralizeWord("file", float64(n), "", "files")
+	d := TS(a) // not a literal
+}
+`
+	err := os.WriteFile(filepath.Join(dir, "x.go"), []byte(src), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := ExtractMessages(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, len(msgs), 2, "count")
+	ztesting.Equal(t, msgs[0].ID, "Save", "id")
+	ztesting.Equal(t, len(msgs[0].References), 2, "references")
+	ztesting.Equal(t, msgs[0].Extracted[0], "button to save a document", "comment")
+	ztesting.Equal(t, msgs[1].Plural, "files", "plural")
+
+	cat := filepath.Join(dir, "ru.po")
+	os.WriteFile(cat, []byte("msgid \"Save\"\nmsgstr \"Сохранить\"\n\nmsgid \"Gone\"\nmsgstr \"Нет\"\n"), 0644)
+	err = UpdateCatalogFile(cat, msgs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCatalog()
+	err = c.LoadFile(os.DirFS(dir), "ru.po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := c.Messages("ru")
+	ztesting.Equal(t, len(updated), 2, "updated count")
+	ztesting.Equal(t, updated[0].Translation[0], "Сохранить", "kept translation")
+	ztesting.Equal(t, len(updated[1].Translation), 3, "ru plural forms")
+}
+
+func TestFormatter(t *testing.T) {
+	en := NewFormatter("en-US")
+	no := NewFormatter("nb-NO")
+	de := NewFormatter("de")
+	es := NewFormatter("es")
+	ztesting.Equal(t, en.FormatNumber(-1234567.891, 2), "-1,234,567.89", "en number")
+	ztesting.Equal(t, no.FormatNumber(1234567.5, -1), "1\u00a0234\u00a0567,5", "no number")
+	ztesting.Equal(t, de.FormatInt(1234), "1.234", "de int")
+	ztesting.Equal(t, es.FormatInt(1234), "1234", "es min grouping")
+	ztesting.Equal(t, es.FormatInt(12345), "12.345", "es grouping")
+	ztesting.Equal(t, en.FormatNumber(-0.001, 2), "0.00", "no negative zero")
+
+	ztesting.Equal(t, en.FormatCurrency(1234.5, "USD"), "$1,234.50", "en USD")
+	ztesting.Equal(t, de.FormatCurrency(-1234.5, "EUR"), "-1.234,50\u00a0€", "de EUR")
+	ztesting.Equal(t, no.FormatCurrency(99, "NOK"), "99,00\u00a0kr", "no NOK")
+	ztesting.Equal(t, en.FormatCurrency(99, "NOK"), "NOK\u00a099.00", "en NOK")
+	ztesting.Equal(t, NewFormatter("ja").FormatCurrency(1234, "JPY"), "¥1,234", "ja JPY")
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	ztesting.Equal(t, en.FormatRelative(now.Add(-3*time.Minute), now), "3 minutes ago", "en ago")
+	ztesting.Equal(t, en.FormatRelative(now.Add(25*time.Hour), now), "in 1 day", "en in")
+	ztesting.Equal(t, en.FormatRelative(now, now), "now", "en now")
+	ztesting.Equal(t, no.FormatRelative(now.Add(-2*time.Hour), now), "for 2 timer siden", "no ago")
+	ztesting.Equal(t, NewFormatter("ru").FormatRelative(now.Add(-5*time.Minute), now), "5 минут назад", "ru 5")
+	ztesting.Equal(t, NewFormatter("ru").FormatRelative(now.Add(-22*time.Minute), now), "22 минуты назад", "ru 22")
+	ztesting.Equal(t, NewFormatter("fi").FormatRelative(now.Add(3*time.Hour), now), "3 tunnin päästä", "fi future")
+	ztesting.Equal(t, NewFormatter("ja").FormatRelative(now.Add(-3*time.Minute), now), "3分前", "ja ago")
+	ztesting.Equal(t, NewFormatter("xx").FormatRelative(now.Add(-time.Minute), now), "1 minute ago", "unknown falls back to en")
+
+	ztesting.Equal(t, en.FormatDistance(16093.44, 1), "10.0\u00a0mi", "en distance")
+	ztesting.Equal(t, NewFormatter("en-GB").FormatDistance(1500, 1), "1.5\u00a0km", "en-GB distance")
+	ztesting.Equal(t, de.FormatTemperature(21.5, 1), "21,5\u00a0°C", "de temperature")
+	ztesting.Equal(t, en.FormatTemperature(100, 0), "212\u00a0°F", "en temperature")
+	ztesting.Equal(t, no.FormatSpeed(10, 0), "36\u00a0km/h", "no speed")
+	ztesting.Equal(t, de.FormatStorageSize(1500000, 1), "1,5\u00a0MB", "de storage")
+	ztesting.Equal(t, len(localeDataTable) >= 15, true, "at least 15 languages")
+	for lang, d := range localeDataTable {
+		ztesting.Equal(t, len(d.RelativeWords.Units), 7, lang+" units")
+		for _, forms := range d.RelativeWords.Units {
+			ztesting.Equal(t, len(forms) == 1 || len(forms) == zwords.PluralFormsCount(lang), true, lang+" forms")
+		}
+	}
+}
@@ -49,6 +49,10 @@ var (
 
 func init() {
 	zdict.AssertFunc = Assert
+	AddHook("zdebug.RecentLogLines", zdebug.AddRecentLogLine)
+	zdebug.WarnFunc = func(parts ...any) {
+		Warn(parts...)
+	}
 	zdebug.MakeContextErrorForSignalRestartFunc = func(pos int, invokeFunc string, parts ...any) error {
 		err := NewLogError(FatalLevel, time.Now(), pos, parts...)
 		if invokeFunc == "" {
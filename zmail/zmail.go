@@ -1,6 +1,7 @@
 package zmail
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/smtp"
@@ -17,12 +18,23 @@ const (
 	SendGridType ServiceType = "sendgrid"
 )
 
+type TLSMode string
+
+const (
+	TLSAuto     TLSMode = ""         // Use STARTTLS if the server offers it
+	TLSStartTLS TLSMode = "starttls" // Require STARTTLS, typically on port 587
+	TLSImplicit TLSMode = "implicit" // Connect with TLS from the start, typically on port 465
+	TLSNone     TLSMode = "none"     // Plain connection, for local relays and testing
+)
+
 type Authentication struct {
 	ServiceType ServiceType
 	UserID      string
 	Password    string
 	Server      string
 	Port        int
+	TLS         TLSMode
+	SkipVerify  bool // don't verify the server's TLS certificate
 }
 
 type Address struct {
@@ -32,10 +44,14 @@ type Address struct {
 
 type Mail struct {
 	To          []Address
+	CC          []Address
+	BCC         []Address
 	From        Address
+	ReplyTo     Address
 	Subject     string
 	TextContent string
 	HTMLContent string
+	Attachments []Attachment
 }
 
 func (m *Mail) AddTo(name, email string) {
@@ -45,54 +61,85 @@ func (m *Mail) AddTo(name, email string) {
 // https://zetcode.com/golang/email-smtp/
 // Test with: https://www.smtper.net
 
+// SendWithSMTP sends m as a MIME message to all of To, CC and BCC in one SMTP session,
+// connecting as set in a.TLS. Authentication is skipped if a.UserID is empty.
 func (m Mail) SendWithSMTP(a Authentication) (err error) {
-	zlog.Assert(len(m.To) != 0 && m.To[0].Email != "")
-	auth := smtp.PlainAuth("", a.UserID, a.Password, a.Server)
-	server := fmt.Sprintf("%s:%d", a.Server, a.Port)
-	zlog.Info("zmail.SendSMTP:", auth, server)
-
+	zlog.Assert(len(m.AllRecipients()) != 0 && m.AllRecipients()[0] != "")
 	if m.From.Email == "" {
 		m.From.Email = a.UserID
 	}
-	zlog.Info("zmail.SendWithSMTP from:", zlog.Full(m.From))
-	var emails []string
-	bulk := true
-	for _, t := range m.To {
-		if t.Name != "" {
-			bulk = false
-			return
+	zlog.Info("zmail.SendWithSMTP from:", zlog.Full(m.From), a.Server, a.Port)
+	message, err := m.MIMEMessage()
+	if err != nil {
+		return zlog.Error("build message", err)
+	}
+	client, err := dialSMTP(a)
+	if err != nil {
+		return zlog.Error("dial", a.Server, a.Port, err)
+	}
+	defer client.Close()
+	if a.UserID != "" {
+		err = client.Auth(smtp.PlainAuth("", a.UserID, a.Password, a.Server))
+		if err != nil {
+			return zlog.Error("auth", a.UserID, err)
 		}
-		emails = append(emails, t.Email)
 	}
-	if len(emails) < 2 {
-		bulk = false
+	err = client.Mail(m.From.Email)
+	if err != nil {
+		return zlog.Error("from", m.From.Email, err)
 	}
-	header := "From: " + m.From.Email + " <" + m.From.Name + ">\r\n"
-	header += "Subject: " + m.Subject + "\r\n\r\n"
-	if bulk {
-		content := []byte(header + m.TextContent)
-		err = smtp.SendMail(server, auth, m.From.Email, emails, content)
+	for _, to := range m.AllRecipients() {
+		err = client.Rcpt(to)
 		if err != nil {
-			return zlog.Error("send", m, a)
+			return zlog.Error("recipient", to, err)
 		}
-		return nil
 	}
-	for _, t := range m.To {
-		var toheader string
-		toheader = "To: " + t.Email
-		if t.Name != "" {
-			toheader += " <" + t.Name + ">"
+	w, err := client.Data()
+	if err != nil {
+		return zlog.Error("data", err)
+	}
+	_, err = w.Write(message)
+	if err != nil {
+		return zlog.Error("write", err)
+	}
+	err = w.Close()
+	if err != nil {
+		return zlog.Error("send", err)
+	}
+	return client.Quit()
+}
+
+func dialSMTP(a Authentication) (*smtp.Client, error) {
+	server := fmt.Sprintf("%s:%d", a.Server, a.Port)
+	tlsConfig := &tls.Config{ServerName: a.Server, InsecureSkipVerify: a.SkipVerify}
+	if a.TLS == TLSImplicit {
+		conn, err := tls.Dial("tcp", server, tlsConfig)
+		if err != nil {
+			return nil, err
 		}
-		toheader += "\r\n"
-		content := []byte(toheader + header + m.TextContent)
-		// zlog.Info("SEND:::", m.From.Email, t.Email)
-		berr := smtp.SendMail(server, auth, m.From.Email, []string{t.Email}, content)
-		if berr != nil {
-			err = berr
-			zlog.Error("send single", a, err)
+		return smtp.NewClient(conn, a.Server)
+	}
+	client, err := smtp.Dial(server)
+	if err != nil {
+		return nil, err
+	}
+	if a.TLS == TLSNone {
+		return client, nil
+	}
+	has, _ := client.Extension("STARTTLS")
+	if !has {
+		if a.TLS == TLSStartTLS {
+			client.Close()
+			return nil, errors.New("server doesn't support STARTTLS")
 		}
+		return client, nil
 	}
-	return err
+	err = client.StartTLS(tlsConfig)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
 }
 
 func (m Mail) Send(a Authentication) error {
@@ -121,4 +168,3 @@ func (m Mail) SendWithPlunk(a Authentication) error {
 	_, err := zhttp.Post(surl, params, body, nil)
 	return err
 }
-
@@ -0,0 +1,313 @@
+package zmail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/torlangballe/zutil/zstr"
+)
+
+// Attachment is a file sent with a mail. If ContentID is set, it is an inline part,
+// typically an image referred to from HTMLContent as <img src="cid:ContentID">.
+type Attachment struct {
+	Name        string
+	ContentType string
+	ContentID   string
+	Data        []byte
+}
+
+func (a Address) String() string {
+	ma := mail.Address{Name: a.Name, Address: a.Email}
+	return ma.String()
+}
+
+func addressList(as []Address) string {
+	var parts []string
+	for _, a := range as {
+		parts = append(parts, a.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// AddAttachment adds data as a file attachment. If contentType is empty, it is guessed from name's extension.
+func (m *Mail) AddAttachment(name, contentType string, data []byte) {
+	m.Attachments = append(m.Attachments, Attachment{Name: name, ContentType: contentTypeForName(name, contentType), Data: data})
+}
+
+// AddInline adds data as an inline part with contentID, for use in HTMLContent as "cid:contentID".
+func (m *Mail) AddInline(contentID, name, contentType string, data []byte) {
+	m.Attachments = append(m.Attachments, Attachment{Name: name, ContentType: contentTypeForName(name, contentType), ContentID: contentID, Data: data})
+}
+
+func (m *Mail) AddAttachmentFromFile(fpath string) error {
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	m.AddAttachment(filepath.Base(fpath), "", data)
+	return nil
+}
+
+func contentTypeForName(name, contentType string) string {
+	if contentType != "" {
+		return contentType
+	}
+	contentType = mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return contentType
+}
+
+// AllRecipients returns the emails of To, CC and BCC, which are who the mail is sent to.
+func (m *Mail) AllRecipients() []string {
+	var all []string
+	for _, list := range [][]Address{m.To, m.CC, m.BCC} {
+		for _, a := range list {
+			all = append(all, a.Email)
+		}
+	}
+	return all
+}
+
+// MIMEMessage builds the message as sent with SMTP, with headers and body.
+// The body is multipart/alternative if it has both TextContent and HTMLContent,
+// wrapped in multipart/related if there are inline attachments, and multipart/mixed if there are file attachments.
+// Non-ASCII names and subject are RFC 2047 encoded. BCC is not included in the headers.
+func (m *Mail) MIMEMessage() ([]byte, error) {
+	var buf bytes.Buffer
+	h := textproto.MIMEHeader{}
+	h.Set("From", m.From.String())
+	if len(m.To) != 0 {
+		h.Set("To", addressList(m.To))
+	}
+	if len(m.CC) != 0 {
+		h.Set("Cc", addressList(m.CC))
+	}
+	if m.ReplyTo.Email != "" {
+		h.Set("Reply-To", m.ReplyTo.String())
+	}
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	h.Set("Date", time.Now().Format(time.RFC1123Z))
+	h.Set("Message-ID", fmt.Sprintf("<%s@%s>", zstr.GenerateRandomHexBytes(16), domainOf(m.From.Email)))
+	h.Set("MIME-Version", "1.0")
+
+	var inline, files []Attachment
+	for _, a := range m.Attachments {
+		if a.ContentID != "" {
+			inline = append(inline, a)
+		} else {
+			files = append(files, a)
+		}
+	}
+	var err error
+	if len(files) != 0 {
+		err = writeMultipart(&buf, h, "mixed", func(mw *multipart.Writer) error {
+			err := m.writeRelated(mw, nil, inline)
+			if err != nil {
+				return err
+			}
+			for _, f := range files {
+				err = writeAttachment(mw, f)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	} else {
+		err = m.writeRelated(nil, &parentHeader{w: &buf, h: h}, inline)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parentHeader is the top-level header and writer, used when a part is the whole message body.
+type parentHeader struct {
+	w io.Writer
+	h textproto.MIMEHeader
+}
+
+// partWriter creates a part with header h, either in mw, or as the whole body after parent's headers.
+func partWriter(mw *multipart.Writer, parent *parentHeader, h textproto.MIMEHeader) (io.Writer, error) {
+	if mw != nil {
+		return mw.CreatePart(h)
+	}
+	for k, v := range h {
+		parent.h[k] = v
+	}
+	err := writeHeader(parent.w, parent.h)
+	return parent.w, err
+}
+
+func (m *Mail) writeRelated(mw *multipart.Writer, parent *parentHeader, inline []Attachment) error {
+	if len(inline) == 0 {
+		return m.writeAlternative(mw, parent)
+	}
+	return writeMultipartPart(mw, parent, "related", func(rw *multipart.Writer) error {
+		err := m.writeAlternative(rw, nil)
+		if err != nil {
+			return err
+		}
+		for _, a := range inline {
+			err = writeAttachment(rw, a)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Mail) writeAlternative(mw *multipart.Writer, parent *parentHeader) error {
+	if m.HTMLContent == "" {
+		return writeText(mw, parent, "text/plain", m.TextContent)
+	}
+	if m.TextContent == "" {
+		return writeText(mw, parent, "text/html", m.HTMLContent)
+	}
+	return writeMultipartPart(mw, parent, "alternative", func(aw *multipart.Writer) error {
+		err := writeText(aw, nil, "text/plain", m.TextContent)
+		if err != nil {
+			return err
+		}
+		return writeText(aw, nil, "text/html", m.HTMLContent)
+	})
+}
+
+func writeText(mw *multipart.Writer, parent *parentHeader, contentType, text string) error {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", contentType+"; charset=utf-8")
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	w, err := partWriter(mw, parent, h)
+	if err != nil {
+		return err
+	}
+	qw := quotedprintable.NewWriter(w)
+	_, err = qw.Write([]byte(text))
+	if err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+func writeAttachment(mw *multipart.Writer, a Attachment) error {
+	h := textproto.MIMEHeader{}
+	ctype := mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Name})
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	h.Set("Content-Type", ctype)
+	h.Set("Content-Transfer-Encoding", "base64")
+	disposition := "attachment"
+	if a.ContentID != "" {
+		h.Set("Content-ID", "<"+a.ContentID+">")
+		disposition = "inline"
+	}
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}))
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	return writeBase64Lines(w, a.Data)
+}
+
+// writeBase64Lines writes data base64 encoded in lines of 76 characters, as RFC 2045 requires.
+func writeBase64Lines(w io.Writer, data []byte) error {
+	const lineLen = 76
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 0 {
+		n := min(lineLen, len(enc))
+		_, err := io.WriteString(w, enc[:n]+"\r\n")
+		if err != nil {
+			return err
+		}
+		enc = enc[n:]
+	}
+	return nil
+}
+
+// writeMultipartPart writes a multipart/sub part inside mw, or as the whole body after parent's headers.
+func writeMultipartPart(mw *multipart.Writer, parent *parentHeader, sub string, write func(w *multipart.Writer) error) error {
+	if mw == nil {
+		return writeMultipart(parent.w, parent.h, sub, write)
+	}
+	inner := multipart.NewWriter(nil)
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", "multipart/"+sub+"; boundary="+inner.Boundary())
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	return writeMultipartBody(w, inner.Boundary(), write)
+}
+
+func writeMultipart(w io.Writer, h textproto.MIMEHeader, sub string, write func(w *multipart.Writer) error) error {
+	boundary := multipart.NewWriter(nil).Boundary()
+	h.Set("Content-Type", "multipart/"+sub+"; boundary="+boundary)
+	err := writeHeader(w, h)
+	if err != nil {
+		return err
+	}
+	return writeMultipartBody(w, boundary, write)
+}
+
+func writeMultipartBody(w io.Writer, boundary string, write func(w *multipart.Writer) error) error {
+	mw := multipart.NewWriter(w)
+	err := mw.SetBoundary(boundary)
+	if err != nil {
+		return err
+	}
+	err = write(mw)
+	if err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// writeHeader writes h in a stable order, with From, To and Subject first, ending with an empty line.
+func writeHeader(w io.Writer, h textproto.MIMEHeader) error {
+	first := []string{"From", "To", "Cc", "Reply-To", "Subject", "Date", "Message-Id", "Mime-Version"}
+	var keys []string
+	for _, k := range first {
+		if len(h[k]) != 0 {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range zstr.SortedMapKeys(h) {
+		if !zstr.StringsContain(first, k) {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		for _, v := range h[k] {
+			_, err := fmt.Fprintf(w, "%s: %s\r\n", k, v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+func domainOf(email string) string {
+	_, domain, got := strings.Cut(email, "@")
+	if !got || domain == "" {
+		return "localhost"
+	}
+	return domain
+}
@@ -0,0 +1,255 @@
+//go:build !js
+
+package zmail
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/torlangballe/zutil/zfile"
+	"github.com/torlangballe/zutil/zjson"
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zstr"
+	"github.com/torlangballe/zutil/ztime"
+)
+
+// Queue is a persistent outbound queue. Mails added are stored as a json file each in its folder,
+// and sent by Run in the background, or SendDue, which can be called concurrently with it. A failed send is retried after RetrySecs,
+// doubling each time up to MaxRetrySecs, until MaxAttempts is reached.
+// Each attempt is appended to a send log in the folder, the latest ones are also kept in memory for Log().
+type Queue struct {
+	Auth         Authentication
+	MaxAttempts  int
+	RetrySecs    float64
+	MaxRetrySecs float64
+	HandleDone   func(q QueuedMail, err error) // called when a mail is sent, or given up on with the last error
+	SendFunc     func(m Mail, a Authentication) error
+
+	folder  string
+	lock    sync.Mutex
+	pending map[string]*QueuedMail
+	sending map[string]bool // ids being sent, so a concurrent SendDue doesn't send them too
+	log     []SendLogEntry
+	wake    chan struct{}
+}
+
+type QueuedMail struct {
+	ID        string
+	Mail      Mail
+	Added     time.Time
+	Attempts  int
+	NextTry   time.Time
+	LastError string
+}
+
+type SendLogEntry struct {
+	ID      string
+	At      time.Time
+	To      []string
+	Subject string
+	Attempt int
+	Sent    bool
+	GaveUp  bool
+	Error   string
+}
+
+const (
+	queueMailPrefix  = "mail-"
+	queueLogName     = "sendlog.jsonl"
+	queueMemoryLog   = 500
+	queueDefaultWait = 30
+)
+
+// NewQueue creates a queue storing mails in folder, loading any left there from before.
+func NewQueue(folder string, auth Authentication) (*Queue, error) {
+	q := &Queue{Auth: auth, folder: folder}
+	q.MaxAttempts = 8
+	q.RetrySecs = 30
+	q.MaxRetrySecs = ztime.Day.Seconds() / 4
+	q.SendFunc = Mail.Send
+	q.pending = map[string]*QueuedMail{}
+	q.sending = map[string]bool{}
+	q.wake = make(chan struct{}, 1)
+	err := zfile.MakeDirAllIfNotExists(folder)
+	if err != nil {
+		return nil, zlog.Error("make folder", folder, err)
+	}
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, zlog.Error("read folder", folder, err)
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), queueMailPrefix) || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		var qm QueuedMail
+		err := zjson.UnmarshalFromFile(&qm, filepath.Join(folder, e.Name()), false)
+		if zlog.OnError(err, e.Name()) {
+			continue
+		}
+		q.pending[qm.ID] = &qm
+	}
+	return q, nil
+}
+
+func (q *Queue) mailPath(id string) string {
+	return filepath.Join(q.folder, queueMailPrefix+id+".json")
+}
+
+// Add stores m in the queue, to be sent as soon as possible.
+func (q *Queue) Add(m Mail) (id string, err error) {
+	qm := &QueuedMail{ID: zstr.GenerateRandomHexBytes(12), Mail: m}
+	qm.Added = time.Now()
+	qm.NextTry = qm.Added
+	err = zjson.MarshalToFile(qm, q.mailPath(qm.ID))
+	if err != nil {
+		return "", zlog.Error("store", err)
+	}
+	q.lock.Lock()
+	q.pending[qm.ID] = qm
+	q.lock.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return qm.ID, nil
+}
+
+// Pending returns the mails not sent or given up on yet, oldest first.
+func (q *Queue) Pending() []QueuedMail {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var all []QueuedMail
+	for _, qm := range q.pending {
+		all = append(all, *qm)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Added.Before(all[j].Added)
+	})
+	return all
+}
+
+// Log returns the latest send attempts, newest last.
+func (q *Queue) Log() []SendLogEntry {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return append([]SendLogEntry{}, q.log...)
+}
+
+// Run sends mails as they become due, until stop is closed.
+func (q *Queue) Run(stop <-chan struct{}) {
+	for {
+		next := q.SendDue(time.Now())
+		wait := ztime.SecondsDur(queueDefaultWait)
+		if !next.IsZero() {
+			wait = min(wait, max(0, time.Until(next)))
+		}
+		select {
+		case <-stop:
+			return
+		case <-q.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// SendDue tries to send all mails due at now, and returns when the next one is due, or zero time if none.
+// Mails being sent by another call are skipped.
+func (q *Queue) SendDue(now time.Time) (next time.Time) {
+	for _, qm := range q.Pending() {
+		if !qm.NextTry.After(now) {
+			if !q.startSending(&qm, now) || !q.send(&qm, now) {
+				continue
+			}
+		}
+		if next.IsZero() || qm.NextTry.Before(next) {
+			next = qm.NextTry
+		}
+	}
+	return next
+}
+
+// startSending marks qm as being sent and updates it, if it is still pending, due and not being sent already.
+func (q *Queue) startSending(qm *QueuedMail, now time.Time) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	current, got := q.pending[qm.ID]
+	if !got || q.sending[qm.ID] || current.NextTry.After(now) {
+		return false
+	}
+	q.sending[qm.ID] = true
+	*qm = *current
+	return true
+}
+
+// send tries to send qm, marked with startSending, returning true if it is still pending afterwards, to be retried at qm.NextTry.
+func (q *Queue) send(qm *QueuedMail, now time.Time) bool {
+	qm.Attempts++
+	err := q.SendFunc(qm.Mail, q.Auth)
+	entry := SendLogEntry{ID: qm.ID, At: now, To: qm.Mail.AllRecipients(), Subject: qm.Mail.Subject, Attempt: qm.Attempts}
+	retry := false
+	if err == nil {
+		entry.Sent = true
+	} else {
+		entry.Error = err.Error()
+		qm.LastError = entry.Error
+		if qm.Attempts < q.MaxAttempts {
+			retry = true
+			qm.NextTry = now.Add(ztime.SecondsDur(q.retryDelaySecs(qm.Attempts)))
+		} else {
+			entry.GaveUp = true
+		}
+	}
+	q.addToLog(entry)
+	q.lock.Lock()
+	delete(q.sending, qm.ID)
+	if retry {
+		stored := *qm
+		q.pending[qm.ID] = &stored
+	} else {
+		delete(q.pending, qm.ID)
+	}
+	q.lock.Unlock()
+	if retry {
+		zlog.OnError(zjson.MarshalToFile(qm, q.mailPath(qm.ID)), qm.ID)
+		return true
+	}
+	os.Remove(q.mailPath(qm.ID))
+	if q.HandleDone != nil {
+		q.HandleDone(*qm, err)
+	}
+	return false
+}
+
+func (q *Queue) retryDelaySecs(attempts int) float64 {
+	secs := q.RetrySecs * math.Pow(2, float64(attempts-1))
+	if q.MaxRetrySecs > 0 {
+		secs = math.Min(secs, q.MaxRetrySecs)
+	}
+	return secs
+}
+
+func (q *Queue) addToLog(entry SendLogEntry) {
+	q.lock.Lock()
+	q.log = append(q.log, entry)
+	if len(q.log) > queueMemoryLog {
+		q.log = q.log[len(q.log)-queueMemoryLog:]
+	}
+	q.lock.Unlock()
+	data, err := json.Marshal(entry)
+	if zlog.OnError(err) {
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(q.folder, queueLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if zlog.OnError(err) {
+		return
+	}
+	defer file.Close()
+	file.Write(append(data, '\n'))
+}
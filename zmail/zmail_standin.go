@@ -0,0 +1,161 @@
+//go:build !js
+
+package zmail
+
+import (
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// StandInServer is a minimal in-process SMTP server for testing sending mail.
+// It accepts AUTH PLAIN with any password unless Password is set, and keeps all messages received.
+// FailNext makes it reject the next n messages with a temporary error, to test retrying.
+type StandInServer struct {
+	Password string
+
+	listener net.Listener
+	lock     sync.Mutex
+	messages []ReceivedMail
+	failNext int
+}
+
+type ReceivedMail struct {
+	From string
+	To   []string
+	User string
+	Data string
+}
+
+func NewStandInServer() (*StandInServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, zlog.Error("listen", err)
+	}
+	s := &StandInServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, nil
+}
+
+// Authentication returns an Authentication for sending to s as user, on a plain connection.
+func (s *StandInServer) Authentication(user string) Authentication {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return Authentication{ServiceType: SMTPType, UserID: user, Password: s.Password, Server: "127.0.0.1", Port: addr.Port, TLS: TLSNone}
+}
+
+func (s *StandInServer) Messages() []ReceivedMail {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]ReceivedMail{}, s.messages...)
+}
+
+func (s *StandInServer) FailNext(n int) {
+	s.lock.Lock()
+	s.failNext = n
+	s.lock.Unlock()
+}
+
+func (s *StandInServer) Close() error {
+	return s.listener.Close()
+}
+
+func (s *StandInServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tc := textproto.NewConn(conn)
+	reply := func(code int, text string) {
+		tc.PrintfLine("%d %s", code, text)
+	}
+	reply(220, "zmail stand-in ready")
+	var m ReceivedMail
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tc.PrintfLine("250-zmail stand-in")
+			reply(250, "AUTH PLAIN")
+		case "AUTH":
+			user, ok := s.checkPlainAuth(arg)
+			if !ok {
+				reply(535, "authentication failed")
+				continue
+			}
+			m.User = user
+			reply(235, "authenticated")
+		case "MAIL":
+			m.From = addressInAngles(arg)
+			m.To = nil
+			reply(250, "ok")
+		case "RCPT":
+			m.To = append(m.To, addressInAngles(arg))
+			reply(250, "ok")
+		case "DATA":
+			reply(354, "send data")
+			data, err := tc.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			fail := s.failNext > 0
+			if fail {
+				s.failNext--
+			} else {
+				m.Data = string(data)
+				s.messages = append(s.messages, m)
+			}
+			s.lock.Unlock()
+			if fail {
+				reply(451, "try again later")
+				continue
+			}
+			reply(250, "queued")
+		case "RSET", "NOOP":
+			reply(250, "ok")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "not implemented")
+		}
+	}
+}
+
+func (s *StandInServer) checkPlainAuth(arg string) (user string, ok bool) {
+	mech, creds, _ := strings.Cut(arg, " ")
+	if strings.ToUpper(mech) != "PLAIN" {
+		return "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(creds)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(string(data), "\x00")
+	if len(parts) != 3 {
+		return "", false
+	}
+	if s.Password != "" && parts[2] != s.Password {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func addressInAngles(arg string) string {
+	_, rest, _ := strings.Cut(arg, "<")
+	addr, _, _ := strings.Cut(rest, ">")
+	return addr
+}
@@ -0,0 +1,222 @@
+package zmail
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/torlangballe/zutil/zlocale"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// Templates renders mails from template files, so they can be restyled without changing code.
+// A mail called name is made from:
+//
+//	name.subject.txt  the subject, a text/template
+//	name.txt          the text content, a text/template
+//	name.html         the html content, an html/template
+//
+// At least one of name.txt and name.html must exist. layout.txt and layout.html, if they exist,
+// wrap the content of all mails, inserting it with {{template "content" .}}.
+// Per-language variants are in a folder named by language code, like no/welcome.html, falling back to the root folder.
+// The file systems are searched in order, so a product's own templates can be put before a package's defaults.
+// Templates get the data given, and "ts" and "lang" functions to translate strings with zlocale and get the language.
+type Templates struct {
+	DefaultLang string
+	Funcs       map[string]any
+
+	fileSystems []fs.FS
+	lock        sync.Mutex
+	cache       map[string]*mailTemplate
+}
+
+type mailTemplate struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+const contentTemplateName = "content"
+
+func NewTemplates(fileSystems ...fs.FS) *Templates {
+	return &Templates{fileSystems: fileSystems, cache: map[string]*mailTemplate{}}
+}
+
+// langFolders returns the folders to look for a file in, most specific first, like "nb-NO", "no", DefaultLang and root.
+func (t *Templates) langFolders(lang string) []string {
+	var folders []string
+	add := func(f string) {
+		for _, e := range folders {
+			if e == f {
+				return
+			}
+		}
+		folders = append(folders, f)
+	}
+	for _, l := range []string{lang, t.DefaultLang} {
+		if l == "" {
+			continue
+		}
+		add(l)
+		code, _ := zlocale.GetLangCodeAndCountryFromLocaleId(l, false)
+		add(code)
+	}
+	add("")
+	return folders
+}
+
+func (t *Templates) readFile(lang, name string) (string, bool) {
+	for _, folder := range t.langFolders(lang) {
+		for _, fsys := range t.fileSystems {
+			data, err := fs.ReadFile(fsys, path.Join(folder, name))
+			if err == nil {
+				return string(data), true
+			}
+		}
+	}
+	return "", false
+}
+
+func (t *Templates) funcs(lang string) map[string]any {
+	m := map[string]any{
+		"ts": func(str string) string {
+			return zlocale.TSL(str, lang)
+		},
+		"lang": func() string {
+			return lang
+		},
+	}
+	for k, f := range t.Funcs {
+		m[k] = f
+	}
+	return m
+}
+
+func (t *Templates) get(name, lang string) (*mailTemplate, error) {
+	key := lang + "/" + name
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	mt := t.cache[key]
+	if mt != nil {
+		return mt, nil
+	}
+	mt = &mailTemplate{}
+	funcs := t.funcs(lang)
+	subject, got := t.readFile(lang, name+".subject.txt")
+	if !got {
+		return nil, zlog.NewError("no subject template:", name, lang)
+	}
+	var err error
+	mt.subject, err = texttemplate.New("subject").Funcs(funcs).Parse(strings.TrimSpace(subject))
+	if err != nil {
+		return nil, zlog.Error("parse subject", name, err)
+	}
+	text, hasText := t.readFile(lang, name+".txt")
+	if hasText {
+		layout, got := t.readFile(lang, "layout.txt")
+		if !got {
+			layout = `{{template "content" .}}`
+		}
+		mt.text, err = texttemplate.New("layout").Funcs(funcs).Parse(layout)
+		if err == nil {
+			_, err = mt.text.New(contentTemplateName).Parse(text)
+		}
+		if err != nil {
+			return nil, zlog.Error("parse text", name, err)
+		}
+	}
+	html, hasHTML := t.readFile(lang, name+".html")
+	if hasHTML {
+		layout, got := t.readFile(lang, "layout.html")
+		if !got {
+			layout = `{{template "content" .}}`
+		}
+		mt.html, err = htmltemplate.New("layout").Funcs(funcs).Parse(layout)
+		if err == nil {
+			_, err = mt.html.New(contentTemplateName).Parse(html)
+		}
+		if err != nil {
+			return nil, zlog.Error("parse html", name, err)
+		}
+	}
+	if !hasText && !hasHTML {
+		return nil, zlog.NewError("no text or html template:", name, lang)
+	}
+	t.cache[key] = mt
+	return mt, nil
+}
+
+// Render executes the templates for the mail called name in language lang with data.
+func (t *Templates) Render(name, lang string, data any) (subject, text, html string, err error) {
+	mt, err := t.get(name, lang)
+	if err != nil {
+		return "", "", "", err
+	}
+	var buf bytes.Buffer
+	err = mt.subject.Execute(&buf, data)
+	if err != nil {
+		return "", "", "", zlog.Error("subject", name, err)
+	}
+	subject = buf.String()
+	if mt.text != nil {
+		buf.Reset()
+		err = mt.text.ExecuteTemplate(&buf, "layout", data)
+		if err != nil {
+			return "", "", "", zlog.Error("text", name, err)
+		}
+		text = buf.String()
+	}
+	if mt.html != nil {
+		buf.Reset()
+		err = mt.html.ExecuteTemplate(&buf, "layout", data)
+		if err != nil {
+			return "", "", "", zlog.Error("html", name, err)
+		}
+		html = buf.String()
+	}
+	return subject, text, html, nil
+}
+
+// MakeMail renders the mail called name, returning a Mail with its subject and content set.
+func (t *Templates) MakeMail(name, lang string, data any) (Mail, error) {
+	var m Mail
+	var err error
+	m.Subject, m.TextContent, m.HTMLContent, err = t.Render(name, lang, data)
+	return m, err
+}
+
+// WritePreview renders the mail called name to an html file at fpath, showing the subject,
+// the text content and the html content, for checking how a mail looks in a browser.
+func (t *Templates) WritePreview(name, lang string, data any, fpath string) error {
+	subject, text, html, err := t.Render(name, lang, data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>")
+	htmltemplate.HTMLEscape(&buf, []byte(subject))
+	buf.WriteString("</title></head>\n<body style=\"font-family:sans-serif\">\n<h3>")
+	htmltemplate.HTMLEscape(&buf, []byte(subject))
+	buf.WriteString("</h3>\n")
+	if text != "" {
+		buf.WriteString("<pre style=\"background:#eee;padding:8px\">")
+		htmltemplate.HTMLEscape(&buf, []byte(text))
+		buf.WriteString("</pre>\n")
+	}
+	if html != "" {
+		buf.WriteString("<iframe style=\"width:100%;height:80vh;border:1px solid gray\" srcdoc=\"")
+		htmltemplate.HTMLEscape(&buf, []byte(html))
+		buf.WriteString("\"></iframe>\n")
+	}
+	buf.WriteString("</body></html>\n")
+	err = os.WriteFile(fpath, buf.Bytes(), 0644)
+	if err != nil {
+		return zlog.Error("write preview", fpath, err)
+	}
+	return nil
+}
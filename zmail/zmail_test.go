@@ -0,0 +1,230 @@
+//go:build !js
+
+package zmail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func makeTestMail() Mail {
+	var m Mail
+	m.From = Address{Name: "Bjørn Ås", Email: "bjorn@example.com"}
+	m.AddTo("Tor", "tor@example.com")
+	m.CC = []Address{{Email: "cc@example.com"}}
+	m.BCC = []Address{{Email: "hidden@example.com"}}
+	m.ReplyTo = Address{Email: "reply@example.com"}
+	m.Subject = "Hei på deg"
+	m.TextContent = "Hello"
+	m.HTMLContent = `<p>Hello <img src="cid:logo"></p>`
+	m.AddInline("logo", "logo.png", "", []byte("png-data"))
+	m.AddAttachment("rapport æ.txt", "", []byte(strings.Repeat("report ", 30)))
+	return m
+}
+
+func readParts(t *testing.T, r io.Reader, contentType string) map[string][]byte {
+	media, params, err := mime.ParseMediaType(contentType)
+	ztesting.Equal(t, err, nil, "parse media type", contentType)
+	parts := map[string][]byte{}
+	if !strings.HasPrefix(media, "multipart/") {
+		data, _ := io.ReadAll(r)
+		parts[media] = data
+		return parts
+	}
+	mr := multipart.NewReader(r, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		ztesting.Equal(t, err, nil, "next part")
+		for k, v := range readParts(t, p, p.Header.Get("Content-Type")) {
+			if p.FileName() != "" {
+				k = p.FileName()
+			}
+			parts[k] = v
+		}
+	}
+	return parts
+}
+
+func TestMIMEMessage(t *testing.T) {
+	m := makeTestMail()
+	data, err := m.MIMEMessage()
+	ztesting.Equal(t, err, nil, "build")
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	ztesting.Equal(t, err, nil, "parse")
+	dec := new(mime.WordDecoder)
+	subject, _ := dec.DecodeHeader(msg.Header.Get("Subject"))
+	ztesting.Equal(t, subject, "Hei på deg", "subject")
+	from, err := msg.Header.AddressList("From")
+	ztesting.Equal(t, err, nil, "from")
+	ztesting.Equal(t, from[0].Name, "Bjørn Ås", "from name")
+	ztesting.Equal(t, msg.Header.Get("Cc"), "<cc@example.com>", "cc")
+	ztesting.Equal(t, msg.Header.Get("Bcc"), "", "bcc hidden")
+	ztesting.Equal(t, msg.Header.Get("Reply-To"), "<reply@example.com>", "reply-to")
+	ztesting.Equal(t, strings.HasPrefix(msg.Header.Get("Content-Type"), "multipart/mixed"), true, "mixed")
+
+	parts := readParts(t, msg.Body, msg.Header.Get("Content-Type"))
+	ztesting.Equal(t, len(parts), 4, "parts", len(parts))
+	ztesting.Equal(t, string(parts["logo.png"]) != "", true, "inline image")
+	ztesting.Equal(t, len(parts["rapport æ.txt"]) != 0, true, "attachment")
+	ztesting.Equal(t, string(parts["text/plain"]) != "", true, "text")
+	ztesting.Equal(t, strings.Contains(string(parts["text/html"]), "cid:logo"), true, "html")
+
+	var simple Mail
+	simple.From = Address{Email: "a@example.com"}
+	simple.AddTo("", "b@example.com")
+	simple.TextContent = "just text"
+	data, err = simple.MIMEMessage()
+	ztesting.Equal(t, err, nil, "build simple")
+	msg, _ = mail.ReadMessage(bytes.NewReader(data))
+	ztesting.Equal(t, msg.Header.Get("Content-Type"), "text/plain; charset=utf-8", "simple type")
+}
+
+func TestSendWithSMTP(t *testing.T) {
+	server, err := NewStandInServer()
+	ztesting.Equal(t, err, nil, "start stand-in")
+	defer server.Close()
+	server.Password = "secret"
+	m := makeTestMail()
+	err = m.SendWithSMTP(server.Authentication("user@example.com"))
+	ztesting.Equal(t, err, nil, "send")
+	got := server.Messages()
+	ztesting.Equal(t, len(got), 1, "received")
+	ztesting.Equal(t, got[0].User, "user@example.com", "auth user")
+	ztesting.Equal(t, strings.Join(got[0].To, ","), "tor@example.com,cc@example.com,hidden@example.com", "recipients")
+	ztesting.Equal(t, strings.Contains(got[0].Data, "Bcc"), false, "no bcc header")
+
+	a := server.Authentication("user@example.com")
+	a.Password = "wrong"
+	err = m.SendWithSMTP(a)
+	ztesting.Different(t, err, nil, "bad password")
+}
+
+func TestQueue(t *testing.T) {
+	server, err := NewStandInServer()
+	ztesting.Equal(t, err, nil, "start stand-in")
+	defer server.Close()
+	folder := t.TempDir()
+	q, err := NewQueue(folder, server.Authentication(""))
+	ztesting.Equal(t, err, nil, "new queue")
+	q.RetrySecs = 10
+	q.MaxAttempts = 3
+	var done []error
+	q.HandleDone = func(qm QueuedMail, err error) {
+		done = append(done, err)
+	}
+	server.FailNext(1)
+	m := makeTestMail()
+	id, err := q.Add(m)
+	ztesting.Equal(t, err, nil, "add")
+
+	now := time.Now()
+	next := q.SendDue(now)
+	ztesting.Equal(t, len(server.Messages()), 0, "first attempt fails")
+	ztesting.Equal(t, next.Sub(now), 10*time.Second, "retry time")
+
+	reloaded, err := NewQueue(folder, server.Authentication(""))
+	ztesting.Equal(t, err, nil, "reload")
+	pending := reloaded.Pending()
+	ztesting.Equal(t, len(pending), 1, "persisted")
+	ztesting.Equal(t, pending[0].ID, id, "persisted id")
+	ztesting.Equal(t, pending[0].Attempts, 1, "persisted attempts")
+
+	ztesting.Equal(t, q.SendDue(now.Add(5*time.Second)), next, "not due yet")
+	q.SendDue(next)
+	ztesting.Equal(t, len(server.Messages()), 1, "sent on retry")
+	ztesting.Equal(t, len(q.Pending()), 0, "none pending")
+	ztesting.Equal(t, len(done), 1, "done called")
+	ztesting.Equal(t, done[0], nil, "done without error")
+	log := q.Log()
+	ztesting.Equal(t, len(log), 2, "log entries")
+	ztesting.Equal(t, log[1].Sent, true, "logged sent")
+
+	q.SendFunc = func(m Mail, a Authentication) error {
+		return errors.New("always fails")
+	}
+	q.Add(m)
+	at := now
+	for i := 0; i < 3; i++ {
+		at = q.SendDue(at.Add(time.Hour))
+	}
+	ztesting.Equal(t, len(q.Pending()), 0, "given up")
+	ztesting.Equal(t, q.Log()[len(q.Log())-1].GaveUp, true, "logged give up")
+	ztesting.Equal(t, len(done), 2, "done called on give up")
+}
+
+func TestQueueConcurrentSendDue(t *testing.T) {
+	q, err := NewQueue(t.TempDir(), Authentication{})
+	ztesting.Equal(t, err, nil, "new queue")
+	release := make(chan struct{})
+	var sends atomic.Int32
+	q.SendFunc = func(m Mail, a Authentication) error {
+		sends.Add(1)
+		<-release
+		return nil
+	}
+	q.Add(makeTestMail())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.SendDue(time.Now())
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	ztesting.Equal(t, sends.Load(), int32(1), "sent once")
+	ztesting.Equal(t, len(q.Pending()), 0, "none pending")
+}
+
+func TestTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.html":            {Data: []byte(`<html><body>{{template "content" .}}<p>{{.Product}}</p></body></html>`)},
+		"layout.txt":             {Data: []byte("{{template \"content\" .}}\n-- {{.Product}}")},
+		"welcome.subject.txt":    {Data: []byte("Welcome to {{.Product}}\n")},
+		"welcome.txt":            {Data: []byte("Hi {{.Name}}")},
+		"welcome.html":           {Data: []byte(`<h1>Hi {{.Name}}</h1><a href="{{.URL}}">{{lang}}</a>`)},
+		"no/welcome.subject.txt": {Data: []byte("Velkommen til {{.Product}}")},
+	}
+	override := fstest.MapFS{
+		"welcome.txt": {Data: []byte("Hello {{.Name}}")},
+	}
+	templates := NewTemplates(override, fsys)
+	data := map[string]string{"Product": "Zap", "Name": "<Ola>", "URL": "https://example.com/?a=1&b=2"}
+	m, err := templates.MakeMail("welcome", "nb-NO", data)
+	ztesting.Equal(t, err, nil, "make")
+	ztesting.Equal(t, m.Subject, "Velkommen til Zap", "language variant")
+	ztesting.Equal(t, m.TextContent, "Hello <Ola>\n-- Zap", "text with override and layout")
+	ztesting.Equal(t, m.HTMLContent, `<html><body><h1>Hi &lt;Ola&gt;</h1><a href="https://example.com/?a=1&amp;b=2">nb-NO</a><p>Zap</p></body></html>`, "html escaped in layout")
+
+	m, err = templates.MakeMail("welcome", "de", data)
+	ztesting.Equal(t, err, nil, "make default")
+	ztesting.Equal(t, m.Subject, "Welcome to Zap", "default language")
+
+	_, err = templates.MakeMail("missing", "", data)
+	ztesting.Different(t, err, nil, "missing template")
+
+	fpath := filepath.Join(t.TempDir(), "preview.html")
+	err = templates.WritePreview("welcome", "en", data, fpath)
+	ztesting.Equal(t, err, nil, "preview")
+	preview, _ := os.ReadFile(fpath)
+	ztesting.Equal(t, strings.Contains(string(preview), "srcdoc=\"&lt;html&gt;"), true, "preview has html")
+}
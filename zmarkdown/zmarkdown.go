@@ -47,6 +47,12 @@ type MarkdownConverter struct {
 	DarkMode        bool
 	AbsolutePrefix  string
 	HeaderMD        string
+	Renderer        RendererType
+	HighlightStyle  string // chroma style for fenced code with RendererGFM, DefaultHighlightStyle or DefaultDarkHighlightStyle if empty
+	HardWraps       bool   // newlines within paragraphs become <br> with RendererGFM, as they did with the old renderer
+	Author          string // for PDF and EPUB metadata
+	Language        string // for EPUB metadata, "en" if empty
+	PDFFont         []byte // a TrueType font for all text in ConvertToPDF, for scripts the Go fonts don't have, like CJK
 }
 
 func pdfGrabber(w io.Writer, url string) chromedp.Tasks {
@@ -104,15 +110,19 @@ func (m *MarkdownConverter) ConvertToHTMLFromString(w io.Writer, fullmd, name st
 	templater := &Templater{TeXFontSize: 14, DPI: 144}
 
 	input := templater.Preprocess(m, m.HeaderMD+fullmd, name)
-	params := blackfriday.HTMLRendererParameters{}
-	params.Title = name
-	params.Flags = blackfriday.CompletePage | blackfriday.HrefTargetBlank
-	//!!		params.Flags |= blackfriday.TOC
 	cssName := "github-markdown"
 	if m.DarkMode {
 		cssName += "-dark"
 	}
-	params.CSS = zrest.AppURLPrefix + "css/zcore/" + cssName + ".css"
+	cssURL := zrest.AppURLPrefix + "css/zcore/" + cssName + ".css"
+	if m.Renderer == RendererGFM {
+		return m.convertGFMToHTML(w, input, name, cssURL)
+	}
+	params := blackfriday.HTMLRendererParameters{}
+	params.Title = name
+	params.Flags = blackfriday.CompletePage | blackfriday.HrefTargetBlank
+	//!!		params.Flags |= blackfriday.TOC
+	params.CSS = cssURL
 	renderer := blackfriday.NewHTMLRenderer(params)
 	renderer.AbsolutePrefix = m.AbsolutePrefix
 	output := blackfriday.Run([]byte(input),
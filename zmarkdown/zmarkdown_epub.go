@@ -0,0 +1,299 @@
+//go:build server
+
+package zmarkdown
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/yuin/goldmark/ast"
+)
+
+// epubChapter is a part of the document starting at a top-level heading, rendered from doc as its own xhtml file.
+type epubChapter struct {
+	file     string
+	title    string
+	headings []epubHeading
+	doc      *ast.Document
+	body     bytes.Buffer
+}
+
+type epubHeading struct {
+	level int
+	id    string
+	title string
+}
+
+type epubImage struct {
+	file      string
+	mediaType string
+	data      []byte
+}
+
+var (
+	epubIDReg   = regexp.MustCompile(` id="([^"]+)"`)
+	epubHrefReg = regexp.MustCompile(`href="#([^"]+)"`)
+)
+
+const epubCSS = `body { font-family: serif; line-height: 1.45; }
+h1, h2, h3, h4, h5, h6 { font-family: sans-serif; line-height: 1.2; }
+pre { white-space: pre-wrap; font-size: 0.85em; padding: 0.5em; background: #f6f8fa; }
+code { font-family: monospace; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; }
+blockquote { color: #555; margin-left: 1em; padding-left: 1em; border-left: 3px solid #ddd; }
+img { max-width: 100%; }
+`
+
+// ConvertToEPUB writes the flattened document as an EPUB 3 book, with a chapter for each top-level heading.
+func (m *MarkdownConverter) ConvertToEPUB(w io.Writer, name string) error {
+	fullmd, err := m.Flatten()
+	if err != nil {
+		return zlog.Error("building doc", name, err)
+	}
+	return m.ConvertToEPUBFromString(w, fullmd, name)
+}
+
+// ConvertToEPUBFromString writes fullmd as an EPUB with name as title, after preprocessing it like ConvertToHTMLFromString.
+// Images read from FileSystem are included in the book, links between chapters and to footnotes are kept.
+func (m *MarkdownConverter) ConvertToEPUBFromString(w io.Writer, fullmd, name string) error {
+	templater := &Templater{TeXFontSize: 14, DPI: 144}
+	input := []byte(templater.Preprocess(m, m.HeaderMD+fullmd, name))
+	doc := m.parseGFM(input)
+	images := m.collectEPUBImages(doc)
+	chapters := splitEPUBChapters(doc, input, name)
+	renderer := m.newGFMMarkdown(m.highlightStyle(), true).Renderer()
+	idFiles := map[string]string{}
+	for _, c := range chapters {
+		err := renderer.Render(&c.body, input, c.doc)
+		if err != nil {
+			return zlog.Error("render chapter", c.title, err)
+		}
+		for _, match := range epubIDReg.FindAllStringSubmatch(c.body.String(), -1) {
+			idFiles[match[1]] = c.file
+		}
+	}
+	zw := zip.NewWriter(w)
+	// the mimetype must be first, and stored uncompressed
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(mw, "application/epub+zip")
+	if err != nil {
+		return err
+	}
+	err = writeZipFile(zw, "META-INF/container.xml", []byte(epubContainerXML))
+	if err == nil {
+		err = writeZipFile(zw, "OEBPS/content.opf", []byte(m.epubPackage(name, input, chapters, images)))
+	}
+	if err == nil {
+		err = writeZipFile(zw, "OEBPS/nav.xhtml", []byte(epubNav(name, chapters)))
+	}
+	if err == nil {
+		err = writeZipFile(zw, "OEBPS/style.css", []byte(epubCSS))
+	}
+	for _, c := range chapters {
+		if err != nil {
+			return err
+		}
+		// links to ids in other chapters need their file
+		body := epubHrefReg.ReplaceAllStringFunc(c.body.String(), func(href string) string {
+			id := epubHrefReg.FindStringSubmatch(href)[1]
+			file := idFiles[id]
+			if file == "" || file == c.file {
+				return href
+			}
+			return `href="` + file + "#" + id + `"`
+		})
+		err = writeZipFile(zw, "OEBPS/"+c.file, []byte(epubXHTML(c.title, body)))
+	}
+	for _, img := range images {
+		if err != nil {
+			return err
+		}
+		err = writeZipFile(zw, "OEBPS/"+img.file, img.data)
+	}
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+// collectEPUBImages reads images from the converter's FileSystem, and changes their destinations to where they are put in the book.
+func (m *MarkdownConverter) collectEPUBImages(doc ast.Node) []epubImage {
+	var images []epubImage
+	files := map[string]string{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		img, is := n.(*ast.Image)
+		if !is || !entering {
+			return ast.WalkContinue, nil
+		}
+		dest := string(img.Destination)
+		file, got := files[dest]
+		if !got {
+			data, imageType := m.readImage(dest)
+			if data == nil {
+				return ast.WalkContinue, nil
+			}
+			mediaType := "image/" + strings.ToLower(imageType)
+			if imageType == "JPG" {
+				mediaType = "image/jpeg"
+			}
+			file = fmt.Sprint("images/image", len(images)+1, strings.ToLower(path.Ext(dest)))
+			files[dest] = file
+			images = append(images, epubImage{file: file, mediaType: mediaType, data: data})
+		}
+		img.Destination = []byte(file)
+		return ast.WalkContinue, nil
+	})
+	return images
+}
+
+// splitEPUBChapters moves doc's top-level nodes into a document per chapter, starting a new one at each heading of the highest level used.
+func splitEPUBChapters(doc ast.Node, source []byte, title string) []*epubChapter {
+	top := 7
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if h, is := n.(*ast.Heading); is {
+			top = min(top, h.Level)
+		}
+	}
+	var chapters []*epubChapter
+	var current *epubChapter
+	for n := doc.FirstChild(); n != nil; {
+		next := n.NextSibling()
+		h, isHeading := n.(*ast.Heading)
+		if current == nil || isHeading && h.Level == top {
+			current = &epubChapter{file: fmt.Sprintf("chapter%03d.xhtml", len(chapters)+1), title: title, doc: ast.NewDocument()}
+			chapters = append(chapters, current)
+		}
+		if isHeading {
+			text := plainText(h, source)
+			if h.Level == top {
+				current.title = text
+			}
+			if id, got := h.AttributeString("id"); got {
+				current.headings = append(current.headings, epubHeading{level: h.Level, id: string(id.([]byte)), title: text})
+			}
+		}
+		doc.RemoveChild(doc, n)
+		current.doc.AppendChild(current.doc, n)
+		n = next
+	}
+	if len(chapters) == 0 {
+		chapters = append(chapters, &epubChapter{file: "chapter001.xhtml", title: title, doc: ast.NewDocument()})
+	}
+	return chapters
+}
+
+const epubContainerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+func epubXHTML(title, body string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+  <title>` + html.EscapeString(title) + `</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+` + body + `</body>
+</html>
+`
+}
+
+// epubNav is the table of contents, with the headings of each chapter nested by level.
+func epubNav(title string, chapters []*epubChapter) string {
+	var nav strings.Builder
+	nav.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n<h1>" + html.EscapeString(title) + "</h1>\n")
+	var levels []int
+	closeTo := func(level int) {
+		for len(levels) > 0 && levels[len(levels)-1] > level {
+			nav.WriteString("</li></ol>\n")
+			levels = levels[:len(levels)-1]
+		}
+	}
+	for _, c := range chapters {
+		headings := c.headings
+		if len(headings) == 0 {
+			headings = []epubHeading{{level: 1, title: c.title}}
+		}
+		for _, h := range headings {
+			closeTo(h.level)
+			if len(levels) > 0 && levels[len(levels)-1] == h.level {
+				nav.WriteString("</li>\n")
+			} else {
+				nav.WriteString("<ol>\n")
+				levels = append(levels, h.level)
+			}
+			href := c.file
+			if h.id != "" {
+				href += "#" + h.id
+			}
+			nav.WriteString(`<li><a href="` + html.EscapeString(href) + `">` + html.EscapeString(h.title) + "</a>")
+		}
+	}
+	closeTo(0)
+	nav.WriteString("</nav>\n")
+	return epubXHTML(title, nav.String())
+}
+
+func (m *MarkdownConverter) epubPackage(title string, source []byte, chapters []*epubChapter, images []epubImage) string {
+	sum := sha1.Sum(source)
+	id := fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+	language := m.Language
+	if language == "" {
+		language = "en"
+	}
+	var opf strings.Builder
+	opf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	opf.WriteString(`    <dc:identifier id="bookid">` + id + "</dc:identifier>\n")
+	opf.WriteString("    <dc:title>" + html.EscapeString(title) + "</dc:title>\n")
+	opf.WriteString("    <dc:language>" + html.EscapeString(language) + "</dc:language>\n")
+	if m.Author != "" {
+		opf.WriteString("    <dc:creator>" + html.EscapeString(m.Author) + "</dc:creator>\n")
+	}
+	opf.WriteString(`    <meta property="dcterms:modified">` + time.Now().UTC().Format("2006-01-02T15:04:05Z") + "</meta>\n")
+	opf.WriteString("  </metadata>\n  <manifest>\n")
+	opf.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	opf.WriteString(`    <item id="css" href="style.css" media-type="text/css"/>` + "\n")
+	for i, c := range chapters {
+		opf.WriteString(fmt.Sprintf(`    <item id="chapter%d" href="%s" media-type="application/xhtml+xml"/>`+"\n", i+1, c.file))
+	}
+	for i, img := range images {
+		opf.WriteString(fmt.Sprintf(`    <item id="image%d" href="%s" media-type="%s"/>`+"\n", i+1, img.file, img.mediaType))
+	}
+	opf.WriteString("  </manifest>\n  <spine>\n")
+	for i := range chapters {
+		opf.WriteString(fmt.Sprintf(`    <itemref idref="chapter%d"/>`+"\n", i+1))
+	}
+	opf.WriteString("  </spine>\n</package>\n")
+	return opf.String()
+}
@@ -0,0 +1,171 @@
+//go:build server
+
+package zmarkdown
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// RendererType is which markdown renderer a MarkdownConverter uses.
+type RendererType string
+
+const (
+	RendererBlackfriday RendererType = ""    // the original renderer, the default
+	RendererGFM         RendererType = "gfm" // CommonMark with GitHub extensions: tables, task lists, footnotes, autolinks and strikethrough
+
+	DefaultHighlightStyle     = "github"
+	DefaultDarkHighlightStyle = "github-dark"
+)
+
+// anchorIDs generates heading ids with headerToAnchorID, the same as Flatten uses for its table of contents,
+// adding -1, -2 etc for duplicates.
+type anchorIDs struct {
+	used map[string]bool
+}
+
+func newAnchorIDs() *anchorIDs {
+	return &anchorIDs{used: map[string]bool{}}
+}
+
+func (a *anchorIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	id := headerToAnchorID(strings.TrimSpace(string(value)))
+	if id == "" {
+		id = "heading"
+	}
+	unique := id
+	for i := 1; a.used[unique]; i++ {
+		unique = id + "-" + strconv.Itoa(i)
+	}
+	a.used[unique] = true
+	return []byte(unique)
+}
+
+func (a *anchorIDs) Put(value []byte) {
+	a.used[string(value)] = true
+}
+
+// linkTransformer makes absolute links open in a new window, and prefixes root-relative links and images with absolutePrefix,
+// like blackfriday's HrefTargetBlank flag and AbsolutePrefix do.
+type linkTransformer struct {
+	absolutePrefix string
+}
+
+func (t linkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch link := n.(type) {
+		case *ast.Link:
+			link.Destination = t.prefixed(link.Destination)
+			if isAbsoluteLink(string(link.Destination)) {
+				link.SetAttributeString("target", []byte("_blank"))
+			}
+		case *ast.AutoLink:
+			link.SetAttributeString("target", []byte("_blank"))
+		case *ast.Image:
+			link.Destination = t.prefixed(link.Destination)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func (t linkTransformer) prefixed(dest []byte) []byte {
+	if t.absolutePrefix == "" || len(dest) == 0 || dest[0] != '/' || bytes.HasPrefix(dest, []byte("//")) {
+		return dest
+	}
+	return []byte(strings.TrimSuffix(t.absolutePrefix, "/") + string(dest))
+}
+
+func isAbsoluteLink(link string) bool {
+	return strings.Contains(link, "://") || strings.HasPrefix(link, "mailto:")
+}
+
+// newGFMMarkdown makes a goldmark converter with the extensions and options MarkdownConverter uses.
+// Fenced code is highlighted with style in the html, unless it is empty.
+func (m *MarkdownConverter) newGFMMarkdown(style string, xhtml bool) goldmark.Markdown {
+	extensions := []goldmark.Extender{
+		extension.GFM,
+		extension.Footnote,
+		extension.DefinitionList,
+	}
+	if style != "" {
+		extensions = append(extensions, highlighting.NewHighlighting(highlighting.WithStyle(style)))
+	}
+	htmlOptions := []renderer.Option{gmhtml.WithUnsafe()}
+	if m.HardWraps {
+		htmlOptions = append(htmlOptions, gmhtml.WithHardWraps())
+	}
+	if xhtml {
+		htmlOptions = append(htmlOptions, gmhtml.WithXHTML())
+	}
+	return goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithHeadingAttribute(),
+			parser.WithASTTransformers(util.Prioritized(linkTransformer{absolutePrefix: m.AbsolutePrefix}, 100)),
+		),
+		goldmark.WithRendererOptions(htmlOptions...),
+	)
+}
+
+func (m *MarkdownConverter) highlightStyle() string {
+	if m.HighlightStyle != "" {
+		return m.HighlightStyle
+	}
+	if m.DarkMode {
+		return DefaultDarkHighlightStyle
+	}
+	return DefaultHighlightStyle
+}
+
+// parseGFM parses markdown to a goldmark document, with heading ids compatible with Flatten's anchors.
+func (m *MarkdownConverter) parseGFM(markdown []byte) ast.Node {
+	ctx := parser.NewContext(parser.WithIDs(newAnchorIDs()))
+	return m.newGFMMarkdown("", false).Parser().Parse(text.NewReader(markdown), parser.WithContext(ctx))
+}
+
+// renderGFMBody writes markdown as html without a page around it.
+func (m *MarkdownConverter) renderGFMBody(w io.Writer, markdown []byte, xhtml bool) error {
+	ctx := parser.NewContext(parser.WithIDs(newAnchorIDs()))
+	return m.newGFMMarkdown(m.highlightStyle(), xhtml).Convert(markdown, w, parser.WithContext(ctx))
+}
+
+// convertGFMToHTML writes preprocessed markdown as a complete html page with the same head as blackfriday's.
+func (m *MarkdownConverter) convertGFMToHTML(w io.Writer, markdown, title, cssURL string) error {
+	var body bytes.Buffer
+	err := m.renderGFMBody(&body, []byte(markdown), false)
+	if err != nil {
+		return err
+	}
+	page := "<!DOCTYPE html>\n<html>\n<head>\n" +
+		"  <title>" + html.EscapeString(title) + "</title>\n" +
+		"  <meta charset=\"utf-8\">\n"
+	if cssURL != "" {
+		page += "  <link rel=\"stylesheet\" type=\"text/css\" href=\"" + html.EscapeString(cssURL) + "\">\n"
+	}
+	page += "</head>\n<body>\n\n"
+	_, err = io.WriteString(w, page)
+	if err == nil {
+		_, err = w.Write(body.Bytes())
+	}
+	if err == nil {
+		_, err = io.WriteString(w, "\n</body>\n</html>\n")
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+//go:build server
+
+package zmarkdown
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/torlangballe/zutil/zdict"
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+const testMarkdown = `# Getting Started
+
+Intro with a footnote[^1] and https://example.com autolink.
+
+- [x] done
+- [ ] todo
+
+| Name | Count |
+|------|------:|
+| a    | 1     |
+
+` + "```go\nfunc main() {}\n```" + `
+
+See [usage](#usage) and ![logo](logo.png).
+
+# Usage {#usage}
+
+## Details
+
+Text ~~removed~~.
+
+[^1]: The note.
+`
+
+func testPNG() []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)))
+	return buf.Bytes()
+}
+
+func testConverter() *MarkdownConverter {
+	return &MarkdownConverter{
+		Variables:  zdict.Dict{},
+		Renderer:   RendererGFM,
+		FileSystem: fstest.MapFS{"docs/logo.png": {Data: testPNG()}},
+		Dir:        "docs",
+	}
+}
+
+func TestGFMToHTML(t *testing.T) {
+	var buf bytes.Buffer
+	m := testConverter()
+	err := m.convertGFMToHTML(&buf, testMarkdown, "Test", "style.css")
+	ztesting.OnErrorFatal(t, err)
+	out := buf.String()
+	for _, want := range []string{
+		`<link rel="stylesheet" type="text/css" href="style.css">`,
+		`<h1 id="gettingstarted">Getting Started</h1>`,
+		`<h1 id="usage">Usage</h1>`,
+		`<input checked="" disabled="" type="checkbox"`,
+		`<a href="https://example.com" target="_blank">https://example.com</a>`,
+		`<sup id="fnref:1"><a href="#fn:1" class="footnote-ref" role="doc-noteref">1</a></sup>`,
+		`<th style="text-align:right">Count</th>`,
+		`<del>removed</del>`,
+		`<span style="color:#`,
+	} {
+		ztesting.Equal(t, strings.Contains(out, want), true, "html contains", want)
+	}
+	ztesting.Equal(t, headerToAnchorID("Getting Started"), "gettingstarted")
+}
+
+func TestConvertToPDF(t *testing.T) {
+	var buf bytes.Buffer
+	err := testConverter().ConvertToPDFFromString(&buf, testMarkdown, "Test")
+	ztesting.OnErrorFatal(t, err)
+	out := buf.String()
+	ztesting.Equal(t, strings.HasPrefix(out, "%PDF-"), true)
+	ztesting.Equal(t, strings.Contains(out, "/Outlines"), true, "has bookmarks")
+	ztesting.Equal(t, strings.Contains(out, "/Subtype /Image"), true, "has image")
+}
+
+func TestConvertToPDFUnicode(t *testing.T) {
+	var buf bytes.Buffer
+	err := testConverter().ConvertToPDFFromString(&buf, "# Привет\n\nΓειά σου, *мир*, `код`.\n", "Unicode")
+	ztesting.OnErrorFatal(t, err)
+	ztesting.Equal(t, strings.Contains(buf.String(), "/Encoding /Identity-H"), true, "unicode font")
+
+	buf.Reset()
+	err = testConverter().ConvertToPDFFromString(&buf, "こんにちは\n", "CJK")
+	ztesting.Equal(t, err != nil, true, "error for characters not in font")
+	ztesting.Equal(t, buf.Len(), 0, "nothing written")
+}
+
+func TestConvertToEPUB(t *testing.T) {
+	var buf bytes.Buffer
+	err := testConverter().ConvertToEPUBFromString(&buf, testMarkdown, "Test")
+	ztesting.OnErrorFatal(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	ztesting.OnErrorFatal(t, err)
+	ztesting.Equal(t, zr.File[0].Name, "mimetype")
+	ztesting.Equal(t, zr.File[0].Method, zip.Store)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		ztesting.OnErrorFatal(t, err)
+		data, _ := io.ReadAll(r)
+		files[f.Name] = string(data)
+	}
+	first := files["OEBPS/chapter001.xhtml"]
+	ztesting.Equal(t, strings.Contains(first, `href="chapter002.xhtml#usage"`), true, "link to other chapter")
+	ztesting.Equal(t, strings.Contains(first, `href="chapter002.xhtml#fn:1"`), true, "link to footnote in last chapter")
+	ztesting.Equal(t, strings.Contains(first, `src="images/image1.png"`), true, "image in book")
+	ztesting.Equal(t, len(files["OEBPS/images/image1.png"]) > 0, true, "image data")
+	ztesting.Equal(t, strings.Contains(files["OEBPS/nav.xhtml"], `<a href="chapter002.xhtml#details">Details</a>`), true, "nav")
+	ztesting.Equal(t, strings.Contains(files["OEBPS/content.opf"], `<itemref idref="chapter2"/>`), true, "spine")
+}
@@ -0,0 +1,587 @@
+//go:build server
+
+package zmarkdown
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/torlangballe/zutil/zfile"
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// PDF layout, in points for font sizes and millimeters for the rest.
+const (
+	pdfFontSize       = 11.0
+	pdfCodeFontSize   = 9.0
+	pdfLineFactor     = 1.45 // line height relative to font size
+	pdfMargin         = 20.0
+	pdfListIndent     = 7.0
+	pdfBlockSpacing   = 2.5
+	pdfPointsPerMM    = 72 / 25.4
+	pdfFootnoteMarker = 7.0
+	pdfTextFamily     = "Text"
+	pdfMonoFamily     = "Mono"
+)
+
+var pdfHeadingSizes = []float64{22, 18, 15, 13, 12, 11}
+
+// pdfWriter renders a goldmark document to a PDF with fpdf, without a browser.
+type pdfWriter struct {
+	m         *MarkdownConverter
+	pdf       *fpdf.Fpdf
+	source    []byte
+	fonts     map[string]*sfnt.Font // by family, to check they have the characters drawn
+	glyphBuf  sfnt.Buffer
+	missing   []rune         // characters the fonts have no glyphs for
+	links     map[string]int // heading id to fpdf link
+	bold      int
+	italic    int
+	mono      int
+	size      float64
+	lastLevel int
+	images    int
+}
+
+// ConvertToPDF writes the flattened document as a PDF rendered in Go, for hosts without headless Chrome.
+func (m *MarkdownConverter) ConvertToPDF(w io.Writer, name string) error {
+	fullmd, err := m.Flatten()
+	if err != nil {
+		return zlog.Error("building doc", name, err)
+	}
+	return m.ConvertToPDFFromString(w, fullmd, name)
+}
+
+// ConvertToPDFFromString writes fullmd as a PDF with name as title, after preprocessing it like ConvertToHTMLFromString.
+// Headings get bookmarks, links to them work, and pages are numbered.
+// Text is drawn with the Go fonts, which cover Latin, Greek and Cyrillic. Set PDFFont for other scripts;
+// an error is returned if the text has characters the font can't draw.
+func (m *MarkdownConverter) ConvertToPDFFromString(w io.Writer, fullmd, name string) error {
+	templater := &Templater{TeXFontSize: 14, DPI: 144}
+	input := []byte(templater.Preprocess(m, m.HeaderMD+fullmd, name))
+	doc := m.parseGFM(input)
+
+	p := &pdfWriter{m: m, source: input, links: map[string]int{}, size: pdfFontSize}
+	p.pdf = fpdf.New("P", "mm", "A4", "")
+	err := p.addFonts()
+	if err != nil {
+		return err
+	}
+	p.pdf.SetTitle(name, true)
+	if m.Author != "" {
+		p.pdf.SetAuthor(m.Author, true)
+	}
+	p.pdf.SetCreator("zmarkdown", true)
+	p.pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	p.pdf.SetAutoPageBreak(true, pdfMargin)
+	p.pdf.SetFooterFunc(func() {
+		p.pdf.SetY(-pdfMargin + 5)
+		p.pdf.SetFont(pdfTextFamily, "", 9)
+		p.pdf.SetTextColor(128, 128, 128)
+		p.pdf.CellFormat(0, 10, strconv.Itoa(p.pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if h, is := n.(*ast.Heading); is && entering {
+			if id, got := h.AttributeString("id"); got {
+				p.links[string(id.([]byte))] = p.pdf.AddLink()
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	p.pdf.AddPage()
+	p.setFont()
+	p.blocks(doc)
+	if len(p.missing) != 0 {
+		return zlog.NewError("pdf font has no characters for", strconv.Quote(string(p.missing)), "in", name, "set PDFFont to one that has")
+	}
+	return p.pdf.Output(w)
+}
+
+// addFonts adds the Go fonts, or m.PDFFont for all styles, as UTF-8 fonts, so text isn't limited to cp1252.
+func (p *pdfWriter) addFonts() error {
+	families := map[string][4][]byte{ // regular, bold, italic and bold italic
+		pdfTextFamily: {goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF},
+		pdfMonoFamily: {gomono.TTF, gomonobold.TTF, gomonoitalic.TTF, gomonobolditalic.TTF},
+	}
+	if p.m.PDFFont != nil {
+		f := p.m.PDFFont
+		families[pdfTextFamily] = [4][]byte{f, f, f, f}
+		families[pdfMonoFamily] = families[pdfTextFamily]
+	}
+	p.fonts = map[string]*sfnt.Font{}
+	for family, ttfs := range families {
+		for i, style := range []string{"", "B", "I", "BI"} {
+			p.pdf.AddUTF8FontFromBytes(family, style, ttfs[i])
+		}
+		font, err := sfnt.Parse(ttfs[0])
+		if err != nil {
+			return zlog.Error("parse pdf font", family, err)
+		}
+		p.fonts[family] = font
+	}
+	if !p.pdf.Ok() {
+		return zlog.Error("add pdf fonts", p.pdf.Error())
+	}
+	return nil
+}
+
+// text returns str to draw with the current font, noting characters the font has no glyphs for.
+func (p *pdfWriter) text(str string) string {
+	font := p.fonts[p.family()]
+	for _, r := range str {
+		if r < ' ' || slices.Contains(p.missing, r) {
+			continue
+		}
+		i, err := font.GlyphIndex(&p.glyphBuf, r)
+		if err != nil || i == 0 {
+			p.missing = append(p.missing, r)
+		}
+	}
+	return str
+}
+
+func (p *pdfWriter) lineHeight() float64 {
+	return p.size * pdfLineFactor / pdfPointsPerMM
+}
+
+func (p *pdfWriter) family() string {
+	if p.mono > 0 {
+		return pdfMonoFamily
+	}
+	return pdfTextFamily
+}
+
+func (p *pdfWriter) setFont() {
+	var style string
+	if p.bold > 0 {
+		style += "B"
+	}
+	if p.italic > 0 {
+		style += "I"
+	}
+	p.pdf.SetFont(p.family(), style, p.size)
+}
+
+func (p *pdfWriter) write(str string) {
+	p.pdf.Write(p.lineHeight(), p.text(str))
+}
+
+func (p *pdfWriter) newLine() {
+	if p.pdf.GetX() > p.leftMargin()+0.1 {
+		p.pdf.Ln(p.lineHeight())
+	}
+}
+
+func (p *pdfWriter) leftMargin() float64 {
+	left, _, _, _ := p.pdf.GetMargins()
+	return left
+}
+
+func (p *pdfWriter) contentWidth() float64 {
+	width, _ := p.pdf.GetPageSize()
+	left, _, right, _ := p.pdf.GetMargins()
+	return width - left - right
+}
+
+func (p *pdfWriter) space(mm float64) {
+	p.newLine()
+	p.pdf.Ln(mm)
+}
+
+func (p *pdfWriter) indented(mm float64, do func()) {
+	left := p.leftMargin()
+	p.pdf.SetLeftMargin(left + mm)
+	p.pdf.SetX(left + mm)
+	do()
+	p.newLine()
+	p.pdf.SetLeftMargin(left)
+	p.pdf.SetX(left)
+}
+
+func (p *pdfWriter) blocks(n ast.Node) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		p.block(c)
+	}
+}
+
+func (p *pdfWriter) block(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Heading:
+		p.heading(n)
+	case *ast.Paragraph:
+		p.inlines(n)
+		p.space(pdfBlockSpacing)
+	case *ast.TextBlock:
+		p.inlines(n)
+		p.newLine()
+	case *ast.List:
+		p.list(n)
+		p.space(pdfBlockSpacing)
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		p.code(n)
+	case *ast.Blockquote:
+		p.pdf.SetTextColor(90, 90, 90)
+		p.italic++
+		p.setFont()
+		p.indented(pdfListIndent, func() {
+			p.blocks(n)
+		})
+		p.italic--
+		p.setFont()
+		p.pdf.SetTextColor(0, 0, 0)
+	case *ast.ThematicBreak:
+		p.newLine()
+		y := p.pdf.GetY() + pdfBlockSpacing
+		p.pdf.SetDrawColor(200, 200, 200)
+		p.pdf.Line(p.leftMargin(), y, p.leftMargin()+p.contentWidth(), y)
+		p.pdf.Ln(pdfBlockSpacing * 2)
+	case *ast.HTMLBlock:
+		// raw html can't be rendered without a browser
+	case *east.Table:
+		p.table(n)
+	case *east.DefinitionTerm:
+		p.bold++
+		p.setFont()
+		p.inlines(n)
+		p.bold--
+		p.setFont()
+		p.newLine()
+	case *east.DefinitionDescription:
+		p.indented(pdfListIndent, func() {
+			p.blocks(n)
+		})
+		p.space(pdfBlockSpacing)
+	case *east.FootnoteList:
+		p.footnotes(n)
+	default:
+		p.blocks(n)
+	}
+}
+
+func (p *pdfWriter) heading(h *ast.Heading) {
+	p.newLine()
+	if p.pdf.GetY() > 60 {
+		p.pdf.Ln(p.lineHeight() / 2)
+	}
+	old := p.size
+	p.size = pdfHeadingSizes[min(h.Level, len(pdfHeadingSizes))-1]
+	_, pageHeight := p.pdf.GetPageSize()
+	if p.pdf.GetY()+p.lineHeight()*3 > pageHeight-pdfMargin { // don't leave a heading alone at the bottom
+		p.pdf.AddPage()
+	}
+	if id, got := h.AttributeString("id"); got {
+		p.pdf.SetLink(p.links[string(id.([]byte))], -1, -1)
+	}
+	level := min(h.Level-1, p.lastLevel+1)
+	p.lastLevel = level
+	p.pdf.Bookmark(p.text(plainText(h, p.source)), level, -1)
+	p.bold++
+	p.setFont()
+	p.inlines(h)
+	p.bold--
+	p.newLine()
+	p.size = old
+	p.setFont()
+	p.pdf.Ln(pdfBlockSpacing)
+}
+
+func (p *pdfWriter) list(l *ast.List) {
+	number := l.Start
+	for item := l.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := "•"
+		if l.IsOrdered() {
+			marker = strconv.Itoa(number) + "."
+			number++
+		}
+		p.newLine()
+		p.indented(pdfListIndent, func() {
+			p.pdf.SetX(p.leftMargin() - pdfListIndent + 1)
+			p.write(marker)
+			p.pdf.SetX(p.leftMargin())
+			p.blocks(item)
+		})
+	}
+}
+
+func (p *pdfWriter) code(n ast.Node) {
+	var buf bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		buf.Write(seg.Value(p.source))
+	}
+	p.newLine()
+	old := p.size
+	p.size = pdfCodeFontSize
+	p.mono++
+	p.setFont()
+	p.pdf.SetFillColor(246, 248, 250)
+	p.pdf.MultiCell(p.contentWidth(), p.lineHeight(), p.text(strings.TrimRight(buf.String(), "\n")), "", "L", true)
+	p.mono--
+	p.size = old
+	p.setFont()
+	p.pdf.Ln(pdfBlockSpacing)
+}
+
+func (p *pdfWriter) table(t *east.Table) {
+	var rows [][]*east.TableCell
+	for r := t.FirstChild(); r != nil; r = r.NextSibling() {
+		var row []*east.TableCell
+		for c := r.FirstChild(); c != nil; c = c.NextSibling() {
+			row = append(row, c.(*east.TableCell))
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return
+	}
+	p.newLine()
+	colWidth := p.contentWidth() / float64(len(rows[0]))
+	lh := p.lineHeight()
+	_, pageHeight := p.pdf.GetPageSize()
+	p.pdf.SetDrawColor(200, 200, 200)
+	p.pdf.SetFillColor(246, 248, 250)
+	for i, row := range rows {
+		header := (i == 0)
+		if header {
+			p.bold++
+			p.setFont()
+		}
+		lines := 1
+		for _, cell := range row {
+			lines = max(lines, len(p.pdf.SplitText(p.text(plainText(cell, p.source)), colWidth-2)))
+		}
+		height := float64(lines)*lh + 1
+		if p.pdf.GetY()+height > pageHeight-pdfMargin {
+			p.pdf.AddPage()
+		}
+		y := p.pdf.GetY()
+		for j, cell := range row {
+			x := p.leftMargin() + float64(j)*colWidth
+			style := "D"
+			if header {
+				style = "FD"
+			}
+			p.pdf.Rect(x, y, colWidth, height, style)
+			p.pdf.SetXY(x, y+0.5)
+			p.pdf.MultiCell(colWidth, lh, p.text(plainText(cell, p.source)), "", cellAlign(cell.Alignment), false)
+		}
+		p.pdf.SetXY(p.leftMargin(), y+height)
+		if header {
+			p.bold--
+			p.setFont()
+		}
+	}
+	p.pdf.Ln(pdfBlockSpacing)
+}
+
+func cellAlign(a east.Alignment) string {
+	switch a {
+	case east.AlignRight:
+		return "R"
+	case east.AlignCenter:
+		return "C"
+	}
+	return "L"
+}
+
+func (p *pdfWriter) footnotes(list *east.FootnoteList) {
+	p.newLine()
+	y := p.pdf.GetY() + pdfBlockSpacing
+	p.pdf.SetDrawColor(200, 200, 200)
+	p.pdf.Line(p.leftMargin(), y, p.leftMargin()+p.contentWidth()/3, y)
+	p.pdf.Ln(pdfBlockSpacing * 2)
+	old := p.size
+	p.size = pdfCodeFontSize
+	p.setFont()
+	for n := list.FirstChild(); n != nil; n = n.NextSibling() {
+		f, is := n.(*east.Footnote)
+		if !is {
+			continue
+		}
+		p.indented(pdfFootnoteMarker, func() {
+			p.pdf.SetX(p.leftMargin() - pdfFootnoteMarker)
+			p.write(strconv.Itoa(f.Index) + ".")
+			p.pdf.SetX(p.leftMargin())
+			p.blocks(f)
+		})
+	}
+	p.size = old
+	p.setFont()
+}
+
+func (p *pdfWriter) inlines(n ast.Node) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		p.inline(c)
+	}
+}
+
+func (p *pdfWriter) inline(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Text:
+		p.write(string(n.Segment.Value(p.source)))
+		if n.SoftLineBreak() || n.HardLineBreak() {
+			p.pdf.Ln(p.lineHeight()) // html output uses hard wraps too
+		}
+	case *ast.String:
+		p.write(string(n.Value))
+	case *ast.Emphasis:
+		if n.Level >= 2 {
+			p.bold++
+		} else {
+			p.italic++
+		}
+		p.setFont()
+		p.inlines(n)
+		if n.Level >= 2 {
+			p.bold--
+		} else {
+			p.italic--
+		}
+		p.setFont()
+	case *ast.CodeSpan:
+		p.mono++
+		p.setFont()
+		p.inlines(n)
+		p.mono--
+		p.setFont()
+	case *ast.Link:
+		p.link(string(n.Destination), plainText(n, p.source))
+	case *ast.AutoLink:
+		p.link(string(n.URL(p.source)), string(n.Label(p.source)))
+	case *ast.Image:
+		p.image(n)
+	case *ast.RawHTML:
+		raw := strings.ToLower(rawHTMLText(n, p.source))
+		if strings.HasPrefix(raw, "<br") {
+			p.pdf.Ln(p.lineHeight())
+		}
+	case *east.TaskCheckBox:
+		box := "[  ] "
+		if n.IsChecked {
+			box = "[x] "
+		}
+		p.mono++
+		p.setFont()
+		p.write(box)
+		p.mono--
+		p.setFont()
+	case *east.FootnoteLink:
+		p.pdf.SubWrite(p.lineHeight(), strconv.Itoa(n.Index), p.size*0.7, p.size*0.4, 0, "")
+	case *east.FootnoteBacklink:
+	default:
+		p.inlines(n)
+	}
+}
+
+func (p *pdfWriter) link(dest, text string) {
+	p.pdf.SetTextColor(3, 102, 214)
+	if id, got := strings.CutPrefix(dest, "#"); got {
+		link, has := p.links[id]
+		if has {
+			p.pdf.WriteLinkID(p.lineHeight(), p.text(text), link)
+		} else {
+			p.write(text)
+		}
+	} else {
+		p.pdf.WriteLinkString(p.lineHeight(), p.text(text), dest)
+	}
+	p.pdf.SetTextColor(0, 0, 0)
+}
+
+// image draws an image from the converter's FileSystem, or its alt text if it can't be read.
+func (p *pdfWriter) image(img *ast.Image) {
+	data, imageType := p.m.readImage(string(img.Destination))
+	if data == nil {
+		p.italic++
+		p.setFont()
+		p.write(plainText(img, p.source))
+		p.italic--
+		p.setFont()
+		return
+	}
+	p.images++
+	name := fmt.Sprint("image", p.images)
+	info := p.pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+	if info == nil || !p.pdf.Ok() {
+		p.pdf.ClearError()
+		p.write(plainText(img, p.source))
+		return
+	}
+	width := min(info.Width()/pdfPointsPerMM, p.contentWidth())
+	p.newLine()
+	p.pdf.ImageOptions(name, p.leftMargin(), p.pdf.GetY(), width, 0, true, fpdf.ImageOptions{ImageType: imageType}, 0, "")
+}
+
+// readImage reads an image linked to in the markdown from FileSystem relative to Dir, returning its type for fpdf.
+func (m *MarkdownConverter) readImage(dest string) (data []byte, imageType string) {
+	if m.FileSystem == nil || isAbsoluteLink(dest) {
+		return nil, ""
+	}
+	imageType = strings.ToUpper(strings.TrimPrefix(path.Ext(dest), "."))
+	switch imageType {
+	case "JPEG":
+		imageType = "JPG"
+	case "PNG", "JPG", "GIF":
+	default:
+		return nil, ""
+	}
+	spath := dest
+	if !strings.HasPrefix(dest, "/") {
+		spath = zfile.JoinPathParts(m.Dir, dest)
+	}
+	data, err := zfile.ReadBytesFromFileInFS(m.FileSystem, strings.TrimPrefix(spath, "/"))
+	if err != nil {
+		return nil, ""
+	}
+	return data, imageType
+}
+
+// plainText is the text of n's inline descendants, without formatting.
+func plainText(n ast.Node, source []byte) string {
+	var str string
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch c := c.(type) {
+		case *ast.Text:
+			str += string(c.Segment.Value(source))
+			if c.SoftLineBreak() {
+				str += " "
+			}
+		case *ast.String:
+			str += string(c.Value)
+		case *ast.AutoLink:
+			str += string(c.Label(source))
+		}
+		return ast.WalkContinue, nil
+	})
+	return str
+}
+
+func rawHTMLText(n *ast.RawHTML, source []byte) string {
+	var str string
+	for i := 0; i < n.Segments.Len(); i++ {
+		seg := n.Segments.At(i)
+		str += string(seg.Value(source))
+	}
+	return str
+}
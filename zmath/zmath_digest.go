@@ -0,0 +1,206 @@
+package zmath
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Digest is a t-digest, which estimates quantiles of a stream of values using little memory,
+// with better accuracy near the extremes (like p99) than in the middle.
+// Values are clustered into centroids, fewer of them the higher the Compression.
+// Digests of different streams can be combined with MergeIn, and they serialize to JSON,
+// so summaries made on a server can be sent to the browser and merged there.
+type Digest struct {
+	Compression float64
+	Centroids   []Centroid
+	Count       float64
+	Min         float64
+	Max         float64
+
+	buffer []Centroid
+}
+
+type Centroid struct {
+	Mean   float64
+	Weight float64
+}
+
+const DefaultDigestCompression = 100
+
+func NewDigest(compression float64) *Digest {
+	if compression <= 0 {
+		compression = DefaultDigestCompression
+	}
+	return &Digest{Compression: compression}
+}
+
+func (d *Digest) Add(value float64) {
+	d.AddWeighted(value, 1)
+}
+
+func (d *Digest) AddWeighted(value, weight float64) {
+	if math.IsNaN(value) || weight <= 0 {
+		return
+	}
+	if d.Count == 0 || value < d.Min {
+		d.Min = value
+	}
+	if d.Count == 0 || value > d.Max {
+		d.Max = value
+	}
+	d.Count += weight
+	d.buffer = append(d.buffer, Centroid{Mean: value, Weight: weight})
+	if float64(len(d.buffer)) > d.compression()*5 {
+		d.compress()
+	}
+}
+
+// MergeIn adds all values of in to d, as if they had been added to d.
+func (d *Digest) MergeIn(in Digest) {
+	if in.Count == 0 {
+		return
+	}
+	if d.Count == 0 || in.Min < d.Min {
+		d.Min = in.Min
+	}
+	if d.Count == 0 || in.Max > d.Max {
+		d.Max = in.Max
+	}
+	d.Count += in.Count
+	d.buffer = append(d.buffer, in.Centroids...)
+	d.buffer = append(d.buffer, in.buffer...)
+	d.compress()
+}
+
+func (d *Digest) compression() float64 {
+	if d.Compression <= 0 {
+		return DefaultDigestCompression
+	}
+	return d.Compression
+}
+
+// kForQ and qForK are the t-digest k1 scale function and its inverse,
+// which limits centroid size so they get smaller towards q=0 and q=1.
+func (d *Digest) kForQ(q float64) float64 {
+	return d.compression() / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func (d *Digest) qForK(k float64) float64 {
+	if k >= d.compression()/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/d.compression()) + 1) / 2
+}
+
+func (d *Digest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+	all := append(slices.Clone(d.Centroids), d.buffer...)
+	d.buffer = nil
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Mean < all[j].Mean
+	})
+	var total float64
+	for _, c := range all {
+		total += c.Weight
+	}
+	out := make([]Centroid, 0, len(all))
+	cur := all[0]
+	var soFar float64
+	qLimit := d.qForK(d.kForQ(0) + 1)
+	for _, c := range all[1:] {
+		q := (soFar + cur.Weight + c.Weight) / total
+		if q <= qLimit {
+			cur.Mean += (c.Mean - cur.Mean) * c.Weight / (cur.Weight + c.Weight)
+			cur.Weight += c.Weight
+			continue
+		}
+		out = append(out, cur)
+		soFar += cur.Weight
+		qLimit = d.qForK(d.kForQ(soFar/total) + 1)
+		cur = c
+	}
+	d.Centroids = append(out, cur)
+}
+
+// Quantile returns an estimate of the value at q (0-1) of all values added, interpolating between centroids.
+// It returns NaN if nothing has been added.
+func (d *Digest) Quantile(q float64) float64 {
+	d.compress()
+	if d.Count == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return d.Min
+	}
+	if q >= 1 {
+		return d.Max
+	}
+	cs := d.Centroids
+	if len(cs) == 1 {
+		return cs[0].Mean
+	}
+	index := q * d.Count
+	if index < cs[0].Weight/2 {
+		return d.Min + (cs[0].Mean-d.Min)*index/(cs[0].Weight/2)
+	}
+	var soFar float64
+	for i := 0; i < len(cs)-1; i++ {
+		center := soFar + cs[i].Weight/2
+		nextCenter := soFar + cs[i].Weight + cs[i+1].Weight/2
+		if index <= nextCenter {
+			t := (index - center) / (nextCenter - center)
+			return cs[i].Mean + t*(cs[i+1].Mean-cs[i].Mean)
+		}
+		soFar += cs[i].Weight
+	}
+	last := cs[len(cs)-1]
+	center := d.Count - last.Weight/2
+	return last.Mean + (d.Max-last.Mean)*(index-center)/(last.Weight/2)
+}
+
+// CDF returns an estimate of the fraction of values added that are <= value.
+func (d *Digest) CDF(value float64) float64 {
+	d.compress()
+	if d.Count == 0 {
+		return math.NaN()
+	}
+	if value < d.Min {
+		return 0
+	}
+	if value >= d.Max {
+		return 1
+	}
+	cs := d.Centroids
+	if len(cs) == 1 {
+		return (value - d.Min) / (d.Max - d.Min)
+	}
+	if value < cs[0].Mean {
+		return cs[0].Weight / 2 * (value - d.Min) / (cs[0].Mean - d.Min) / d.Count
+	}
+	var soFar float64
+	for i := 0; i < len(cs)-1; i++ {
+		if value < cs[i+1].Mean {
+			center := soFar + cs[i].Weight/2
+			nextCenter := soFar + cs[i].Weight + cs[i+1].Weight/2
+			t := (value - cs[i].Mean) / (cs[i+1].Mean - cs[i].Mean)
+			return (center + t*(nextCenter-center)) / d.Count
+		}
+		soFar += cs[i].Weight
+	}
+	last := cs[len(cs)-1]
+	center := d.Count - last.Weight/2
+	return (center + last.Weight/2*(value-last.Mean)/(d.Max-last.Mean)) / d.Count
+}
+
+// MarshalJSON compresses a copy of d first, so values still buffered are included.
+func (d Digest) MarshalJSON() ([]byte, error) {
+	d.Centroids = slices.Clone(d.Centroids)
+	d.buffer = slices.Clone(d.buffer)
+	d.compress()
+	type digest Digest
+	return json.Marshal(digest(d))
+}
@@ -0,0 +1,63 @@
+package zmath
+
+import "math"
+
+// EWMA is an exponentially weighted moving average and variance.
+// Alpha is how much each new value counts, from 0 to 1; the higher, the faster it follows changes.
+type EWMA struct {
+	Alpha    float64
+	Mean     float64
+	Variance float64
+	Count    int64
+}
+
+func NewEWMA(alpha float64) *EWMA {
+	return &EWMA{Alpha: alpha}
+}
+
+// NewEWMAForHalfLife returns an EWMA where a value's weight halves after samples newer values.
+func NewEWMAForHalfLife(samples float64) *EWMA {
+	return NewEWMA(1 - math.Pow(0.5, 1/samples))
+}
+
+func (e *EWMA) Add(value float64) {
+	e.Count++
+	if e.Count == 1 {
+		e.Mean = value
+		e.Variance = 0
+		return
+	}
+	diff := value - e.Mean
+	inc := e.Alpha * diff
+	e.Mean += inc
+	e.Variance = (1 - e.Alpha) * (e.Variance + diff*inc)
+}
+
+func (e *EWMA) StdDev() float64 {
+	return math.Sqrt(e.Variance)
+}
+
+// MergeIn combines in with e, weighting by Count, including the spread between the two means in the variance.
+// This is an approximation, meant for EWMAs with the same Alpha of similar streams, like the same metric on several servers.
+func (e *EWMA) MergeIn(in EWMA) {
+	if in.Count == 0 {
+		return
+	}
+	if e.Count == 0 {
+		alpha := e.Alpha
+		*e = in
+		if alpha != 0 {
+			e.Alpha = alpha
+		}
+		return
+	}
+	total := float64(e.Count + in.Count)
+	fe := float64(e.Count) / total
+	fi := float64(in.Count) / total
+	mean := fe*e.Mean + fi*in.Mean
+	de := e.Mean - mean
+	di := in.Mean - mean
+	e.Variance = fe*(e.Variance+de*de) + fi*(in.Variance+di*di)
+	e.Mean = mean
+	e.Count += in.Count
+}
@@ -0,0 +1,116 @@
+package zmath
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func TestDigest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	d1 := NewDigest(100)
+	d2 := NewDigest(100)
+	for i := 0; i < 50000; i++ {
+		d1.Add(r.Float64() * 1000)
+		d2.Add(1000 + r.Float64()*1000)
+	}
+	ztesting.Equal(t, d1.Quantile(0), d1.Min, "q0 is min")
+	ztesting.NearEqualF(t, d1.Quantile(0.5)/1000, 0.5, "median", d1.Quantile(0.5))
+	ztesting.Equal(t, math.Abs(d1.Quantile(0.99)-990) < 2, true, "p99", d1.Quantile(0.99))
+	ztesting.Equal(t, math.Abs(d1.CDF(250)-0.25) < 0.01, true, "cdf", d1.CDF(250))
+	ztesting.GreaterThan(t, 1000, len(d1.Centroids))
+
+	data, err := json.Marshal(d2)
+	ztesting.Equal(t, err, nil, "marshal")
+	var d2b Digest
+	err = json.Unmarshal(data, &d2b)
+	ztesting.Equal(t, err, nil, "unmarshal")
+	d1.MergeIn(d2b)
+	ztesting.Equal(t, d1.Count, 100000.0, "merged count")
+	ztesting.Equal(t, math.Abs(d1.Quantile(0.5)-1000) < 10, true, "merged median", d1.Quantile(0.5))
+	ztesting.Equal(t, math.Abs(d1.Quantile(0.75)-1500) < 10, true, "merged q75", d1.Quantile(0.75))
+	ztesting.Equal(t, math.IsNaN(NewDigest(0).Quantile(0.5)), true, "empty is NaN")
+}
+
+func TestEWMA(t *testing.T) {
+	e := NewEWMAForHalfLife(10)
+	for i := 0; i < 200; i++ {
+		e.Add(10)
+	}
+	ztesting.NearEqualF(t, e.Mean, 10.0, "steady mean")
+	ztesting.NearEqualF(t, e.Variance, 0.0, "steady variance")
+	for i := 0; i < 10; i++ {
+		e.Add(20)
+	}
+	ztesting.NearEqualF(t, e.Mean, 15.0, "half-life") // half way after 10 samples
+	ztesting.GreaterThan(t, e.StdDev(), 1.0)
+
+	a := EWMA{Alpha: 0.1, Mean: 10, Count: 10}
+	b := EWMA{Alpha: 0.1, Mean: 20, Count: 10}
+	a.MergeIn(b)
+	ztesting.NearEqualF(t, a.Mean, 15.0, "merged mean")
+	ztesting.NearEqualF(t, a.Variance, 25.0, "merged variance")
+	ztesting.Equal(t, a.Count, int64(20), "merged count")
+}
+
+func TestSlidingWindow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewSlidingWindow(60, 10)
+	for i := 0; i < 120; i++ {
+		w.AddAt(float64(i), start.Add(time.Duration(i)*time.Second))
+	}
+	now := start.Add(119 * time.Second)
+	s := w.StatsAt(now)
+	ztesting.Equal(t, s.Min, 50.0, "min") // the bucket from 50-59 is partly in the window
+	ztesting.Equal(t, s.Max, 119.0, "max")
+	ztesting.Equal(t, s.Count, 70, "count")
+	ztesting.NearEqualF(t, s.Mean, 84.5, "mean")
+	ztesting.NearEqualF(t, s.StdDev, math.Sqrt((70*70-1)/12.0), "stddev")
+	ztesting.Equal(t, len(w.Buckets), 7, "buckets")
+
+	w2 := NewSlidingWindow(60, 10)
+	w2.AddAt(1000, now)
+	data, _ := json.Marshal(w2)
+	var w2b SlidingWindow
+	json.Unmarshal(data, &w2b)
+	w.MergeIn(w2b)
+	s = w.StatsAt(now)
+	ztesting.Equal(t, s.Max, 1000.0, "merged max")
+	ztesting.Equal(t, s.Count, 71, "merged count")
+	ztesting.Equal(t, w.StatsAt(now.Add(time.Hour)).Count, 0, "all old")
+}
+
+func TestTopK(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	top := NewTopK(10)
+	for i := 0; i < 10000; i++ {
+		switch {
+		case i%4 == 0:
+			top.Add("a")
+		case i%10 == 1:
+			top.Add("b")
+		default:
+			top.Add(string(rune('c' + r.Intn(20))))
+		}
+	}
+	best := top.Top(2)
+	ztesting.Equal(t, best[0].Key, "a", "most frequent")
+	ztesting.Equal(t, best[1].Key, "b", "second most frequent")
+	ztesting.Equal(t, best[0].Count-best[0].Error <= 2500, true, "a count lower bound")
+	ztesting.Equal(t, best[0].Count >= 2500, true, "a count upper bound")
+
+	other := NewTopK(10)
+	other.AddCount("b", 5000)
+	data, _ := json.Marshal(other)
+	var ob TopK
+	json.Unmarshal(data, &ob)
+	top.MergeIn(ob)
+	ztesting.Equal(t, top.Top(1)[0].Key, "b", "merged most frequent")
+	ztesting.Equal(t, len(top.Items), 10, "merged size")
+	top.Add("zz")
+	ztesting.Equal(t, len(top.Items), 10, "size after merge and add")
+}
@@ -0,0 +1,136 @@
+package zmath
+
+import "sort"
+
+// TopK finds the most frequent keys in a stream using the space-saving algorithm, keeping only Size counters.
+// A key's Count may be over-estimated by up to its Error, which is 0 for keys counted since they first appeared.
+// Keys with a true count above total/Size are always kept.
+type TopK struct {
+	Size  int
+	Items []TopKItem
+
+	index map[string]int
+}
+
+type TopKItem struct {
+	Key   string
+	Count int64
+	Error int64 `json:",omitempty"`
+}
+
+func NewTopK(size int) *TopK {
+	return &TopK{Size: size}
+}
+
+func (t *TopK) Add(key string) {
+	t.AddCount(key, 1)
+}
+
+func (t *TopK) AddCount(key string, n int64) {
+	t.makeIndex()
+	i, got := t.index[key]
+	if got {
+		t.Items[i].Count += n
+		return
+	}
+	if len(t.Items) < t.Size {
+		t.index[key] = len(t.Items)
+		t.Items = append(t.Items, TopKItem{Key: key, Count: n})
+		return
+	}
+	if len(t.Items) == 0 {
+		return
+	}
+	m := t.minIndex()
+	old := t.Items[m]
+	delete(t.index, old.Key)
+	t.index[key] = m
+	t.Items[m] = TopKItem{Key: key, Count: old.Count + n, Error: old.Count}
+}
+
+// makeIndex builds the key index, which is needed after unmarshaling.
+func (t *TopK) makeIndex() {
+	if t.index != nil {
+		return
+	}
+	t.index = map[string]int{}
+	for i, item := range t.Items {
+		t.index[item.Key] = i
+	}
+}
+
+func (t *TopK) minIndex() int {
+	m := 0
+	for i, item := range t.Items {
+		if item.Count < t.Items[m].Count {
+			m = i
+		}
+	}
+	return m
+}
+
+func (t *TopK) minCount() int64 {
+	if len(t.Items) < t.Size || len(t.Items) == 0 {
+		return 0
+	}
+	return t.Items[t.minIndex()].Count
+}
+
+// Top returns the n items with the highest count, highest first. If n <= 0 all are returned.
+func (t *TopK) Top(n int) []TopKItem {
+	items := append([]TopKItem{}, t.Items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Count > items[j].Count
+	})
+	if n > 0 && n < len(items) {
+		items = items[:n]
+	}
+	return items
+}
+
+// MergeIn combines in's counts with t's. A key missing from a full summary might have been counted up to
+// its minimum count there, so that is added to the key's count and error, before keeping the Size highest.
+func (t *TopK) MergeIn(in TopK) {
+	if t.Size == 0 {
+		t.Size = in.Size
+	}
+	tMin := t.minCount()
+	inMin := in.minCount()
+	merged := map[string]TopKItem{}
+	for _, item := range t.Items {
+		merged[item.Key] = item
+	}
+	inKeys := map[string]bool{}
+	for _, ii := range in.Items {
+		inKeys[ii.Key] = true
+		item, got := merged[ii.Key]
+		if got {
+			item.Count += ii.Count
+			item.Error += ii.Error
+		} else {
+			item = TopKItem{Key: ii.Key, Count: ii.Count + tMin, Error: ii.Error + tMin}
+		}
+		merged[ii.Key] = item
+	}
+	for key, item := range merged {
+		if !inKeys[key] {
+			item.Count += inMin
+			item.Error += inMin
+			merged[key] = item
+		}
+	}
+	t.Items = t.Items[:0]
+	for _, item := range merged {
+		t.Items = append(t.Items, item)
+	}
+	sort.Slice(t.Items, func(i, j int) bool {
+		if t.Items[i].Count == t.Items[j].Count {
+			return t.Items[i].Key < t.Items[j].Key
+		}
+		return t.Items[i].Count > t.Items[j].Count
+	})
+	if len(t.Items) > t.Size {
+		t.Items = t.Items[:t.Size]
+	}
+	t.index = nil
+}
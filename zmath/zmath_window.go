@@ -0,0 +1,129 @@
+package zmath
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// SlidingWindow keeps the count, mean, min, max and standard deviation of values added in the last WindowSecs.
+// Values are summed into buckets BucketSecs wide, so the window slides in steps of BucketSecs,
+// and memory use is WindowSecs/BucketSecs buckets no matter how many values are added.
+// Windows with the same BucketSecs can be combined with MergeIn.
+type SlidingWindow struct {
+	WindowSecs float64
+	BucketSecs float64
+	Buckets    []WindowBucket
+}
+
+type WindowBucket struct {
+	Start int64 // index of bucket since 1970, in BucketSecs
+	Accumulator
+	SumSquares float64
+}
+
+type WindowStats struct {
+	Count  int
+	Mean   float64
+	Min    float64
+	Max    float64
+	StdDev float64
+}
+
+func NewSlidingWindow(windowSecs, bucketSecs float64) *SlidingWindow {
+	if bucketSecs <= 0 {
+		bucketSecs = windowSecs / 60
+	}
+	return &SlidingWindow{WindowSecs: windowSecs, BucketSecs: bucketSecs}
+}
+
+func (w *SlidingWindow) bucketIndex(t time.Time) int64 {
+	return int64(math.Floor(float64(t.UnixMicro()) / 1e6 / w.BucketSecs))
+}
+
+func (w *SlidingWindow) Add(value float64) {
+	w.AddAt(value, time.Now())
+}
+
+func (w *SlidingWindow) AddAt(value float64, at time.Time) {
+	w.prune(at)
+	b := w.bucketFor(w.bucketIndex(at))
+	b.Add(value)
+	b.SumSquares += value * value
+}
+
+// bucketFor returns the bucket with index start, adding it in order if needed.
+func (w *SlidingWindow) bucketFor(start int64) *WindowBucket {
+	i := sort.Search(len(w.Buckets), func(i int) bool {
+		return w.Buckets[i].Start >= start
+	})
+	if i == len(w.Buckets) || w.Buckets[i].Start != start {
+		w.Buckets = append(w.Buckets, WindowBucket{})
+		copy(w.Buckets[i+1:], w.Buckets[i:])
+		w.Buckets[i] = WindowBucket{Start: start}
+	}
+	return &w.Buckets[i]
+}
+
+// prune removes buckets that are entirely older than WindowSecs before now.
+func (w *SlidingWindow) prune(now time.Time) {
+	oldest := w.bucketIndex(now.Add(-time.Duration(w.WindowSecs * float64(time.Second))))
+	i := 0
+	for i < len(w.Buckets) && w.Buckets[i].Start < oldest {
+		i++
+	}
+	if i > 0 {
+		w.Buckets = append(w.Buckets[:0], w.Buckets[i:]...)
+	}
+}
+
+func (w *SlidingWindow) Stats() WindowStats {
+	return w.StatsAt(time.Now())
+}
+
+// StatsAt returns the statistics of the window ending at now.
+func (w *SlidingWindow) StatsAt(now time.Time) WindowStats {
+	w.prune(now)
+	var s WindowStats
+	var sum, sumSquares float64
+	for _, b := range w.Buckets {
+		if b.Count == 0 {
+			continue
+		}
+		if s.Count == 0 || b.Min < s.Min {
+			s.Min = b.Min
+		}
+		if s.Count == 0 || b.Max > s.Max {
+			s.Max = b.Max
+		}
+		s.Count += b.Count
+		sum += b.Sum
+		sumSquares += b.SumSquares
+	}
+	if s.Count == 0 {
+		return s
+	}
+	n := float64(s.Count)
+	s.Mean = sum / n
+	s.StdDev = math.Sqrt(math.Max(0, sumSquares/n-s.Mean*s.Mean))
+	return s
+}
+
+// MergeIn adds the buckets of in to w. They must have the same BucketSecs.
+func (w *SlidingWindow) MergeIn(in SlidingWindow) {
+	for _, ib := range in.Buckets {
+		if ib.Count == 0 {
+			continue
+		}
+		b := w.bucketFor(ib.Start)
+		if b.Count == 0 || ib.Min < b.Min {
+			b.Min = ib.Min
+		}
+		if b.Count == 0 || ib.Max > b.Max {
+			b.Max = ib.Max
+		}
+		b.Count += ib.Count
+		b.Sum += ib.Sum
+		b.SumSquares += ib.SumSquares
+	}
+}
@@ -3,13 +3,17 @@ package znamedfuncs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go/token"
 	"net/http"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/torlangballe/zutil/zdebug"
+	"github.com/torlangballe/zutil/zdict"
+	"github.com/torlangballe/zutil/zerrors"
 	"github.com/torlangballe/zutil/zlog"
 	"github.com/torlangballe/zutil/znet"
 	"github.com/torlangballe/zutil/zprocess"
@@ -86,9 +90,21 @@ type methodType struct {
 // ReceivePayload is what the result of the call is returned in.
 type ReceivePayload struct {
 	Result           json.RawMessage
-	Error            string         `json:",omitempty"`
-	TransportError   TransportError `json:",omitempty"`
-	ExecutorTargetID int64          `json:",omitempty"` // Target ID of the executor that executed the call, for client to update targetID if changed.
+	Error            string               `json:",omitempty"`
+	ErrorCode        zerrors.Code         `json:",omitempty"` // zerrors.CodeOf the error, so callers can branch on it
+	ErrorContext     *ContextErrorPayload `json:",omitempty"` // set if the error was a zerrors.ContextError, to rebuild it with its values
+	TransportError   TransportError       `json:",omitempty"`
+	ExecutorTargetID int64                `json:",omitempty"` // Target ID of the executor that executed the call, for client to update targetID if changed.
+}
+
+// ContextErrorPayload is the parts of a zerrors.ContextError sent as separate fields,
+// as its WrappedError isn't sent as json, and can't be parsed back out of its Error() text.
+type ContextErrorPayload struct {
+	Title     string               `json:",omitempty"`
+	Code      zerrors.Code         `json:",omitempty"`
+	Wrapped   string               `json:",omitempty"` // the WrappedError's text
+	KeyValues zdict.Dict           `json:",omitempty"`
+	Sub       *ContextErrorPayload `json:",omitempty"`
 }
 
 var (
@@ -101,6 +117,68 @@ func (t TransportError) Error() string {
 	return string(t)
 }
 
+// ErrorCode makes TransportError a zerrors.Coder.
+func (t TransportError) ErrorCode() zerrors.Code {
+	switch {
+	case t == "":
+		return zerrors.CodeNone
+	case t == AuthenticationInvalidError:
+		return zerrors.CodeUnauthenticated
+	case t == ExecuteTimedOutError || strings.HasPrefix(string(t), "namedfuncs.Call expired"):
+		return zerrors.CodeDeadlineExceeded
+	case strings.HasPrefix(string(t), "no method registered"):
+		return zerrors.CodeUnimplemented
+	case strings.HasPrefix(string(t), "TargetID mismatch"):
+		return zerrors.CodeUnavailable
+	case strings.HasPrefix(string(t), "Unmarshal"):
+		return zerrors.CodeInvalidArgument
+	}
+	return zerrors.CodeInternal
+}
+
+func makeContextErrorPayload(ce *zerrors.ContextError) *ContextErrorPayload {
+	if ce == nil {
+		return nil
+	}
+	p := &ContextErrorPayload{Title: ce.Title, Code: ce.Code, KeyValues: ce.KeyValues}
+	if ce.WrappedError != nil {
+		p.Wrapped = ce.WrappedError.Error()
+	}
+	p.Sub = makeContextErrorPayload(ce.SubContextError)
+	return p
+}
+
+func (p *ContextErrorPayload) contextError() *zerrors.ContextError {
+	if p == nil {
+		return nil
+	}
+	ce := &zerrors.ContextError{Title: p.Title, Code: p.Code, KeyValues: p.KeyValues}
+	if p.Wrapped != "" {
+		ce.WrappedError = errors.New(p.Wrapped)
+	}
+	ce.SubContextError = p.Sub.contextError()
+	return ce
+}
+
+// ErrorFromPayload returns the error in rp, if any. A call error keeps its code and context,
+// a TransportError is wrapped, so zerrors.CodeOf works on both.
+func (rp *ReceivePayload) ErrorFromPayload() error {
+	if rp.Error != "" {
+		ce := zerrors.ContextError{Title: rp.Error}
+		if rp.ErrorContext != nil {
+			ce = *rp.ErrorContext.contextError()
+		}
+		if ce.Code == zerrors.CodeNone {
+			ce.Code = rp.ErrorCode
+		}
+		return ce
+	}
+	if rp.TransportError != "" {
+		return fmt.Errorf("RPC call transport error: %w", rp.TransportError)
+	}
+	return nil
+}
+
 func NewExecutor() *Executor {
 	e := &Executor{}
 	e.callMethods = map[string]*methodType{}
@@ -205,6 +283,15 @@ func suitableMethods(c any) map[string]*methodType {
 	return methods
 }
 
+// MethodTypes returns the argument and reply types of registered method name, reply is nil if it has none.
+func (e *Executor) MethodTypes(name string) (arg, reply reflect.Type, got bool) {
+	m, got := e.callMethods[name]
+	if !got {
+		return nil, nil, false
+	}
+	return m.ArgType, m.ReplyType, true
+}
+
 func isExportedOrBuiltinType(t reflect.Type) bool {
 	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -291,6 +378,10 @@ func callMethod(e *Executor, ci ClientInfo, mtype *methodType, rawArg json.RawMe
 		err := errInter.(error)
 		zlog.Error(EnableLogExecute, "Call Error", mtype.Method.Name, err)
 		rp.Error = err.Error()
+		rp.ErrorCode = zerrors.CodeOf(err)
+		if ce, got := err.(zerrors.ContextError); got {
+			rp.ErrorContext = makeContextErrorPayload(&ce)
+		}
 		return
 	}
 	if hasReply {
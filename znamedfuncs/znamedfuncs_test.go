@@ -3,8 +3,11 @@ package znamedfuncs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 
+	"github.com/torlangballe/zutil/zdict"
+	"github.com/torlangballe/zutil/zerrors"
 	"github.com/torlangballe/zutil/ztesting"
 )
 
@@ -20,6 +23,10 @@ func (Calls) Add(in AddStruct, out *int) error {
 	return nil
 }
 
+func (Calls) Find(name string, out *int) error {
+	return zerrors.MakeCodedError(zerrors.CodeNotFound, zdict.Dict{"Name": name}, "no such item: "+name, errors.New("lookup: failed"))
+}
+
 func TestCall(t *testing.T) {
 	executor := NewExecutor()
 	executor.Register(Calls{})
@@ -41,3 +48,36 @@ func TestCall(t *testing.T) {
 	}
 	ztesting.Equal(t, string(rp.Result), "7", "Add result not 7")
 }
+
+func TestErrorCode(t *testing.T) {
+	executor := NewExecutor()
+	executor.Register(Calls{})
+	call := func(method string, args any) error {
+		var cp CallPayloadReceive
+		cp.Method = method
+		cp.Args, _ = json.Marshal(args)
+		var result []byte
+		executor.ExecuteFromToJSON(mustMarshal(cp), &result, ClientInfo{}, 0)
+		var rp ReceivePayload
+		err := json.Unmarshal(result, &rp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rp.ErrorFromPayload()
+	}
+	err := call("Calls.Find", "x")
+	ztesting.Equal(t, zerrors.CodeOf(err), zerrors.CodeNotFound, "code kept")
+	ztesting.Equal(t, err.Error(), "no such item: x: lookup: failed", "message kept")
+	ce, _ := err.(zerrors.ContextError)
+	ztesting.Equal(t, ce.Title, "no such item: x", "title kept")
+	ztesting.Equal(t, ce.WrappedError.Error(), "lookup: failed", "wrapped kept")
+	ztesting.Equal(t, ce.KeyValues["Name"], any("x"), "values kept")
+
+	err = call("Calls.Missing", nil)
+	ztesting.Equal(t, zerrors.CodeOf(err), zerrors.CodeUnimplemented, "transport code")
+}
+
+func mustMarshal(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
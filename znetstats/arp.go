@@ -26,12 +26,10 @@ func (n *NetStats) listenARP(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case p := <-ps.Packets():
-			arp := p.Layer(layers.LayerTypeARP).(*layers.ARP)
-			if arp.Operation == 2 {
-				mac := net.HardwareAddr(arp.SourceHwAddress)
+			parsed, mac, got := arpReplyFromPacket(p)
+			if got {
 				m := n.lookupManuf(mac.String())
-				n.pushData(ParseIP(arp.SourceProtAddress).String(), mac, "", m)
-				parsed := ParseIP(arp.SourceProtAddress)
+				n.pushData(parsed.String(), mac, "", m)
 				go func() {
 					n.sendMdns(parsed, mac)
 					n.sendNbns(parsed, mac)
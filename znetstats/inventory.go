@@ -0,0 +1,231 @@
+//go:build server
+
+package znetstats
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/torlangballe/zutil/zkeyvalue"
+	"github.com/torlangballe/zutil/ztime"
+)
+
+type DeviceEventType string
+
+const (
+	DeviceNew       DeviceEventType = "new"
+	DeviceIPChanged DeviceEventType = "ip-changed"
+	DeviceGone      DeviceEventType = "gone"
+	DeviceReturned  DeviceEventType = "returned"
+)
+
+type IPChange struct {
+	IP    string
+	Until time.Time
+}
+
+type Device struct {
+	Mac       string
+	IP        string
+	Hostname  string
+	Manuf     string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Gone      bool
+	IPHistory []IPChange `json:",omitempty"` // previous IPs, and when the device stopped using them
+}
+
+type DeviceEvent struct {
+	Type   DeviceEventType
+	Device Device
+	OldIP  string // set for DeviceIPChanged
+	At     time.Time
+}
+
+// Inventory keeps track of devices seen on the LAN over time, keyed by MAC address.
+// It is fed by Observe, typically from repeated CollectStats sweeps in RunInventory,
+// or from a capture file using ReadPcapFile.
+// If a store is given, the devices are loaded from and saved to it.
+type Inventory struct {
+	GoneAfterSecs   float64                 // a device not seen for this long is marked gone by CheckGone
+	MaxIPHistoryLen int                     // IPHistory is trimmed to this length if > 0
+	HandleEvent     func(event DeviceEvent) // called for new, changed, gone and returned devices
+
+	lock      sync.Mutex
+	devices   map[string]*Device
+	hostnames map[string]string // hostnames from mDNS/NBNS responses for ips no device has yet
+	store     *zkeyvalue.Store
+	storeKey  string
+	changed   bool
+}
+
+func NewInventory(store *zkeyvalue.Store, storeKey string) *Inventory {
+	inv := &Inventory{}
+	inv.GoneAfterSecs = ztime.Day.Seconds()
+	inv.MaxIPHistoryLen = 20
+	inv.devices = map[string]*Device{}
+	inv.hostnames = map[string]string{}
+	inv.store = store
+	inv.storeKey = storeKey
+	if store != nil {
+		var devices []Device
+		if store.GetObject(storeKey, &devices) {
+			for _, d := range devices {
+				dev := d
+				inv.devices[d.Mac] = &dev
+			}
+		}
+	}
+	return inv
+}
+
+// Observe registers that a device with mac had ip (and possibly hostname and manufacturer) at time at.
+// Entries with no ip are ignored. As the mac is what identifies a device, a hostname without one,
+// as from mDNS/NBNS responses in a capture file, is set on the device with ip, or kept until one has it.
+func (inv *Inventory) Observe(ip, mac, hostname, manuf string, at time.Time) {
+	if ip == "" {
+		return
+	}
+	var events []DeviceEvent
+	inv.lock.Lock()
+	if mac == "" {
+		inv.observeHostname(ip, hostname)
+		inv.lock.Unlock()
+		return
+	}
+	if hostname == "" {
+		hostname = inv.hostnames[ip]
+	}
+	delete(inv.hostnames, ip)
+	d := inv.devices[mac]
+	if d == nil {
+		d = &Device{Mac: mac, IP: ip, Hostname: hostname, Manuf: manuf, FirstSeen: at, LastSeen: at}
+		inv.devices[mac] = d
+		events = append(events, DeviceEvent{Type: DeviceNew, Device: *d, At: at})
+	} else {
+		if at.Before(d.LastSeen) { // out-of-order packets from a capture file
+			at = d.LastSeen
+		}
+		if d.Gone {
+			d.Gone = false
+			events = append(events, DeviceEvent{Type: DeviceReturned, Device: *d, At: at})
+		}
+		if ip != d.IP {
+			old := d.IP
+			d.IPHistory = append(d.IPHistory, IPChange{IP: old, Until: at})
+			if inv.MaxIPHistoryLen > 0 && len(d.IPHistory) > inv.MaxIPHistoryLen {
+				d.IPHistory = d.IPHistory[len(d.IPHistory)-inv.MaxIPHistoryLen:]
+			}
+			d.IP = ip
+			events = append(events, DeviceEvent{Type: DeviceIPChanged, Device: *d, OldIP: old, At: at})
+		}
+		if hostname != "" {
+			d.Hostname = hostname
+		}
+		if manuf != "" {
+			d.Manuf = manuf
+		}
+		d.LastSeen = at
+	}
+	inv.changed = true
+	inv.lock.Unlock()
+	inv.sendEvents(events)
+}
+
+func (inv *Inventory) observeHostname(ip, hostname string) {
+	if hostname == "" {
+		return
+	}
+	for _, d := range inv.devices {
+		if d.IP == ip && !d.Gone {
+			if d.Hostname != hostname {
+				d.Hostname = hostname
+				inv.changed = true
+			}
+			return
+		}
+	}
+	inv.hostnames[ip] = hostname
+}
+
+// CheckGone marks devices not seen for GoneAfterSecs before now as gone, sending DeviceGone events for them.
+func (inv *Inventory) CheckGone(now time.Time) {
+	var events []DeviceEvent
+	inv.lock.Lock()
+	for _, d := range inv.devices {
+		if !d.Gone && now.Sub(d.LastSeen) > ztime.SecondsDur(inv.GoneAfterSecs) {
+			d.Gone = true
+			inv.changed = true
+			events = append(events, DeviceEvent{Type: DeviceGone, Device: *d, At: now})
+		}
+	}
+	inv.lock.Unlock()
+	inv.sendEvents(events)
+}
+
+func (inv *Inventory) sendEvents(events []DeviceEvent) {
+	if inv.HandleEvent == nil {
+		return
+	}
+	for _, e := range events {
+		inv.HandleEvent(e)
+	}
+}
+
+// Devices returns a copy of all devices ever seen, sorted by IP address.
+func (inv *Inventory) Devices() []Device {
+	inv.lock.Lock()
+	devices := make([]Device, 0, len(inv.devices))
+	for _, d := range inv.devices {
+		devices = append(devices, *d)
+	}
+	inv.lock.Unlock()
+	sort.Slice(devices, func(i, j int) bool {
+		return ParseIPString(devices[i].IP) < ParseIPString(devices[j].IP)
+	})
+	return devices
+}
+
+func (inv *Inventory) Device(mac string) (Device, bool) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	d := inv.devices[mac]
+	if d == nil {
+		return Device{}, false
+	}
+	return *d, true
+}
+
+// Save stores the devices in the inventory's store, if it has one and anything changed.
+func (inv *Inventory) Save() {
+	if inv.store == nil {
+		return
+	}
+	inv.lock.Lock()
+	changed := inv.changed
+	inv.changed = false
+	inv.lock.Unlock()
+	if changed {
+		inv.store.SetObject(inv.Devices(), inv.storeKey, true)
+	}
+}
+
+// RunInventory sweeps iface for sweepSecs every intervalSecs until ctx is done,
+// feeding the results into inv, checking for gone devices and saving it after each sweep.
+func (n *NetStats) RunInventory(ctx context.Context, iface string, inv *Inventory, sweepSecs, intervalSecs float64) {
+	for {
+		now := time.Now()
+		n.CollectStats(iface, sweepSecs, func(ip, mac, host, manuf string) {
+			inv.Observe(ip, mac, host, manuf, now)
+		})
+		inv.CheckGone(time.Now())
+		inv.Save()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ztime.SecondsDur(intervalSecs)):
+		}
+	}
+}
@@ -3,7 +3,6 @@
 package znetstats
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"net"
@@ -29,22 +28,9 @@ func (n *NetStats) listenMDNS(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case p := <-ps.Packets():
-			if len(p.Layers()) == 4 {
-				c := p.Layers()[3].LayerContents()
-				if c[2] == 0x84 && c[3] == 0x00 && c[6] == 0x00 && c[7] == 0x01 {
-					// ipv4 IP
-					i := p.Layer(layers.LayerTypeIPv4)
-					if i == nil {
-						continue
-					}
-					ipv4 := i.(*layers.IPv4)
-					ip := ipv4.SrcIP.String()
-					// hostname
-					h := ParseMdns(c)
-					if len(h) > 0 {
-						n.pushData(ip, nil, h, "")
-					}
-				}
+			ip, h := hostFromResponsePacket(p, ParseMdns)
+			if h != "" {
+				n.pushData(ip, nil, h, "")
 			}
 		}
 	}
@@ -130,34 +116,3 @@ func (n *NetStats) sendMdns(ip IP, mhaddr net.HardwareAddr) {
 		zlog.Fatal("writePacket...")
 	}
 }
-
-// 参数data  开头是 dns的协议头 0x0000 0x8400 0x0000 0x0001(ans) 0x0000 0x0000
-// 从 mdns响应报文中获取主机名
-func ParseMdns(data []byte) string {
-	var buf bytes.Buffer
-	i := bytes.Index(data, []byte{0x05, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x00})
-	if i < 0 {
-		return ""
-	}
-
-	for s := i - 1; s > 1; s-- {
-		num := i - s
-		if s-2 < 0 {
-			break
-		}
-		// 包括 .local_ 7 个字符
-		if bto16([]byte{data[s-2], data[s-1]}) == uint16(num+7) {
-			return Reverse(buf.String())
-		}
-		buf.WriteByte(data[s])
-	}
-
-	return ""
-}
-
-func bto16(b []byte) uint16 {
-	if len(b) != 2 {
-		zlog.Fatal("b只能是2个字节")
-	}
-	return uint16(b[0])<<8 + uint16(b[1])
-}
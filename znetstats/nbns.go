@@ -3,7 +3,6 @@
 package znetstats
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"math/rand"
@@ -29,20 +28,9 @@ func (n *NetStats) listenNBNS(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case p := <-ps.Packets():
-			if len(p.Layers()) == 4 {
-				c := p.Layers()[3].LayerContents()
-				if len(c) > 8 && c[2] == 0x84 && c[3] == 0x00 && c[6] == 0x00 && c[7] == 0x01 {
-					i := p.Layer(layers.LayerTypeIPv4)
-					if i == nil {
-						continue
-					}
-					ipv4 := i.(*layers.IPv4)
-					ip := ipv4.SrcIP.String()
-					m := ParseNBNS(c)
-					if len(m) > 0 {
-						n.pushData(ip, nil, m, "")
-					}
-				}
+			ip, m := hostFromResponsePacket(p, ParseNBNS)
+			if m != "" {
+				n.pushData(ip, nil, m, "")
 			}
 		}
 	}
@@ -123,23 +111,3 @@ func (n *NetStats) sendNbns(ip IP, mhaddr net.HardwareAddr) {
 		zlog.Fatal("udp...")
 	}
 }
-
-func ParseNBNS(data []byte) string {
-	var buf bytes.Buffer
-	i := bytes.Index(data, []byte{0x20, 0x43, 0x4b, 0x41, 0x41})
-	if i < 0 || len(data) < 32 {
-		return ""
-	}
-	index := i + 1 + 0x20 + 12
-	if data[index-1] == 0x00 {
-		return ""
-	}
-	for t := index; ; t++ {
-		// 0x20 0x00
-		if data[t] == 0x20 || data[t] == 0x00 {
-			break
-		}
-		buf.WriteByte(data[t])
-	}
-	return buf.String()
-}
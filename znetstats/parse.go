@@ -0,0 +1,122 @@
+//go:build server
+
+package znetstats
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// PacketInfo is what one ARP reply, mDNS or NBNS response tells about a host.
+// Mac is only set for ARP replies, Hostname only for mDNS/NBNS responses.
+type PacketInfo struct {
+	IP       string
+	Mac      net.HardwareAddr
+	Hostname string
+}
+
+// ParsePacket extracts host information from an ARP reply or an mDNS/NBNS response packet.
+// It is used by the live listeners and when reading offline capture files.
+func ParsePacket(p gopacket.Packet) (PacketInfo, bool) {
+	var info PacketInfo
+	ip, mac, got := arpReplyFromPacket(p)
+	if got {
+		info.IP = ip.String()
+		info.Mac = mac
+		return info, true
+	}
+	udp, _ := p.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if udp == nil {
+		return info, false
+	}
+	var parse func(data []byte) string
+	switch {
+	case udp.SrcPort == 5353 || udp.DstPort == 5353:
+		parse = ParseMdns
+	case udp.SrcPort == 137 || udp.DstPort == 137:
+		parse = ParseNBNS
+	default:
+		return info, false
+	}
+	info.IP, info.Hostname = hostFromResponsePacket(p, parse)
+	return info, info.Hostname != ""
+}
+
+func arpReplyFromPacket(p gopacket.Packet) (IP, net.HardwareAddr, bool) {
+	arp, _ := p.Layer(layers.LayerTypeARP).(*layers.ARP)
+	if arp == nil || arp.Operation != 2 || len(arp.SourceProtAddress) != 4 {
+		return 0, nil, false
+	}
+	return ParseIP(arp.SourceProtAddress), net.HardwareAddr(arp.SourceHwAddress), true
+}
+
+// hostFromResponsePacket checks that p is a single-answer dns-style response, and uses parse to get the hostname from it.
+func hostFromResponsePacket(p gopacket.Packet, parse func(data []byte) string) (ip, host string) {
+	if len(p.Layers()) != 4 {
+		return "", ""
+	}
+	c := p.Layers()[3].LayerContents()
+	if len(c) <= 8 || c[2] != 0x84 || c[3] != 0x00 || c[6] != 0x00 || c[7] != 0x01 {
+		return "", ""
+	}
+	ipv4, _ := p.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if ipv4 == nil {
+		return "", ""
+	}
+	return ipv4.SrcIP.String(), parse(c)
+}
+
+// 参数data  开头是 dns的协议头 0x0000 0x8400 0x0000 0x0001(ans) 0x0000 0x0000
+// 从 mdns响应报文中获取主机名
+func ParseMdns(data []byte) string {
+	var buf bytes.Buffer
+	i := bytes.Index(data, []byte{0x05, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x00})
+	if i < 0 {
+		return ""
+	}
+
+	for s := i - 1; s > 1; s-- {
+		num := i - s
+		if s-2 < 0 {
+			break
+		}
+		// 包括 .local_ 7 个字符
+		if bto16([]byte{data[s-2], data[s-1]}) == uint16(num+7) {
+			return Reverse(buf.String())
+		}
+		buf.WriteByte(data[s])
+	}
+
+	return ""
+}
+
+func bto16(b []byte) uint16 {
+	if len(b) != 2 {
+		zlog.Fatal("b只能是2个字节")
+	}
+	return uint16(b[0])<<8 + uint16(b[1])
+}
+
+func ParseNBNS(data []byte) string {
+	var buf bytes.Buffer
+	i := bytes.Index(data, []byte{0x20, 0x43, 0x4b, 0x41, 0x41})
+	if i < 0 || len(data) < 32 {
+		return ""
+	}
+	index := i + 1 + 0x20 + 12
+	if index >= len(data) || data[index-1] == 0x00 {
+		return ""
+	}
+	for t := index; t < len(data); t++ {
+		// 0x20 0x00
+		if data[t] == 0x20 || data[t] == 0x00 {
+			break
+		}
+		buf.WriteByte(data[t])
+	}
+	return buf.String()
+}
@@ -0,0 +1,77 @@
+//go:build server
+
+package znetstats
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+type pcapFileSource interface {
+	gopacket.PacketDataSource
+	LinkType() layers.LinkType
+}
+
+var pcapNgMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// ReadPcapFile reads a pcap or pcapng capture file, calling got with the capture time and
+// host information of each ARP reply or mDNS/NBNS response in it.
+// It doesn't need root or a live network, so it can be used to test the parsing.
+func ReadPcapFile(fpath string, got func(info PacketInfo, at time.Time)) error {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return zlog.Error("open", fpath, err)
+	}
+	defer file.Close()
+
+	var source pcapFileSource
+	reader := bufio.NewReader(file)
+	magic, _ := reader.Peek(len(pcapNgMagic))
+	if bytes.Equal(magic, pcapNgMagic) {
+		source, err = pcapgo.NewNgReader(reader, pcapgo.DefaultNgReaderOptions)
+	} else {
+		source, err = pcapgo.NewReader(reader)
+	}
+	if err != nil {
+		return zlog.Error("new reader", fpath, err)
+	}
+	ps := gopacket.NewPacketSource(source, source.LinkType())
+	for p := range ps.Packets() {
+		info, ok := ParsePacket(p)
+		if ok {
+			got(info, p.Metadata().Timestamp)
+		}
+	}
+	return nil
+}
+
+// CollectStatsFromPcapFile is like CollectStats, but gets its packets from a capture file rather than a live sweep.
+// got is also called with the capture time of the last packet from each ip.
+func (n *NetStats) CollectStatsFromPcapFile(fpath string, got func(ip, mac, host, manuf string, at time.Time)) error {
+	n.data = make(map[string]Info)
+	seen := map[string]time.Time{}
+	err := ReadPcapFile(fpath, func(info PacketInfo, at time.Time) {
+		var manuf string
+		if info.Mac != nil {
+			manuf = n.lookupManuf(info.Mac.String())
+		}
+		n.addData(info.IP, info.Mac, info.Hostname, manuf)
+		if at.After(seen[info.IP]) {
+			seen[info.IP] = at
+		}
+	})
+	if err != nil {
+		return err
+	}
+	n.forwardData(func(ip, mac, host, manuf string) {
+		got(ip, mac, host, manuf, seen[ip])
+	})
+	return nil
+}
@@ -65,6 +65,10 @@ func (n *NetStats) pushData(ip string, mac net.HardwareAddr, hostname string, ma
 		n.do <- END
 		mu.RUnlock()
 	}()
+	n.addData(ip, mac, hostname, manuf)
+}
+
+func (n *NetStats) addData(ip string, mac net.HardwareAddr, hostname string, manuf string) {
 	if _, ok := n.data[ip]; !ok {
 		n.data[ip] = Info{Mac: mac, Hostname: hostname, Manuf: manuf}
 		return
@@ -0,0 +1,196 @@
+//go:build server
+
+package znetstats
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+var (
+	testMac   = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	testLocal = net.HardwareAddr{0x00, 0xaa, 0xbb, 0xcc, 0xdd, 0xee}
+)
+
+func mdnsResponse(host string) []byte {
+	data := []byte{0x00, 0x00, 0x84, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+	data = append(data, 1, '5', 1, '1', 3, '1', '6', '8', 3, '1', '9', '2', 7, 'i', 'n', '-', 'a', 'd', 'd', 'r', 4, 'a', 'r', 'p', 'a', 0)
+	data = append(data, 0x00, 0x0c, 0x80, 0x01, 0x00, 0x00, 0x00, 0x78) // PTR, IN, ttl
+	data = binary.BigEndian.AppendUint16(data, uint16(len(host)+1+7))
+	data = append(data, byte(len(host)))
+	data = append(data, host...)
+	return append(data, 5, 'l', 'o', 'c', 'a', 'l', 0)
+}
+
+func nbnsResponse(name string) []byte {
+	data := []byte{0x12, 0x34, 0x84, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+	data = append(data, 0x20, 'C', 'K')
+	for i := 0; i < 30; i++ {
+		data = append(data, 'A')
+	}
+	data = append(data, 0x00, 0x00, 0x21, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x41, 0x01)
+	padded := []byte("               ")
+	copy(padded, name)
+	data = append(data, padded...)
+	return append(data, 0x00, 0x04, 0x00)
+}
+
+func udpPacket(t *testing.T, srcIP string, port int, payload []byte) []byte {
+	ether := &layers.Ethernet{SrcMAC: testMac, DstMAC: testLocal, EthernetType: layers.EthernetTypeIPv4}
+	ip4 := &layers.IPv4{Version: 4, IHL: 5, TTL: 255, Protocol: layers.IPProtocolUDP, SrcIP: net.ParseIP(srcIP).To4(), DstIP: net.ParseIP("192.168.1.2").To4()}
+	udp := &layers.UDP{SrcPort: layers.UDPPort(port), DstPort: layers.UDPPort(60666)}
+	udp.SetNetworkLayerForChecksum(ip4)
+	buffer := gopacket.NewSerializeBuffer()
+	opt := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	err := gopacket.SerializeLayers(buffer, opt, ether, ip4, udp, gopacket.Payload(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func arpReply(t *testing.T, ip string, mac net.HardwareAddr) []byte {
+	ether := &layers.Ethernet{SrcMAC: mac, DstMAC: testLocal, EthernetType: layers.EthernetTypeARP}
+	a := &layers.ARP{
+		AddrType:          layers.LinkTypeEthernet,
+		Protocol:          layers.EthernetTypeIPv4,
+		HwAddressSize:     6,
+		ProtAddressSize:   4,
+		Operation:         2,
+		SourceHwAddress:   mac,
+		SourceProtAddress: net.ParseIP(ip).To4(),
+		DstHwAddress:      testLocal,
+		DstProtAddress:    net.ParseIP("192.168.1.2").To4(),
+	}
+	buffer := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buffer, gopacket.SerializeOptions{}, ether, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func writePcap(t *testing.T, start time.Time, packets ...[]byte) string {
+	fpath := filepath.Join(t.TempDir(), "capture.pcap")
+	file, err := os.Create(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	w := pcapgo.NewWriter(file)
+	w.WriteFileHeader(65536, layers.LinkTypeEthernet)
+	for i, p := range packets {
+		ci := gopacket.CaptureInfo{Timestamp: start.Add(time.Duration(i) * time.Second), CaptureLength: len(p), Length: len(p)}
+		err = w.WritePacket(ci, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fpath
+}
+
+func TestParse(t *testing.T) {
+	ztesting.Equal(t, ParseMdns(mdnsResponse("printer")), "printer", "mdns")
+	ztesting.Equal(t, ParseNBNS(nbnsResponse("NASBOX")), "NASBOX", "nbns")
+	ztesting.Equal(t, ParseNBNS([]byte{0x20, 0x43, 0x4b, 0x41, 0x41}), "", "nbns truncated")
+}
+
+func TestPcapFile(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fpath := writePcap(t, start,
+		arpReply(t, "192.168.1.5", testMac),
+		udpPacket(t, "192.168.1.5", 5353, mdnsResponse("printer")),
+		udpPacket(t, "192.168.1.7", 137, nbnsResponse("NASBOX")),
+		arpReply(t, "192.168.1.9", testMac),
+	)
+	var infos []PacketInfo
+	err := ReadPcapFile(fpath, func(info PacketInfo, at time.Time) {
+		infos = append(infos, info)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ztesting.Equal(t, len(infos), 4, "packet count") {
+		return
+	}
+	ztesting.Equal(t, infos[0].Mac.String(), testMac.String(), "arp mac")
+	ztesting.Equal(t, infos[1].Hostname, "printer", "mdns host")
+	ztesting.Equal(t, infos[2].IP, "192.168.1.7", "nbns ip")
+
+	n := New()
+	hosts := map[string]string{}
+	seen := map[string]time.Time{}
+	err = n.CollectStatsFromPcapFile(fpath, func(ip, mac, host, manuf string, at time.Time) {
+		hosts[ip] = host
+		seen[ip] = at
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, hosts["192.168.1.5"], "printer", "collected host")
+	ztesting.Equal(t, len(hosts), 3, "collected count")
+	ztesting.Equal(t, seen["192.168.1.5"], start.Add(time.Second), "last packet time")
+	ztesting.Equal(t, seen["192.168.1.9"], start.Add(3*time.Second), "capture time")
+
+	inv := NewInventory(nil, "")
+	err = ReadPcapFile(fpath, func(info PacketInfo, at time.Time) {
+		var mac string
+		if info.Mac != nil {
+			mac = info.Mac.String()
+		}
+		inv.Observe(info.IP, mac, info.Hostname, "", at)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := inv.Device(testMac.String())
+	ztesting.Equal(t, d.Hostname, "printer", "hostname without mac")
+	ztesting.Equal(t, d.LastSeen, start.Add(3*time.Second), "inventory capture time")
+	inv.Observe("192.168.1.7", "00:01:02:03:04:05", "", "", start.Add(time.Minute))
+	d, _ = inv.Device("00:01:02:03:04:05")
+	ztesting.Equal(t, d.Hostname, "NASBOX", "hostname before device")
+}
+
+func TestInventory(t *testing.T) {
+	var events []DeviceEvent
+	inv := NewInventory(nil, "")
+	inv.GoneAfterSecs = 60
+	inv.HandleEvent = func(e DeviceEvent) {
+		events = append(events, e)
+	}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	mac := testMac.String()
+	inv.Observe("192.168.1.5", mac, "printer", "", start)
+	inv.Observe("192.168.1.5", mac, "", "", start.Add(10*time.Second))
+	inv.Observe("192.168.1.9", mac, "", "", start.Add(20*time.Second))
+	inv.CheckGone(start.Add(2 * time.Minute))
+	inv.Observe("192.168.1.9", mac, "", "", start.Add(3*time.Minute))
+
+	var types []DeviceEventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	if !ztesting.Equal(t, len(types), 4, "event count", types) {
+		return
+	}
+	ztesting.Equal(t, types[0], DeviceNew)
+	ztesting.Equal(t, types[1], DeviceIPChanged)
+	ztesting.Equal(t, events[1].OldIP, "192.168.1.5")
+	ztesting.Equal(t, types[2], DeviceGone)
+	ztesting.Equal(t, types[3], DeviceReturned)
+
+	d, got := inv.Device(mac)
+	ztesting.Equal(t, got, true, "device")
+	ztesting.Equal(t, d.Hostname, "printer")
+	ztesting.Equal(t, d.FirstSeen, start)
+	ztesting.Equal(t, len(d.IPHistory), 1)
+}
@@ -357,6 +357,34 @@ func MemoryBytesUsedByProcess(processID int64) int64 {
 	return int64(info.RSS)
 }
 
+// GetProcessStats returns the CPU time, memory, open files and threads of process with processID.
+func GetProcessStats(processID int64) (ProcessStats, error) {
+	stats := ProcessStats{OpenFiles: -1}
+	proc, err := process.NewProcess(int32(processID))
+	if err != nil {
+		return stats, err
+	}
+	times, err := proc.Times()
+	if err != nil {
+		return stats, err
+	}
+	stats.CPUSeconds = times.User + times.System
+	info, err := proc.MemoryInfo()
+	if err != nil {
+		return stats, err
+	}
+	stats.RSS = int64(info.RSS)
+	fds, err := proc.NumFDs()
+	if err == nil {
+		stats.OpenFiles = int(fds)
+	}
+	threads, err := proc.NumThreads()
+	if err == nil {
+		stats.Threads = int(threads)
+	}
+	return stats, nil
+}
+
 func ConsumeOutAndError(outPipe, errPipe io.ReadCloser, ctx context.Context, dump func(isErr bool, line string)) {
 	if dump == nil {
 		dump = func(isErr bool, line string) {
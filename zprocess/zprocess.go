@@ -24,6 +24,14 @@ type OnceWait struct {
 	timeout time.Duration
 }
 
+// ProcessStats is what a process uses of the machine, from GetProcessStats.
+type ProcessStats struct {
+	CPUSeconds float64 // user and system time used since it started
+	RSS        int64   // resident memory in bytes
+	OpenFiles  int     // open file descriptors, -1 if unknown on this platform
+	Threads    int
+}
+
 var (
 	MainThreadExeCh chan func()
 	procs           zmap.LockMap[int64, *proc]
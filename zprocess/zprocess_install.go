@@ -3,8 +3,10 @@
 package zprocess
 
 import (
+	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
 	"strings"
 
 	"github.com/torlangballe/zutil/zfile"
@@ -12,11 +14,47 @@ import (
 	"github.com/torlangballe/zutil/zstr"
 )
 
+type InitSystem string
+
+const (
+	InitAuto    InitSystem = "" // launchd on macOS, otherwise systemd, OpenRC or SysV, whichever is found
+	InitLaunchd InitSystem = "launchd"
+	InitSystemd InitSystem = "systemd"
+	InitOpenRC  InitSystem = "openrc"
+	InitSysV    InitSystem = "sysv"
+)
+
+// SystemdOptions are extra settings for a systemd unit file. Zero values are not written.
+type SystemdOptions struct {
+	UserUnit         bool // installed in ~/.config/systemd/user and controlled with systemctl --user
+	EnvironmentFiles []string
+	Environment      map[string]string
+	RestartSecs      float64
+	LimitNOFILE      int
+	MemoryMax        string // i.e 512M
+	CPUQuota         string // i.e 50%
+	After            []string
+}
+
 type Installer struct {
 	Company     string
 	ProductName string
 	Domain      string
 	UserName    string // username to set owner of file/dirs
+	InitSystem  InitSystem
+	Systemd     SystemdOptions
+
+	// If DryRunRoot is set, all files are written relative to it instead of /,
+	// and service commands are not run, but added to DryRunCommands.
+	// Paths returned and rendered into launcher files are the same as for a real install.
+	DryRunRoot     string
+	DryRunCommands []string
+}
+
+type ServiceStatus struct {
+	Installed bool // the launcher file exists
+	Running   bool
+	Output    string // output of the status command
 }
 
 const launchAgentPlistStr = `
@@ -55,37 +93,117 @@ const launchAgentPlistStr = `
 
 const serviceFileStr = `
 [Unit]
-
+{unit}
 [Service]
-AmbientCapabilities=CAP_NET_BIND_SERVICE
-PermissionsStartOnly=true
-ExecStart={bin} {args}
+{system}ExecStart={bin} {args}
 Restart=always
-User={user}
-StandardOutput=journal
+{service}StandardOutput=journal
 WorkingDirectory={bindir}
 [Install]
 WantedBy=default.target
 `
 
+const openRCScriptStr = `#!/sbin/openrc-run
+
+name="{id}"
+description="{product}"
+command="{bin}"
+command_args="{args}"
+command_user="{user}"
+command_background=true
+pidfile="/run/{id}.pid"
+directory="{bindir}"
+output_log="{log}"
+error_log="{log}"
+
+depend() {
+	need net
+}
+`
+
+const sysVScriptStr = `#!/bin/sh
+### BEGIN INIT INFO
+# Provides:          {id}
+# Required-Start:    $network $remote_fs
+# Required-Stop:     $network $remote_fs
+# Default-Start:     2 3 4 5
+# Default-Stop:      0 1 6
+# Short-Description: {product}
+### END INIT INFO
+
+PIDFILE="/var/run/{id}.pid"
+
+is_running() {
+	[ -f "$PIDFILE" ] && kill -0 "$(cat "$PIDFILE")" 2>/dev/null
+}
+
+start() {
+	if is_running; then
+		echo "{id} is already running"
+		return 0
+	fi
+	cd "{bindir}" || return 1
+	{start} &
+}
+
+stop() {
+	if is_running; then
+		kill "$(cat "$PIDFILE")"
+		for i in 1 2 3 4 5 6 7 8 9 10; do
+			is_running || break
+			sleep 1
+		done
+		is_running && kill -9 "$(cat "$PIDFILE")"
+	fi
+	rm -f "$PIDFILE"
+}
+
+case "$1" in
+	start) start ;;
+	stop) stop ;;
+	restart) stop; start ;;
+	status)
+		if is_running; then
+			echo "{id} is running"
+		else
+			echo "{id} is stopped"
+			exit 3
+		fi
+		;;
+	*)
+		echo "Usage: $0 {start|stop|restart|status}"
+		exit 1
+		;;
+esac
+`
+
 func (i *Installer) addPath(isFile bool, parts ...string) string {
 	var path string
 	for j, part := range parts {
 		path = zfile.JoinPathParts(path, part)
+		rooted := i.rootedPath(path)
 		if j != len(parts)-1 || !isFile {
-			err := zfile.MakeDirAllIfNotExists(path)
-			zlog.AssertNotError(err, path)
+			err := zfile.MakeDirAllIfNotExists(rooted)
+			zlog.AssertNotError(err, rooted)
 		}
 		if i.UserName != "" {
-			if zfile.Exists(path) {
-				err := zfile.SetOwnerAndMainGroup(path, i.UserName)
-				zlog.AssertNotError(err, path, i.UserName)
+			if zfile.Exists(rooted) {
+				err := zfile.SetOwnerAndMainGroup(rooted, i.UserName)
+				zlog.AssertNotError(err, rooted, i.UserName)
 			}
 		}
 	}
 	return path
 }
 
+// rootedPath returns fpath inside DryRunRoot if it is set. It is used where files are written, read or removed.
+func (i *Installer) rootedPath(fpath string) string {
+	if i.DryRunRoot == "" {
+		return fpath
+	}
+	return filepath.Join(i.DryRunRoot, fpath)
+}
+
 func (i *Installer) OptPath(isFile bool, parts ...string) string {
 	str := "/opt/"
 	if OnDarwin() {
@@ -110,7 +228,42 @@ func (i *Installer) ID() string {
 	return zstr.Concat(".", i.Domain, i.Company, i.ProductName)
 }
 
-func (i *Installer) InstallProgramWithLauncher(args []string, copyBinary bool) error {
+// GetInitSystem returns i.InitSystem, or if it is InitAuto, the one used on this machine.
+func (i *Installer) GetInitSystem() InitSystem {
+	if i.InitSystem != InitAuto {
+		return i.InitSystem
+	}
+	if OnDarwin() {
+		return InitLaunchd
+	}
+	if zfile.Exists("/run/systemd/system") {
+		return InitSystemd
+	}
+	if zfile.Exists("/sbin/openrc-run") {
+		return InitOpenRC
+	}
+	return InitSysV
+}
+
+// LauncherPath is where the plist, unit file or init script for the program is installed.
+func (i *Installer) LauncherPath() string {
+	var fpath string
+	switch i.GetInitSystem() {
+	case InitLaunchd:
+		fpath = zfile.ExpandTildeInFilepath("~/Library/LaunchAgents/"+i.ID()) + ".plist"
+	case InitSystemd:
+		if i.Systemd.UserUnit {
+			fpath = zfile.ExpandTildeInFilepath("~/.config/systemd/user/" + i.ID() + ".service")
+		} else {
+			fpath = "/lib/systemd/system/" + i.ID() + ".service"
+		}
+	default:
+		fpath = "/etc/init.d/" + i.ID()
+	}
+	return fpath
+}
+
+func (i *Installer) setUserName() {
 	user, _ := user.Current()
 	if user != nil {
 		i.UserName = user.Username
@@ -124,65 +277,285 @@ func (i *Installer) InstallProgramWithLauncher(args []string, copyBinary bool) e
 		}
 		// zlog.Info("InstallProgramWithLauncher2:", i.UserName, wd)
 	}
-	// zlog.Info("InstallProgramWithLauncher2:", i.UserName)
-	binDir := i.BinPath(false)
-	bin := i.BinPath(true, i.ProductName)
-	if copyBinary {
-		os.Remove(bin) // delete existing, in case running, and we get busy error
-		err := zfile.CopyFile(bin, os.Args[0])
-		if zlog.OnError(err, bin, os.Args[0]) {
-			return err
-		}
+}
+
+func (i *Installer) copyBinary(source string) error {
+	bin := i.rootedPath(i.BinPath(true, i.ProductName))
+	os.Remove(bin) // delete existing, in case running, and we get busy error
+	err := zfile.CopyFile(bin, source)
+	if zlog.OnError(err, bin, source) {
+		return err
 	}
+	return i.setBinaryPermissions()
+}
+
+func (i *Installer) setBinaryPermissions() error {
+	bin := i.rootedPath(i.BinPath(true, i.ProductName))
 	err := os.Chmod(bin, 0700)
 	if zlog.OnError(err, bin) {
 		return err
 	}
-
 	if i.UserName != "" {
 		err := zfile.SetOwnerAndMainGroup(bin, i.UserName)
 		if zlog.OnError(err, bin) {
 			return err
 		}
 	}
-	var launchConfig, launcherPath, serviceTool, sargs string
-	if OnDarwin() {
-		serviceTool = "launchctl"
+	return nil
+}
+
+func (i *Installer) systemdOptionLines() (unit, system, service string) {
+	o := i.Systemd
+	for _, a := range o.After {
+		unit += "After=" + a + "\n"
+	}
+	if !o.UserUnit {
+		system = "AmbientCapabilities=CAP_NET_BIND_SERVICE\nPermissionsStartOnly=true\n"
+	}
+	if o.RestartSecs != 0 {
+		service += fmt.Sprintf("RestartSec=%g\n", o.RestartSecs)
+	}
+	if !o.UserUnit {
+		service += "User=" + i.UserName + "\n"
+	}
+	for _, f := range o.EnvironmentFiles {
+		service += "EnvironmentFile=" + f + "\n"
+	}
+	keys := zstr.SortedMapKeys(o.Environment)
+	for _, k := range keys {
+		service += fmt.Sprintf("Environment=%q\n", k+"="+o.Environment[k])
+	}
+	if o.LimitNOFILE != 0 {
+		service += fmt.Sprint("LimitNOFILE=", o.LimitNOFILE, "\n")
+	}
+	if o.MemoryMax != "" {
+		service += "MemoryMax=" + o.MemoryMax + "\n"
+	}
+	if o.CPUQuota != "" {
+		service += "CPUQuota=" + o.CPUQuota + "\n"
+	}
+	return unit, system, service
+}
+
+// RenderLauncher returns the launcher file contents for the program run with args.
+func (i *Installer) RenderLauncher(args []string) string {
+	var launchConfig, sargs, unit, system, service string
+	switch i.GetInitSystem() {
+	case InitLaunchd:
 		launchConfig = launchAgentPlistStr
-		launcherPath = zfile.ExpandTildeInFilepath("~/Library/LaunchAgents/"+i.ID()) + ".plist"
 		for _, a := range args {
 			sargs += "<string>" + a + "</string>\n"
 		}
-	} else {
-		serviceTool = "systemctl"
+	case InitSystemd:
 		launchConfig = serviceFileStr
-		launcherPath = "/lib/systemd/system/" + i.ID() + ".service"
 		sargs = strings.Join(args, " ")
+		unit, system, service = i.systemdOptionLines()
+	case InitOpenRC:
+		launchConfig = openRCScriptStr
+		sargs = quotedArgs(args)
+	case InitSysV:
+		launchConfig = strings.Replace(sysVScriptStr, "{start}", i.sysVStartLine(), 1)
+		sargs = quotedArgs(args)
 	}
 	replacer := strings.NewReplacer(
 		"{user}", i.UserName,
-		"{bin}", bin,
+		"{bin}", i.BinPath(true, i.ProductName),
 		"{log}", i.VarPath(true, "log.txt"),
 		"{id}", i.ID(),
-		"{bindir}", binDir,
+		"{product}", i.ProductName,
+		"{bindir}", i.BinPath(false),
 		"{args}", sargs,
+		"{unit}", unit,
+		"{system}", system,
+		"{service}", service,
 	)
-	zlog.Info("WriteServiceFile:", launcherPath)
-	launchConfig = replacer.Replace(launchConfig)
-	err = zfile.WriteStringToFile(launchConfig, launcherPath)
-	zlog.Info("install launcher", launcherPath, zfile.Exists(launcherPath))
+	return replacer.Replace(launchConfig)
+}
+
+// sysVStartLine returns the command starting the program in the background in a SysV script, as UserName if set.
+// The program's shell writes its own pid before exec'ing it, as $! would be the pid of su.
+func (i *Installer) sysVStartLine() string {
+	script := `'echo $$ > "/var/run/{id}.pid"; exec "$0" "$@" >> "{log}" 2>&1'`
+	if i.UserName == "" {
+		return `/bin/sh -c ` + script + ` "{bin}" {args}`
+	}
+	return `touch "$PIDFILE" && chown {user} "$PIDFILE" || return 1
+	su -s /bin/sh -c ` + script + ` {user} -- "{bin}" {args}`
+}
+
+func quotedArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for j, a := range args {
+		quoted[j] = QuoteCommandLineArgument(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// runServiceCommand runs command, or adds it to DryRunCommands if DryRunRoot is set.
+func (i *Installer) runServiceCommand(command string, args ...any) (string, error) {
+	if i.DryRunRoot != "" {
+		line := zstr.Concat(" ", command, zstr.Concat(" ", args...))
+		i.DryRunCommands = append(i.DryRunCommands, line)
+		return "", nil
+	}
+	str, err := RunCommand(command, 5, args...)
+	zlog.OnError(err, command, args, str)
+	return str, err
+}
+
+func (i *Installer) systemctl(args ...any) (string, error) {
+	if i.Systemd.UserUnit {
+		args = append([]any{"--user"}, args...)
+	}
+	return i.runServiceCommand("systemctl", args...)
+}
+
+func (i *Installer) controlService(verb string) (string, error) {
+	id := i.ID()
+	switch i.GetInitSystem() {
+	case InitLaunchd:
+		return i.runServiceCommand("launchctl", verb, id)
+	case InitSystemd:
+		return i.systemctl(verb, id)
+	case InitOpenRC:
+		return i.runServiceCommand("rc-service", id, verb)
+	default:
+		return i.runServiceCommand("/etc/init.d/"+id, verb)
+	}
+}
+
+func (i *Installer) InstallProgramWithLauncher(args []string, copyBinary bool) error {
+	i.setUserName()
+	if copyBinary {
+		err := i.copyBinary(os.Args[0])
+		if err != nil {
+			return err
+		}
+	} else {
+		err := i.setBinaryPermissions()
+		if err != nil {
+			return err
+		}
+	}
+	launcherPath := i.LauncherPath()
+	launcherFile := i.rootedPath(launcherPath)
+	launchConfig := i.RenderLauncher(args)
+	zlog.Info("WriteServiceFile:", launcherFile)
+	zfile.MakeDirAllIfNotExists(filepath.Dir(launcherFile))
+	err := zfile.WriteStringToFile(launchConfig, launcherFile)
+	zlog.Info("install launcher", launcherFile, zfile.Exists(launcherFile))
 	if err != nil {
-		return zlog.Error("install launcher", launcherPath, zfile.Exists(launcherPath), err)
+		return zlog.Error("install launcher", launcherFile, zfile.Exists(launcherFile), err)
 	}
 	// if OnLinux() && userName != "root" && userName != "" {
 	// 	zfile.SetOwnerAndMainGroup(launcherPath, userName)
 	// }
-	_, err = RunCommand(serviceTool, 5, "load", launcherPath)
-	zlog.OnError(err, launcherPath)
 	id := i.ID()
-	str, err := RunCommand(serviceTool, 5, "stop", id)
-	zlog.OnError(err, str)
-	str, err = RunCommand(serviceTool, 5, "start", id)
-	zlog.OnError(err, str)
+	switch i.GetInitSystem() {
+	case InitLaunchd:
+		_, err = i.runServiceCommand("launchctl", "load", launcherPath)
+	case InitSystemd:
+		_, err = i.systemctl("daemon-reload")
+		if err == nil {
+			_, err = i.systemctl("enable", id)
+		}
+	case InitOpenRC:
+		os.Chmod(launcherFile, 0755)
+		_, err = i.runServiceCommand("rc-update", "add", id, "default")
+	case InitSysV:
+		os.Chmod(launcherFile, 0755)
+		_, err = i.runServiceCommand("update-rc.d", id, "defaults")
+	}
+	if err != nil {
+		return err
+	}
+	i.controlService("stop") // it might not be running
+	_, err = i.controlService("start")
+	return err
+}
+
+// Uninstall stops and disables the service, and removes its launcher file.
+// If removeFiles is true, the program's /opt directory with binary and var files is removed too.
+func (i *Installer) Uninstall(removeFiles bool) error {
+	var err error
+	launcherPath := i.LauncherPath()
+	id := i.ID()
+	i.controlService("stop") // it might not be running
+	switch i.GetInitSystem() {
+	case InitLaunchd:
+		_, err = i.runServiceCommand("launchctl", "unload", launcherPath)
+	case InitSystemd:
+		_, err = i.systemctl("disable", id)
+	case InitOpenRC:
+		_, err = i.runServiceCommand("rc-update", "del", id, "default")
+	case InitSysV:
+		_, err = i.runServiceCommand("update-rc.d", "-f", id, "remove")
+	}
+	if err != nil {
+		return err
+	}
+	launcherFile := i.rootedPath(launcherPath)
+	err = os.Remove(launcherFile)
+	if err != nil && !os.IsNotExist(err) {
+		return zlog.Error("remove launcher", launcherFile, err)
+	}
+	if i.GetInitSystem() == InitSystemd {
+		i.systemctl("daemon-reload")
+	}
+	if removeFiles {
+		dir := i.rootedPath(i.OptPath(false))
+		err = os.RemoveAll(dir)
+		if err != nil {
+			return zlog.Error("remove files", dir, err)
+		}
+	}
 	return nil
 }
+
+// Upgrade stops the service, replaces its binary with the one at binaryPath, and starts it again.
+func (i *Installer) Upgrade(binaryPath string) error {
+	if i.UserName == "" {
+		i.setUserName()
+	}
+	i.controlService("stop")
+	err := i.copyBinary(binaryPath)
+	if err != nil {
+		return err
+	}
+	_, err = i.controlService("start")
+	return err
+}
+
+// Status returns if the service is installed, and if the init system reports it as running.
+// In dry-run mode, Running is always false.
+func (i *Installer) Status() ServiceStatus {
+	var status ServiceStatus
+	var err error
+	status.Installed = zfile.Exists(i.rootedPath(i.LauncherPath()))
+	id := i.ID()
+	switch i.GetInitSystem() {
+	case InitLaunchd:
+		status.Output, err = i.runServiceCommand("launchctl", "list", id)
+		status.Running = (err == nil && launchdPIDRunning(status.Output))
+	case InitSystemd:
+		status.Output, err = i.systemctl("is-active", id)
+		status.Running = (err == nil && strings.TrimSpace(status.Output) == "active")
+	default:
+		status.Output, err = i.controlService("status")
+		status.Running = (err == nil && i.DryRunRoot == "")
+	}
+	return status
+}
+
+// launchdPIDRunning checks if the output of launchctl list <label> has a "PID" entry.
+func launchdPIDRunning(output string) bool {
+	var found bool
+	zstr.RangeStringLines(output, true, func(s string) bool {
+		if strings.HasPrefix(strings.TrimSpace(s), `"PID" =`) {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
@@ -1,5 +1,7 @@
 package zprocess
 
+import "errors"
+
 func GetOpenFileCount() int {
 	return 0
 }
@@ -7,3 +9,7 @@ func GetOpenFileCount() int {
 func MemoryBytesUsedByProcess(processID int64) int64 {
 	return 0
 }
+
+func GetProcessStats(processID int64) (ProcessStats, error) {
+	return ProcessStats{OpenFiles: -1}, errors.New("no process stats in browser")
+}
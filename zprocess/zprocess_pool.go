@@ -0,0 +1,225 @@
+package zprocess
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/ztime"
+)
+
+type PoolOptions struct {
+	Workers         int                   // number of items processed at once, default 1
+	QueueSize       int                   // how many items Add can queue before blocking
+	ItemTimeoutSecs float64               // if > 0, an attempt to process an item is abandoned after this long
+	Retries         int                   // number of times a failed item is retried
+	RetryDelaySecs  float64               // wait between retries
+	HandleProgress  func(pp PoolProgress) // called after each item is finished, serially
+}
+
+type PoolProgress struct {
+	Done   int  // items finished, including failed
+	Failed int  // items finished with an error
+	Total  int  // items added so far
+	Closed bool // true if Close has been called, so Total is final
+}
+
+type PoolResult[R any] struct {
+	Index    int // the order the item was added in
+	Value    R
+	Err      error
+	Attempts int
+}
+
+// Pool runs do() on items added to it, with PoolOptions.Workers goroutines at a time.
+// Items can be added incrementally with Add, which blocks while the queue is full.
+// Each item's result and error is stored in a PoolResult, returned in the order added by Wait.
+// If the context is canceled, items not yet started get its error as their result.
+// PoolWorkOnItemsWithContext is a convenience function for working on a slice.
+type Pool[T, R any] struct {
+	opts      PoolOptions
+	ctx       context.Context
+	do        func(ctx context.Context, item T) (R, error)
+	jobs      chan poolJob[T]
+	wg        sync.WaitGroup
+	lock      sync.Mutex
+	sending   sync.RWMutex // held for reading while adding, so Close doesn't close jobs during a send
+	finishing sync.Mutex   // serializes HandleProgress calls
+	results   []PoolResult[R]
+	progress  PoolProgress
+	closed    bool
+}
+
+type poolJob[T any] struct {
+	index int
+	item  T
+}
+
+// Fraction returns how much is done from 0 to 1, or -1 if the total isn't known yet.
+func (pp PoolProgress) Fraction() float64 {
+	if !pp.Closed {
+		return -1
+	}
+	if pp.Total == 0 {
+		return 1
+	}
+	return float64(pp.Done) / float64(pp.Total)
+}
+
+func NewPool[T, R any](ctx context.Context, opts PoolOptions, do func(ctx context.Context, item T) (R, error)) *Pool[T, R] {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	p := &Pool[T, R]{opts: opts, ctx: ctx, do: do}
+	p.jobs = make(chan poolJob[T], opts.QueueSize)
+	for i := 0; i < opts.Workers; i++ {
+		go func() {
+			for j := range p.jobs {
+				p.work(j)
+				p.wg.Done()
+			}
+		}()
+	}
+	return p
+}
+
+// Add queues item, blocking if the queue is full. It returns an error if the pool's context is done, or Close has been called.
+func (p *Pool[T, R]) Add(item T) error {
+	p.sending.RLock()
+	defer p.sending.RUnlock()
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return zlog.Error("pool closed")
+	}
+	j := poolJob[T]{index: p.progress.Total, item: item}
+	p.progress.Total++
+	p.wg.Add(1)
+	p.lock.Unlock()
+	select {
+	case p.jobs <- j:
+		return nil
+	case <-p.ctx.Done():
+		p.finish(PoolResult[R]{Index: j.index, Err: p.ctx.Err()})
+		p.wg.Done()
+		return p.ctx.Err()
+	}
+}
+
+// Close tells the pool no more items will be added. Wait calls it.
+func (p *Pool[T, R]) Close() {
+	p.sending.Lock()
+	defer p.sending.Unlock()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.closed {
+		p.closed = true
+		p.progress.Closed = true
+		close(p.jobs)
+	}
+}
+
+// Wait closes the pool, waits for all items to be finished, and returns their results in the order they were added.
+func (p *Pool[T, R]) Wait() []PoolResult[R] {
+	p.Close()
+	p.wg.Wait()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	sort.Slice(p.results, func(i, j int) bool {
+		return p.results[i].Index < p.results[j].Index
+	})
+	return p.results
+}
+
+func (p *Pool[T, R]) Progress() PoolProgress {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.progress
+}
+
+func (p *Pool[T, R]) work(j poolJob[T]) {
+	r := PoolResult[R]{Index: j.index}
+	for r.Attempts <= p.opts.Retries {
+		if p.ctx.Err() != nil {
+			if r.Err == nil {
+				r.Err = p.ctx.Err()
+			}
+			break
+		}
+		if r.Attempts > 0 && p.opts.RetryDelaySecs > 0 {
+			select {
+			case <-p.ctx.Done():
+				continue
+			case <-time.After(ztime.SecondsDur(p.opts.RetryDelaySecs)):
+			}
+		}
+		r.Attempts++
+		r.Value, r.Err = p.attempt(j.item)
+		if r.Err == nil {
+			break
+		}
+	}
+	p.finish(r)
+}
+
+// attempt runs do on item, using RunFuncUntilContextDone to give up after ItemTimeoutSecs.
+// The value and error are only read if do completed, as an abandoned do may still set them.
+func (p *Pool[T, R]) attempt(item T) (R, error) {
+	if p.opts.ItemTimeoutSecs <= 0 {
+		return p.do(p.ctx, item)
+	}
+	type reply struct {
+		value R
+		err   error
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, ztime.SecondsDur(p.opts.ItemTimeoutSecs))
+	defer cancel()
+	rp := &reply{}
+	completed := RunFuncUntilContextDone(ctx, func() {
+		var r reply
+		r.value, r.err = p.do(ctx, item)
+		*rp = r
+	})
+	if !completed {
+		var zero R
+		return zero, ctx.Err()
+	}
+	return rp.value, rp.err
+}
+
+func (p *Pool[T, R]) finish(r PoolResult[R]) {
+	p.finishing.Lock()
+	defer p.finishing.Unlock()
+	p.lock.Lock()
+	p.results = append(p.results, r)
+	p.progress.Done++
+	if r.Err != nil {
+		p.progress.Failed++
+	}
+	progress := p.progress
+	p.lock.Unlock()
+	if p.opts.HandleProgress != nil {
+		p.opts.HandleProgress(progress)
+	}
+}
+
+// PoolWorkOnItemsWithContext is like PoolWorkOnItems, but with a context, retries, timeouts and progress as in opts,
+// returning the result of do() for each item in all, in order.
+func PoolWorkOnItemsWithContext[T, R any](ctx context.Context, all []T, opts PoolOptions, do func(ctx context.Context, item T) (R, error)) []PoolResult[R] {
+	if opts.QueueSize == 0 {
+		opts.QueueSize = len(all)
+	}
+	p := NewPool(ctx, opts, do)
+	for _, item := range all {
+		if p.Add(item) != nil {
+			break
+		}
+	}
+	results := p.Wait()
+	for i := len(results); i < len(all); i++ {
+		results = append(results, PoolResult[R]{Index: i, Err: ctx.Err()})
+	}
+	return results
+}
@@ -0,0 +1,426 @@
+//go:build !js
+
+package zprocess
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/torlangballe/zutil/zfilelog"
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zmap"
+	"github.com/torlangballe/zutil/ztime"
+)
+
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"
+	RestartAlways    RestartPolicy = "always"
+	RestartOnFailure RestartPolicy = "on-failure"
+)
+
+type ChildState string
+
+const (
+	ChildStopped  ChildState = "stopped"
+	ChildRunning  ChildState = "running"
+	ChildBackoff  ChildState = "backoff"  // waiting to be restarted
+	ChildExited   ChildState = "exited"   // exited and not to be restarted due to policy
+	ChildFailed   ChildState = "failed"   // couldn't start, or MaxRestarts reached
+	ChildStopping ChildState = "stopping" // SIGTERM sent
+)
+
+type ChildSpec struct {
+	Name                  string
+	Command               string
+	Args                  []string
+	Env                   map[string]string // added to the supervisor's environment
+	Dir                   string
+	Restart               RestartPolicy
+	MaxRestarts           int     // if > 0, the child is set to failed after restarting this many times in a row
+	BackoffSecs           float64 // delay before first restart, doubled for each subsequent one
+	MaxBackoffSecs        float64
+	ResetBackoffAfterSecs float64 // if a child runs this long, its restart count and backoff are reset
+	StopTimeoutSecs       float64 // how long to wait after SIGTERM before SIGKILL
+	LogDir                string  // if set, output is written to <LogDir>/<Name>.stdout.log and .stderr.log
+	LogMaxBytes           int64   // log files are rotated when reaching this size
+	LogKeep               int     // number of rotated log files kept
+}
+
+type ChildStatus struct {
+	Name         string
+	State        ChildState
+	PID          int64
+	Started      time.Time
+	LastExit     time.Time
+	ExitCode     int
+	LastError    string
+	Restarts     int
+	MemoryBytes  int64 // resident memory of a running child
+	RunningSecs  float64
+	NextStartSec float64 // seconds until next start when in ChildBackoff
+}
+
+// Supervisor runs a set of named child processes, restarting them according to their RestartPolicy
+// with an exponential backoff. Output is optionally written to rotating log files in ChildSpec.LogDir,
+// and/or sent line-by-line to HandleOutput.
+// Stop sends SIGTERM, then SIGKILL if the child hasn't exited after StopTimeoutSecs.
+type Supervisor struct {
+	HandleOutput      func(name string, isErr bool, line string)
+	HandleStateChange func(status ChildStatus)
+
+	children zmap.LockMap[string, *child]
+}
+
+type child struct {
+	spec      ChildSpec
+	lock      sync.Mutex
+	status    ChildStatus
+	nextStart time.Time
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewSupervisor() *Supervisor {
+	return &Supervisor{}
+}
+
+func (s *Supervisor) setSpecDefaults(spec *ChildSpec) {
+	if spec.Restart == "" {
+		spec.Restart = RestartOnFailure
+	}
+	if spec.BackoffSecs == 0 {
+		spec.BackoffSecs = 1
+	}
+	if spec.MaxBackoffSecs == 0 {
+		spec.MaxBackoffSecs = 60
+	}
+	if spec.ResetBackoffAfterSecs == 0 {
+		spec.ResetBackoffAfterSecs = 60
+	}
+	if spec.StopTimeoutSecs == 0 {
+		spec.StopTimeoutSecs = 10
+	}
+	if spec.LogMaxBytes == 0 {
+		spec.LogMaxBytes = 10 * 1024 * 1024
+	}
+	if spec.LogKeep == 0 {
+		spec.LogKeep = 5
+	}
+}
+
+// Add adds a child with spec. It isn't started until Start or StartAll is called.
+func (s *Supervisor) Add(spec ChildSpec) error {
+	if spec.Name == "" || spec.Command == "" {
+		return zlog.Error("child needs name and command", spec.Name, spec.Command)
+	}
+	if s.children.Has(spec.Name) {
+		return zlog.Error("child already added", spec.Name)
+	}
+	s.setSpecDefaults(&spec)
+	c := &child{spec: spec}
+	c.status.Name = spec.Name
+	c.status.State = ChildStopped
+	s.children.Set(spec.Name, c)
+	return nil
+}
+
+// Remove stops the child if running, and removes it.
+func (s *Supervisor) Remove(name string) error {
+	err := s.Stop(name)
+	if err != nil {
+		return err
+	}
+	s.children.Remove(name)
+	return nil
+}
+
+func (s *Supervisor) getChild(name string) (*child, error) {
+	c, got := s.children.Get(name)
+	if !got {
+		return nil, zlog.Error("no child named", name)
+	}
+	return c, nil
+}
+
+func (s *Supervisor) Start(name string) error {
+	c, err := s.getChild(name)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.done != nil {
+		return nil // already being supervised
+	}
+	c.status.Restarts = 0
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go s.supervise(c, c.stop, c.done)
+	return nil
+}
+
+// Stop stops a child gracefully, waiting until it has exited.
+func (s *Supervisor) Stop(name string) error {
+	c, err := s.getChild(name)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	stop := c.stop
+	done := c.done
+	c.stop = nil
+	c.lock.Unlock()
+	if stop == nil || done == nil {
+		return nil
+	}
+	close(stop)
+	<-done
+	return nil
+}
+
+func (s *Supervisor) Restart(name string) error {
+	err := s.Stop(name)
+	if err != nil {
+		return err
+	}
+	return s.Start(name)
+}
+
+func (s *Supervisor) StartAll() {
+	for _, name := range s.Names() {
+		zlog.OnError(s.Start(name), name)
+	}
+}
+
+// StopAll stops all children concurrently, returning when all have exited.
+func (s *Supervisor) StopAll() {
+	var wg sync.WaitGroup
+	for _, name := range s.Names() {
+		wg.Add(1)
+		go func(name string) {
+			zlog.OnError(s.Stop(name), name)
+			wg.Done()
+		}(name)
+	}
+	wg.Wait()
+}
+
+func (s *Supervisor) Names() []string {
+	var names []string
+	s.children.ForAll(func(name string, c *child) {
+		names = append(names, name)
+	})
+	sort.Strings(names)
+	return names
+}
+
+func (s *Supervisor) Status(name string) (ChildStatus, error) {
+	c, err := s.getChild(name)
+	if err != nil {
+		return ChildStatus{}, err
+	}
+	c.lock.Lock()
+	status := c.status
+	next := c.nextStart
+	c.lock.Unlock()
+	switch status.State {
+	case ChildRunning, ChildStopping:
+		status.RunningSecs = ztime.Since(status.Started)
+		status.MemoryBytes = MemoryBytesUsedByProcess(status.PID)
+	case ChildBackoff:
+		status.NextStartSec = math.Max(0, -ztime.Since(next))
+	}
+	return status, nil
+}
+
+// Statuses returns the status of all children, sorted by name.
+func (s *Supervisor) Statuses() []ChildStatus {
+	var all []ChildStatus
+	for _, name := range s.Names() {
+		status, err := s.Status(name)
+		if err == nil {
+			all = append(all, status)
+		}
+	}
+	return all
+}
+
+func (s *Supervisor) setState(c *child, state ChildState, change func(status *ChildStatus)) {
+	c.lock.Lock()
+	c.status.State = state
+	if change != nil {
+		change(&c.status)
+	}
+	status := c.status
+	c.lock.Unlock()
+	if s.HandleStateChange != nil {
+		s.HandleStateChange(status)
+	}
+}
+
+func (s *Supervisor) supervise(c *child, stop, done chan struct{}) {
+	defer func() {
+		c.lock.Lock()
+		c.done = nil
+		c.lock.Unlock()
+		close(done)
+	}()
+	restarts := 0
+	for {
+		start := time.Now()
+		started, exitCode, err := s.runOnce(c, stop)
+		select {
+		case <-stop:
+			s.setState(c, ChildStopped, nil)
+			return
+		default:
+		}
+		if !started { // a start failure is a failed run, it can be transient, like the binary being replaced during an upgrade
+			c.lock.Lock()
+			c.status.LastError = err.Error()
+			c.lock.Unlock()
+		}
+		failed := (!started || exitCode != 0 || err != nil)
+		if c.spec.Restart == RestartNever || (c.spec.Restart == RestartOnFailure && !failed) {
+			state := ChildExited
+			if !started {
+				state = ChildFailed
+			}
+			s.setState(c, state, nil)
+			return
+		}
+		if ztime.Since(start) >= c.spec.ResetBackoffAfterSecs {
+			restarts = 0
+		}
+		if c.spec.MaxRestarts > 0 && restarts >= c.spec.MaxRestarts {
+			s.setState(c, ChildFailed, func(status *ChildStatus) {
+				status.LastError = "max restarts reached"
+			})
+			return
+		}
+		delay := math.Min(c.spec.BackoffSecs*math.Pow(2, float64(restarts)), c.spec.MaxBackoffSecs)
+		restarts++
+		s.setState(c, ChildBackoff, func(status *ChildStatus) {
+			status.Restarts++
+		})
+		c.lock.Lock()
+		c.nextStart = time.Now().Add(ztime.SecondsDur(delay))
+		c.lock.Unlock()
+		select {
+		case <-stop:
+			s.setState(c, ChildStopped, nil)
+			return
+		case <-time.After(ztime.SecondsDur(delay)):
+		}
+	}
+}
+
+func (s *Supervisor) makeOutput(c *child, isErr bool, closers *[]io.Closer) io.Writer {
+	var writers []io.Writer
+	if s.HandleOutput != nil {
+		writers = append(writers, &lineWriter{got: func(line string) {
+			s.HandleOutput(c.spec.Name, isErr, line)
+		}})
+	}
+	if c.spec.LogDir != "" {
+		suffix := ".stdout.log"
+		if isErr {
+			suffix = ".stderr.log"
+		}
+		fpath := filepath.Join(c.spec.LogDir, c.spec.Name+suffix)
+		rf, err := zfilelog.NewRotatingFile(fpath, c.spec.LogMaxBytes, c.spec.LogKeep)
+		if !zlog.OnError(err, fpath) {
+			writers = append(writers, rf)
+			*closers = append(*closers, rf)
+		}
+	}
+	if len(writers) == 0 {
+		return nil
+	}
+	return io.MultiWriter(writers...)
+}
+
+// runOnce starts the child's command and waits for it to exit, or for stop to be closed,
+// in which case it is terminated.
+func (s *Supervisor) runOnce(c *child, stop chan struct{}) (started bool, exitCode int, err error) {
+	var closers []io.Closer
+	defer func() {
+		for _, cl := range closers {
+			cl.Close()
+		}
+	}()
+	cmd := exec.Command(c.spec.Command, c.spec.Args...)
+	cmd.Dir = c.spec.Dir
+	if len(c.spec.Env) != 0 {
+		cmd.Env = os.Environ()
+		for k, v := range c.spec.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	cmd.Stdout = s.makeOutput(c, false, &closers)
+	cmd.Stderr = s.makeOutput(c, true, &closers)
+	err = cmd.Start()
+	if err != nil {
+		return false, -1, zlog.Error("start", c.spec.Name, err)
+	}
+	s.setState(c, ChildRunning, func(status *ChildStatus) {
+		status.PID = int64(cmd.Process.Pid)
+		status.Started = time.Now()
+	})
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	select {
+	case err = <-exited:
+	case <-stop:
+		s.setState(c, ChildStopping, nil)
+		cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case err = <-exited:
+		case <-time.After(ztime.SecondsDur(c.spec.StopTimeoutSecs)):
+			zlog.Info("zprocess.Supervisor: killing child after stop timeout:", c.spec.Name)
+			cmd.Process.Kill()
+			err = <-exited
+		}
+	}
+	exitCode = cmd.ProcessState.ExitCode()
+	c.lock.Lock()
+	c.status.ExitCode = exitCode
+	c.status.LastExit = time.Now()
+	c.status.LastError = ""
+	if err != nil {
+		c.status.LastError = err.Error()
+	}
+	c.lock.Unlock()
+	return true, exitCode, err
+}
+
+// lineWriter calls got for each complete line written to it.
+type lineWriter struct {
+	buf []byte
+	got func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i == -1 {
+			break
+		}
+		w.got(string(bytes.TrimSuffix(w.buf[:i], []byte{'\r'})))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
@@ -1,10 +1,18 @@
 package zprocess
 
 import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/torlangballe/zutil/zfile"
 	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zstr"
+	"github.com/torlangballe/zutil/ztesting"
 	"github.com/torlangballe/zutil/ztime"
 )
 
@@ -52,7 +60,156 @@ func testPooling(t *testing.T) {
 // 	}
 // }
 
+func testSupervisor(t *testing.T) {
+	zlog.Warn("testSupervisor")
+
+	var lock sync.Mutex
+	var lines []string
+	s := NewSupervisor()
+	s.HandleOutput = func(name string, isErr bool, line string) {
+		lock.Lock()
+		lines = append(lines, name+":"+line)
+		lock.Unlock()
+	}
+	s.Add(ChildSpec{Name: "failer", Command: "/bin/sh", Args: []string{"-c", "echo hello; exit 3"}, MaxRestarts: 2, BackoffSecs: 0.01})
+	s.Add(ChildSpec{Name: "missing", Command: "/nonexistent/program", MaxRestarts: 2, BackoffSecs: 0.01})
+	s.Add(ChildSpec{Name: "sleeper", Command: "/bin/sh", Args: []string{"-c", "exec sleep 30"}, Restart: RestartAlways, StopTimeoutSecs: 1})
+	s.StartAll()
+	time.Sleep(time.Millisecond * 300)
+
+	status, _ := s.Status("failer")
+	ztesting.Equal(t, status.State, ChildFailed, "failer state")
+	ztesting.Equal(t, status.ExitCode, 3, "failer exit code")
+	ztesting.Equal(t, status.Restarts, 2, "failer restarts")
+	status, _ = s.Status("missing")
+	ztesting.Equal(t, status.State, ChildFailed, "missing state")
+	ztesting.Equal(t, status.Restarts, 2, "missing restarts")
+	lock.Lock()
+	ztesting.Equal(t, len(lines), 3, "output lines")
+	lock.Unlock()
+
+	status, _ = s.Status("sleeper")
+	ztesting.Equal(t, status.State, ChildRunning, "sleeper running")
+	start := time.Now()
+	s.StopAll()
+	ztesting.GreaterThan(t, 0.5, ztime.Since(start), "stop time")
+	status, _ = s.Status("sleeper")
+	ztesting.Equal(t, status.State, ChildStopped, "sleeper stopped")
+}
+
+func testInstallerDryRun(t *testing.T) {
+	zlog.Warn("testInstallerDryRun")
+
+	i := Installer{Company: "acme", ProductName: "widget", Domain: "com", DryRunRoot: t.TempDir(), InitSystem: InitSystemd}
+	i.Systemd.EnvironmentFiles = []string{"/etc/widget.env"}
+	i.Systemd.RestartSecs = 5
+	i.Systemd.LimitNOFILE = 4096
+	err := i.InstallProgramWithLauncher([]string{"-port", "80"}, true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	str, _ := zfile.ReadStringFromFile(i.rootedPath(i.LauncherPath()))
+	ztesting.Equal(t, strings.Contains(str, i.DryRunRoot), false, "rendered as for a real install", str)
+	for _, want := range []string{"ExecStart=" + i.BinPath(true, "widget") + " -port 80", "EnvironmentFile=/etc/widget.env", "RestartSec=5\n", "LimitNOFILE=4096", "User="} {
+		ztesting.Equal(t, strings.Contains(str, want), true, "unit contains", want)
+	}
+	ztesting.Equal(t, zstr.StringsContain(i.DryRunCommands, "systemctl enable com.acme.widget"), true, "enable command", i.DryRunCommands)
+	ztesting.Equal(t, i.Status().Installed, true, "installed")
+
+	i.Systemd.UserUnit = true
+	ztesting.Equal(t, strings.Contains(i.RenderLauncher(nil), "User="), false, "user unit has no User=")
+	i.Systemd.UserUnit = false
+
+	err = i.Uninstall(true)
+	ztesting.Equal(t, err, nil, "uninstall")
+	ztesting.Equal(t, zfile.Exists(i.rootedPath(i.LauncherPath())), false, "launcher removed")
+	ztesting.Equal(t, zfile.Exists(i.rootedPath(i.BinPath(true, "widget"))), false, "binary removed")
+
+	for _, initSys := range []InitSystem{InitOpenRC, InitSysV} {
+		i.InitSystem = initSys
+		err = i.InstallProgramWithLauncher([]string{"-name", "a b"}, true)
+		ztesting.Equal(t, err, nil, "install", initSys)
+		info, err := os.Stat(i.rootedPath(i.LauncherPath()))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		ztesting.Equal(t, info.Mode().Perm(), os.FileMode(0755), "script mode", initSys)
+		str, _ = zfile.ReadStringFromFile(i.rootedPath(i.LauncherPath()))
+		ztesting.Equal(t, strings.Contains(str, "'a b'"), true, "quoted arg", initSys)
+	}
+	i.InitSystem = InitSysV
+	user := i.UserName
+	i.UserName = ""
+	str = i.RenderLauncher(nil)
+	ztesting.Equal(t, strings.Contains(str, "su "), false, "sysv without user has no su")
+	ztesting.Equal(t, strings.Contains(str, `/bin/sh -c 'echo $$ > "/var/run/com.acme.widget.pid"; exec`), true, "sysv child writes pid", str)
+	i.UserName = user
+	str = i.RenderLauncher(nil)
+	ztesting.Equal(t, strings.Contains(str, `' `+user+` -- "`), true, "sysv su to user", str)
+}
+
+func testPoolWithContext(t *testing.T) {
+	zlog.Warn("testPoolWithContext")
+
+	var lock sync.Mutex
+	var last PoolProgress
+	failedOnce := map[int]bool{}
+	opts := PoolOptions{Workers: 3, ItemTimeoutSecs: 0.2, Retries: 1}
+	opts.HandleProgress = func(pp PoolProgress) {
+		last = pp
+	}
+	all := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	results := PoolWorkOnItemsWithContext(context.Background(), all, opts, func(ctx context.Context, n int) (int, error) {
+		switch n {
+		case 5:
+			lock.Lock()
+			defer lock.Unlock()
+			if !failedOnce[n] {
+				failedOnce[n] = true
+				return 0, errors.New("fail once")
+			}
+		case 7:
+			time.Sleep(time.Millisecond * 300)
+		}
+		return n * 2, nil
+	})
+	ztesting.Equal(t, len(results), 10, "result count")
+	for i, r := range results {
+		ztesting.Equal(t, r.Index, i, "result order")
+		if i == 6 {
+			ztesting.Equal(t, r.Err, context.DeadlineExceeded, "timeout err")
+			ztesting.Equal(t, r.Attempts, 2, "timeout attempts")
+			continue
+		}
+		ztesting.Equal(t, r.Err, nil, "err", i)
+		ztesting.Equal(t, r.Value, all[i]*2, "value", i)
+	}
+	ztesting.Equal(t, results[4].Attempts, 2, "retried")
+	ztesting.Equal(t, last.Done, 10, "progress done")
+	ztesting.Equal(t, last.Failed, 1, "progress failed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPool(ctx, PoolOptions{Workers: 1, QueueSize: 1}, func(ctx context.Context, n int) (int, error) {
+		if n == 2 {
+			cancel()
+		}
+		return n, nil
+	})
+	for i := 1; i <= 5; i++ {
+		if p.Add(i) != nil {
+			break
+		}
+	}
+	results = p.Wait()
+	ztesting.Equal(t, results[len(results)-1].Err, context.Canceled, "canceled")
+}
+
 func TestAll(t *testing.T) {
 	testPooling(t)
+	testSupervisor(t)
+	testInstallerDryRun(t)
+	testPoolWithContext(t)
 	// testLocking(t)
 }
@@ -0,0 +1,115 @@
+package zredis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// Mutex is a distributed lock held for a lease time, so it is released if its holder dies.
+// Each time it is acquired, it gets a fencing token that is larger than all previous ones for the same name.
+// Pass the token with writes to shared storage, and have the storage reject tokens older than the newest it has seen,
+// so a holder whose lease has expired without it knowing can't overwrite the new holder's work.
+type Mutex struct {
+	pool  *redis.Pool
+	key   string
+	lease time.Duration
+	value string // random value identifying this holder
+	token int64
+
+	RetrySecs float64 // how often Lock tries to acquire the lock
+}
+
+// the lock key is set to the holder's value, and the fencing counter incremented, only if no one holds it.
+var lockScript = redis.NewScript(2, `
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+var extendScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var unlockScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// NewMutex returns a mutex for name, that is held for lease after being locked unless extended or unlocked.
+func NewMutex(redisPool *redis.Pool, name string, lease time.Duration) *Mutex {
+	m := &Mutex{pool: redisPool, key: fullKey("lock/" + name), lease: lease}
+	m.RetrySecs = 0.05
+	return m
+}
+
+// TryLock tries to acquire the lock once. If got, token is its fencing token.
+func (m *Mutex) TryLock() (token int64, got bool, err error) {
+	random := make([]byte, 16)
+	rand.Read(random)
+	value := hex.EncodeToString(random)
+	conn := m.pool.Get()
+	defer conn.Close()
+	token, err = redis.Int64(lockScript.Do(conn, m.key, m.key+"/fence", value, m.lease.Milliseconds()))
+	if err != nil {
+		return 0, false, zlog.Error("lock", m.key, err)
+	}
+	if token == 0 {
+		return 0, false, nil
+	}
+	m.value = value
+	m.token = token
+	return token, true, nil
+}
+
+// Lock waits up to timeout to acquire the lock, returning its fencing token.
+func (m *Mutex) Lock(timeout time.Duration) (token int64, err error) {
+	end := time.Now().Add(timeout)
+	for {
+		token, got, err := m.TryLock()
+		if err != nil {
+			return 0, err
+		}
+		if got {
+			return token, nil
+		}
+		if time.Now().After(end) {
+			return 0, zlog.NewError("timed out waiting for lock:", m.key)
+		}
+		time.Sleep(time.Duration(m.RetrySecs * float64(time.Second)))
+	}
+}
+
+// Token returns the fencing token of the last time the lock was acquired.
+func (m *Mutex) Token() int64 {
+	return m.token
+}
+
+// Extend sets the lock to expire lease from now. It returns false if the lock has been lost.
+func (m *Mutex) Extend(lease time.Duration) (bool, error) {
+	return m.doIfHeld(extendScript, m.key, m.value, lease.Milliseconds())
+}
+
+// Unlock releases the lock. It returns false if it had already expired.
+func (m *Mutex) Unlock() (bool, error) {
+	return m.doIfHeld(unlockScript, m.key, m.value)
+}
+
+func (m *Mutex) doIfHeld(script *redis.Script, keysAndArgs ...any) (bool, error) {
+	conn := m.pool.Get()
+	defer conn.Close()
+	n, err := redis.Int(script.Do(conn, keysAndArgs...))
+	if err != nil {
+		return false, zlog.Error("lock", m.key, err)
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,189 @@
+package zredis
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// Subscription receives messages published to redis channels, reconnecting if the connection is lost.
+// Channels with *, ? or [ in them are subscribed to as patterns.
+type Subscription struct {
+	pool     *redis.Pool
+	channels []string
+	got      func(channel string, data []byte)
+	lock     sync.Mutex
+	conn     redis.PubSubConn
+	stopped  bool
+	done     chan struct{}
+
+	PingSecs       float64 // how often to ping the server when idle, to find dead connections
+	MaxBackoffSecs float64 // maximum time between reconnect attempts
+}
+
+func fullKey(key string) string {
+	if rootPath != "" {
+		return rootPath + "/" + key
+	}
+	return key
+}
+
+// Publish publishes v as json to channel.
+func Publish(redisPool *redis.Pool, channel string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	conn := redisPool.Get()
+	defer conn.Close()
+	_, err = conn.Do("PUBLISH", fullKey(channel), data)
+	return err
+}
+
+// Subscribe calls got with the data of each message published to channels, until the Subscription is closed.
+// got is called on the subscription's goroutine, the channel it is given is without the Init path.
+func Subscribe(redisPool *redis.Pool, got func(channel string, data []byte), channels ...string) *Subscription {
+	s := &Subscription{pool: redisPool, channels: channels, got: got, done: make(chan struct{})}
+	s.PingSecs = 30
+	s.MaxBackoffSecs = 30
+	go s.run()
+	return s
+}
+
+// SubscribeJSON is like Subscribe, but unmarshals each message into a new T.
+func SubscribeJSON[T any](redisPool *redis.Pool, got func(channel string, v T), channels ...string) *Subscription {
+	return Subscribe(redisPool, func(channel string, data []byte) {
+		var v T
+		err := json.Unmarshal(data, &v)
+		if zlog.OnError(err, "unmarshal", channel) {
+			return
+		}
+		got(channel, v)
+	}, channels...)
+}
+
+func isPattern(channel string) bool {
+	return strings.ContainsAny(channel, "*?[")
+}
+
+func (s *Subscription) run() {
+	defer close(s.done)
+	backoff := 0.1
+	for {
+		subscribed, err := s.receive()
+		s.lock.Lock()
+		stopped := s.stopped
+		s.lock.Unlock()
+		if stopped {
+			return
+		}
+		if subscribed {
+			backoff = 0.1
+		}
+		zlog.Error("redis subscription lost, reconnecting:", err)
+		time.Sleep(time.Duration(backoff * float64(time.Second)))
+		backoff = min(backoff*2, s.MaxBackoffSecs)
+	}
+}
+
+// write calls f with the lock held, so pings and unsubscribing aren't sent at the same time.
+func (s *Subscription) write(f func(conn redis.PubSubConn) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conn.Conn == nil {
+		return nil
+	}
+	return f(s.conn)
+}
+
+// receive subscribes on a new connection and receives messages until an error occurs.
+// It pings the server every PingSecs, and gives up if nothing is received for twice that.
+func (s *Subscription) receive() (subscribed bool, err error) {
+	s.lock.Lock()
+	if s.stopped {
+		s.lock.Unlock()
+		return false, nil
+	}
+	conn := redis.PubSubConn{Conn: s.pool.Get()}
+	s.conn = conn
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		s.conn = redis.PubSubConn{}
+		s.lock.Unlock()
+		conn.Close()
+	}()
+
+	var channels, patterns []any
+	for _, c := range s.channels {
+		if isPattern(c) {
+			patterns = append(patterns, fullKey(c))
+		} else {
+			channels = append(channels, fullKey(c))
+		}
+	}
+	if len(channels) != 0 {
+		err = s.write(func(conn redis.PubSubConn) error { return conn.Subscribe(channels...) })
+		if err != nil {
+			return false, err
+		}
+	}
+	if len(patterns) != 0 {
+		err = s.write(func(conn redis.PubSubConn) error { return conn.PSubscribe(patterns...) })
+		if err != nil {
+			return false, err
+		}
+	}
+	ping := time.Duration(s.PingSecs * float64(time.Second))
+	ticker := time.NewTicker(ping)
+	defer ticker.Stop()
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go func() {
+		for {
+			select {
+			case <-stopPing:
+				return
+			case <-ticker.C:
+				if s.write(func(conn redis.PubSubConn) error { return conn.Ping("") }) != nil {
+					return
+				}
+			}
+		}
+	}()
+	for {
+		switch m := conn.ReceiveWithTimeout(ping * 2).(type) {
+		case redis.Message:
+			s.got(strings.TrimPrefix(m.Channel, fullKey("")), m.Data)
+		case redis.Subscription:
+			if m.Kind == "subscribe" || m.Kind == "psubscribe" {
+				subscribed = true
+			}
+			if m.Count == 0 {
+				return subscribed, nil
+			}
+		case error:
+			return subscribed, m
+		}
+	}
+}
+
+// Close unsubscribes and stops the subscription, waiting for its goroutine to exit.
+func (s *Subscription) Close() {
+	s.lock.Lock()
+	if s.stopped {
+		s.lock.Unlock()
+		return
+	}
+	s.stopped = true
+	if s.conn.Conn != nil {
+		s.conn.Unsubscribe()
+		s.conn.PUnsubscribe()
+	}
+	s.lock.Unlock()
+	<-s.done
+}
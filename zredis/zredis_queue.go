@@ -0,0 +1,190 @@
+package zredis
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// Queue is a job queue on a redis stream, where each job is given to one of the consumers in a group.
+// Jobs must be acknowledged when done; jobs a consumer has had for too long without doing so can be claimed by others.
+type Queue struct {
+	pool   *redis.Pool
+	stream string
+	group  string
+
+	MaxLength int // if non-zero, the stream is trimmed to about this many entries when adding
+}
+
+// Job is a job read from a Queue. Data is the json added with Add.
+type Job struct {
+	ID   string
+	Data []byte
+}
+
+// NewQueue returns a queue on the stream name, creating it and the consumer group if needed.
+func NewQueue(redisPool *redis.Pool, name, group string) (*Queue, error) {
+	q := &Queue{pool: redisPool, stream: fullKey("queue/" + name), group: group}
+	conn := redisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("XGROUP", "CREATE", q.stream, group, "0", "MKSTREAM")
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, zlog.Error("create group", q.stream, group, err)
+	}
+	return q, nil
+}
+
+// Add adds v as json to the queue, returning the job's id.
+func (q *Queue) Add(v any) (id string, err error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	args := []any{q.stream}
+	if q.MaxLength != 0 {
+		args = append(args, "MAXLEN", "~", q.MaxLength)
+	}
+	args = append(args, "*", "data", data)
+	conn := q.pool.Get()
+	defer conn.Close()
+	return redis.String(conn.Do("XADD", args...))
+}
+
+// Read returns up to count new jobs for consumer, waiting up to block for any to arrive.
+// It returns no jobs and no error if none arrived.
+func (q *Queue) Read(consumer string, count int, block time.Duration) ([]Job, error) {
+	conn := q.pool.Get()
+	defer conn.Close()
+	reply, err := conn.Do("XREADGROUP", "GROUP", q.group, consumer, "COUNT", count, "BLOCK", block.Milliseconds(), "STREAMS", q.stream, ">")
+	if err == redis.ErrNil || reply == nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	streams, err := redis.Values(reply, nil)
+	if err != nil || len(streams) == 0 {
+		return nil, err
+	}
+	stream, err := redis.Values(streams[0], nil)
+	if err != nil || len(stream) != 2 {
+		return nil, err
+	}
+	return parseJobs(stream[1])
+}
+
+// Claim gives consumer up to count jobs other consumers have had for more than minIdle without acknowledging them,
+// for instance because they crashed.
+func (q *Queue) Claim(consumer string, minIdle time.Duration, count int) ([]Job, error) {
+	conn := q.pool.Get()
+	defer conn.Close()
+	reply, err := redis.Values(conn.Do("XAUTOCLAIM", q.stream, q.group, consumer, minIdle.Milliseconds(), "0-0", "COUNT", count))
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) < 2 {
+		return nil, nil
+	}
+	return parseJobs(reply[1])
+}
+
+// parseJobs parses stream entries, each an id and a list of field/value pairs.
+func parseJobs(reply any) ([]Job, error) {
+	entries, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	for _, e := range entries {
+		if e == nil {
+			continue
+		}
+		entry, err := redis.Values(e, nil)
+		if err != nil {
+			return jobs, err
+		}
+		if len(entry) != 2 || entry[1] == nil { // entries deleted while pending have no fields
+			continue
+		}
+		var job Job
+		job.ID, err = redis.String(entry[0], nil)
+		if err != nil {
+			return jobs, err
+		}
+		fields, err := redis.ByteSlices(entry[1], nil)
+		if err != nil {
+			return jobs, err
+		}
+		for i := 0; i+1 < len(fields); i += 2 {
+			if string(fields[i]) == "data" {
+				job.Data = fields[i+1]
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Ack marks jobs as done, removing them from the queue.
+func (q *Queue) Ack(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var idArgs []any
+	for _, id := range ids {
+		idArgs = append(idArgs, id)
+	}
+	conn := q.pool.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("XACK", append([]any{q.stream, q.group}, idArgs...)...)
+	conn.Send("XDEL", append([]any{q.stream}, idArgs...)...)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+// Pending returns how many jobs have been read but not acknowledged.
+func (q *Queue) Pending() (int, error) {
+	conn := q.pool.Get()
+	defer conn.Close()
+	reply, err := redis.Values(conn.Do("XPENDING", q.stream, q.group))
+	if err != nil || len(reply) == 0 {
+		return 0, err
+	}
+	return redis.Int(reply[0], nil)
+}
+
+// Process reads jobs for consumer and calls handle with them one at a time until stop is closed.
+// Jobs handle returns nil for are acknowledged. Others are retried by claiming them again after retryAfter,
+// either by this or another consumer.
+func (q *Queue) Process(consumer string, retryAfter time.Duration, stop <-chan struct{}, handle func(job Job) error) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		jobs, err := q.Claim(consumer, retryAfter, 10)
+		if zlog.OnError(err, "claim", q.stream) {
+			time.Sleep(time.Second)
+			continue
+		}
+		if len(jobs) == 0 {
+			jobs, err = q.Read(consumer, 10, time.Second)
+			if zlog.OnError(err, "read", q.stream) {
+				time.Sleep(time.Second)
+				continue
+			}
+		}
+		for _, job := range jobs {
+			err := handle(job)
+			if zlog.OnError(err, "job", q.stream, job.ID) {
+				continue
+			}
+			zlog.OnError(q.Ack(job.ID), "ack", q.stream, job.ID)
+		}
+	}
+}
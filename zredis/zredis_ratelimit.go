@@ -0,0 +1,74 @@
+package zredis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// RateLimiters are sliding-window rate limiters shared by all servers using the same redis and name.
+// Its Do method works like ztimer.RateLimiters.Do, so it can be used in its place when running several instances.
+type RateLimiters struct {
+	pool        *redis.Pool
+	name        string
+	defaultSecs float64
+}
+
+// the times of allowed events are stored as scores in a sorted set, using redis's clock so all servers agree.
+var rateScript = redis.NewScript(1, `
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local window = tonumber(ARGV[1])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+if redis.call("ZCARD", KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call("ZADD", KEYS[1], now, now .. "-" .. ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], window)
+	return 1
+end
+return 0
+`)
+
+// NewRateLimiters returns rate limiters stored under name, using secs as the window when Do is given 0.
+func NewRateLimiters(redisPool *redis.Pool, name string, secs float64) *RateLimiters {
+	return &RateLimiters{pool: redisPool, name: name, defaultSecs: secs}
+}
+
+// Allow returns true if fewer than max events have been allowed for id in the last window, counting this one if so.
+func (r *RateLimiters) Allow(id string, window time.Duration, max int) (bool, error) {
+	random := make([]byte, 8)
+	rand.Read(random)
+	conn := r.pool.Get()
+	defer conn.Close()
+	key := fullKey("ratelimit/" + r.name + "/" + id)
+	n, err := redis.Int(rateScript.Do(conn, key, window.Milliseconds(), max, hex.EncodeToString(random)))
+	if err != nil {
+		return false, zlog.Error("rate limit", key, err)
+	}
+	return n == 1, nil
+}
+
+// Do calls do if it hasn't been called for id in the last secs seconds by any server.
+// If secs is 0, the default of NewRateLimiters is used. do isn't called if redis can't be reached.
+func (r *RateLimiters) Do(id string, secs float64, do func()) {
+	if secs <= 0 {
+		secs = r.defaultSecs
+		if secs == 0 {
+			panic("no default secs")
+		}
+	}
+	allow, err := r.Allow(id, time.Duration(secs*float64(time.Second)), 1)
+	if err == nil && allow {
+		do()
+	}
+}
+
+// Remove forgets id's events, so it is allowed again.
+func (r *RateLimiters) Remove(id string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("DEL", fullKey("ratelimit/"+r.name+"/"+id))
+	return err
+}
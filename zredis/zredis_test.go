@@ -0,0 +1,156 @@
+package zredis
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+// testPool connects to the redis at $REDIS_ADDRESS, or localhost:6379, such as a redis-server or miniredis.
+// The test is skipped if there is none.
+func testPool(t *testing.T) *redis.Pool {
+	pool, err := Setup(os.Getenv("REDIS_ADDRESS"))
+	if err != nil {
+		t.Skip("no redis:", err)
+	}
+	Init(pool, "zredis-test-"+time.Now().Format("150405.000000"))
+	t.Cleanup(func() {
+		conn := pool.Get()
+		keys, _ := redis.Values(conn.Do("KEYS", fullKey("*")))
+		if len(keys) != 0 {
+			conn.Do("DEL", keys...)
+		}
+		conn.Close()
+		pool.Close()
+		rootPath = ""
+	})
+	return pool
+}
+
+func TestPubSub(t *testing.T) {
+	pool := testPool(t)
+	got := make(chan string, 10)
+	sub := SubscribeJSON(pool, func(channel string, v string) {
+		got <- channel + ":" + v
+	}, "news", "sport/*")
+	defer sub.Close()
+	time.Sleep(100 * time.Millisecond) // let it subscribe
+	Publish(pool, "news", "hello")
+	Publish(pool, "sport/ski", "gold")
+	Publish(pool, "weather", "rain")
+	ztesting.Equal(t, <-got, "news:hello", "channel")
+	ztesting.Equal(t, <-got, "sport/ski:gold", "pattern")
+	select {
+	case str := <-got:
+		t.Error("got unsubscribed message", str)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMutex(t *testing.T) {
+	pool := testPool(t)
+	a := NewMutex(pool, "job", time.Second)
+	b := NewMutex(pool, "job", time.Second)
+	token, got, err := a.TryLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, got, true, "a locks")
+	_, got, _ = b.TryLock()
+	ztesting.Equal(t, got, false, "b can't lock")
+	unlocked, _ := b.Unlock()
+	ztesting.Equal(t, unlocked, false, "b can't unlock a's lock")
+	extended, _ := a.Extend(time.Second)
+	ztesting.Equal(t, extended, true, "a extends")
+	unlocked, _ = a.Unlock()
+	ztesting.Equal(t, unlocked, true, "a unlocks")
+	token2, err := b.Lock(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, token2 > token, true, "fencing token increases")
+
+	short := NewMutex(pool, "short", 50*time.Millisecond)
+	short.TryLock()
+	other := NewMutex(pool, "short", time.Second)
+	_, err = other.Lock(time.Second)
+	ztesting.Equal(t, err, nil, "lease expires")
+	extended, _ = short.Extend(time.Second)
+	ztesting.Equal(t, extended, false, "expired lock can't be extended")
+}
+
+func TestRateLimiters(t *testing.T) {
+	pool := testPool(t)
+	r := NewRateLimiters(pool, "test", 0.2)
+	for i := 0; i < 5; i++ {
+		allow, err := r.Allow("burst", time.Second, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ztesting.Equal(t, allow, i < 3, "burst", i)
+	}
+	var count int
+	for i := 0; i < 3; i++ {
+		r.Do("do", 0, func() { count++ })
+	}
+	ztesting.Equal(t, count, 1, "once per window")
+	time.Sleep(250 * time.Millisecond)
+	r.Do("do", 0, func() { count++ })
+	ztesting.Equal(t, count, 2, "again after window")
+}
+
+func TestQueue(t *testing.T) {
+	pool := testPool(t)
+	q, err := NewQueue(pool, "jobs", "workers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, 2, 3} {
+		_, err := q.Add(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	jobs, err := q.Read("a", 2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, len(jobs), 2, "read count")
+	ztesting.Equal(t, string(jobs[0].Data), "1", "data")
+	q.Ack(jobs[0].ID)
+	pending, _ := q.Pending()
+	ztesting.Equal(t, pending, 1, "one pending")
+	time.Sleep(50 * time.Millisecond)
+	claimed, err := q.Claim("b", 10*time.Millisecond, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, len(claimed), 1, "claimed from a")
+	ztesting.Equal(t, string(claimed[0].Data), "2", "claimed data")
+
+	var lock sync.Mutex
+	var done []string
+	stop := make(chan struct{})
+	go q.Process("b", 10*time.Millisecond, stop, func(job Job) error {
+		lock.Lock()
+		done = append(done, string(job.Data))
+		lock.Unlock()
+		return nil
+	})
+	for i := 0; i < 100; i++ {
+		lock.Lock()
+		n := len(done)
+		lock.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	close(stop)
+	pending, _ = q.Pending()
+	ztesting.Equal(t, pending, 0, "all done")
+}
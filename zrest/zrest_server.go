@@ -3,6 +3,7 @@
 package zrest
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,6 +19,7 @@ import (
 	"github.com/torlangballe/zutil/zbool"
 	"github.com/torlangballe/zutil/zdebug"
 	"github.com/torlangballe/zutil/zdict"
+	"github.com/torlangballe/zutil/zerrors"
 	"github.com/torlangballe/zutil/zlog"
 	"github.com/torlangballe/zutil/zprocess"
 	"github.com/torlangballe/zutil/zstr"
@@ -72,17 +74,51 @@ func AddCORSHeaders(w http.ResponseWriter, req *http.Request) {
 }
 
 // Returns HTTP error code and error messages in JSON representation, with string made of args and, printed
+// If errorCode is 0, it is the HTTP status of the zerrors.Code of the first error in a.
 func ReturnAndPrintError(w http.ResponseWriter, req *http.Request, errorCode int, a ...interface{}) error {
 	str := fmt.Sprintln(a...)
 	zlog.ErrorAtStack(5, a...)
-	ReturnError(w, req, str, errorCode)
+	code := zerrors.CodeNone
+	for _, p := range a {
+		if err, is := p.(error); is {
+			code = zerrors.CodeOf(err)
+			break
+		}
+	}
+	if errorCode == 0 {
+		errorCode = cmp.Or(code, zerrors.CodeInternal).HTTPStatus()
+	}
+	returnError(w, req, str, errorCode, code, nil)
 	return errors.New(str)
 }
 
 // Returns HTTP error code and error messages in JSON representation.
+// The body has a "code" with the zerrors.Code for errorCode, which zhttp turns back into an error with that code.
 func ReturnError(w http.ResponseWriter, req *http.Request, message string, errorCode int) {
+	returnError(w, req, message, errorCode, zerrors.CodeNone, nil)
+}
+
+// ReturnErrorFromError returns err with the HTTP status of its zerrors.Code, and the code in the body.
+// A zerrors.ContextError is also returned as "context", so its values and sub-errors are kept.
+func ReturnErrorFromError(w http.ResponseWriter, req *http.Request, err error) {
+	code := zerrors.CodeOf(err)
+	var context *zerrors.ContextError
+	if ce, got := zerrors.ContextErrorFromError(err); got {
+		context = &ce
+	}
+	returnError(w, req, err.Error(), code.HTTPStatus(), code, context)
+}
+
+func returnError(w http.ResponseWriter, req *http.Request, message string, errorCode int, code zerrors.Code, context *zerrors.ContextError) {
+	if code == zerrors.CodeNone {
+		code = zerrors.CodeFromHTTPStatus(errorCode)
+	}
+	dict := zdict.Dict{"error": message, "code": code}
+	if context != nil {
+		dict["context"] = context
+	}
 	w.WriteHeader(errorCode)
-	ReturnDict(w, req, zdict.Dict{"error": message})
+	ReturnDict(w, req, dict)
 }
 
 // Returns {"somekey":<some interface{}>}.
@@ -3,13 +3,17 @@
 package zrest
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/torlangballe/zutil/zdict"
+	"github.com/torlangballe/zutil/zerrors"
 	"github.com/torlangballe/zutil/zhttp"
 	"github.com/torlangballe/zutil/zlog"
 	"github.com/torlangballe/zutil/znet"
@@ -58,3 +62,30 @@ func TestCalls(t *testing.T) {
 	zlog.Warn("100 calls:", since)
 	ztesting.LessThan(t, since, 0.6, "100 calls less than 600ms")
 }
+
+func TestErrorCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/coded":
+			ReturnErrorFromError(w, req, zerrors.MakeCodedError(zerrors.CodeResourceExhausted, zdict.Dict{"Limit": 10}, "too many calls"))
+		case "/status":
+			ReturnError(w, req, "who are you", http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	params := zhttp.MakeParameters()
+	_, err := zhttp.Get(server.URL+"/coded", params, nil)
+	ztesting.Equal(t, zerrors.CodeOf(err), zerrors.CodeResourceExhausted, "code from body")
+	ztesting.Equal(t, zerrors.IsRetryable(err), true, "retryable")
+	ztesting.Equal(t, err.Error(), "Get: too many calls", "message")
+	var herr *zhttp.HTTPError
+	ztesting.Equal(t, errors.As(err, &herr) && herr.StatusCode == http.StatusTooManyRequests, true, "status")
+
+	_, err = zhttp.Get(server.URL+"/status", params, nil)
+	ztesting.Equal(t, zerrors.CodeOf(err), zerrors.CodeUnauthenticated, "code from status")
+
+	params.GetErrorFromBody = true
+	_, err = zhttp.Get(server.URL+"/coded", params, nil)
+	ztesting.Equal(t, zerrors.CodeOf(err), zerrors.CodeResourceExhausted, "CheckErrorFromBody")
+}
@@ -0,0 +1,67 @@
+//go:build server && !windows
+
+package ztelemetry
+
+import (
+	"github.com/torlangballe/zutil/zdevice"
+)
+
+var (
+	hostGauges = map[string]*GaugeVec{
+		zdevice.MetricCPU:               NewGaugeVec("host_cpu_fraction", "Fraction of all CPU cores used on the host.", "host"),
+		zdevice.MetricMemoryUsed:        NewGaugeVec("host_memory_used_fraction", "Fraction of memory used on the host.", "host"),
+		zdevice.MetricMemoryAvailable:   NewGaugeVec("host_memory_available_bytes", "Memory available on the host.", "host"),
+		zdevice.MetricDiskUsed:          NewGaugeVec("host_disk_used_fraction", "Fraction of disk used on the host.", "host", "path"),
+		zdevice.MetricDiskFree:          NewGaugeVec("host_disk_free_bytes", "Free disk space on the host.", "host", "path"),
+		zdevice.MetricNetIn:             NewGaugeVec("host_network_in_bytes_per_second", "Bytes received per second on the host.", "host", "interface"),
+		zdevice.MetricNetOut:            NewGaugeVec("host_network_out_bytes_per_second", "Bytes sent per second on the host.", "host", "interface"),
+		zdevice.MetricUptime:            NewGaugeVec("host_uptime_seconds", "Seconds since the host booted.", "host"),
+		zdevice.MetricProcessCPU:        NewGaugeVec("app_process_cpu_fraction", "Fraction of all CPU cores used by the process.", "host"),
+		zdevice.MetricProcessRSS:        NewGaugeVec("app_process_resident_memory_bytes", "Resident memory of the process.", "host"),
+		zdevice.MetricProcessOpenFiles:  NewGaugeVec("app_process_open_files", "Open file descriptors of the process.", "host"),
+		zdevice.MetricProcessThreads:    NewGaugeVec("app_process_threads", "OS threads of the process.", "host"),
+		zdevice.MetricProcessGoroutines: NewGaugeVec("app_process_goroutines", "Goroutines in the process.", "host"),
+	}
+	otherHostMetrics = NewGaugeVec("host_metric", "Metrics added to the host metrics collector with AddGauge.", "host", "name", "label")
+	hostAlerts       = NewCounterVec("host_metric_alerts", "Host metrics crossing a threshold.", "host", "name", "label")
+)
+
+// ReportHostMetrics makes c export what it samples as gauges, and count alerts that fire,
+// in addition to any HandleSampleFunc and AlertFunc it already has.
+func ReportHostMetrics(c *zdevice.MetricsCollector) {
+	host := zdevice.Name()
+	oldSample := c.HandleSampleFunc
+	oldAlert := c.AlertFunc
+	c.HandleSampleFunc = func(name, label string, value float64) {
+		if IsRunning() {
+			setHostGauge(host, name, label, value)
+		}
+		if oldSample != nil {
+			oldSample(name, label, value)
+		}
+	}
+	c.AlertFunc = func(alert zdevice.MetricAlert) {
+		if IsRunning() && alert.Firing {
+			hostAlerts.Inc(map[string]string{"host": host, "name": alert.Name, "label": alert.Label})
+		}
+		if oldAlert != nil {
+			oldAlert(alert)
+		}
+	}
+}
+
+func setHostGauge(host, name, label string, value float64) {
+	g := hostGauges[name]
+	if g == nil {
+		otherHostMetrics.Set(value, map[string]string{"host": host, "name": name, "label": label})
+		return
+	}
+	labels := map[string]string{"host": host}
+	switch name {
+	case zdevice.MetricDiskUsed, zdevice.MetricDiskFree:
+		labels["path"] = label
+	case zdevice.MetricNetIn, zdevice.MetricNetOut:
+		labels["interface"] = label
+	}
+	g.Set(value, labels)
+}
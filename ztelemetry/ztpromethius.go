@@ -5,6 +5,7 @@ package ztelemetry
 import (
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
@@ -34,13 +35,50 @@ type SummaryVec struct {
 	registered bool
 }
 
+type MetricKind string
+
+const (
+	MetricCounter   MetricKind = "counter"
+	MetricGauge     MetricKind = "gauge"
+	MetricHistogram MetricKind = "histogram"
+	MetricSummary   MetricKind = "summary"
+)
+
+// Metric describes a metric made with one of the New...Vec functions, so dashboards can be generated for them.
+type Metric struct {
+	Name   string
+	Help   string
+	Kind   MetricKind
+	Labels []string
+}
+
 const URLBaseLabel = "url_base"
 
 var (
 	registry    *prometheus.Registry
 	httpBuckets []float64 = prometheus.ExponentialBuckets(0.1, 1.5, 5)
+	metrics     []Metric
+	metricsLock sync.Mutex
 )
 
+func addMetric(name, help string, kind MetricKind, labels []string) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	for _, m := range metrics {
+		if m.Name == name {
+			return
+		}
+	}
+	metrics = append(metrics, Metric{Name: name, Help: help, Kind: kind, Labels: labels})
+}
+
+// Metrics returns the metrics made so far, in the order they were made.
+func Metrics() []Metric {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	return append([]Metric{}, metrics...)
+}
+
 func IsRunning() bool {
 	return registry != nil
 }
@@ -77,6 +115,7 @@ func StartPrometheusHandling() {
 
 func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
 	var c CounterVec
+	addMetric(name, help, MetricCounter, labelNames)
 
 	c.cv = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: name,
@@ -95,6 +134,7 @@ func (c *CounterVec) Inc(labels map[string]string) {
 
 func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
 	var g GaugeVec
+	addMetric(name, help, MetricGauge, labelNames)
 	g.gv = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: name,
 		Help: help,
@@ -112,6 +152,7 @@ func (g *GaugeVec) Set(val float64, labels map[string]string) {
 
 func NewHistogramVec(name string, buckets []float64, help string, labelNames ...string) *HistogramVec {
 	var h HistogramVec
+	addMetric(name, help, MetricHistogram, labelNames)
 	h.hv = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			// Namespace: "zui",
@@ -132,6 +173,7 @@ func (h *HistogramVec) Observe(val float64, labels map[string]string) {
 
 func NewSummaryVec(name string, help string, labelNames ...string) *SummaryVec {
 	var s SummaryVec
+	addMetric(name, help, MetricSummary, labelNames)
 	s.sv = prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
 			// Namespace: "zui",
@@ -151,6 +193,9 @@ func (s *SummaryVec) Observe(val float64, labels map[string]string) {
 
 func WrapHandler(handlerName string, handlerFunc http.HandlerFunc) http.HandlerFunc {
 	reg := prometheus.WrapRegistererWith(prometheus.Labels{"handler": handlerName}, registry)
+	labels := []string{"handler", "method", "code"}
+	addMetric("http_requests_total", "Tracks the number of HTTP requests.", MetricCounter, labels)
+	addMetric("http_request_duration_seconds", "Tracks the latencies for HTTP requests.", MetricHistogram, labels)
 	requestsTotal := promauto.With(reg).NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "http_requests_total",
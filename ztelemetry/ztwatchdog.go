@@ -0,0 +1,39 @@
+//go:build server
+
+package ztelemetry
+
+import (
+	"github.com/torlangballe/zutil/zdebug"
+)
+
+var (
+	goroutinesByCreator = NewGaugeVec("goroutines_by_creator", "Goroutines per function that created them, from the goroutine watchdog.", "creator")
+	watchdogFindings    = NewCounterVec("goroutine_watchdog_findings", "Blocked or growing goroutines found by the goroutine watchdog.", "kind", "place")
+)
+
+// ReportGoroutineWatchdog makes w export goroutine counts per creating function and its findings as metrics,
+// in addition to any HandleCountsFunc and HandleFindingsFunc it already has.
+func ReportGoroutineWatchdog(w *zdebug.Watchdog) {
+	oldCounts := w.HandleCountsFunc
+	oldFindings := w.HandleFindingsFunc
+	w.HandleCountsFunc = func(counts map[string]int) {
+		if IsRunning() {
+			for creator, count := range counts {
+				goroutinesByCreator.Set(float64(count), map[string]string{"creator": creator})
+			}
+		}
+		if oldCounts != nil {
+			oldCounts(counts)
+		}
+	}
+	w.HandleFindingsFunc = func(findings []zdebug.WatchdogFinding) {
+		if IsRunning() {
+			for _, f := range findings {
+				watchdogFindings.Inc(map[string]string{"kind": string(f.Kind), "place": f.Place})
+			}
+		}
+		if oldFindings != nil {
+			oldFindings(findings)
+		}
+	}
+}
@@ -10,29 +10,44 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/torlangballe/zutil/zfile"
 	"github.com/torlangballe/zutil/zlog"
 	"github.com/torlangballe/zutil/zmap"
 	"github.com/torlangballe/zutil/zrest"
 	"github.com/torlangballe/zutil/zstr"
+	"github.com/torlangballe/zutil/ztimer"
 )
 
+// Handler parses .gohtml templates in basePath of fileSystem when first executed, caching them.
+// A page can extend a layout in LayoutsDir by starting with {{/* layout "name" */}}, defining the layout's
+// {{block}}s it changes. Layouts can extend other layouts. Templates in PartialsDir are available to all pages
+// with {{template "name" .}}, where name is their file name without extension.
 type Handler struct {
-	templateLoaded zmap.LockMap[string, bool]
+	LayoutsDir     string // relative to basePath
+	PartialsDir    string // relative to basePath
+	AssetDir       string // folder in fileSystem the asset template function hashes files in
+	AssetURLPrefix string // prefix of urls the asset template function returns
+
+	templateLoaded zmap.LockMap[string, *pageTemplate]
+	assetHashes    zmap.LockMap[string, string]
 	basePath       string
-	mainTemplate   *template.Template
+	partials       *template.Template // all partials, cloned for each page
 	fileSystem     fs.FS
 	lock           sync.Mutex
+	watcher        *ztimer.Repeater
+	modified       map[string]time.Time
 }
 
 func NewHandler(base string, fileSystem fs.FS) (h *Handler) {
 	h = new(Handler)
 	h.basePath = base
 	h.fileSystem = fileSystem
+	h.LayoutsDir = "layouts"
+	h.PartialsDir = "partials"
 	return
 }
 
@@ -97,35 +112,28 @@ var fmap = map[string]interface{}{
 	"either":   Either,
 }
 
-func (h *Handler) loadTemplate(name string) error { // https://stackoverflow.com/questions/38686583/golang-parse-all-templates-in-directory-and-subdirectories
+// loadTemplate returns the page template name with its layouts and partials, parsing it if not cached.
+func (h *Handler) loadTemplate(name string) (*pageTemplate, error) { // https://stackoverflow.com/questions/38686583/golang-parse-all-templates-in-directory-and-subdirectories
 	if filepath.Ext(name) != ".gohtml" {
-		return zlog.NewError("not template:", name)
+		return nil, zlog.NewError("not template:", name)
+	}
+	name = strings.TrimPrefix(name, "/")
+	page, loaded := h.templateLoaded.Get(name)
+	if loaded {
+		return page, nil
 	}
-
 	h.lock.Lock()
 	defer h.lock.Unlock()
-	_, loaded := h.templateLoaded.GetSet(name, true)
+	page, loaded = h.templateLoaded.Get(name)
 	if loaded {
-		return nil
+		return page, nil
 	}
-	tpath := zstr.Concat("/", h.basePath, name)
-	if h.mainTemplate == nil {
-		h.mainTemplate = template.New("base")
-	}
-	data, errio := zfile.ReadBytesFromFileInFS(h.fileSystem, tpath)
-	if errio != nil {
-		return zlog.Error(errio, "ReadBytesFromFileInFS", tpath)
-	}
-	if len(data) == 0 {
-		return zlog.Error(errio, "ReadBytesFromFileInFS data size 0", tpath)
-	}
-	zlog.Info("load template:", tpath, len(data))
-	t := h.mainTemplate.New(name).Funcs(fmap)
-	_, err := t.Parse(string(data))
+	page, err := h.compile(name)
 	if err != nil {
-		return zlog.Error("(parse)", err)
+		return nil, err
 	}
-	return nil
+	h.templateLoaded.Set(name, page)
+	return page, nil
 }
 
 func (h *Handler) ExecuteTemplate(w http.ResponseWriter, req *http.Request, dump bool, v interface{}) error {
@@ -144,11 +152,11 @@ func (h *Handler) ExecuteTemplate(w http.ResponseWriter, req *http.Request, dump
 	zstr.HasPrefix(path, zrest.AppURLPrefix, &path)
 	name := path + ".gohtml"
 	// zlog.Info("ExecuteTemplate:", name)
-	err := h.loadTemplate(name)
+	page, err := h.loadTemplate(name)
 	if err != nil {
 		return zrest.ReturnAndPrintError(w, req, http.StatusInternalServerError, "templates load error:", req.URL.Path, err)
 	}
-	err = h.mainTemplate.ExecuteTemplate(out, name, v)
+	err = page.template.ExecuteTemplate(out, page.execute, v)
 	if err != nil {
 		return zlog.Error("exe error:", name, zlog.Full(v), err)
 	}
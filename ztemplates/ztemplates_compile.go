@@ -0,0 +1,233 @@
+//go:build server
+
+package ztemplates
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/torlangballe/zutil/zfile"
+	"github.com/torlangballe/zutil/zlocale"
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zstr"
+	"github.com/torlangballe/zutil/ztime"
+)
+
+// pageTemplate is a page parsed with its layouts and partials, and the name of the template to execute for it:
+// its outermost layout if it has one.
+type pageTemplate struct {
+	template *template.Template
+	execute  string
+}
+
+var layoutReg = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*layout:?\s*"?([\w./-]+?)"?\s*\*/\s*-?\}\}`)
+
+// FormatDate formats t with layout, which can be a time.Format layout or one of iso, date, short, nice or js.
+// Zero times are empty.
+func FormatDate(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	switch layout {
+	case "iso":
+		layout = ztime.ISO8601Format
+	case "date":
+		layout = ztime.ISO8601DateFormat
+	case "short":
+		layout = ztime.ShortFormat
+	case "js":
+		layout = ztime.JavascriptFormat
+	case "nice":
+		return ztime.GetNice(t, false)
+	}
+	return t.Format(layout)
+}
+
+func formatDuration(d time.Duration) string {
+	return ztime.GetDurNice(d, 0)
+}
+
+// funcs is fmap with functions for translation, dates and asset urls added.
+func (h *Handler) funcs() template.FuncMap {
+	m := template.FuncMap{}
+	for k, f := range fmap {
+		m[k] = f
+	}
+	m["ts"] = zlocale.TS
+	m["date"] = FormatDate
+	m["nicetime"] = ztime.GetNice
+	m["duration"] = formatDuration
+	m["asset"] = h.AssetURL
+	return m
+}
+
+// AssetURL returns AssetURLPrefix + file with a ?v= hash of the contents of file in AssetDir added,
+// so browsers can cache it until it changes. Hashes are cached until templates are reloaded.
+// If the file can't be read, the url is returned without a hash.
+func (h *Handler) AssetURL(file string) template.URL {
+	u := zstr.Concat("/", h.AssetURLPrefix, file)
+	hash, got := h.assetHashes.Get(file)
+	if !got {
+		fpath := fsPath(h.AssetDir, file)
+		data, err := zfile.ReadBytesFromFileInFS(h.fileSystem, fpath)
+		if err != nil {
+			zlog.Error("asset", fpath, err)
+			return template.URL(u)
+		}
+		hash = zstr.HashTo32Hex(string(data))
+		h.assetHashes.Set(file, hash)
+	}
+	return template.URL(u + "?v=" + hash)
+}
+
+// fsPath joins parts to a path valid in an fs.FS, without leading slash.
+func fsPath(parts ...string) string {
+	p := strings.TrimPrefix(path.Join(parts...), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+func (h *Handler) readTemplateFile(rel string) (string, error) {
+	tpath := fsPath(h.basePath, rel)
+	data, err := zfile.ReadBytesFromFileInFS(h.fileSystem, tpath)
+	if err != nil {
+		return "", zlog.Error(err, "ReadBytesFromFileInFS", tpath)
+	}
+	if len(data) == 0 {
+		return "", zlog.Error("ReadBytesFromFileInFS data size 0", tpath)
+	}
+	return string(data), nil
+}
+
+// layoutOf returns the layout named at the start of a template, or "" if none.
+func layoutOf(text string) string {
+	m := layoutReg.FindStringSubmatch(text)
+	if m == nil {
+		return ""
+	}
+	return strings.TrimSuffix(m[1], ".gohtml")
+}
+
+// loadPartials parses all the templates in PartialsDir into one template, named by their file names without extension.
+func (h *Handler) loadPartials() (*template.Template, error) {
+	if h.partials != nil {
+		return h.partials, nil
+	}
+	partials := template.New("partials").Funcs(h.funcs())
+	dir := fsPath(h.basePath, h.PartialsDir)
+	var errs []error
+	if h.PartialsDir != "" {
+		fs.WalkDir(h.fileSystem, dir, func(fpath string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || path.Ext(fpath) != ".gohtml" {
+				return nil
+			}
+			rel := strings.TrimPrefix(strings.TrimPrefix(fpath, dir), "/")
+			text, err := h.readTemplateFile(path.Join(h.PartialsDir, rel))
+			if err == nil {
+				_, err = partials.New(strings.TrimSuffix(rel, ".gohtml")).Parse(text)
+			}
+			if err != nil {
+				errs = append(errs, zlog.Error("partial", fpath, err))
+			}
+			return nil
+		})
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	h.partials = partials
+	return partials, nil
+}
+
+// compile parses page name on a clone of the partials, after its layouts starting with the outermost,
+// so its {{define}}s replace their {{block}}s.
+func (h *Handler) compile(name string) (*pageTemplate, error) {
+	partials, err := h.loadPartials()
+	if err != nil {
+		return nil, err
+	}
+	t, err := partials.Clone()
+	if err != nil {
+		return nil, zlog.Error("clone", err)
+	}
+	text, err := h.readTemplateFile(name)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{name}
+	texts := []string{text}
+	for layout := layoutOf(text); layout != ""; layout = layoutOf(text) {
+		lname := path.Join(h.LayoutsDir, layout+".gohtml")
+		if zstr.StringsContain(names, lname) {
+			return nil, zlog.NewError("layout loop:", name, names)
+		}
+		text, err = h.readTemplateFile(lname)
+		if err != nil {
+			return nil, zlog.Error("layout of", names[len(names)-1], err)
+		}
+		names = append([]string{lname}, names...)
+		texts = append([]string{text}, texts...)
+	}
+	for i, n := range names {
+		_, err = t.New(n).Parse(texts[i])
+		if err != nil {
+			return nil, zlog.Error("(parse)", err)
+		}
+	}
+	zlog.Info("load template:", name, len(texts[len(texts)-1]))
+	return &pageTemplate{template: t, execute: names[0]}, nil
+}
+
+// CompileAll parses all pages in basePath, with their layouts and partials, returning all errors found joined,
+// so they can be fixed at once at startup instead of when each page is first requested.
+// Pages that parse are cached.
+func (h *Handler) CompileAll() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	base := fsPath(h.basePath)
+	partials := fsPath(h.basePath, h.PartialsDir)
+	layouts := fsPath(h.basePath, h.LayoutsDir)
+	var errs []error
+	_, err := h.loadPartials()
+	if err != nil {
+		return err // the pages would all fail the same way
+	}
+	err = fs.WalkDir(h.fileSystem, base, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if d.IsDir() {
+			if fpath != base && (fpath == partials && h.PartialsDir != "" || fpath == layouts && h.LayoutsDir != "") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if path.Ext(fpath) != ".gohtml" {
+			return nil
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(fpath, base), "/")
+		if base == "." {
+			name = fpath
+		}
+		page, err := h.compile(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return nil
+		}
+		h.templateLoaded.Set(name, page)
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
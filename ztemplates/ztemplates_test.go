@@ -0,0 +1,98 @@
+//go:build server
+
+package ztemplates
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"www/layouts/base.gohtml": {Data: []byte(`<html><title>{{block "title" .}}Default{{end}}</title>{{block "body" .}}{{end}}</html>`)},
+		"www/layouts/page.gohtml": {Data: []byte(`{{/* layout "base" */}}{{define "body"}}<nav>{{template "nav" .}}</nav>{{block "content" .}}{{end}}{{end}}`)},
+		"www/partials/nav.gohtml": {Data: []byte(`<a href="{{asset "site.css"}}">{{ts "Home"}}</a>`)},
+		"www/home.gohtml":         {Data: []byte(`{{/* layout "page" */}}{{define "title"}}Home{{end}}{{define "content"}}<p>{{.Name}} {{date .When "date"}}</p>{{end}}`)},
+		"www/plain.gohtml":        {Data: []byte(`plain {{.Name}}`)},
+		"assets/site.css":         {Data: []byte(`body {}`), ModTime: time.Unix(1, 0)},
+	}
+}
+
+func execute(t *testing.T, h *Handler, name string, v any) string {
+	t.Helper()
+	page, err := h.loadTemplate(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	err = page.template.ExecuteTemplate(&out, page.execute, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func newTestHandler(fsys fstest.MapFS) *Handler {
+	h := NewHandler("www", fsys)
+	h.AssetDir = "assets"
+	h.AssetURLPrefix = "/static/"
+	return h
+}
+
+func TestLayouts(t *testing.T) {
+	fsys := testFS()
+	h := newTestHandler(fsys)
+	v := struct {
+		Name string
+		When time.Time
+	}{"Tor", time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)}
+	got := execute(t, h, "home.gohtml", v)
+	hash := string(h.AssetURL("site.css"))
+	ztesting.Equal(t, strings.HasPrefix(hash, "/static/site.css?v="), true, "asset url", hash)
+	ztesting.Equal(t, got, `<html><title>Home</title><nav><a href="`+hash+`">Home</a></nav><p>Tor 2024-03-05</p></html>`, "home")
+	ztesting.Equal(t, execute(t, h, "/plain.gohtml", v), "plain Tor", "plain")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/plain", nil)
+	err := h.ExecuteTemplate(rec, req, false, v)
+	ztesting.OnErrorFatal(t, err)
+	ztesting.Equal(t, rec.Body.String(), "plain Tor", "executed")
+}
+
+func TestReload(t *testing.T) {
+	fsys := testFS()
+	h := newTestHandler(fsys)
+	ztesting.Equal(t, h.CheckForChanges(), false, "first check")
+	ztesting.Equal(t, execute(t, h, "plain.gohtml", struct{ Name string }{"a"}), "plain a", "before")
+	oldURL := h.AssetURL("site.css")
+	ztesting.Equal(t, h.CheckForChanges(), false, "unchanged")
+
+	fsys["www/plain.gohtml"] = &fstest.MapFile{Data: []byte(`changed {{.Name}}`), ModTime: time.Unix(2, 0)}
+	fsys["assets/site.css"] = &fstest.MapFile{Data: []byte(`body { color: red }`), ModTime: time.Unix(2, 0)}
+	ztesting.Equal(t, h.CheckForChanges(), true, "changed")
+	ztesting.Equal(t, execute(t, h, "plain.gohtml", struct{ Name string }{"a"}), "changed a", "after")
+	ztesting.Different(t, h.AssetURL("site.css"), oldURL, "asset hash changed")
+}
+
+func TestCompileAll(t *testing.T) {
+	fsys := testFS()
+	h := newTestHandler(fsys)
+	ztesting.OnError(t, h.CompileAll())
+	ztesting.Equal(t, h.templateLoaded.Count(), 2, "pages cached")
+
+	fsys["www/bad1.gohtml"] = &fstest.MapFile{Data: []byte(`{{if}}`)}
+	fsys["www/sub/bad2.gohtml"] = &fstest.MapFile{Data: []byte(`{{/* layout "missing" */}}x`)}
+	h.Reload()
+	err := h.CompileAll()
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	ztesting.Equal(t, strings.Contains(err.Error(), "bad1.gohtml"), true, "bad1 reported", err)
+	ztesting.Equal(t, strings.Contains(err.Error(), "sub/bad2.gohtml"), true, "bad2 reported", err)
+	ztesting.Equal(t, h.templateLoaded.Count(), 2, "good pages cached")
+}
@@ -0,0 +1,84 @@
+//go:build server
+
+package ztemplates
+
+import (
+	"io/fs"
+	"maps"
+	"time"
+
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/ztimer"
+)
+
+// Watch makes h check the modification times of the files in basePath and AssetDir every secs seconds,
+// reloading all templates and asset hashes if any have changed, been added or removed.
+// It is for development, with a file system like os.DirFS of the source folder, as embedded files never change.
+func (h *Handler) Watch(secs float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.watcher != nil {
+		return
+	}
+	h.modified = h.modTimes()
+	h.watcher = ztimer.RepeatForever(secs, func() {
+		h.CheckForChanges()
+	})
+}
+
+// StopWatching stops checking for changes started with Watch.
+func (h *Handler) StopWatching() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.watcher != nil {
+		h.watcher.Stop()
+		h.watcher = nil
+	}
+}
+
+// CheckForChanges reloads if any files have changed since it was last called, returning true if so.
+func (h *Handler) CheckForChanges() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	times := h.modTimes()
+	if h.modified != nil && maps.EqualFunc(times, h.modified, time.Time.Equal) {
+		return false
+	}
+	changed := h.modified != nil
+	h.modified = times
+	if changed {
+		zlog.Info("templates changed, reloading:", h.basePath)
+		h.reload()
+	}
+	return changed
+}
+
+// Reload makes templates and asset hashes be read again as they are used.
+func (h *Handler) Reload() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.reload()
+}
+
+func (h *Handler) reload() {
+	h.templateLoaded.RemoveAll()
+	h.assetHashes.RemoveAll()
+	h.partials = nil
+}
+
+func (h *Handler) modTimes() map[string]time.Time {
+	m := map[string]time.Time{}
+	for _, dir := range []string{fsPath(h.basePath), fsPath(h.AssetDir)} {
+		fs.WalkDir(h.fileSystem, dir, func(fpath string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err == nil {
+				m[fpath] = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return m
+}
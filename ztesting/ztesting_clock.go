@@ -0,0 +1,18 @@
+package ztesting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/torlangballe/zutil/zclock"
+)
+
+// UseFakeClock sets a new zclock.FakeClock as the clock used by ztimer and ztime until t ends.
+func UseFakeClock(t *testing.T, start time.Time) *zclock.FakeClock {
+	c := zclock.NewFakeClock(start)
+	previous := zclock.Set(c)
+	t.Cleanup(func() {
+		zclock.Set(previous)
+	})
+	return c
+}
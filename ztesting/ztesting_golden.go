@@ -0,0 +1,161 @@
+package ztesting
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// GoldenDir is where golden and snapshot files are kept, relative to the package being tested.
+var GoldenDir = "testdata"
+
+// updateGolden is the -update flag. It is registered when ztesting is imported, before a test package's
+// own variables are initialized, so a test package using ztesting can't define an -update flag of its own.
+var updateGolden = flag.Bool("update", false, "write golden and snapshot files instead of comparing with them")
+
+// UpdatingGolden is true if tests are run with -update, to write golden files with what tests got.
+func UpdatingGolden() bool {
+	return *updateGolden
+}
+
+// GoldenPath is the path of a golden file with name in GoldenDir.
+func GoldenPath(name string) string {
+	return filepath.Join(GoldenDir, filepath.FromSlash(name))
+}
+
+// Golden compares got with the contents of golden file name in GoldenDir, failing t with a line diff if they differ,
+// or byte counts if they aren't text. With -update, got is written to the file instead.
+func Golden(t *testing.T, name string, got []byte) bool {
+	t.Helper()
+	path := GoldenPath(name)
+	if UpdatingGolden() {
+		return writeGolden(t, path, got)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		fail(t, "read golden file (run with -update to create it):", err)
+		return false
+	}
+	if bytes.Equal(want, got) {
+		return true
+	}
+	if utf8.Valid(want) && utf8.Valid(got) {
+		fail(t, "differs from golden file", path, "(-want +got):\n"+TextDiff(string(want), string(got)))
+		return false
+	}
+	fail(t, "differs from binary golden file", path, fmt.Sprintf("want %d bytes, got %d", len(want), len(got)))
+	return false
+}
+
+// MatchSnapshot compares v with snapshot file name in GoldenDir like Golden does.
+// Strings and byte slices are compared as text, anything else as indented json, so diffs are readable.
+func MatchSnapshot(t *testing.T, name string, v any) bool {
+	t.Helper()
+	var data []byte
+	switch s := v.(type) {
+	case string:
+		data = []byte(s)
+	case []byte:
+		data = s
+	default:
+		var err error
+		data, err = json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			fail(t, "marshal snapshot:", err)
+			return false
+		}
+		data = append(data, '\n')
+		if filepath.Ext(name) == "" {
+			name += ".json"
+		}
+	}
+	if filepath.Ext(name) == "" {
+		name += ".txt"
+	}
+	return Golden(t, name, data)
+}
+
+func writeGolden(t *testing.T, path string, data []byte) bool {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		fail(t, "write golden file:", err)
+		return false
+	}
+	return true
+}
+
+func fail(t *testing.T, parts ...any) {
+	str := fmt.Sprintln(parts...)
+	str = strings.TrimSuffix(str, "\n")
+	zlog.Error(zlog.StackAdjust(2), "Fail:", str)
+	t.Error(str)
+}
+
+// TextDiff returns a line diff of a and b, with removed lines prefixed by "-", added by "+",
+// and unchanged ones by a space. Only up to 3 unchanged lines around changes are included.
+func TextDiff(a, b string) string {
+	const context = 3
+	al := strings.Split(a, "\n")
+	bl := strings.Split(b, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of al[i:] and bl[j:]
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var lines []string
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			lines = append(lines, " "+al[i])
+			i++
+			j++
+		case i < len(al) && (j == len(bl) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+al[i])
+			i++
+		default:
+			lines = append(lines, "+"+bl[j])
+			j++
+		}
+	}
+	var out []string
+	for n, line := range lines {
+		if line[0] != ' ' || nearChange(lines, n, context) {
+			out = append(out, line)
+			continue
+		}
+		if len(out) == 0 || out[len(out)-1] != "..." {
+			out = append(out, "...")
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func nearChange(lines []string, n, context int) bool {
+	for i := max(0, n-context); i <= min(len(lines)-1, n+context); i++ {
+		if lines[i][0] != ' ' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package ztesting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/torlangballe/zutil/zdebug"
+)
+
+// LeakIgnoreFuncs are goroutines CheckGoroutineLeaks never counts as leaked, as they are pooled or live for the whole process.
+var LeakIgnoreFuncs = []string{
+	"net/http.(*persistConn).readLoop",
+	"net/http.(*persistConn).writeLoop",
+	"os/signal.signal_recv",
+	"testing.(*T).Run",
+	"testing.runTests",
+}
+
+// CheckGoroutineLeaks fails t when it ends if goroutines started after this call are still running,
+// after giving them a second to finish. Call it first in a test. Goroutines with any of ignoreFuncs
+// or LeakIgnoreFuncs in their stack are not counted.
+func CheckGoroutineLeaks(t *testing.T, ignoreFuncs ...string) {
+	before := map[int64]bool{}
+	for _, g := range zdebug.AllGoroutines() {
+		before[g.ID] = true
+	}
+	w := zdebug.Watchdog{IgnoreFuncs: append(ignoreFuncs, LeakIgnoreFuncs...)}
+	t.Cleanup(func() {
+		var leaked []zdebug.Goroutine
+		for start := time.Now(); time.Since(start) < time.Second; time.Sleep(20 * time.Millisecond) {
+			leaked = leaked[:0]
+			for _, g := range zdebug.AllGoroutines() {
+				if !before[g.ID] && !w.Ignores(g) {
+					leaked = append(leaked, g)
+				}
+			}
+			if len(leaked) == 0 {
+				return
+			}
+		}
+		for _, g := range leaked {
+			t.Error("leaked goroutine:\n" + g.Stack)
+		}
+	})
+}
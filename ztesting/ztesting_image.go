@@ -0,0 +1,126 @@
+package ztesting
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+// ImageDiffOptions sets how different images can be for GoldenImage and CompareImages.
+type ImageDiffOptions struct {
+	Tolerance       float64 // 0-1, how perceptually different a pixel can be and still be the same. 0 means DefaultImageTolerance
+	MaxDiffFraction float64 // how much of the image can be different pixels, 0 means none
+}
+
+// DefaultImageTolerance ignores anti-aliasing and rounding differences between renderers.
+const DefaultImageTolerance = 0.1
+
+// maxYIQDelta is the largest yiqDelta, between black and white
+const maxYIQDelta = 35215.0
+
+// CompareImages compares a and b pixel by pixel using perceptual YIQ color distance, returning how many differ,
+// and an image of b faded to gray with differing pixels in red. It panics if they are different sizes.
+func CompareImages(a, b image.Image, opts ImageDiffOptions) (diffCount int, diff *image.RGBA) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		panic(fmt.Sprint("compare images of different sizes:", ab.Size(), bb.Size()))
+	}
+	tolerance := opts.Tolerance
+	if tolerance == 0 {
+		tolerance = DefaultImageTolerance
+	}
+	limit := maxYIQDelta * tolerance * tolerance
+	diff = image.NewRGBA(image.Rect(0, 0, ab.Dx(), ab.Dy()))
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ca := a.At(ab.Min.X+x, ab.Min.Y+y)
+			cb := b.At(bb.Min.X+x, bb.Min.Y+y)
+			if yiqDelta(ca, cb) > limit {
+				diffCount++
+				diff.Set(x, y, color.RGBA{R: 255, A: 255})
+				continue
+			}
+			gray := 255 - (255-color.GrayModel.Convert(cb).(color.Gray).Y)/4
+			diff.Set(x, y, color.Gray{Y: gray})
+		}
+	}
+	return diffCount, diff
+}
+
+// yiqDelta is the perceptual difference between two colors blended on white,
+// from "Measuring perceived color difference using YIQ NTSC transmission color space" by Kotsarenko and Ramos.
+func yiqDelta(a, b color.Color) float64 {
+	ar, ag, ab := blendOnWhite(a)
+	br, bg, bb := blendOnWhite(b)
+	y := yiqY(ar, ag, ab) - yiqY(br, bg, bb)
+	i := yiqI(ar, ag, ab) - yiqI(br, bg, bb)
+	q := yiqQ(ar, ag, ab) - yiqQ(br, bg, bb)
+	return 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+}
+
+func blendOnWhite(c color.Color) (r, g, b float64) {
+	cr, cg, cb, ca := c.RGBA() // alpha-premultiplied 0-65535
+	white := 255 * (1 - float64(ca)/0xFFFF)
+	return float64(cr)/257 + white, float64(cg)/257 + white, float64(cb)/257 + white
+}
+
+func yiqY(r, g, b float64) float64 { return r*0.29889531 + g*0.58662247 + b*0.11448223 }
+func yiqI(r, g, b float64) float64 { return r*0.59597799 - g*0.27417610 - b*0.32180189 }
+func yiqQ(r, g, b float64) float64 { return r*0.21147017 - g*0.52261711 + b*0.31114694 }
+
+// GoldenImage compares img with golden png file name in GoldenDir using CompareImages.
+// If they differ more than opts allows, t fails, and img and a diff image are written next to the golden file,
+// with .got.png and .diff.png extensions. With -update, img is written as the golden file instead.
+func GoldenImage(t *testing.T, name string, img image.Image, opts ImageDiffOptions) bool {
+	t.Helper()
+	if !strings.HasSuffix(name, ".png") {
+		name += ".png"
+	}
+	path := GoldenPath(name)
+	base := strings.TrimSuffix(path, ".png")
+	if UpdatingGolden() {
+		return writeGolden(t, path, encodePNG(t, img))
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		fail(t, "open golden image (run with -update to create it):", err)
+		return false
+	}
+	want, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		fail(t, "decode golden image:", path, err)
+		return false
+	}
+	if want.Bounds().Size() != img.Bounds().Size() {
+		writeGolden(t, base+".got.png", encodePNG(t, img))
+		fail(t, "image size", img.Bounds().Size(), "differs from golden", path, want.Bounds().Size())
+		return false
+	}
+	count, diff := CompareImages(want, img, opts)
+	size := img.Bounds().Size()
+	fraction := float64(count) / float64(size.X*size.Y)
+	if count == 0 || fraction <= opts.MaxDiffFraction {
+		os.Remove(base + ".got.png")
+		os.Remove(base + ".diff.png")
+		return true
+	}
+	writeGolden(t, base+".got.png", encodePNG(t, img))
+	writeGolden(t, base+".diff.png", encodePNG(t, diff))
+	fail(t, fmt.Sprintf("%d pixels (%.2f%%) differ from golden image %s, see %s", count, fraction*100, path, base+".diff.png"))
+	return false
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		fail(t, "encode png:", err)
+	}
+	return buf.Bytes()
+}
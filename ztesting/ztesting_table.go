@@ -0,0 +1,27 @@
+package ztesting
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Case is a named input and the output wanted from it, for RunTable.
+type Case[In any, Out comparable] struct {
+	Name string
+	In   In
+	Want Out
+}
+
+// RunTable runs f on each case's In as a subtest named Name, or its index if empty, checking it returns Want.
+func RunTable[In any, Out comparable](t *testing.T, f func(In) Out, cases ...Case[In, Out]) {
+	t.Helper()
+	for i, c := range cases {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprint(i)
+		}
+		t.Run(name, func(t *testing.T) {
+			Equal(t, f(c.In), c.Want, "case", name, fmt.Sprintf("(%v):", c.In))
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package ztesting
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/torlangballe/zutil/zclock"
+	"github.com/torlangballe/zutil/ztimer"
+)
+
+func useTempGoldenDir(t *testing.T, update bool) {
+	oldDir, oldUpdate := GoldenDir, *updateGolden
+	GoldenDir = t.TempDir()
+	*updateGolden = update
+	t.Cleanup(func() {
+		GoldenDir = oldDir
+		*updateGolden = oldUpdate
+	})
+}
+
+func TestTextDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine"
+	b := "one\ntwo\nthree\nfour\nfive\nSIX\nseven\neight\nnine\nten"
+	want := "...\n three\n four\n five\n-six\n+SIX\n seven\n eight\n nine\n+ten"
+	Equal(t, TextDiff(a, b), want)
+	Equal(t, TextDiff(a, a), "...")
+}
+
+func TestGolden(t *testing.T) {
+	useTempGoldenDir(t, true)
+	Equal(t, Golden(t, "sub/hello.txt", []byte("hello\nworld\n")), true)
+	data, err := os.ReadFile(filepath.Join(GoldenDir, "sub", "hello.txt"))
+	OnError(t, err)
+	Equal(t, string(data), "hello\nworld\n")
+
+	type thing struct {
+		Name  string
+		Count int
+	}
+	Equal(t, MatchSnapshot(t, "thing", thing{Name: "x", Count: 3}), true)
+	data, err = os.ReadFile(filepath.Join(GoldenDir, "thing.json"))
+	OnError(t, err)
+	Equal(t, string(data), "{\n  \"Name\": \"x\",\n  \"Count\": 3\n}\n")
+
+	*updateGolden = false
+	Golden(t, "sub/hello.txt", []byte("hello\nworld\n"))
+	MatchSnapshot(t, "thing", thing{Name: "x", Count: 3})
+}
+
+func testImage(c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	img.Set(5, 5, c)
+	return img
+}
+
+func TestCompareImages(t *testing.T) {
+	white := testImage(color.White)
+	count, _ := CompareImages(white, testImage(color.RGBA{250, 250, 250, 255}), ImageDiffOptions{})
+	Equal(t, count, 0, "near white")
+	count, diff := CompareImages(white, testImage(color.Black), ImageDiffOptions{})
+	Equal(t, count, 1, "black")
+	Equal(t, diff.RGBAAt(5, 5), color.RGBA{R: 255, A: 255})
+	count, _ = CompareImages(white, testImage(color.Transparent), ImageDiffOptions{})
+	Equal(t, count, 0, "transparent on white")
+
+	useTempGoldenDir(t, true)
+	GoldenImage(t, "dot", testImage(color.Black), ImageDiffOptions{})
+	*updateGolden = false
+	Equal(t, GoldenImage(t, "dot", testImage(color.RGBA{10, 10, 10, 255}), ImageDiffOptions{}), true)
+	Equal(t, GoldenImage(t, "dot", white, ImageDiffOptions{MaxDiffFraction: 0.02}), true)
+	_, err := os.Stat(filepath.Join(GoldenDir, "dot.diff.png"))
+	Equal(t, os.IsNotExist(err), true, "no diff image on success")
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	clock := UseFakeClock(t, start)
+	Equal(t, zclock.Now(), start)
+
+	var fired []string
+	ztimer.StartIn(2, func() { fired = append(fired, "2s") })
+	ztimer.StartIn(1, func() { fired = append(fired, "1s") })
+	stopped := ztimer.StartIn(1.5, func() { fired = append(fired, "stopped") })
+	stopped.Stop()
+	clock.Advance(1500 * time.Millisecond)
+	Equal(t, strings.Join(fired, ","), "1s")
+	Equal(t, zclock.Since(start), 1500*time.Millisecond)
+	clock.Advance(time.Second)
+	Equal(t, strings.Join(fired, ","), "1s,2s")
+
+	var ticks atomic.Int32
+	r := ztimer.RepeatForever(10, func() {
+		ticks.Add(1)
+	})
+	clock.Advance(10 * time.Second)
+	Equal(t, ticks.Load(), int32(1), "tick handled before Advance returns")
+	clock.Advance(25 * time.Second)
+	Equal(t, ticks.Load(), int32(3))
+	r.Stop()
+	for i := 0; i < 100 && clock.Pending() != 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	Equal(t, clock.Pending(), 0, "repeater ticker stopped")
+}
+
+func TestRunTable(t *testing.T) {
+	RunTable(t, strings.ToUpper,
+		Case[string, string]{Name: "lower", In: "abc", Want: "ABC"},
+		Case[string, string]{In: "MiXeD", Want: "MIXED"},
+	)
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/torlangballe/zutil/zbool"
+	"github.com/torlangballe/zutil/zclock"
 	"github.com/torlangballe/zutil/zint"
 	"github.com/torlangballe/zutil/zlocale"
 	"github.com/torlangballe/zutil/zlog"
@@ -201,7 +202,7 @@ func GetNiceSubSecs(t time.Time, subSecs int) string {
 		str = t.Format(f) + " today"
 	} else {
 		f += " 02-Jan"
-		if t.Year() != time.Now().Year() {
+		if t.Year() != zclock.Now().Year() {
 			f += " 2006"
 		}
 		str = t.Format(f)
@@ -234,7 +235,7 @@ func DurSeconds(d time.Duration) float64 {
 }
 
 func Since(t time.Time) float64 {
-	return DurSeconds(time.Since(t))
+	return DurSeconds(zclock.Since(t))
 }
 
 func GetDurationAsHMSString(duration time.Duration, hours, mins, secs bool, subdigits int) string {
@@ -368,7 +369,7 @@ func OnThisPeriod(t time.Time, field TimeFieldFlags, inc int) time.Time {
 }
 
 func GetStartOfToday() time.Time {
-	return GetStartOfDay(time.Now().Local())
+	return GetStartOfDay(zclock.Now().Local())
 }
 
 func IsSameDay(a, b time.Time) bool {
@@ -386,7 +387,7 @@ func IsSameDay(a, b time.Time) bool {
 
 // IsToday returns true if t in local time is same day as now local.
 func IsToday(t time.Time) bool {
-	return IsSameDay(t.Local(), time.Now())
+	return IsSameDay(t.Local(), zclock.Now())
 }
 
 func GetDurationHourMinSec(d time.Duration) (hours int, mins int, secs int, fract float64) {
@@ -953,7 +954,7 @@ func ParseDate(date string, location *time.Location, flags TimeFieldFlags) (t ti
 		return time.Time{}, nil, nil
 	}
 	date = strings.ToLower(date)
-	now := time.Now().In(location)
+	now := zclock.Now().In(location)
 	month := int(now.Month())
 	year := now.Year()
 	day := now.Day()
@@ -1024,7 +1025,7 @@ func ParseDate(date string, location *time.Location, flags TimeFieldFlags) (t ti
 	for {
 		t = time.Date(year, time.Month(month), day, hour, min, sec, 0, location)
 		// zlog.Warn("PARSE:", date, time.Since(t), flags&TimeFieldNotFutureIfAmbiguous != 0)
-		if time.Since(t) >= 0 {
+		if zclock.Since(t) >= 0 {
 			break
 		}
 		if flags&TimeFieldNotFutureIfAmbiguous == 0 {
@@ -1186,7 +1187,7 @@ func WeekDayName(w time.Weekday, mondayFirst bool) string {
 }
 
 func SleepUntilAtLeastAfter(atLeast time.Duration, after time.Time) {
-	since := time.Since(after)
+	since := zclock.Since(after)
 	if since < atLeast {
 		time.Sleep(atLeast - since)
 	}
@@ -0,0 +1,59 @@
+package ztime
+
+import (
+	"time"
+
+	"github.com/torlangballe/zutil/zlocale"
+)
+
+// LocalizedLayout returns a time.Format layout for a numeric date and/or time as f's locale writes it.
+// flags can have TimeFieldDateOnly or TimeFieldTimeOnly, TimeFieldSecs to add seconds and TimeFieldShortYear.
+func LocalizedLayout(f *zlocale.Formatter, flags TimeFieldFlags) string {
+	var date, clock string
+	if flags&TimeFieldTimeOnly == 0 {
+		day, month, year := "2", "1", "2006"
+		if f.DatePadded {
+			day, month = "02", "01"
+		}
+		if flags&TimeFieldShortYear != 0 {
+			year = "06"
+		}
+		sep := f.DateSeparator
+		switch f.DateOrder {
+		case zlocale.MonthDayYear:
+			date = month + sep + day + sep + year
+		case zlocale.YearMonthDay:
+			date = year + sep + month + sep + day
+		default:
+			date = day + sep + month + sep + year
+		}
+	}
+	if flags&TimeFieldDateOnly == 0 {
+		hour := "3"
+		if f.Use24Hour {
+			hour = "15"
+		}
+		clock = hour + f.TimeSeparator + "04"
+		if flags&TimeFieldSecs != 0 {
+			clock += f.TimeSeparator + "05"
+		}
+		if !f.Use24Hour {
+			clock += " PM"
+		}
+	}
+	if date != "" && clock != "" {
+		return date + " " + clock
+	}
+	return date + clock
+}
+
+// FormatLocalized formats t as a numeric date and/or time for f's locale, see LocalizedLayout for flags.
+func FormatLocalized(t time.Time, f *zlocale.Formatter, flags TimeFieldFlags) string {
+	if t.IsZero() {
+		return "null"
+	}
+	if IsBigTime(t) {
+		return "∞"
+	}
+	return t.Format(LocalizedLayout(f, flags))
+}
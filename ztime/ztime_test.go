@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/torlangballe/zutil/zlocale"
 	"github.com/torlangballe/zutil/zlog"
 	"github.com/torlangballe/zutil/ztesting"
 )
@@ -73,3 +74,12 @@ func TestAll(t *testing.T) {
 	testParseDate(t)
 	testDaysSince2000FromTime(t)
 }
+
+func TestFormatLocalized(t *testing.T) {
+	tm := time.Date(2026, 3, 9, 14, 5, 7, 0, time.UTC)
+	ztesting.Equal(t, FormatLocalized(tm, zlocale.NewFormatter("en-US"), TimeFieldNone), "3/9/2026 2:05 PM", "en-US")
+	ztesting.Equal(t, FormatLocalized(tm, zlocale.NewFormatter("nb-NO"), TimeFieldSecs), "09.03.2026 14:05:07", "nb-NO")
+	ztesting.Equal(t, FormatLocalized(tm, zlocale.NewFormatter("sv"), TimeFieldDateOnly), "2026-03-09", "sv date")
+	ztesting.Equal(t, FormatLocalized(tm, zlocale.NewFormatter("fi"), TimeFieldTimeOnly), "14.05", "fi time")
+	ztesting.Equal(t, FormatLocalized(tm, zlocale.NewFormatter("en-GB"), TimeFieldDateOnly|TimeFieldShortYear), "09/03/26", "en-GB short")
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/torlangballe/zutil/zclock"
 	"github.com/torlangballe/zutil/zlog"
 )
 
@@ -46,7 +47,7 @@ func NewRateLimiter(secs, maxSecs float64) *RateLimiter {
 }
 
 func secsSince(t time.Time) float64 {
-	return float64(time.Since(t)) / float64(time.Second)
+	return float64(zclock.Since(t)) / float64(time.Second)
 }
 
 func (r *RateLimiter) Do(do func()) {
@@ -76,7 +77,7 @@ func (r *RateLimiter) DoBackoff(do func() bool) {
 	}
 	ready := (secsSince(r.last) > r.freqSecs)
 	if ready {
-		r.last = time.Now()
+		r.last = zclock.Now()
 	}
 	if ready {
 		r.executing = true
@@ -153,9 +154,9 @@ func (r *RateLimiters) Remove(id string) {
 }
 
 func (r *RateCounter) Add() (countInWindow int) {
-	now := time.Now()
+	now := zclock.Now()
 	for i := 0; i < len(r.timeStamps); i++ {
-		if time.Since(r.timeStamps[i]) > r.Window {
+		if now.Sub(r.timeStamps[i]) > r.Window {
 			r.timeStamps = slices.Delete(r.timeStamps, i, i+1)
 			i--
 		} else {
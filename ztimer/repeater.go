@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/torlangballe/zutil/zclock"
 	"github.com/torlangballe/zutil/zdebug"
 	"github.com/torlangballe/zutil/zlog"
 )
@@ -19,7 +20,7 @@ var (
 )
 
 type Repeater struct {
-	ticker *time.Ticker
+	ticker zclock.Ticker
 	stop   chan bool
 	stack  string
 }
@@ -65,7 +66,7 @@ func (r *Repeater) Set(secs float64, now bool, perform func() bool) {
 		r.ticker.Stop()
 	}
 	invokeFunc := zdebug.FileLineAndCallingFunctionString(4, true)
-	r.ticker = time.NewTicker(secs2Dur(secs))
+	r.ticker = zclock.Current().NewTicker(secs2Dur(secs))
 	repeatersMutex.Lock()
 	r.stack = zdebug.FileLineAndCallingFunctionString(4, true)
 	// zlog.Info("Repeater.Set():", r.stack)
@@ -84,7 +85,7 @@ func (r *Repeater) Set(secs float64, now bool, perform func() bool) {
 		if t == nil {
 			return
 		}
-		ch := t.C //
+		ch := t.Chan()
 		doing := false
 		for {
 			select {
@@ -99,6 +100,7 @@ func (r *Repeater) Set(secs float64, now bool, perform func() bool) {
 					// don't return here, must do case <-stop: below
 				}
 				doing = false
+				t.Done()
 			case <-r.stop:
 				stopped++
 				GoingCount--
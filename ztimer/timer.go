@@ -7,12 +7,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/torlangballe/zutil/zclock"
 	"github.com/torlangballe/zutil/zdebug"
 	"github.com/torlangballe/zutil/zlog"
 )
 
 type Timer struct {
-	timer *time.Timer
+	timer zclock.Timer
 	start time.Time
 	mutex sync.Mutex
 	secs  float64
@@ -49,9 +50,9 @@ func (t *Timer) StartIn(secs float64, perform func()) {
 	}
 	countMutex.Unlock()
 
-	t.start = time.Now()
+	t.start = zclock.Now()
 	invokeFunc := zdebug.FileLineAndCallingFunctionString(4, true)
-	t.timer = time.AfterFunc(secs2Dur(secs), func() {
+	t.timer = zclock.Current().AfterFunc(secs2Dur(secs), func() {
 		countMutex.Lock()
 		timersCount[secs]--
 		countMutex.Unlock()
@@ -65,7 +66,7 @@ func (t *Timer) SinceStart() time.Duration {
 	if !t.IsRunning() {
 		return 0
 	}
-	return time.Since(t.start)
+	return zclock.Since(t.start)
 }
 
 func (t *Timer) Stop() {
@@ -96,7 +97,7 @@ func TryFor(secs float64, try func()) (err error) {
 }
 
 func StartAt(t time.Time, f func()) *Timer {
-	secs := dur2Secs(time.Until(t))
+	secs := dur2Secs(t.Sub(zclock.Now()))
 	timer := StartIn(secs, f)
 	return timer
 }
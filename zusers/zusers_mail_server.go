@@ -0,0 +1,114 @@
+//go:build server
+
+package zusers
+
+import (
+	"embed"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/torlangballe/zutil/zlog"
+	"github.com/torlangballe/zutil/zmail"
+	"github.com/torlangballe/zutil/znamedfuncs"
+	"github.com/torlangballe/zutil/zstr"
+)
+
+// MailSettings sets how zusers sends mails, using ForgotPassword's MailAuth, From and ProductName.
+// Mails are rendered with Templates, which default to the ones in the mailtemplates folder.
+// To restyle them, make Templates with your own file system first, falling back to DefaultMailTemplates:
+//
+//	zusers.Mails.Templates = zmail.NewTemplates(myTemplatesFS, zusers.DefaultMailTemplates)
+type MailSettings struct {
+	Templates        *zmail.Templates
+	Queue            *zmail.Queue // if set, mails are added to it instead of sent directly
+	Language         string       // language code for mails, "" uses Templates.DefaultLang
+	LoginURL         string       // used in welcome mail
+	SendWelcome      bool         // send a welcome mail when a user registers with an email as user name
+	NotifyNewLoginIP bool         // send a mail when a user logs in from an IP address none of its sessions have
+}
+
+// MailData is what the mail templates get.
+type MailData struct {
+	ProductName string
+	UserName    string
+	URL         string
+	IPAddress   string
+	UserAgent   string
+	Time        string
+}
+
+const (
+	ForgotPasswordMail = "forgot-password"
+	WelcomeMail        = "welcome"
+	NewLoginMail       = "new-login"
+)
+
+//go:embed mailtemplates
+var mailTemplatesFS embed.FS
+
+var (
+	DefaultMailTemplates, _ = fs.Sub(mailTemplatesFS, "mailtemplates")
+	Mails                   = MailSettings{Templates: zmail.NewTemplates(DefaultMailTemplates)}
+)
+
+func makeMailData(userName string) MailData {
+	return MailData{ProductName: ForgotPassword.ProductName, UserName: userName}
+}
+
+// SendTemplateMail renders the template mail called name with data, and sends it to email, or adds it to Mails.Queue.
+func SendTemplateMail(name, email string, data MailData) error {
+	m, err := Mails.Templates.MakeMail(name, Mails.Language, data)
+	if err != nil {
+		return err
+	}
+	m.From = ForgotPassword.From
+	m.AddTo("", email)
+	if Mails.Queue != nil {
+		_, err = Mails.Queue.Add(m)
+		return err
+	}
+	err = m.Send(ForgotPassword.MailAuth)
+	if err != nil {
+		return zlog.Error("send", name, email, err)
+	}
+	return nil
+}
+
+func sendWelcomeMail(userName string) {
+	if !Mails.SendWelcome || !strings.Contains(userName, "@") {
+		return
+	}
+	data := makeMailData(userName)
+	data.URL = Mails.LoginURL
+	go func() {
+		zlog.OnError(SendTemplateMail(WelcomeMail, userName, data), userName)
+	}()
+}
+
+// isLoginFromNewIP returns true if the user has sessions, but none from ci's IP address.
+// A user without sessions is logging in for the first time, or after logging out everywhere, so isn't notified.
+func isLoginFromNewIP(ci *znamedfuncs.ClientInfo, userName string) bool {
+	if !Mails.NotifyNewLoginIP || ci.IPAddress == "" || !strings.Contains(userName, "@") {
+		return false
+	}
+	u, err := MainServer.GetUserForUserName(userName)
+	if err != nil {
+		return false
+	}
+	ips, err := MainServer.GetSessionIPAddressesForUser(u.ID)
+	if zlog.OnError(err, u.ID) {
+		return false
+	}
+	return len(ips) != 0 && !zstr.StringsContain(ips, ci.IPAddress)
+}
+
+func sendNewLoginMail(ci *znamedfuncs.ClientInfo, userName string) {
+	data := makeMailData(userName)
+	data.IPAddress = ci.IPAddress
+	data.UserAgent = ci.UserAgent
+	data.Time = time.Now().UTC().Format("2006-01-02 15:04 UTC")
+	go func() {
+		zlog.OnError(SendTemplateMail(NewLoginMail, userName, data), userName)
+	}()
+}
@@ -114,10 +114,17 @@ func (UsersCalls) Authenticate(ci *znamedfuncs.ClientInfo, a Authentication, ui
 		ui.UserID, ui.Token, err = MainServer.RegisterUser(ci, a.UserName, a.Password, makeToken)
 		ui.UserName = a.UserName
 		ui.Permissions = []string{} // nothing yet, we just registered
+		if err == nil {
+			sendWelcomeMail(a.UserName)
+		}
 	} else {
 		ci.Token = "" // clear any old token already stored, so Login generates a new one
+		newIP := isLoginFromNewIP(ci, a.UserName)
 		*ui, err = MainServer.Login(ci, a.UserName, a.Password)
 		zlog.Info("Login:", ui, err)
+		if err == nil && newIP {
+			sendNewLoginMail(ci, a.UserName)
+		}
 	}
 	if err != nil {
 		zlog.Error("authenticate", a, a.IsRegister, err)
@@ -130,23 +137,20 @@ func (UsersCalls) SendForgotPasswordPasswordMail(email string) error {
 	if MainServer == nil {
 		return nil
 	}
-	var m zmail.Mail
 	random := zstr.GenerateRandomHexBytes(16)
 	surl, _ := zhttp.MakeURLWithArgs(ForgotPassword.URL, map[string]string{
 		"reset": random,
 		"email": email,
 	})
-	m.From.Name = "Tor Langballe"
-	m.AddTo("", email)
-	m.Subject = "Reset password for " + ForgotPassword.ProductName
-	m.TextContent = "Click here to reset your password:\n\n" + surl
-	// err := m.SendWithSMTP(ForgotPassword.MailAuth)
-	err := m.Send(ForgotPassword.MailAuth)
-	if err == nil {
-		zlog.Error("forgot password send error:", m, ForgotPassword.MailAuth, err)
-		resetCache.Put(random, email)
+	data := makeMailData(email)
+	data.URL = surl
+	err := SendTemplateMail(ForgotPasswordMail, email, data)
+	if err != nil {
+		zlog.Error("forgot password send error:", email, err)
+		return err
 	}
-	return err
+	resetCache.Put(random, email)
+	return nil
 }
 
 func (UsersCalls) SetNewPasswordFromForgotPassword(ci *znamedfuncs.ClientInfo, reset ResetPassword, token *string) error {
@@ -141,6 +141,27 @@ func (s *SQLServer) GetSessionsForClientID(clientID int64) ([]Session, error) {
 	return sessions, nil
 }
 
+// GetSessionIPAddressesForUser returns the distinct IP addresses the user's sessions were made from.
+func (s *SQLServer) GetSessionIPAddressesForUser(userID int64) ([]string, error) {
+	squery := "SELECT DISTINCT ipaddress FROM zuser_sessions WHERE userid=$1"
+	squery = s.customizeQuery(squery)
+	rows, err := s.DB.Query(squery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ips []string
+	for rows.Next() {
+		var ip string
+		err = rows.Scan(&ip)
+		if err != nil {
+			return nil, err
+		}
+		ips = append(ips, ip)
+	}
+	return ips, rows.Err()
+}
+
 func (s *SQLServer) IsTokenValid(token string, req *http.Request) (bool, int64) {
 	var userID int64
 	squery := "SELECT userid FROM zuser_sessions WHERE token=$1"
@@ -2,8 +2,12 @@ package zvnc
 
 import (
 	"context"
+	"encoding/binary"
 	"image"
+	"image/draw"
+	"io"
 	"net"
+	"sync"
 	"time"
 
 	//	vnc "github.com/amitbet/vnc2video"
@@ -15,36 +19,213 @@ import (
 // https://www.techrepublic.com/article/how-to-enable-screen-sharing-on-macs-via-terminal/
 
 type Client struct {
-	client *vnc.ClientConn
+	client     *vnc.ClientConn
+	canvas     *vnc.VncCanvas // drawn in by the server message reader, under canvasLock
+	screen     *image.RGBA    // copy of canvas when the last framebuffer update was complete
+	canvasLock sync.Mutex
+	messages   chan vnc.ClientMessage
+	done       chan struct{}
+	closeOnce  sync.Once
+	lock       sync.Mutex
+	buttons    MouseButton
+	recorder   *recorder
+}
+
+// Options are for ConnectWithOptions.
+type Options struct {
+	Password        string
+	UpdateSecs      float64           // how often to request a screen update
+	Encodings       []Encoding        // encodings to ask the server for, in order of preference. DefaultEncodings if empty
+	RecordPath      string            // if set, frames and input are recorded to this file, see ReadRecording
+	HandleClipboard func(text string) // called when the server's clipboard changes
+}
+
+// Encoding is a framebuffer encoding or pseudo-encoding the client can ask the server to use.
+type Encoding = vnc.EncodingType
+
+const (
+	EncodingRaw         = vnc.EncRaw
+	EncodingCopyRect    = vnc.EncCopyRect
+	EncodingRRE         = vnc.EncRRE
+	EncodingHextile     = vnc.EncHextile
+	EncodingZlib        = vnc.EncZlib
+	EncodingTight       = vnc.EncTight
+	EncodingZRLE        = vnc.EncZRLE
+	EncodingCursor      = vnc.EncCursorPseudo
+	EncodingPointerPos  = vnc.EncPointerPosPseudo
+	EncodingDesktopSize = vnc.EncDesktopSizePseudo
+)
+
+var DefaultEncodings = []Encoding{
+	EncodingCursor,
+	EncodingPointerPos,
+	EncodingCopyRect,
+	EncodingTight,
+	EncodingZRLE,
 }
 
 func (c *Client) Close() {
-	c.client.Close()
+	c.closeOnce.Do(func() {
+		c.client.Close()
+		if c.recorder != nil {
+			zlog.OnError(c.recorder.close())
+		}
+	})
 }
 
 func Connect(address, password string, updateSecs float64, got func(i image.Image, err error)) (*Client, error) {
+	return ConnectWithOptions(address, Options{Password: password, UpdateSecs: updateSecs}, got)
+}
+
+// messageHandler replaces vnc2video's DefaultClientMessageHandler. It sets up the canvas before reading server messages,
+// so the first framebuffer update has somewhere to be drawn, and draws in it under canvasLock,
+// publishing a copy as screen when each FramebufferUpdate is complete, so the canvas is never read while drawn in.
+type messageHandler struct {
+	client *Client
+	cfg    *vnc.ClientConfig
+}
+
+func (h *messageHandler) Handle(conn vnc.Conn) error {
+	c := h.client
+	cc := conn.(*vnc.ClientConn)
+	c.canvas = vnc.NewVncCanvas(int(cc.Width()), int(cc.Height()))
+	c.canvas.DrawCursor = h.cfg.DrawCursor
+	cc.Canvas = c.canvas
+	serverMessages := map[vnc.ServerMessageType]vnc.ServerMessage{}
+	for _, m := range h.cfg.Messages {
+		serverMessages[m.Type()] = m
+	}
+	var encs []Encoding
+	for _, enc := range h.cfg.Encodings {
+		encs = append(encs, enc.Type())
+		renderer, ok := enc.(vnc.Renderer)
+		if ok {
+			renderer.SetTargetImage(c.canvas)
+		}
+	}
+	err := cc.SetEncodings(encs)
+	if err != nil {
+		return err
+	}
+	first := vnc.FramebufferUpdateRequest{Width: cc.Width(), Height: cc.Height()}
+	err = first.Write(cc)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			select {
+			case msg := <-h.cfg.ClientMessageCh:
+				err := msg.Write(cc)
+				if err != nil {
+					h.cfg.ErrorCh <- err
+					return
+				}
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			msg, err := h.readMessage(cc, serverMessages)
+			if err != nil {
+				h.cfg.ErrorCh <- err
+				return
+			}
+			select {
+			case h.cfg.ServerMessageCh <- msg:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (h *messageHandler) readMessage(cc *vnc.ClientConn, serverMessages map[vnc.ServerMessageType]vnc.ServerMessage) (vnc.ServerMessage, error) {
+	var messageType vnc.ServerMessageType
+	err := binary.Read(cc, binary.BigEndian, &messageType)
+	if err != nil {
+		return nil, err
+	}
+	msg := serverMessages[messageType]
+	if msg == nil {
+		return nil, zlog.NewError("unknown server message type:", messageType)
+	}
+	c := h.client
+	c.canvasLock.Lock()
+	defer c.canvasLock.Unlock()
+	c.canvas.RemoveCursor()
+	parsed, err := msg.Read(cc)
+	c.canvas.PaintCursor()
+	if err == nil && messageType == vnc.FramebufferUpdateMsgType {
+		c.screen = copyImage(c.canvas)
+	}
+	return parsed, err
+}
+
+// serverCutText reads a ServerCutText message with the 3 padding bytes of the RFB spec; vnc2video only reads 1.
+type serverCutText struct {
+	vnc.ServerCutText
+}
+
+func (*serverCutText) Read(c vnc.Conn) (vnc.ServerMessage, error) {
+	var head struct {
+		_      [3]byte
+		Length uint32
+	}
+	err := binary.Read(c, binary.BigEndian, &head)
+	if err != nil {
+		return nil, err
+	}
+	msg := &vnc.ServerCutText{Length: head.Length, Text: make([]byte, head.Length)}
+	_, err = io.ReadFull(c, msg.Text)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ConnectWithOptions connects to a VNC server at address, requesting a screen update every opts.UpdateSecs,
+// calling got with the screen image when it arrives. Keyboard, pointer and clipboard events can be sent with the Client.
+func ConnectWithOptions(address string, opts Options, got func(i image.Image, err error)) (*Client, error) {
 	nc, err := net.DialTimeout("tcp", address, 25*time.Second)
 	if err != nil || nc == nil {
 		return nil, zlog.Error("dial", err)
 	}
+	c := &Client{}
+	if opts.RecordPath != "" {
+		c.recorder, err = newRecorder(opts.RecordPath)
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
 	// Negotiate connection with the server.
 	cchServer := make(chan vnc.ServerMessage)
-	cchClient := make(chan vnc.ClientMessage)
-	errorCh := make(chan error)
+	c.messages = make(chan vnc.ClientMessage)
+	c.done = make(chan struct{})
+	errorCh := make(chan error, 4) // vnc2video.Connect puts an error on it before returning if handshake fails
 	quitCh := make(chan struct{})
 
 	// zlog.Info("starting up the vnc client, connecting to:", address, "pass:", password)
 	ccfg := &vnc.ClientConfig{
 		SecurityHandlers: []vnc.SecurityHandler{
 			// &vnc.ClientAuthATEN{Username: []byte(os.Args[2]), Password: []byte(os.Args[3])},
-			&vnc.ClientAuthVNC{Password: []byte(password)},
+			&vnc.ClientAuthVNC{Password: []byte(opts.Password)},
 			&vnc.ClientAuthNone{},
 		},
 		DrawCursor:      false,
 		PixelFormat:     vnc.PixelFormat32bit,
-		ClientMessageCh: cchClient,
+		ClientMessageCh: c.messages,
 		ServerMessageCh: cchServer,
-		Messages:        vnc.DefaultServerMessages,
+		Messages: []vnc.ServerMessage{
+			&vnc.FramebufferUpdate{},
+			&vnc.SetColorMapEntries{},
+			&vnc.Bell{},
+			&serverCutText{},
+		},
 		Encodings: []vnc.Encoding{
 			&vnc.RawEncoding{},
 			&vnc.TightEncoding{},
@@ -59,25 +240,34 @@ func Connect(address, password string, updateSecs float64, got func(i image.Imag
 		ErrorCh: errorCh,
 		QuitCh:  quitCh,
 	}
-	var screenImage *vnc.VncCanvas
-	var cc *vnc.ClientConn
-
-	ticker := time.NewTicker(ztime.SecondsDur(updateSecs))
-	var getScreen bool
-	cc, err = vnc.Connect(context.Background(), nc, ccfg)
+	ccfg.Handlers = []vnc.Handler{
+		&vnc.DefaultClientVersionHandler{},
+		&vnc.DefaultClientSecurityHandler{},
+		&vnc.DefaultClientClientInitHandler{},
+		&vnc.DefaultClientServerInitHandler{},
+		&messageHandler{client: c, cfg: ccfg},
+	}
+	cc, err := vnc.Connect(context.Background(), nc, ccfg)
 	if err != nil || cc == nil {
+		if c.recorder != nil {
+			c.recorder.close()
+		}
 		return nil, zlog.Error("connect", err)
 	}
+	c.client = cc
+
+	ticker := time.NewTicker(ztime.SecondsDur(opts.UpdateSecs))
+	var getScreen bool
 	go func() { // because vnc2video.Connect puts error on error channel during setup, we need to do for/select to pop it before calling:
 		// defer zlog.LogRecover()
+		defer close(c.done)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
 				// send message to update frame:
-				// zlog.Info("en", updateSecs, screenImage.Bounds())
 				getScreen = true
-				reqMsg := vnc.FramebufferUpdateRequest{Inc: 1, X: 0, Y: 0, Width: cc.Width(), Height: cc.Height()}
-				reqMsg.Write(cc)
+				c.RequestUpdate(true)
 
 			case <-quitCh:
 				// zlog.Info("quit")
@@ -90,38 +280,97 @@ func Connect(address, password string, updateSecs float64, got func(i image.Imag
 				}
 				return
 
-			case msg := <-cchClient:
-				zlog.Info("VNC Received client message type:%v msg:%v\n", msg.Type(), msg)
-
 			case msg := <-cchServer:
-				if msg.Type() == vnc.FramebufferUpdateMsgType {
+				switch msg.Type() {
+				case vnc.FramebufferUpdateMsgType:
 					// zlog.Info("VNC New screen!", getScreen, updateSecs, screenImage.Bounds())
+					if c.recorder != nil {
+						c.recorder.addFrame(c.lastScreen())
+					}
 					if getScreen && got != nil {
-						got(screenImage, nil)
+						got(c.Screenshot(), nil)
 					}
 					getScreen = false
+				case vnc.ServerCutTextMsgType:
+					text := latin1ToString(msg.(*vnc.ServerCutText).Text)
+					c.record(Event{Type: EventServerClipboard, Text: text})
+					if opts.HandleClipboard != nil {
+						opts.HandleClipboard(text)
+					}
 				}
 			}
 		}
 	}()
-
-	screenImage = cc.Canvas
-	for _, enc := range ccfg.Encodings {
-		myRenderer, ok := enc.(vnc.Renderer)
-
-		if ok {
-			myRenderer.SetTargetImage(screenImage)
-		}
-	}
 	zlog.Info("vnc connected to:", address)
-	cc.SetEncodings([]vnc.EncodingType{
-		vnc.EncCursorPseudo,
-		vnc.EncPointerPosPseudo,
-		vnc.EncCopyRect,
-		vnc.EncTight,
-		vnc.EncZRLE,
-	})
-	c := &Client{client: cc}
+	encs := opts.Encodings
+	if len(encs) == 0 {
+		encs = DefaultEncodings
+	}
+	err = c.SetEncodings(encs...)
 	return c, err
 	//cc.Wait()
 }
+
+// send queues msg to be written by the vnc2video client's writer goroutine, which all messages must go through.
+func (c *Client) send(msg vnc.ClientMessage) error {
+	select {
+	case c.messages <- msg:
+		return nil
+	case <-c.done:
+		return zlog.NewError("vnc connection closed")
+	}
+}
+
+// SetEncodings asks the server to use encs, in order of preference. Raw is always supported.
+func (c *Client) SetEncodings(encs ...Encoding) error {
+	return c.send(&vnc.SetEncodings{EncNum: uint16(len(encs)), Encodings: encs})
+}
+
+// RequestUpdate asks the server for a framebuffer update of the whole screen.
+// If incremental is true, the server only sends what has changed.
+func (c *Client) RequestUpdate(incremental bool) error {
+	var inc uint8
+	if incremental {
+		inc = 1
+	}
+	return c.send(&vnc.FramebufferUpdateRequest{Inc: inc, Width: c.client.Width(), Height: c.client.Height()})
+}
+
+// ScreenSize returns the size of the remote screen in pixels.
+func (c *Client) ScreenSize() image.Point {
+	return image.Pt(int(c.client.Width()), int(c.client.Height()))
+}
+
+// Screenshot returns a copy of the screen as last updated. It is blank until the first update arrives.
+func (c *Client) Screenshot() *image.RGBA {
+	screen := c.lastScreen()
+	if screen == nil {
+		return image.NewRGBA(image.Rectangle{Max: c.ScreenSize()})
+	}
+	return copyImage(screen)
+}
+
+// lastScreen returns the copy of the canvas made after the last framebuffer update, which must not be modified.
+func (c *Client) lastScreen() *image.RGBA {
+	c.canvasLock.Lock()
+	defer c.canvasLock.Unlock()
+	return c.screen
+}
+
+func latin1ToString(latin1 []byte) string {
+	runes := make([]rune, len(latin1))
+	for i, b := range latin1 {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// copyImage copies img to an opaque RGBA image. vnc2video's canvas gets colors with an alpha of 1 from the server.
+func copyImage(img image.Image) *image.RGBA {
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Rect, img, img.Bounds().Min, draw.Src)
+	for i := 3; i < len(rgba.Pix); i += 4 {
+		rgba.Pix[i] = 255
+	}
+	return rgba
+}
@@ -0,0 +1,174 @@
+package zvnc
+
+import (
+	vnc "github.com/torlangballe/vnc2video"
+	"github.com/torlangballe/zutil/zgeo"
+)
+
+// Key is an X11 keysym, as sent in VNC key events. Latin-1 characters are their own keysym.
+type Key uint32
+
+const (
+	KeyBackSpace  Key = 0xff08
+	KeyTab        Key = 0xff09
+	KeyReturn     Key = 0xff0d
+	KeyEscape     Key = 0xff1b
+	KeyDelete     Key = 0xffff
+	KeyHome       Key = 0xff50
+	KeyLeftArrow  Key = 0xff51
+	KeyUpArrow    Key = 0xff52
+	KeyRightArrow Key = 0xff53
+	KeyDownArrow  Key = 0xff54
+	KeyPageUp     Key = 0xff55
+	KeyPageDown   Key = 0xff56
+	KeyEnd        Key = 0xff57
+	KeyInsert     Key = 0xff63
+	KeyF1         Key = 0xffbe // F2-F12 follow
+	KeyShift      Key = 0xffe1
+	KeyControl    Key = 0xffe3
+	KeyMeta       Key = 0xffe7
+	KeyAlt        Key = 0xffe9
+	KeySuper      Key = 0xffeb
+)
+
+// MouseButton is a mask of pointer buttons.
+type MouseButton uint8
+
+const (
+	MouseLeft MouseButton = 1 << iota
+	MouseMiddle
+	MouseRight
+	MouseScrollUp
+	MouseScrollDown
+	MouseNone MouseButton = 0
+)
+
+// KeyForRune returns the keysym for r, mapping newline, tab and backspace to their keys.
+func KeyForRune(r rune) Key {
+	switch r {
+	case '\n', '\r':
+		return KeyReturn
+	case '\t':
+		return KeyTab
+	case '\b':
+		return KeyBackSpace
+	}
+	if r < 0x100 {
+		return Key(r)
+	}
+	return Key(0x01000000 + r)
+}
+
+func (c *Client) sendKey(key Key, down bool) error {
+	var d uint8
+	if down {
+		d = 1
+	}
+	c.record(Event{Type: EventKey, Key: key, Down: down})
+	return c.send(&vnc.KeyEvent{Down: d, Key: vnc.Key(key)})
+}
+
+func (c *Client) KeyDown(key Key) error {
+	return c.sendKey(key, true)
+}
+
+func (c *Client) KeyUp(key Key) error {
+	return c.sendKey(key, false)
+}
+
+// KeyPress presses and releases key, with modifiers like KeyShift held down.
+func (c *Client) KeyPress(key Key, modifiers ...Key) error {
+	for _, m := range modifiers {
+		err := c.KeyDown(m)
+		if err != nil {
+			return err
+		}
+	}
+	err := c.KeyDown(key)
+	if err == nil {
+		err = c.KeyUp(key)
+	}
+	for i := len(modifiers) - 1; i >= 0; i-- {
+		e := c.KeyUp(modifiers[i])
+		if err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// TypeText presses the key for each rune in text.
+func (c *Client) TypeText(text string) error {
+	for _, r := range text {
+		err := c.KeyPress(KeyForRune(r))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) sendPointer(pos zgeo.Pos, buttons MouseButton) error {
+	c.lock.Lock()
+	c.buttons = buttons
+	c.lock.Unlock()
+	c.record(Event{Type: EventPointer, Pos: pos, Buttons: buttons})
+	return c.send(&vnc.PointerEvent{Mask: uint8(buttons), X: uint16(pos.X), Y: uint16(pos.Y)})
+}
+
+func (c *Client) currentButtons() MouseButton {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.buttons
+}
+
+// MouseMove moves the pointer to pos, keeping any buttons pressed.
+func (c *Client) MouseMove(pos zgeo.Pos) error {
+	return c.sendPointer(pos, c.currentButtons())
+}
+
+func (c *Client) MouseDown(pos zgeo.Pos, button MouseButton) error {
+	return c.sendPointer(pos, c.currentButtons()|button)
+}
+
+func (c *Client) MouseUp(pos zgeo.Pos, button MouseButton) error {
+	return c.sendPointer(pos, c.currentButtons()&^button)
+}
+
+// Click presses and releases button at pos.
+func (c *Client) Click(pos zgeo.Pos, button MouseButton) error {
+	err := c.MouseDown(pos, button)
+	if err != nil {
+		return err
+	}
+	return c.MouseUp(pos, button)
+}
+
+// Scroll scrolls at pos, up if steps is negative and down if positive, one wheel click per step.
+func (c *Client) Scroll(pos zgeo.Pos, steps int) error {
+	button := MouseScrollDown
+	if steps < 0 {
+		button = MouseScrollUp
+		steps = -steps
+	}
+	for i := 0; i < steps; i++ {
+		err := c.Click(pos, button)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetClipboard sets the server's clipboard to text. VNC only supports Latin-1 text, other characters are replaced with ?.
+func (c *Client) SetClipboard(text string) error {
+	c.record(Event{Type: EventClipboard, Text: text})
+	latin1 := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r >= 0x100 {
+			r = '?'
+		}
+		latin1 = append(latin1, byte(r))
+	}
+	return c.send(&vnc.ClientCutText{Length: uint32(len(latin1)), Text: latin1})
+}
@@ -0,0 +1,177 @@
+package zvnc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/torlangballe/zutil/zgeo"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+type EventType string
+
+const (
+	EventFrame           EventType = "frame"
+	EventKey             EventType = "key"
+	EventPointer         EventType = "pointer"
+	EventClipboard       EventType = "clipboard"        // text the client put on the server's clipboard
+	EventServerClipboard EventType = "server-clipboard" // text the server put on its clipboard
+)
+
+// Event is a recorded screen frame or input event. At is the time since the recording started.
+// A recording file has an Event as json on each line, frames are PNG images, only stored when the screen changed.
+type Event struct {
+	Type    EventType
+	At      time.Duration
+	Key     Key
+	Down    bool
+	Pos     zgeo.Pos
+	Buttons MouseButton
+	Text    string
+	PNG     []byte
+}
+
+type recorder struct {
+	lock      sync.Mutex
+	file      *os.File
+	writer    *bufio.Writer
+	start     time.Time
+	lastFrame []byte
+}
+
+func newRecorder(fpath string) (*recorder, error) {
+	file, err := os.Create(fpath)
+	if err != nil {
+		return nil, zlog.Error("create recording", fpath, err)
+	}
+	r := &recorder{file: file, writer: bufio.NewWriter(file), start: time.Now()}
+	return r, nil
+}
+
+func (r *recorder) add(e Event) {
+	e.At = time.Since(r.start)
+	data, err := json.Marshal(e)
+	if zlog.OnError(err) {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.writer.Write(append(data, '\n'))
+}
+
+// addFrame adds img as a frame if it is different from the last one added.
+func (r *recorder) addFrame(img image.Image) {
+	rgba := copyImage(img)
+	r.lock.Lock()
+	same := bytes.Equal(rgba.Pix, r.lastFrame)
+	if !same {
+		r.lastFrame = rgba.Pix
+	}
+	r.lock.Unlock()
+	if same {
+		return
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, rgba)
+	if zlog.OnError(err) {
+		return
+	}
+	r.add(Event{Type: EventFrame, PNG: buf.Bytes()})
+}
+
+func (r *recorder) close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	err := r.writer.Flush()
+	if err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+func (c *Client) record(e Event) {
+	if c.recorder != nil {
+		c.recorder.add(e)
+	}
+}
+
+// ReadRecording reads the events of a recording made with Options.RecordPath.
+func ReadRecording(fpath string) ([]Event, error) {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return nil, zlog.Error("open", fpath, err)
+	}
+	defer file.Close()
+	var events []Event
+	decoder := json.NewDecoder(file)
+	for {
+		var e Event
+		err := decoder.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events, zlog.Error("decode", fpath, len(events), err)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// Image decodes the PNG of a frame event.
+func (e Event) Image() (image.Image, error) {
+	return png.Decode(bytes.NewReader(e.PNG))
+}
+
+// FrameAt returns the last frame recorded at or before at, or nil if none.
+func FrameAt(events []Event, at time.Duration) *Event {
+	var frame *Event
+	for i, e := range events {
+		if e.At > at {
+			break
+		}
+		if e.Type == EventFrame {
+			frame = &events[i]
+		}
+	}
+	return frame
+}
+
+// Replay sends the key, pointer and clipboard events in events to c, at their recorded times divided by speed.
+// If speed is 0, they are sent as fast as possible. It stops early if stop is closed.
+func Replay(c *Client, events []Event, speed float64, stop <-chan struct{}) error {
+	start := time.Now()
+	for _, e := range events {
+		if speed != 0 {
+			wait := time.Duration(float64(e.At)/speed) - time.Since(start)
+			if wait > 0 {
+				select {
+				case <-stop:
+					return nil
+				case <-time.After(wait):
+				}
+			}
+		}
+		var err error
+		switch e.Type {
+		case EventKey:
+			err = c.sendKey(e.Key, e.Down)
+		case EventPointer:
+			err = c.sendPointer(e.Pos, e.Buttons)
+		case EventClipboard:
+			err = c.SetClipboard(e.Text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package zvnc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	vnc "github.com/torlangballe/vnc2video"
+	"github.com/torlangballe/zutil/zgeo"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// StandInServer is a minimal in-process VNC server, for testing clients without a real machine.
+// It speaks RFB 3.8 with VNC password authentication, sends its image as raw 32-bit frames on every update request,
+// and remembers the key, pointer and clipboard events it receives.
+type StandInServer struct {
+	listener  net.Listener
+	password  string
+	start     time.Time
+	lock      sync.Mutex
+	image     *image.RGBA
+	events    []Event
+	encodings []Encoding
+	conns     map[*standInConn]bool
+}
+
+type standInConn struct {
+	conn      net.Conn
+	writeLock sync.Mutex
+}
+
+// NewStandInServer starts a server with a width x height screen filled with white, listening on a free localhost port.
+func NewStandInServer(width, height int, password string) (*StandInServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, zlog.Error("listen", err)
+	}
+	s := &StandInServer{listener: listener, password: password, start: time.Now(), conns: map[*standInConn]bool{}}
+	s.image = image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(s.image, s.image.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+	go s.accept()
+	return s, nil
+}
+
+// Address is the host:port to connect to.
+func (s *StandInServer) Address() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops listening and closes all connections.
+func (s *StandInServer) Close() {
+	s.listener.Close()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for sc := range s.conns {
+		sc.conn.Close()
+	}
+}
+
+// SetImage sets the screen, which is sent on the next update request. It must be the size the server was made with.
+func (s *StandInServer) SetImage(img image.Image) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	draw.Draw(s.image, s.image.Rect, img, img.Bounds().Min, draw.Src)
+}
+
+// SendClipboard sends text as the server's clipboard to all connected clients.
+func (s *StandInServer) SendClipboard(text string) {
+	var buf bytes.Buffer
+	buf.Write([]byte{byte(vnc.ServerCutTextMsgType), 0, 0, 0})
+	binary.Write(&buf, binary.BigEndian, uint32(len(text)))
+	for _, r := range text {
+		buf.WriteByte(byte(r))
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for sc := range s.conns {
+		zlog.OnError(sc.write(buf.Bytes()))
+	}
+}
+
+// Events returns the key, pointer and clipboard events received so far.
+func (s *StandInServer) Events() []Event {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Event{}, s.events...)
+}
+
+// Encodings returns the encodings the last client asked for.
+func (s *StandInServer) Encodings() []Encoding {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Encoding{}, s.encodings...)
+}
+
+func (s *StandInServer) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			sc := &standInConn{conn: conn}
+			err := s.handshake(sc)
+			if err != nil {
+				zlog.Info("vnc stand-in handshake:", err)
+				conn.Close()
+				return
+			}
+			s.lock.Lock()
+			s.conns[sc] = true
+			s.lock.Unlock()
+			err = s.serve(sc)
+			if err != nil && err != io.EOF {
+				zlog.Info("vnc stand-in:", err)
+			}
+			s.lock.Lock()
+			delete(s.conns, sc)
+			s.lock.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (sc *standInConn) write(data []byte) error {
+	sc.writeLock.Lock()
+	defer sc.writeLock.Unlock()
+	_, err := sc.conn.Write(data)
+	return err
+}
+
+func (s *StandInServer) handshake(sc *standInConn) error {
+	conn := sc.conn
+	version := make([]byte, 12)
+	err := sc.write([]byte("RFB 003.008\n"))
+	if err == nil {
+		_, err = io.ReadFull(conn, version)
+	}
+	if err != nil {
+		return err
+	}
+	secType := make([]byte, 1)
+	err = sc.write([]byte{1, byte(vnc.SecTypeVNC)})
+	if err == nil {
+		_, err = io.ReadFull(conn, secType)
+	}
+	if err != nil {
+		return err
+	}
+	if secType[0] != byte(vnc.SecTypeVNC) {
+		return zlog.NewError("unsupported security type:", secType[0])
+	}
+	challenge := make([]byte, 16)
+	rand.Read(challenge)
+	response := make([]byte, 16)
+	err = sc.write(challenge)
+	if err == nil {
+		_, err = io.ReadFull(conn, response)
+	}
+	if err != nil {
+		return err
+	}
+	expected, err := vnc.AuthVNCEncode([]byte(s.password), append([]byte{}, challenge...)) // it encrypts in place
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(response, expected) {
+		reason := "authentication failed"
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.BigEndian, []uint32{1, uint32(len(reason))})
+		buf.WriteString(reason)
+		sc.write(buf.Bytes())
+		return zlog.NewError(reason)
+	}
+	shared := make([]byte, 1)
+	err = sc.write([]byte{0, 0, 0, 0})
+	if err == nil {
+		_, err = io.ReadFull(conn, shared)
+	}
+	if err != nil {
+		return err
+	}
+	const name = "zvnc stand-in"
+	s.lock.Lock()
+	size := s.image.Rect.Size()
+	s.lock.Unlock()
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{uint16(size.X), uint16(size.Y)})
+	binary.Write(&buf, binary.BigEndian, vnc.PixelFormat32bit)
+	binary.Write(&buf, binary.BigEndian, uint32(len(name)))
+	buf.WriteString(name)
+	return sc.write(buf.Bytes())
+}
+
+// serve reads client messages until the connection closes.
+// The client is assumed to use vnc.PixelFormat32bit, which zvnc's Client always asks for.
+func (s *StandInServer) serve(sc *standInConn) error {
+	r := sc.conn
+	for {
+		var msgType uint8
+		err := binary.Read(r, binary.BigEndian, &msgType)
+		if err != nil {
+			return err
+		}
+		switch vnc.ClientMessageType(msgType) {
+		case vnc.SetPixelFormatMsgType:
+			_, err = io.ReadFull(r, make([]byte, 3+16))
+
+		case vnc.SetEncodingsMsgType:
+			var head struct {
+				_     uint8
+				Count uint16
+			}
+			err = binary.Read(r, binary.BigEndian, &head)
+			if err == nil {
+				encs := make([]Encoding, head.Count)
+				err = binary.Read(r, binary.BigEndian, encs)
+				s.lock.Lock()
+				s.encodings = encs
+				s.lock.Unlock()
+			}
+
+		case vnc.FramebufferUpdateRequestMsgType:
+			_, err = io.ReadFull(r, make([]byte, 9))
+			if err == nil {
+				err = sc.write(s.frame())
+			}
+
+		case vnc.KeyEventMsgType:
+			var key struct {
+				Down uint8
+				_    [2]byte
+				Key  uint32
+			}
+			err = binary.Read(r, binary.BigEndian, &key)
+			s.addEvent(Event{Type: EventKey, Key: Key(key.Key), Down: key.Down != 0})
+
+		case vnc.PointerEventMsgType:
+			var pointer struct {
+				Mask uint8
+				X, Y uint16
+			}
+			err = binary.Read(r, binary.BigEndian, &pointer)
+			s.addEvent(Event{Type: EventPointer, Pos: zgeo.PosI(int(pointer.X), int(pointer.Y)), Buttons: MouseButton(pointer.Mask)})
+
+		case vnc.ClientCutTextMsgType:
+			var head struct {
+				_      [3]byte
+				Length uint32
+			}
+			err = binary.Read(r, binary.BigEndian, &head)
+			if err == nil {
+				text := make([]byte, head.Length)
+				_, err = io.ReadFull(r, text)
+				s.addEvent(Event{Type: EventClipboard, Text: latin1ToString(text)})
+			}
+
+		default:
+			return zlog.NewError("unknown client message type:", msgType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *StandInServer) addEvent(e Event) {
+	e.At = time.Since(s.start)
+	s.lock.Lock()
+	s.events = append(s.events, e)
+	s.lock.Unlock()
+}
+
+// frame returns a framebuffer update message with the whole screen as one raw rectangle.
+func (s *StandInServer) frame() []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	size := s.image.Rect.Size()
+	var buf bytes.Buffer
+	buf.Write([]byte{byte(vnc.FramebufferUpdateMsgType), 0})
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0, 0, uint16(size.X), uint16(size.Y)})
+	binary.Write(&buf, binary.BigEndian, int32(vnc.EncRaw))
+	for i := 0; i < len(s.image.Pix); i += 4 {
+		p := s.image.Pix[i : i+4]
+		buf.Write([]byte{p[2], p[1], p[0], 0}) // little-endian with red shifted 16, green 8 and blue 0
+	}
+	return buf.Bytes()
+}
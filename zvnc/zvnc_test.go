@@ -0,0 +1,112 @@
+package zvnc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/torlangballe/zutil/zgeo"
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+func waitFor(t *testing.T, what string, check func() bool) {
+	for i := 0; i < 200; i++ {
+		if check() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for", what)
+}
+
+func TestStandIn(t *testing.T) {
+	server, err := NewStandInServer(40, 30, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	_, err = Connect(server.Address(), "wrong", 1, nil)
+	ztesting.Equal(t, err != nil, true, "wrong password fails")
+
+	red := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	draw.Draw(red, red.Rect, image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	server.SetImage(red)
+
+	fpath := filepath.Join(t.TempDir(), "session.jsonl")
+	clipboard := make(chan string, 1)
+	opts := Options{
+		Password:        "secret",
+		UpdateSecs:      0.05,
+		Encodings:       []Encoding{EncodingRaw},
+		RecordPath:      fpath,
+		HandleClipboard: func(text string) { clipboard <- text },
+	}
+	c, err := ConnectWithOptions(server.Address(), opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, c.ScreenSize(), image.Pt(40, 30), "screen size")
+	waitFor(t, "red screen", func() bool {
+		return c.Screenshot().RGBAAt(5, 5) == color.RGBA{R: 255, A: 255}
+	})
+	waitFor(t, "encodings", func() bool {
+		encs := server.Encodings()
+		return len(encs) == 1 && encs[0] == EncodingRaw
+	})
+
+	c.TypeText("Aæ")
+	c.KeyPress(KeyReturn, KeyShift)
+	c.Click(zgeo.PosI(10, 20), MouseLeft)
+	c.SetClipboard("hello")
+	server.SendClipboard("from server")
+	ztesting.Equal(t, <-clipboard, "from server", "server clipboard")
+
+	waitFor(t, "events", func() bool { return len(server.Events()) == 11 })
+	events := server.Events()
+	ztesting.Equal(t, events[0].Key, Key('A'), "key A")
+	ztesting.Equal(t, events[2].Key, Key(0xe6), "latin-1 key")
+	ztesting.Equal(t, events[4].Key, KeyShift, "modifier first")
+	ztesting.Equal(t, events[8].Pos, zgeo.PosI(10, 20), "pointer pos")
+	ztesting.Equal(t, events[8].Buttons, MouseLeft, "button down")
+	ztesting.Equal(t, events[9].Buttons, MouseNone, "button up")
+	ztesting.Equal(t, events[10].Text, "hello", "client clipboard")
+	c.Close()
+
+	recorded, err := ReadRecording(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames, inputs int
+	for _, e := range recorded {
+		switch e.Type {
+		case EventFrame:
+			frames++
+		case EventKey, EventPointer, EventClipboard:
+			inputs++
+		}
+	}
+	ztesting.Equal(t, frames, 1, "unchanged frames not repeated")
+	ztesting.Equal(t, inputs, 11, "recorded inputs")
+	frame := FrameAt(recorded, recorded[len(recorded)-1].At)
+	img, err := frame.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, color.RGBAModel.Convert(img.At(1, 1)), color.Color(color.RGBA{R: 255, A: 255}), "recorded frame")
+
+	c, err = Connect(server.Address(), "secret", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	err = Replay(c, recorded, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "replayed events", func() bool { return len(server.Events()) == 22 })
+	ztesting.Equal(t, server.Events()[21].Text, "hello", "replayed clipboard")
+}
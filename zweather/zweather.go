@@ -1,9 +1,18 @@
 package zweather
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"time"
+
+	"github.com/torlangballe/zutil/zgeo"
 )
 
+// Forcast is the weather at Time. Precipitation and SymbolCode are for the Duration after Time,
+// which is an hour for the first few days and longer further ahead.
+// SymbolCode is a met.no symbol like "partlycloudy_day" or "heavyrain", other providers' codes are converted to these.
 type Forcast struct {
 	Time                     time.Time
 	Duration                 time.Duration
@@ -16,3 +25,156 @@ type Forcast struct {
 	WindSpeedMPS             float64 `json:"wind_speed"`
 	SymbolCode               string
 }
+
+// DayForcast summarizes a calendar day's forcasts.
+type DayForcast struct {
+	Date                  time.Time // midnight at the start of the day
+	MinTemperatureCelcius float64
+	MaxTemperatureCelcius float64
+	PrecipitationAmountMM float64
+	MaxWindSpeedMPS       float64
+	SymbolCode            string
+	Sun                   SunInfo
+}
+
+// Weather is the normalized forcast from a Provider.
+type Weather struct {
+	Provider  string
+	Pos       zgeo.Pos
+	UpdatedAt time.Time
+	Hourly    []Forcast
+	Daily     []DayForcast
+}
+
+// Provider is a weather service that can give forcasts for a position, with longitude as X and latitude as Y.
+type Provider interface {
+	Name() string
+	HourlyForcasts(pos zgeo.Pos) (forcasts []Forcast, updatedAt time.Time, err error)
+}
+
+// GetWeather gets forcasts for pos from the first of providers that succeeds, with daily summaries for days in loc.
+func GetWeather(pos zgeo.Pos, loc *time.Location, providers ...Provider) (*Weather, error) {
+	if len(providers) == 0 {
+		providers = []Provider{&MetNoWeather{}, &OpenMeteoWeather{}}
+	}
+	var errs []error
+	for _, p := range providers {
+		hourly, updated, err := p.HourlyForcasts(pos)
+		if err == nil && len(hourly) == 0 {
+			err = fmt.Errorf("%s: no forcasts", p.Name())
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		w := &Weather{Provider: p.Name(), Pos: pos, UpdatedAt: updated, Hourly: hourly}
+		w.Daily = DailyForcasts(hourly, pos, loc)
+		return w, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Aggregate combines forcasts into periods of period length starting at multiples of it in loc, like 3 or 6 hours.
+// Temperature, pressure, humidity and clouds are averaged, precipitation summed, and the strongest wind kept.
+// The symbol is that of the wettest forcast, or the most common if none have precipitation.
+func Aggregate(forcasts []Forcast, period time.Duration, loc *time.Location) []Forcast {
+	var out []Forcast
+	var group []Forcast
+	var groupStart time.Time
+	for _, f := range forcasts {
+		start := periodStart(f.Time.In(loc), period)
+		if len(group) != 0 && !start.Equal(groupStart) {
+			out = append(out, combine(group, groupStart, period))
+			group = nil
+		}
+		groupStart = start
+		group = append(group, f)
+	}
+	if len(group) != 0 {
+		out = append(out, combine(group, groupStart, period))
+	}
+	return out
+}
+
+func periodStart(t time.Time, period time.Duration) time.Time {
+	y, m, d := t.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	if period >= 24*time.Hour {
+		return midnight
+	}
+	return midnight.Add(t.Sub(midnight) / period * period)
+}
+
+func combine(group []Forcast, start time.Time, period time.Duration) Forcast {
+	c := Forcast{Time: start, Duration: period}
+	n := float64(len(group))
+	var wettest Forcast
+	for _, f := range group {
+		c.AirPressureAtSeaLevelHPA += f.AirPressureAtSeaLevelHPA / n
+		c.AirTemperatureCelcius += f.AirTemperatureCelcius / n
+		c.CloudAreaFractionPercent += f.CloudAreaFractionPercent / n
+		c.RelativeHumidityPercent += f.RelativeHumidityPercent / n
+		c.PrecipitationAmountMM += f.PrecipitationAmountMM
+		if f.WindSpeedMPS >= c.WindSpeedMPS {
+			c.WindSpeedMPS = f.WindSpeedMPS
+			c.WindFromDirectionDegrees = f.WindFromDirectionDegrees
+		}
+		if f.PrecipitationAmountMM > wettest.PrecipitationAmountMM {
+			wettest = f
+		}
+	}
+	c.SymbolCode = wettest.SymbolCode
+	if c.SymbolCode == "" {
+		c.SymbolCode = mostCommonSymbol(group)
+	}
+	return c
+}
+
+func mostCommonSymbol(forcasts []Forcast) string {
+	counts := map[string]int{}
+	var best string
+	for _, f := range forcasts {
+		if f.SymbolCode == "" {
+			continue
+		}
+		counts[f.SymbolCode]++
+		if counts[f.SymbolCode] > counts[best] {
+			best = f.SymbolCode
+		}
+	}
+	return best
+}
+
+// DailyForcasts summarizes forcasts per calendar day in loc, with sunrise and sunset at pos.
+// The symbol is the day's most common, preferring daytime forcasts.
+func DailyForcasts(forcasts []Forcast, pos zgeo.Pos, loc *time.Location) []DayForcast {
+	byDay := map[time.Time][]Forcast{}
+	for _, f := range forcasts {
+		day := periodStart(f.Time.In(loc), 24*time.Hour)
+		byDay[day] = append(byDay[day], f)
+	}
+	var days []DayForcast
+	for day, group := range byDay {
+		d := DayForcast{Date: day, MinTemperatureCelcius: math.Inf(1), MaxTemperatureCelcius: math.Inf(-1)}
+		var daytime []Forcast
+		for _, f := range group {
+			d.MinTemperatureCelcius = min(d.MinTemperatureCelcius, f.AirTemperatureCelcius)
+			d.MaxTemperatureCelcius = max(d.MaxTemperatureCelcius, f.AirTemperatureCelcius)
+			d.PrecipitationAmountMM += f.PrecipitationAmountMM
+			d.MaxWindSpeedMPS = max(d.MaxWindSpeedMPS, f.WindSpeedMPS)
+			if h := f.Time.In(loc).Hour(); h >= 6 && h < 18 {
+				daytime = append(daytime, f)
+			}
+		}
+		if len(daytime) == 0 {
+			daytime = group
+		}
+		d.SymbolCode = mostCommonSymbol(daytime)
+		d.Sun = SunTimes(pos, day)
+		days = append(days, d)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Date.Before(days[j].Date)
+	})
+	return days
+}
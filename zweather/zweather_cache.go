@@ -0,0 +1,99 @@
+package zweather
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/torlangballe/zutil/zhttp"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// CachedResponse is a response body and the caching headers it came with.
+type CachedResponse struct {
+	Data         []byte
+	Expires      time.Time
+	LastModified time.Time
+}
+
+// Cache stores responses from weather providers by url.
+// met.no requires clients to not fetch again before Expires, and to use If-Modified-Since after.
+type Cache interface {
+	Get(key string) (CachedResponse, bool)
+	Put(key string, r CachedResponse)
+}
+
+type MemoryCache struct {
+	lock      sync.Mutex
+	responses map[string]CachedResponse
+}
+
+// DefaultCache is used by providers with no Cache set.
+var DefaultCache Cache = NewMemoryCache()
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{responses: map[string]CachedResponse{}}
+}
+
+func (c *MemoryCache) Get(key string) (CachedResponse, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	r, got := c.responses[key]
+	return r, got
+}
+
+func (c *MemoryCache) Put(key string, r CachedResponse) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.responses[key] = r
+}
+
+func parseHTTPTime(header http.Header, key string) time.Time {
+	t, err := http.ParseTime(header.Get(key))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// getCached gets surl, using a cached response if it hasn't expired, or if the server says it isn't modified.
+// If the server gives no Expires header, responses are used for minTTL.
+// If the request fails and there is a cached response, it is returned with the error logged.
+func getCached(cache Cache, surl string, params zhttp.Parameters, minTTL time.Duration) ([]byte, error) {
+	if cache == nil {
+		cache = DefaultCache
+	}
+	cached, got := cache.Get(surl)
+	now := time.Now()
+	if got && now.Before(cached.Expires) {
+		return cached.Data, nil
+	}
+	if got && !cached.LastModified.IsZero() {
+		params.Headers["If-Modified-Since"] = cached.LastModified.UTC().Format(http.TimeFormat)
+	}
+	var data []byte
+	resp, err := zhttp.Get(surl, params, &data)
+	if resp != nil && resp.StatusCode == http.StatusNotModified && got {
+		cached.Expires = parseHTTPTime(resp.Header, "Expires")
+		if cached.Expires.IsZero() {
+			cached.Expires = now.Add(minTTL)
+		}
+		cache.Put(surl, cached)
+		return cached.Data, nil
+	}
+	if err != nil {
+		if got {
+			zlog.Error("weather request failed, using cached", surl, err)
+			return cached.Data, nil
+		}
+		return nil, err
+	}
+	r := CachedResponse{Data: data}
+	r.Expires = parseHTTPTime(resp.Header, "Expires")
+	if r.Expires.IsZero() {
+		r.Expires = now.Add(minTTL)
+	}
+	r.LastModified = parseHTTPTime(resp.Header, "Last-Modified")
+	cache.Put(surl, r)
+	return data, nil
+}
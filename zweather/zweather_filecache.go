@@ -0,0 +1,46 @@
+//go:build server
+
+package zweather
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+
+	"github.com/torlangballe/zutil/zfilecache"
+)
+
+// FileCache stores responses in a zfilecache, so they survive restarts.
+type FileCache struct {
+	cache *zfilecache.Cache
+}
+
+func NewFileCache(cache *zfilecache.Cache) *FileCache {
+	return &FileCache{cache: cache}
+}
+
+func (c *FileCache) name(key string) string {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return fmt.Sprintf("%016x.json", h.Sum64())
+}
+
+func (c *FileCache) Get(key string) (CachedResponse, bool) {
+	var r CachedResponse
+	fpath, _ := c.cache.GetPathForName(c.name(key))
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return r, false
+	}
+	err = json.Unmarshal(data, &r)
+	return r, err == nil
+}
+
+func (c *FileCache) Put(key string, r CachedResponse) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	c.cache.CacheFromData(data, c.name(key))
+}
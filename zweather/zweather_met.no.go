@@ -1,16 +1,31 @@
 package zweather
 
 import (
-	"fmt"
+	"cmp"
+	"encoding/json"
+	"strconv"
 	"time"
 
 	"github.com/torlangballe/zutil/zgeo"
 	"github.com/torlangballe/zutil/zhttp"
+	"github.com/torlangballe/zutil/zlog"
 )
 
 // https://api.met.no/weatherapi/locationforecast/2.0/documentation
 
-type MetNoWeather struct{}
+// MetNoWeather is a Provider using met.no's locationforecast, which covers the whole world,
+// with most detail in the Nordic countries. It follows their terms: identifying User-Agent,
+// coordinates with at most 4 decimals, and caching until Expires, then using If-Modified-Since.
+type MetNoWeather struct {
+	UserAgent string // must identify the application and a contact, MetNoUserAgent if empty
+	Cache     Cache  // DefaultCache if nil
+	URL       string // MetNoURL if empty
+}
+
+var (
+	MetNoUserAgent = "UserAgent-etheros.online"
+	MetNoURL       = "https://api.met.no/weatherapi/locationforecast/2.0/compact"
+)
 
 type Measurements struct {
 	AirPressureAtSeaLevel float64 `json:"air_pressure_at_sea_level"`
@@ -66,35 +81,62 @@ type LocationForcast struct {
 	} `json:"properties"`
 }
 
-func GetLocalForcast(geoPos zgeo.Pos) ([]Forcast, error) {
+func (m *MetNoWeather) Name() string {
+	return "met.no"
+}
+
+func (m *MetNoWeather) HourlyForcasts(geoPos zgeo.Pos) ([]Forcast, time.Time, error) {
 	var loc LocationForcast
-	var forcasts []Forcast
-	surl := "https://api.met.no/weatherapi/locationforecast/2.0/compact"
+	surl := m.URL
+	if surl == "" {
+		surl = MetNoURL
+	}
 	args := map[string]string{
-		"lat": fmt.Sprint(geoPos.Y),
-		"lon": fmt.Sprint(geoPos.X),
+		"lat": strconv.FormatFloat(geoPos.Y, 'f', 4, 64),
+		"lon": strconv.FormatFloat(geoPos.X, 'f', 4, 64),
 	}
 	surl, _ = zhttp.MakeURLWithArgs(surl, args)
 	params := zhttp.MakeParameters()
-	params.Headers["User-Agent"] = "UserAgent-etheros.online"
-	_, err := zhttp.Get(surl, params, &loc)
+	params.Headers["User-Agent"] = cmp.Or(m.UserAgent, MetNoUserAgent)
+	data, err := getCached(m.Cache, surl, params, 0)
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
+	err = json.Unmarshal(data, &loc)
+	if err != nil {
+		return nil, time.Time{}, zlog.Error("unmarshal", err)
+	}
+	var forcasts []Forcast
 	for _, t := range loc.Properties.Timeseries {
 		var f Forcast
 		f.Time = t.Time
-		f.Duration = time.Hour
 		f.AirPressureAtSeaLevelHPA = t.Data.Instant.Details.AirPressureAtSeaLevel
 		f.AirTemperatureCelcius = t.Data.Instant.Details.AirTemperature
 		f.CloudAreaFractionPercent = t.Data.Instant.Details.CloudAreaFraction
-		f.PrecipitationAmountMM = t.Data.Instant.Details.PrecipitationAmount
-
 		f.RelativeHumidityPercent = t.Data.Instant.Details.RelativeHumidity
 		f.WindFromDirectionDegrees = t.Data.Instant.Details.WindFromDirection
 		f.WindSpeedMPS = t.Data.Instant.Details.WindSpeed
-		f.SymbolCode = t.Data.Next1Hours.Summary.SymbolCode
+		// The first days have hourly periods, later ones 6 hours, and the last entry may have none.
+		switch {
+		case t.Data.Next1Hours.Summary.SymbolCode != "":
+			f.Duration = time.Hour
+			f.PrecipitationAmountMM = t.Data.Next1Hours.Details.PrecipitationAmount
+			f.SymbolCode = t.Data.Next1Hours.Summary.SymbolCode
+		case t.Data.Next6Hours.Summary.SymbolCode != "":
+			f.Duration = 6 * time.Hour
+			f.PrecipitationAmountMM = t.Data.Next6Hours.Details.PrecipitationAmount
+			f.SymbolCode = t.Data.Next6Hours.Summary.SymbolCode
+		default:
+			continue
+		}
 		forcasts = append(forcasts, f)
 	}
-	return forcasts, nil
+	return forcasts, loc.Properties.Meta.UpdatedAt, nil
+}
+
+// GetLocalForcast gets met.no's forcasts for geoPos.
+func GetLocalForcast(geoPos zgeo.Pos) ([]Forcast, error) {
+	var m MetNoWeather
+	forcasts, _, err := m.HourlyForcasts(geoPos)
+	return forcasts, err
 }
@@ -0,0 +1,159 @@
+package zweather
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/torlangballe/zutil/zgeo"
+	"github.com/torlangballe/zutil/zhttp"
+	"github.com/torlangballe/zutil/zlog"
+)
+
+// https://open-meteo.com/en/docs
+
+// OpenMeteoWeather is a Provider using Open-Meteo, which combines national weather models and needs no key
+// for non-commercial use. Its WMO weather codes are converted to met.no symbol codes.
+type OpenMeteoWeather struct {
+	Cache  Cache  // DefaultCache if nil
+	URL    string // OpenMeteoURL if empty
+	APIKey string // for the commercial API
+	Days   int    // days to forcast, 7 if 0
+}
+
+var OpenMeteoURL = "https://api.open-meteo.com/v1/forecast"
+
+// OpenMeteoCacheTime is how long responses are cached, as Open-Meteo doesn't say. Its models update hourly at most.
+var OpenMeteoCacheTime = 15 * time.Minute
+
+type openMeteoResponse struct {
+	Hourly struct {
+		Time             []int64   `json:"time"`
+		Temperature      []float64 `json:"temperature_2m"`
+		RelativeHumidity []float64 `json:"relative_humidity_2m"`
+		Precipitation    []float64 `json:"precipitation"`
+		CloudCover       []float64 `json:"cloud_cover"`
+		PressureMSL      []float64 `json:"pressure_msl"`
+		WindSpeed        []float64 `json:"wind_speed_10m"`
+		WindDirection    []float64 `json:"wind_direction_10m"`
+		WeatherCode      []int     `json:"weather_code"`
+		IsDay            []int     `json:"is_day"`
+	} `json:"hourly"`
+}
+
+var openMeteoHourly = []string{"temperature_2m", "relative_humidity_2m", "precipitation", "cloud_cover", "pressure_msl", "wind_speed_10m", "wind_direction_10m", "weather_code", "is_day"}
+
+func (o *OpenMeteoWeather) Name() string {
+	return "open-meteo"
+}
+
+func (o *OpenMeteoWeather) HourlyForcasts(geoPos zgeo.Pos) ([]Forcast, time.Time, error) {
+	surl := o.URL
+	if surl == "" {
+		surl = OpenMeteoURL
+	}
+	days := o.Days
+	if days == 0 {
+		days = 7
+	}
+	args := map[string]string{
+		"latitude":        strconv.FormatFloat(geoPos.Y, 'f', 4, 64),
+		"longitude":       strconv.FormatFloat(geoPos.X, 'f', 4, 64),
+		"hourly":          strings.Join(openMeteoHourly, ","),
+		"wind_speed_unit": "ms",
+		"timeformat":      "unixtime",
+		"forecast_days":   strconv.Itoa(days),
+	}
+	if o.APIKey != "" {
+		args["apikey"] = o.APIKey
+	}
+	surl, _ = zhttp.MakeURLWithArgs(surl, args)
+	data, err := getCached(o.Cache, surl, zhttp.MakeParameters(), OpenMeteoCacheTime)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var resp openMeteoResponse
+	err = json.Unmarshal(data, &resp)
+	if err != nil {
+		return nil, time.Time{}, zlog.Error("unmarshal", err)
+	}
+	h := resp.Hourly
+	at := func(vals []float64, i int) float64 {
+		if i < len(vals) {
+			return vals[i]
+		}
+		return 0
+	}
+	var forcasts []Forcast
+	for i, t := range h.Time {
+		var f Forcast
+		f.Time = time.Unix(t, 0).UTC()
+		f.Duration = time.Hour
+		f.AirTemperatureCelcius = at(h.Temperature, i)
+		f.RelativeHumidityPercent = at(h.RelativeHumidity, i)
+		f.CloudAreaFractionPercent = at(h.CloudCover, i)
+		f.AirPressureAtSeaLevelHPA = at(h.PressureMSL, i)
+		f.WindSpeedMPS = at(h.WindSpeed, i)
+		f.WindFromDirectionDegrees = at(h.WindDirection, i)
+		// Open-Meteo's precipitation is for the preceding hour, Forcast's for the following one.
+		f.PrecipitationAmountMM = at(h.Precipitation, i+1)
+		if i < len(h.WeatherCode) {
+			isDay := i >= len(h.IsDay) || h.IsDay[i] == 1
+			f.SymbolCode = SymbolForWMOCode(h.WeatherCode[i], isDay)
+		}
+		forcasts = append(forcasts, f)
+	}
+	return forcasts, time.Now(), nil
+}
+
+var wmoSymbols = map[int]string{
+	0:  "clearsky",
+	1:  "fair",
+	2:  "partlycloudy",
+	3:  "cloudy",
+	45: "fog",
+	48: "fog",
+	51: "lightrain",
+	53: "lightrain",
+	55: "rain",
+	56: "lightsleet",
+	57: "sleet",
+	61: "lightrain",
+	63: "rain",
+	65: "heavyrain",
+	66: "lightsleet",
+	67: "heavysleet",
+	71: "lightsnow",
+	73: "snow",
+	75: "heavysnow",
+	77: "lightsnow",
+	80: "lightrainshowers",
+	81: "rainshowers",
+	82: "heavyrainshowers",
+	85: "lightsnowshowers",
+	86: "heavysnowshowers",
+	95: "rainandthunder",
+	96: "heavyrainandthunder",
+	99: "heavyrainandthunder",
+}
+
+// SymbolForWMOCode converts a WMO weather interpretation code to a met.no symbol code,
+// adding _day or _night to the symbols met.no has variants of.
+func SymbolForWMOCode(code int, isDay bool) string {
+	symbol, got := wmoSymbols[code]
+	if !got {
+		return ""
+	}
+	switch symbol {
+	case "clearsky", "fair", "partlycloudy":
+	default:
+		if !strings.HasSuffix(symbol, "showers") {
+			return symbol
+		}
+	}
+	if isDay {
+		return symbol + "_day"
+	}
+	return symbol + "_night"
+}
@@ -0,0 +1,78 @@
+package zweather
+
+import (
+	"math"
+	"time"
+
+	"github.com/torlangballe/zutil/zgeo"
+)
+
+// SunInfo is when the sun rises and sets on a day. In midnight sun or polar night, Sunrise and Sunset are zero.
+type SunInfo struct {
+	Sunrise     time.Time
+	Sunset      time.Time
+	SolarNoon   time.Time
+	MidnightSun bool
+	PolarNight  bool
+}
+
+const julianUnixEpoch = 2440587.5 // the julian day of 1970-01-01 00:00 UTC
+
+func julianToTime(jd float64) time.Time {
+	return time.UnixMilli(int64(math.Round((jd - julianUnixEpoch) * 86400 * 1000)))
+}
+
+func sinDeg(d float64) float64 {
+	return math.Sin(d * math.Pi / 180)
+}
+
+func cosDeg(d float64) float64 {
+	return math.Cos(d * math.Pi / 180)
+}
+
+// SunTimes calculates sunrise, sunset and solar noon at pos (longitude as X) on date's calendar day,
+// with the sunrise equation, which is accurate to a minute or two away from the polar circles.
+// The times are in date's location.
+func SunTimes(pos zgeo.Pos, date time.Time) SunInfo {
+	var info SunInfo
+	y, m, d := date.Date()
+	noonUTC := time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	n := math.Round(float64(noonUTC.Unix())/86400 + julianUnixEpoch - 2451545.0)
+	jStar := n - pos.X/360 // mean solar noon
+	mean := math.Mod(357.5291+0.98560028*jStar, 360)
+	center := 1.9148*sinDeg(mean) + 0.02*sinDeg(2*mean) + 0.0003*sinDeg(3*mean)
+	lambda := math.Mod(mean+center+180+102.9372, 360) // ecliptic longitude
+	transit := 2451545.0 + jStar + 0.0053*sinDeg(mean) - 0.0069*sinDeg(2*lambda)
+	sinDecl := sinDeg(lambda) * sinDeg(23.4397)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	cosHour := (sinDeg(-0.833) - sinDeg(pos.Y)*sinDecl) / (cosDeg(pos.Y) * cosDecl)
+	loc := date.Location()
+	info.SolarNoon = julianToTime(transit).In(loc)
+	if cosHour < -1 {
+		info.MidnightSun = true
+		return info
+	}
+	if cosHour > 1 {
+		info.PolarNight = true
+		return info
+	}
+	hourAngle := math.Acos(cosHour) * 180 / math.Pi
+	info.Sunrise = julianToTime(transit - hourAngle/360).In(loc)
+	info.Sunset = julianToTime(transit + hourAngle/360).In(loc)
+	return info
+}
+
+// IsDaylight returns true if the sun is up at pos at time t.
+// Days before and after t's UTC day are checked too, as far from Greenwich the sun is up across midnight UTC.
+func IsDaylight(pos zgeo.Pos, t time.Time) bool {
+	for _, days := range []int{0, -1, 1} {
+		info := SunTimes(pos, t.UTC().AddDate(0, 0, days))
+		if days == 0 && (info.MidnightSun || info.PolarNight) {
+			return info.MidnightSun
+		}
+		if !info.Sunrise.IsZero() && !t.Before(info.Sunrise) && t.Before(info.Sunset) {
+			return true
+		}
+	}
+	return false
+}
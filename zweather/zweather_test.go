@@ -0,0 +1,138 @@
+package zweather
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/torlangballe/zutil/zgeo"
+	"github.com/torlangballe/zutil/ztesting"
+)
+
+const metNoJSON = `{"properties":{"meta":{"updated_at":"2026-06-20T10:00:00Z"},"timeseries":[
+{"time":"2026-06-20T10:00:00Z","data":{"instant":{"details":{"air_temperature":14.0,"wind_speed":3.0}},
+	"next_1_hours":{"summary":{"symbol_code":"cloudy"},"details":{"precipitation_amount":0.0}}}},
+{"time":"2026-06-20T11:00:00Z","data":{"instant":{"details":{"air_temperature":16.0,"wind_speed":5.0}},
+	"next_1_hours":{"summary":{"symbol_code":"rain"},"details":{"precipitation_amount":1.5}}}},
+{"time":"2026-06-20T12:00:00Z","data":{"instant":{"details":{"air_temperature":18.0,"wind_speed":4.0}},
+	"next_6_hours":{"summary":{"symbol_code":"lightrain"},"details":{"precipitation_amount":0.5}}}},
+{"time":"2026-06-20T18:00:00Z","data":{"instant":{"details":{"air_temperature":12.0}}}}
+]}}`
+
+func TestMetNo(t *testing.T) {
+	var requests, notModified int
+	modified := time.Date(2026, 6, 20, 10, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		ztesting.Equal(t, req.URL.Query().Get("lat"), "59.9139", "4 decimals")
+		ztesting.Equal(t, req.Header.Get("User-Agent"), "test/1.0 me@example.com", "user agent")
+		if req.Header.Get("If-Modified-Since") != "" {
+			notModified++
+			w.Header().Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Expires", time.Now().Add(-time.Second).UTC().Format(http.TimeFormat)) // expired at once, to test If-Modified-Since
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Write([]byte(metNoJSON))
+	}))
+	defer server.Close()
+
+	m := &MetNoWeather{URL: server.URL, UserAgent: "test/1.0 me@example.com", Cache: NewMemoryCache()}
+	oslo := zgeo.PosD(10.75225, 59.91387)
+	forcasts, updated, err := m.HourlyForcasts(oslo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, updated, modified, "updated")
+	ztesting.Equal(t, len(forcasts), 3, "entries without periods skipped")
+	ztesting.Equal(t, forcasts[1].PrecipitationAmountMM, 1.5, "hourly precipitation")
+	ztesting.Equal(t, forcasts[2].Duration, 6*time.Hour, "6 hour period")
+
+	m.HourlyForcasts(oslo)
+	m.HourlyForcasts(oslo)
+	ztesting.Equal(t, requests, 2, "cached until expires")
+	ztesting.Equal(t, notModified, 1, "if-modified-since")
+
+	w, err := GetWeather(oslo, time.UTC, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, w.Provider, "met.no", "provider")
+	ztesting.Equal(t, len(w.Daily), 1, "days")
+	day := w.Daily[0]
+	ztesting.Equal(t, day.MinTemperatureCelcius, 14.0, "min")
+	ztesting.Equal(t, day.MaxTemperatureCelcius, 18.0, "max")
+	ztesting.Equal(t, day.PrecipitationAmountMM, 2.0, "precipitation sum")
+	ztesting.Equal(t, day.MaxWindSpeedMPS, 5.0, "max wind")
+	ztesting.Equal(t, day.Sun.Sunrise.IsZero(), false, "sunrise")
+}
+
+func TestOpenMeteo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		ztesting.Equal(t, req.URL.Query().Get("wind_speed_unit"), "ms", "wind unit")
+		w.Write([]byte(`{"hourly":{"time":[1781949600,1781953200],"temperature_2m":[20.5,21],"precipitation":[0,0.4],
+			"wind_speed_10m":[2,3],"weather_code":[2,61],"is_day":[0,1]}}`))
+	}))
+	defer server.Close()
+	failing := &MetNoWeather{URL: server.URL + "/missing", Cache: NewMemoryCache()}
+	o := &OpenMeteoWeather{URL: server.URL, Cache: NewMemoryCache()}
+	w, err := GetWeather(zgeo.PosD(10.75, 59.91), time.UTC, failing, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztesting.Equal(t, w.Provider, "open-meteo", "falls back")
+	ztesting.Equal(t, len(w.Hourly), 2, "hours")
+	ztesting.Equal(t, w.Hourly[0].SymbolCode, "partlycloudy_night", "wmo symbol")
+	ztesting.Equal(t, w.Hourly[1].SymbolCode, "lightrain", "no day variant")
+	ztesting.Equal(t, w.Hourly[0].PrecipitationAmountMM, 0.4, "precipitation of following hour")
+}
+
+func TestAggregate(t *testing.T) {
+	start := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	var hourly []Forcast
+	for i := 0; i < 6; i++ {
+		f := Forcast{Time: start.Add(time.Duration(i) * time.Hour), Duration: time.Hour, AirTemperatureCelcius: float64(i), SymbolCode: "cloudy", WindSpeedMPS: float64(i % 4)}
+		if i == 4 {
+			f.PrecipitationAmountMM = 2
+			f.SymbolCode = "snow"
+		}
+		hourly = append(hourly, f)
+	}
+	agg := Aggregate(hourly, 3*time.Hour, time.UTC)
+	ztesting.Equal(t, len(agg), 2, "periods")
+	ztesting.Equal(t, agg[0].AirTemperatureCelcius, 1.0, "average")
+	ztesting.Equal(t, agg[0].SymbolCode, "cloudy", "common symbol")
+	ztesting.Equal(t, agg[1].SymbolCode, "snow", "wettest symbol")
+	ztesting.Equal(t, agg[1].WindSpeedMPS, 3.0, "max wind")
+	ztesting.Equal(t, agg[1].Time, start.Add(3*time.Hour), "period start")
+}
+
+func TestSunAndUnits(t *testing.T) {
+	oslo, _ := time.LoadLocation("Europe/Oslo")
+	sun := SunTimes(zgeo.PosD(10.75, 59.91), time.Date(2026, 6, 21, 0, 0, 0, 0, oslo))
+	near := func(got time.Time, hour, minute int) bool {
+		want := time.Date(2026, 6, 21, hour, minute, 0, 0, oslo)
+		return got.Sub(want).Abs() < 5*time.Minute
+	}
+	ztesting.Equal(t, near(sun.Sunrise, 3, 54), true, "oslo sunrise", sun.Sunrise)
+	ztesting.Equal(t, near(sun.Sunset, 22, 44), true, "oslo sunset", sun.Sunset)
+	tromso := zgeo.PosD(18.96, 69.65)
+	ztesting.Equal(t, SunTimes(tromso, time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC)).MidnightSun, true, "midnight sun")
+	ztesting.Equal(t, SunTimes(tromso, time.Date(2026, 12, 21, 0, 0, 0, 0, time.UTC)).PolarNight, true, "polar night")
+	sydney := zgeo.PosD(151.21, -33.87)
+	ztesting.Equal(t, IsDaylight(sydney, time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)), true, "sydney morning is utc evening")
+	ztesting.Equal(t, IsDaylight(sydney, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)), false, "sydney night")
+
+	ztesting.Equal(t, CelsiusToFahrenheit(100), 212.0, "fahrenheit")
+	ztesting.Equal(t, MPSToKMH(10), 36.0, "km/h")
+	ztesting.Equal(t, Beaufort(0.1), 0, "calm")
+	ztesting.Equal(t, Beaufort(33), 12, "hurricane")
+	ztesting.Equal(t, CompassDirection(350), "N", "north")
+	ztesting.Equal(t, CompassDirection(225), "SW", "south west")
+}
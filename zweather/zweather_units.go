@@ -0,0 +1,43 @@
+package zweather
+
+import "math"
+
+// The Forcast fields are in SI-ish units as met.no gives them; these convert to others for display.
+
+func CelsiusToFahrenheit(c float64) float64 {
+	return c*9/5 + 32
+}
+
+func MPSToKMH(mps float64) float64 {
+	return mps * 3.6
+}
+
+func MPSToMPH(mps float64) float64 {
+	return mps * 2.2369363
+}
+
+func MPSToKnots(mps float64) float64 {
+	return mps * 1.9438445
+}
+
+func MMToInches(mm float64) float64 {
+	return mm / 25.4
+}
+
+func HPAToInHg(hpa float64) float64 {
+	return hpa * 0.02953
+}
+
+// Beaufort returns the Beaufort wind force 0-12 for a wind speed in meters per second.
+func Beaufort(mps float64) int {
+	// v = 0.836 B^(3/2) m/s
+	b := int(math.Round(math.Pow(mps/0.836, 2.0/3)))
+	return min(b, 12)
+}
+
+// CompassDirection returns the 16-point compass direction like "NNE" for degrees, as in WindFromDirectionDegrees.
+func CompassDirection(degrees float64) string {
+	points := []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+	i := int(math.Round(math.Mod(math.Mod(degrees, 360)+360, 360)/22.5)) % 16
+	return points[i]
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/torlangballe/zutil/zbytes"
@@ -29,8 +31,9 @@ type base struct {
 	ID           string
 	Timeout      time.Duration
 	AuthToken    string
-	conn         *websocket.Conn
-	shutdown     bool
+	conn         *websocket.Conn // set and read with connLock, as readForever and Close run in different goroutines
+	connLock     sync.Mutex
+	shutdown     atomic.Bool
 	receiveChans zmap.LockMap[int64, chan message]
 	handlerFunc  func(msg []byte, err error) []byte
 	url          string
@@ -55,7 +58,6 @@ var TimeoutError = errors.New("timeout")
 
 func NewClient(id, url string, handler func([]byte, error) []byte) (*Client, error) {
 	c := &Client{}
-	c.shutdown = false
 	c.sendID = true
 	// zlog.Warn("NewClient:", handler != nil)
 	c.handlerFunc = func(msg []byte, err error) []byte {
@@ -77,8 +79,21 @@ func (b *base) SetHandler(f func(msg []byte, err error) []byte) {
 	b.handlerFunc = f
 }
 
+func (b *base) connection() *websocket.Conn {
+	b.connLock.Lock()
+	defer b.connLock.Unlock()
+	return b.conn
+}
+
+func (b *base) setConnection(conn *websocket.Conn) {
+	b.connLock.Lock()
+	b.conn = conn
+	b.connLock.Unlock()
+}
+
 func (b *base) Exchange(msg []byte) ([]byte, error) {
-	if b.conn == nil {
+	conn := b.connection()
+	if conn == nil {
 		return nil, zlog.NewError("no ws connection")
 	}
 	var outErr error
@@ -88,7 +103,7 @@ func (b *base) Exchange(msg []byte) ([]byte, error) {
 	ch := make(chan message, 1)
 	b.receiveChans.Set(num, ch)
 	defer b.receiveChans.Remove(num)
-	err := websocket.Message.Send(b.conn, send)
+	err := websocket.Message.Send(conn, send)
 	if err != nil {
 		outErr = err
 		return nil, outErr
@@ -108,7 +123,9 @@ func (b *base) Exchange(msg []byte) ([]byte, error) {
 }
 
 func (b *base) Close() {
-	b.shutdown = true
+	b.shutdown.Store(true)
+	b.connLock.Lock()
+	defer b.connLock.Unlock()
 	if b.conn != nil {
 		b.conn.Close()
 	}
@@ -117,8 +134,9 @@ func (b *base) Close() {
 
 func (b *base) readForever() {
 	first := true
-	for !b.shutdown {
-		if b.conn == nil {
+	for !b.shutdown.Load() {
+		conn := b.connection()
+		if conn == nil {
 			zlog.Info("opening ws client to", b.url)
 			if b.openFunc != nil {
 				b.openFunc()
@@ -127,9 +145,9 @@ func (b *base) readForever() {
 			continue
 		}
 		var msg []byte
-		err := websocket.Message.Receive(b.conn, &msg)
+		err := websocket.Message.Receive(conn, &msg)
 		if err != nil {
-			if b.shutdown {
+			if b.shutdown.Load() {
 				return
 			}
 			if err == io.EOF && first {
@@ -160,7 +178,7 @@ func (b *base) readForever() {
 		// otherwise, it's a new incoming message, handle and reply
 		result := b.handlerFunc(msg[8:], nil)
 		pre := zbytes.UInt64ToBytes(uint64(-n))
-		err = websocket.Message.Send(b.conn, append(pre, result...))
+		err = websocket.Message.Send(conn, append(pre, result...))
 		// zlog.Warn("post send-reply:", string(result), "num:", num)
 		zlog.OnError(err, "sending reply")
 		if err != nil {
@@ -188,10 +206,10 @@ func (c *Client) open() error {
 		time.Sleep(time.Second)
 		return err
 	}
-	c.conn = ws
+	c.setConnection(ws)
 	return nil
 }
 
 func (c *Client) IsConnected() bool {
-	return c.conn != nil
+	return c.connection() != nil
 }
@@ -65,19 +65,21 @@ func NewServerWithRouter(path string, router *mux.Router, handler func(id string
 
 func (s *Server) Close() {
 	for _, c := range s.Connections {
-		if c.conn != nil {
-			c.conn.Close()
+		if conn := c.connection(); conn != nil {
+			conn.Close()
 		}
 	}
 	s.Connections = []*ClientToServer{}
-	s.httpServer.Close()
+	if s.httpServer != nil { // nil for NewServerWithRouter
+		s.httpServer.Close()
+	}
 }
 
 func (s *Server) RemoveConnection(id string) {
 	for i, c := range s.Connections {
 		if c.ID == id {
-			if c.conn != nil {
-				c.conn.Close()
+			if conn := c.connection(); conn != nil {
+				conn.Close()
 			}
 			zslices.RemoveAt(&s.Connections, i)
 			return
@@ -76,12 +76,17 @@ func PluralizeEnglishWord(word string) string {
 }
 
 // PluralizeWord returns word if count == 1 or plural if != "".
-// Otherwise it uses langauge-specific rules to pluralize.
+// If TSPlural has a translation for word in langCode, the plural form for count is returned.
+// Otherwise it uses langauge-specific rules to pluralize, or returns word if there are none for langCode.
 // langCode == "" uses DefaultLanguage
 func PluralizeWord(word string, count float64, langCode, plural string) string {
 	if langCode == "" {
 		langCode = DefaultLanguage
 	}
+	translated, got := TSPlural(word, plural, count, langCode)
+	if got {
+		return translated
+	}
 	var str string
 	if int64(count) != 1 {
 		if plural != "" {
@@ -92,6 +97,8 @@ func PluralizeWord(word string, count float64, langCode, plural string) string {
 				str += PluralizeEnglishWord(word)
 			case "no", "da", "sv":
 				str += word + "er"
+			default:
+				str += word
 			}
 		}
 	} else {
@@ -0,0 +1,148 @@
+package zwords
+
+import "fmt"
+
+// TSPlural translates a word with plural forms to langCode, choosing the form for count.
+// It returns false if there is no translation, and PluralizeWord falls back to its own rules.
+// zlocale sets it to look up its catalog.
+var TSPlural = func(word, plural string, count float64, langCode string) (string, bool) {
+	return "", false
+}
+
+// PluralFormsCount returns how many plural forms langCode has, as in gettext's nplurals.
+func PluralFormsCount(langCode string) int {
+	switch pluralRuleForLanguage(langCode) {
+	case pluralRuleNone:
+		return 1
+	case pluralRuleSlavic, pluralRulePolish, pluralRuleCzech, pluralRuleRomanian:
+		return 3
+	case pluralRuleArabic:
+		return 6
+	}
+	return 2
+}
+
+// PluralFormIndex returns which of langCode's plural forms to use for count, 0 being singular.
+// The rules are the ones used in gettext's Plural-Forms headers, with count truncated to an integer.
+func PluralFormIndex(langCode string, count float64) int {
+	n := int64(count)
+	if n < 0 {
+		n = -n
+	}
+	n10 := n % 10
+	n100 := n % 100
+	switch pluralRuleForLanguage(langCode) {
+	case pluralRuleNone:
+		return 0
+	case pluralRuleZeroOne:
+		if n <= 1 {
+			return 0
+		}
+		return 1
+	case pluralRuleSlavic:
+		if n10 == 1 && n100 != 11 {
+			return 0
+		}
+		if n10 >= 2 && n10 <= 4 && (n100 < 10 || n100 >= 20) {
+			return 1
+		}
+		return 2
+	case pluralRulePolish:
+		if n == 1 {
+			return 0
+		}
+		if n10 >= 2 && n10 <= 4 && (n100 < 10 || n100 >= 20) {
+			return 1
+		}
+		return 2
+	case pluralRuleCzech:
+		if n == 1 {
+			return 0
+		}
+		if n >= 2 && n <= 4 {
+			return 1
+		}
+		return 2
+	case pluralRuleRomanian:
+		if n == 1 {
+			return 0
+		}
+		if n == 0 || (n100 > 0 && n100 < 20) {
+			return 1
+		}
+		return 2
+	case pluralRuleArabic:
+		switch {
+		case n <= 2:
+			return int(n)
+		case n100 >= 3 && n100 <= 10:
+			return 3
+		case n100 >= 11:
+			return 4
+		}
+		return 5
+	}
+	if n == 1 {
+		return 0
+	}
+	return 1
+}
+
+// PluralFormsHeader returns the Plural-Forms header value for langCode in a gettext .po file.
+func PluralFormsHeader(langCode string) string {
+	return fmt.Sprintf("nplurals=%d; plural=%s;", PluralFormsCount(langCode), pluralExpressions[pluralRuleForLanguage(langCode)])
+}
+
+type pluralRule int
+
+const (
+	pluralRuleOne      pluralRule = iota // singular for 1, plural otherwise: en, no, de etc
+	pluralRuleNone                       // no plural forms: ja, zh etc
+	pluralRuleZeroOne                    // singular for 0 and 1: fr, pt-BR
+	pluralRuleSlavic                     // ru, hr etc
+	pluralRulePolish                     // pl
+	pluralRuleCzech                      // cs, sk
+	pluralRuleRomanian                   // ro
+	pluralRuleArabic                     // ar
+)
+
+var pluralExpressions = map[pluralRule]string{
+	pluralRuleOne:      "(n != 1)",
+	pluralRuleNone:     "0",
+	pluralRuleZeroOne:  "(n > 1)",
+	pluralRuleSlavic:   "(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2)",
+	pluralRulePolish:   "(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2)",
+	pluralRuleCzech:    "(n==1 ? 0 : (n>=2 && n<=4) ? 1 : 2)",
+	pluralRuleRomanian: "(n==1 ? 0 : (n==0 || (n%100>0 && n%100<20)) ? 1 : 2)",
+	pluralRuleArabic:   "(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5)",
+}
+
+func pluralRuleForLanguage(langCode string) pluralRule {
+	if langCode == "" {
+		langCode = DefaultLanguage
+	}
+	switch langCode {
+	case "pt-BR", "pt_BR":
+		return pluralRuleZeroOne
+	}
+	if len(langCode) > 2 && (langCode[2] == '-' || langCode[2] == '_') {
+		langCode = langCode[:2]
+	}
+	switch langCode {
+	case "ja", "zh", "ko", "vi", "th", "id", "ms":
+		return pluralRuleNone
+	case "fr":
+		return pluralRuleZeroOne
+	case "ru", "be", "sr", "hr", "bs": // not uk, which PluralizeWord uses for UK English
+		return pluralRuleSlavic
+	case "pl":
+		return pluralRulePolish
+	case "cs", "sk":
+		return pluralRuleCzech
+	case "ro":
+		return pluralRuleRomanian
+	case "ar":
+		return pluralRuleArabic
+	}
+	return pluralRuleOne
+}